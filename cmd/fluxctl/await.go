@@ -8,13 +8,14 @@ import (
 	"time"
 
 	"github.com/fluxcd/flux/pkg/api"
+	v14 "github.com/fluxcd/flux/pkg/api/v14"
 	"github.com/fluxcd/flux/pkg/job"
 	"github.com/fluxcd/flux/pkg/update"
 )
 
 var ErrTimeout = errors.New("timeout")
 
-// await polls for a job to complete, then for the resulting commit to
+// await waits for a job to complete, then for the resulting commit to
 // be applied
 func await(ctx context.Context, stdout, stderr io.Writer, client api.Server, jobID job.ID, apply bool, verbosity int, timeout time.Duration) error {
 	result, err := awaitJob(ctx, client, jobID, timeout)
@@ -35,6 +36,11 @@ is safe to retry operations.`)
 	if result.Revision != "" {
 		fmt.Fprintf(stderr, "Commit pushed:\t%s\n", result.Revision[:7])
 	}
+	if result.PullRequest != "" {
+		// The changes won't be applied until the pull request is
+		// merged, so there's no waiting for that
+		fmt.Fprintf(stderr, "Pull request:\t%s\n", result.PullRequest)
+	}
 	if result.Result == nil {
 		fmt.Fprintf(stderr, "Nothing to do\n")
 		return nil
@@ -60,10 +66,34 @@ to run a sync interactively.`)
 	return nil
 }
 
-// await polls for a job to have been completed, with exponential backoff.
+// streamBackstop is how often to check anyway, while waiting for an
+// update from the daemon's stream, in case an update went missing.
+const streamBackstop = 10 * time.Second
+
+// watchUpdates subscribes to the daemon's stream of updates. If the
+// daemon can't stream updates (e.g., because it's an older version),
+// it returns nil, and the caller will have to poll.
+func watchUpdates(ctx context.Context, client api.Server) <-chan v14.Update {
+	updates, err := client.Watch(ctx, v14.WatchOptions{})
+	if err != nil {
+		return nil
+	}
+	return updates
+}
+
+// await polls for a job to have been completed, with exponential
+// backoff, or where the daemon streams updates, checks whenever the
+// job's status changes.
 func awaitJob(ctx context.Context, client api.Server, jobID job.ID, timeout time.Duration) (job.Result, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	updates := watchUpdates(ctx, client)
+	jobUpdate := func(u v14.Update) bool {
+		return u.Job != nil && u.Job.ID == jobID
+	}
+
 	var result job.Result
-	err := backoff(100*time.Millisecond, 2, 50, timeout, func() (bool, error) {
+	err := backoff(100*time.Millisecond, 2, 50, timeout, updates, jobUpdate, func() (bool, error) {
 		j, err := client.JobStatus(ctx, jobID)
 		if err != nil {
 			return false, err
@@ -85,16 +115,29 @@ func awaitJob(ctx context.Context, client api.Server, jobID job.ID, timeout time
 	return result, err
 }
 
-// await polls for a commit to have been applied, with exponential backoff.
+// await polls for a commit to have been applied, with exponential
+// backoff, or where the daemon streams updates, checks whenever a
+// sync finishes.
 func awaitSync(ctx context.Context, client api.Server, revision string, timeout time.Duration) error {
-	return backoff(1*time.Second, 2, 10, timeout, func() (bool, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	updates := watchUpdates(ctx, client)
+	syncFinished := func(u v14.Update) bool {
+		// Only syncs of the main git repo can apply the commit
+		return u.Type == v14.UpdateSyncFinished && u.Sync != nil && u.Sync.Source == ""
+	}
+
+	return backoff(1*time.Second, 2, 10, timeout, updates, syncFinished, func() (bool, error) {
 		refs, err := client.SyncStatus(ctx, revision)
 		return err == nil && len(refs) == 0, err
 	})
 }
 
-// backoff polls for f() to have been completed, with exponential backoff.
-func backoff(initialDelay, factor, maxFactor, timeout time.Duration, f func() (bool, error)) error {
+// backoff polls for f() to have been completed, with exponential
+// backoff. If there's a stream of updates, it checks f() whenever a
+// relevant update arrives instead (and every so often regardless);
+// should the stream end, it goes back to polling.
+func backoff(initialDelay, factor, maxFactor, timeout time.Duration, updates <-chan v14.Update, relevant func(v14.Update) bool, f func() (bool, error)) error {
 	maxDelay := initialDelay * maxFactor
 	finish := time.Now().Add(timeout)
 	for delay := initialDelay; time.Now().Before(finish); delay = min(delay*factor, maxDelay) {
@@ -102,15 +145,39 @@ func backoff(initialDelay, factor, maxFactor, timeout time.Duration, f func() (b
 		if ok || err != nil {
 			return err
 		}
-		// If we don't have time to try again, stop
-		if time.Now().Add(delay).After(finish) {
+		wait := delay
+		if updates != nil {
+			wait = min(streamBackstop, time.Until(finish))
+		} else if time.Now().Add(delay).After(finish) {
+			// If we don't have time to try again, stop
 			break
 		}
-		time.Sleep(delay)
+		updates = awaitUpdate(updates, relevant, wait)
 	}
 	return ErrTimeout
 }
 
+// awaitUpdate waits until a relevant update arrives, or for the
+// duration given, whichever is sooner. It returns the stream of
+// updates, or nil if the stream has ended.
+func awaitUpdate(updates <-chan v14.Update, relevant func(v14.Update) bool, wait time.Duration) <-chan v14.Update {
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+			return updates
+		case u, ok := <-updates:
+			if !ok {
+				return nil
+			}
+			if relevant(u) {
+				return updates
+			}
+		}
+	}
+}
+
 func min(t1, t2 time.Duration) time.Duration {
 	if t1 < t2 {
 		return t1
@@ -14,7 +14,9 @@ import (
 
 	"github.com/fluxcd/flux/pkg/registry"
 
+	v13 "github.com/fluxcd/flux/pkg/api/v13"
 	v6 "github.com/fluxcd/flux/pkg/api/v6"
+	"github.com/fluxcd/flux/pkg/event"
 
 	"github.com/spf13/cobra"
 )
@@ -91,7 +93,7 @@ func outputImagesTab(images []v6.ImageStatus, opts *imageListOpts) {
 	out := newTabwriter()
 
 	if !opts.noHeaders {
-		fmt.Fprintln(out, "WORKLOAD\tCONTAINER\tIMAGE\tCREATED")
+		fmt.Fprintln(out, "WORKLOAD\tCONTAINER\tIMAGE\tCREATED\tPLATFORMS")
 	}
 
 	for _, image := range images {
@@ -143,7 +145,7 @@ func outputImagesTab(images []v6.ImageStatus, opts *imageListOpts) {
 					if !available.CreatedAt.IsZero() {
 						createdAt = available.CreatedAt.Format(time.RFC822)
 					}
-					fmt.Fprintf(out, "\t\t%s %s\t%s\n", running, tag, createdAt)
+					fmt.Fprintf(out, "\t\t%s %s\t%s\t%s\n", running, tag, createdAt, platforms(available))
 				}
 			}
 			if !foundRunning {
@@ -176,13 +178,55 @@ func outputWorkloadsTab(workloads []v6.ControllerStatus, opts *workloadListOpts)
 	for _, workload := range workloads {
 		if len(workload.Containers) > 0 {
 			c := workload.Containers[0]
-			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", workload.ID, c.Name, c.Current.ID, workload.Status, policies(workload))
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", workload.ID, c.Name, c.Current.ID, releaseStatus(workload), policies(workload))
 			for _, c := range workload.Containers[1:] {
 				fmt.Fprintf(w, "\t%s\t%s\t\t\n", c.Name, c.Current.ID)
 			}
 		} else {
-			fmt.Fprintf(w, "%s\t\t\t%s\t%s\n", workload.ID, workload.Status, policies(workload))
+			fmt.Fprintf(w, "%s\t\t\t%s\t%s\n", workload.ID, releaseStatus(workload), policies(workload))
 		}
 	}
 	w.Flush()
 }
+
+// outputResourcesJson sends the provided resource statuses to the io.Writer as JSON
+func outputResourcesJson(resources []v13.ResourceStatus, out io.Writer) error {
+	encoder := json.NewEncoder(out)
+	return encoder.Encode(resources)
+}
+
+// outputResourcesTab sends the provided resource statuses to the io.Writer, formatted with tabs for CLI
+func outputResourcesTab(resources []v13.ResourceStatus, out io.Writer, opts *resourceListOpts) {
+	w := tabwriter.NewWriter(out, 0, 2, 2, ' ', 0)
+	if !opts.noHeaders {
+		fmt.Fprintf(w, "RESOURCE\tSYNC\tHEALTH\tMESSAGE\n")
+	}
+
+	for _, r := range resources {
+		sync, message := "ok", r.Health.Message
+		if r.SyncError != "" {
+			sync, message = "error", r.SyncError
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.ID, sync, r.Health.Status, message)
+	}
+	w.Flush()
+}
+
+// outputHistoryJson sends the provided events to the io.Writer as JSON
+func outputHistoryJson(events []event.Event, out io.Writer) error {
+	encoder := json.NewEncoder(out)
+	return encoder.Encode(events)
+}
+
+// outputHistoryTab sends the provided events to the io.Writer, formatted with tabs for CLI
+func outputHistoryTab(events []event.Event, out io.Writer, opts *historyOpts) {
+	w := tabwriter.NewWriter(out, 0, 2, 2, ' ', 0)
+	if !opts.noHeaders {
+		fmt.Fprintf(w, "TIME\tTYPE\tUSER\tREVISION\tDESCRIPTION\n")
+	}
+
+	for _, e := range events {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%.7s\t%s\n", e.StartedAt.Format(time.RFC3339), e.Type, eventUser(e), eventRevision(e), e.String())
+	}
+	w.Flush()
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	v15 "github.com/fluxcd/flux/pkg/api/v15"
+	"github.com/fluxcd/flux/pkg/event"
+	"github.com/fluxcd/flux/pkg/resource"
+)
+
+type historyOpts struct {
+	*rootOpts
+	namespace    string
+	workloads    []string
+	limit        int
+	noHeaders    bool
+	outputFormat string
+}
+
+func newHistory(parent *rootOpts) *historyOpts {
+	return &historyOpts{rootOpts: parent}
+}
+
+func (opts *historyOpts) Command() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "history",
+		Short: "Show the history of syncs, releases and policy changes, most recent first.",
+		Example: makeExample(
+			"fluxctl history",
+			"fluxctl history --workload=default:deployment/helloworld --limit=10",
+		),
+		RunE: opts.RunE,
+	}
+	cmd.Flags().StringVarP(&opts.namespace, "namespace", "n", "", "Workload namespace")
+	cmd.Flags().StringSliceVarP(&opts.workloads, "workload", "w", []string{}, "Show only the history of these workloads <namespace>:<kind>/<name>")
+	cmd.Flags().IntVarP(&opts.limit, "limit", "l", 20, "Number of events to show; zero means as many as the daemon will give")
+	cmd.Flags().BoolVar(&opts.noHeaders, "no-headers", false, "Don't print headers (default print headers)")
+	cmd.Flags().StringVarP(&opts.outputFormat, "output-format", "o", "tab", "Output format (tab or json)")
+	return cmd
+}
+
+func (opts *historyOpts) RunE(cmd *cobra.Command, args []string) error {
+	if len(args) != 0 {
+		return errorWantedNoArgs
+	}
+	if !outputFormatIsValid(opts.outputFormat) {
+		return errorInvalidOutputFormat
+	}
+
+	query := v15.HistoryOptions{Limit: opts.limit}
+	ns := getKubeConfigContextNamespaceOrDefault(opts.namespace, "default", opts.Context)
+	for _, workload := range opts.workloads {
+		id, err := resource.ParseIDOptionalNamespace(ns, workload)
+		if err != nil {
+			return err
+		}
+		query.Workloads = append(query.Workloads, id)
+	}
+
+	ctx := context.Background()
+	events, err := opts.API.History(ctx, query)
+	if err != nil {
+		return err
+	}
+
+	switch opts.outputFormat {
+	case outputFormatJson:
+		return outputHistoryJson(events, os.Stdout)
+	default:
+		outputHistoryTab(events, os.Stdout, opts)
+	}
+	return nil
+}
+
+// eventUser says who caused an event, where that's known.
+func eventUser(e event.Event) string {
+	switch m := e.Metadata.(type) {
+	case *event.CommitEventMetadata:
+		if m.Spec != nil {
+			return m.Spec.Cause.User
+		}
+	case *event.ReleaseEventMetadata:
+		return m.Cause.User
+	case *event.AutoReleaseEventMetadata, *event.RollbackEventMetadata:
+		return "(automated)"
+	}
+	return ""
+}
+
+// eventRevision gives the commit an event is about, where there is
+// one. For a sync of several commits, that's the most recent.
+func eventRevision(e event.Event) string {
+	switch m := e.Metadata.(type) {
+	case *event.CommitEventMetadata:
+		return m.Revision
+	case *event.ReleaseEventMetadata:
+		return m.Revision
+	case *event.AutoReleaseEventMetadata:
+		return m.Revision
+	case *event.RollbackEventMetadata:
+		return m.Revision
+	case *event.SyncEventMetadata:
+		if len(m.Commits) > 0 {
+			return m.Commits[0].Revision
+		}
+	}
+	return ""
+}
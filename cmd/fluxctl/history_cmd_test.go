@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/fluxcd/flux/pkg/event"
+	"github.com/fluxcd/flux/pkg/resource"
+	"github.com/fluxcd/flux/pkg/update"
+)
+
+func TestOutputHistoryTab(t *testing.T) {
+	app := resource.MustParseID("default:deployment/app")
+	synced := time.Date(2019, 10, 1, 12, 5, 0, 0, time.UTC)
+	committed := time.Date(2019, 10, 1, 12, 0, 0, 0, time.UTC)
+	events := []event.Event{
+		{
+			ServiceIDs: []resource.ID{app},
+			Type:       event.EventSync,
+			StartedAt:  synced,
+			Metadata: &event.SyncEventMetadata{
+				Commits: []event.Commit{{Revision: "fedcba9876543210"}, {Revision: "0123456789abcdef"}},
+			},
+		},
+		{
+			ServiceIDs: []resource.ID{app},
+			Type:       event.EventCommit,
+			StartedAt:  committed,
+			Metadata: &event.CommitEventMetadata{
+				Revision: "0123456789abcdef",
+				Spec:     &update.Spec{Type: update.Images, Cause: update.Cause{User: "alice"}},
+			},
+		},
+	}
+
+	buf := &bytes.Buffer{}
+	outputHistoryTab(events, buf, &historyOpts{})
+	assert.Equal(t, `TIME                  TYPE    USER   REVISION  DESCRIPTION
+2019-10-01T12:05:00Z  sync           fedcba9   Sync: fedcba9, default:deployment/app
+2019-10-01T12:00:00Z  commit  alice  0123456   Commit: 0123456, default:deployment/app
+`, buf.String())
+}
@@ -4,11 +4,13 @@ import (
 	"context"
 	"os"
 	"sort"
+	"strings"
 
 	"github.com/spf13/cobra"
 
 	v10 "github.com/fluxcd/flux/pkg/api/v10"
 	v6 "github.com/fluxcd/flux/pkg/api/v6"
+	"github.com/fluxcd/flux/pkg/image"
 	"github.com/fluxcd/flux/pkg/resource"
 	"github.com/fluxcd/flux/pkg/update"
 )
@@ -111,3 +113,12 @@ func (s imageStatusByName) Less(a, b int) bool {
 func (s imageStatusByName) Swap(a, b int) {
 	s[a], s[b] = s[b], s[a]
 }
+
+// platforms gives the platforms an image is built for, if known.
+func platforms(info image.Info) string {
+	var ps []string
+	for _, p := range info.Platforms {
+		ps = append(ps, p.String())
+	}
+	return strings.Join(ps, ",")
+}
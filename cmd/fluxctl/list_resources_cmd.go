@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	v13 "github.com/fluxcd/flux/pkg/api/v13"
+	"github.com/fluxcd/flux/pkg/cluster"
+)
+
+type resourceListOpts struct {
+	*rootOpts
+	namespace     string
+	allNamespaces bool
+	unhealthy     bool
+	noHeaders     bool
+	outputFormat  string
+}
+
+func newResourceList(parent *rootOpts) *resourceListOpts {
+	return &resourceListOpts{rootOpts: parent}
+}
+
+func (opts *resourceListOpts) Command() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list-resources",
+		Short: "List the resources synced to the cluster, with their sync status and health.",
+		Example: makeExample(
+			"fluxctl list-resources",
+			"fluxctl list-resources --all-namespaces --unhealthy",
+		),
+		RunE: opts.RunE,
+	}
+	cmd.Flags().StringVarP(&opts.namespace, "namespace", "n", "", "Confine query to namespace")
+	cmd.Flags().BoolVarP(&opts.allNamespaces, "all-namespaces", "a", false, "Query across all namespaces")
+	cmd.Flags().BoolVar(&opts.unhealthy, "unhealthy", false, "Only list resources that failed to sync or are not healthy")
+	cmd.Flags().BoolVar(&opts.noHeaders, "no-headers", false, "Don't print headers (default print headers)")
+	cmd.Flags().StringVarP(&opts.outputFormat, "output-format", "o", "tab", "Output format (tab or json)")
+	return cmd
+}
+
+func (opts *resourceListOpts) RunE(cmd *cobra.Command, args []string) error {
+	if len(args) != 0 {
+		return errorWantedNoArgs
+	}
+
+	if !outputFormatIsValid(opts.outputFormat) {
+		return errorInvalidOutputFormat
+	}
+
+	var ns string
+	if opts.allNamespaces {
+		ns = ""
+	} else {
+		ns = getKubeConfigContextNamespaceOrDefault(opts.namespace, "default", opts.Context)
+	}
+
+	ctx := context.Background()
+
+	resources, err := opts.API.ListResources(ctx, v13.ListResourcesOptions{Namespace: ns})
+	if err != nil {
+		return err
+	}
+
+	if opts.unhealthy {
+		resources = filterUnhealthy(resources)
+	}
+
+	switch opts.outputFormat {
+	case outputFormatJson:
+		return outputResourcesJson(resources, os.Stdout)
+	default:
+		outputResourcesTab(resources, os.Stdout, opts)
+	}
+
+	return nil
+}
+
+// Extract the resources that failed to sync, or are not healthy
+func filterUnhealthy(resources []v13.ResourceStatus) (filtered []v13.ResourceStatus) {
+	for _, r := range resources {
+		if r.SyncError != "" || r.Health.Status != cluster.HealthHealthy {
+			filtered = append(filtered, r)
+		}
+	}
+	return
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	v13 "github.com/fluxcd/flux/pkg/api/v13"
+	"github.com/fluxcd/flux/pkg/cluster"
+	"github.com/fluxcd/flux/pkg/resource"
+)
+
+var testResources = []v13.ResourceStatus{
+	{ID: resource.MustParseID("default:configmap/config"), Health: cluster.Health{Status: cluster.HealthHealthy}},
+	{ID: resource.MustParseID("default:deployment/broken"), Health: cluster.Health{Status: cluster.HealthUnknown, Message: "not found in cluster"}, SyncError: "invalid manifest"},
+	{ID: resource.MustParseID("default:job/migrate"), Health: cluster.Health{Status: cluster.HealthDegraded, Message: "backoff limit reached"}},
+}
+
+func TestOutputResourcesTab(t *testing.T) {
+	buf := &bytes.Buffer{}
+	outputResourcesTab(testResources, buf, &resourceListOpts{})
+	assert.Equal(t, `RESOURCE                   SYNC   HEALTH    MESSAGE
+default:configmap/config   ok     healthy   
+default:deployment/broken  error  unknown   invalid manifest
+default:job/migrate        ok     degraded  backoff limit reached
+`, buf.String())
+}
+
+func TestFilterUnhealthy(t *testing.T) {
+	filtered := filterUnhealthy(testResources)
+	assert.Len(t, filtered, 2)
+	assert.Equal(t, "default:deployment/broken", filtered[0].ID.String())
+	assert.Equal(t, "default:job/migrate", filtered[1].ID.String())
+}
@@ -2,9 +2,11 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 
@@ -94,6 +96,19 @@ func (s workloadStatusByName) Swap(a, b int) {
 	s[a], s[b] = s[b], s[a]
 }
 
+// releaseStatus gives the status of a workload's release, noting any drift
+// found by the last sync.
+func releaseStatus(s v6.ControllerStatus) string {
+	switch {
+	case s.Drift == nil:
+		return s.Status
+	case s.Drift.Corrected:
+		return s.Status + " (drift corrected)"
+	default:
+		return s.Status + " (drifted)"
+	}
+}
+
 func policies(s v6.ControllerStatus) string {
 	var ps []string
 	if s.Automated {
@@ -106,7 +121,15 @@ func policies(s v6.ControllerStatus) string {
 		ps = append(ps, string(policy.Ignore))
 	}
 	sort.Strings(ps)
-	return strings.Join(ps, ",")
+	p := strings.Join(ps, ",")
+	if s.Frozen != nil {
+		if s.Frozen.Until.IsZero() {
+			p += " (frozen)"
+		} else {
+			p += fmt.Sprintf(" (frozen until %s)", s.Frozen.Until.Format(time.RFC3339))
+		}
+	}
+	return p
 }
 
 // Extract workloads having its container name equal to containerName
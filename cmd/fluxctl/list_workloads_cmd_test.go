@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
@@ -28,6 +29,28 @@ func Test_outputWorkloadsJson(t *testing.T) {
 	})
 }
 
+func Test_policies(t *testing.T) {
+	s := v6.ControllerStatus{Automated: true, Locked: true}
+	require.Equal(t, "automated,locked", policies(s))
+
+	s.Frozen = &v6.Freeze{Until: time.Date(2020, 12, 18, 17, 0, 0, 0, time.UTC), Reason: "release freeze"}
+	require.Equal(t, "automated,locked (frozen until 2020-12-18T17:00:00Z)", policies(s))
+
+	s.Frozen.Until = time.Time{}
+	require.Equal(t, "automated,locked (frozen)", policies(s))
+}
+
+func Test_releaseStatus(t *testing.T) {
+	s := v6.ControllerStatus{Status: "ready"}
+	require.Equal(t, "ready", releaseStatus(s))
+
+	s.Drift = &cluster.ResourceDrift{Corrected: true}
+	require.Equal(t, "ready (drift corrected)", releaseStatus(s))
+
+	s.Drift.Corrected = false
+	require.Equal(t, "ready (drifted)", releaseStatus(s))
+}
+
 func testWorkloads(workloadCount int) []v6.ControllerStatus {
 	workloads := []v6.ControllerStatus{}
 	for i := 0; i < workloadCount; i++ {
@@ -1,7 +1,10 @@
 package main
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/url"
 	"os"
@@ -19,13 +22,17 @@ import (
 )
 
 type rootOpts struct {
-	Context   string
-	URL       string
-	Token     string
-	Namespace string
-	Labels    map[string]string
-	API       api.Server
-	Timeout   time.Duration
+	Context       string
+	URL           string
+	Token         string
+	AuthToken     string
+	TLSClientCert string
+	TLSClientKey  string
+	TLSCACert     string
+	Namespace     string
+	Labels        map[string]string
+	API           api.Server
+	Timeout       time.Duration
 }
 
 func newRoot() *rootOpts {
@@ -59,6 +66,7 @@ const (
 	envVariableLabels     = "FLUX_FORWARD_LABELS"
 	envVariableToken      = "FLUX_SERVICE_TOKEN"
 	envVariableCloudToken = "WEAVE_CLOUD_TOKEN"
+	envVariableAuthToken  = "FLUX_AUTH_TOKEN"
 	envVariableTimeout    = "FLUX_TIMEOUT"
 )
 
@@ -81,12 +89,23 @@ func (opts *rootOpts) Command() *cobra.Command {
 		fmt.Sprintf("Base URL of the Flux API (defaults to %q if a token is provided); you can also set the environment variable %s", defaultURLGivenToken, envVariableURL))
 	cmd.PersistentFlags().StringVarP(&opts.Token, "token", "t", "",
 		fmt.Sprintf("Weave Cloud authentication token; you can also set the environment variable %s or %s", envVariableCloudToken, envVariableToken))
+	cmd.PersistentFlags().StringVar(&opts.AuthToken, "auth-token", "",
+		fmt.Sprintf("Bearer token for authenticating with fluxd, if it requires authentication; you can also set the environment variable %s", envVariableAuthToken))
+	cmd.PersistentFlags().StringVar(&opts.TLSClientCert, "tls-client-cert", "",
+		"Path to a PEM-encoded client certificate, for authenticating with fluxd when it serves the API over HTTPS")
+	cmd.PersistentFlags().StringVar(&opts.TLSClientKey, "tls-client-key", "",
+		"Path to the PEM-encoded private key for --tls-client-cert")
+	cmd.PersistentFlags().StringVar(&opts.TLSCACert, "tls-ca-cert", "",
+		"Path to PEM-encoded CA certificate(s) for verifying fluxd's certificate, when it serves the API over HTTPS; if given, the port forward is connected to with HTTPS")
 	cmd.PersistentFlags().DurationVar(&opts.Timeout, "timeout", 60*time.Second,
 		fmt.Sprintf("Global command timeout; you can also set the environment variable %s", envVariableTimeout))
 	cmd.AddCommand(
 		newVersionCommand(),
 		newImageList(opts).Command(),
 		newWorkloadList(opts).Command(),
+		newResourceList(opts).Command(),
+		newWatch(opts).Command(),
+		newHistory(opts).Command(),
 		newWorkloadRelease(opts).Command(),
 		newWorkloadAutomate(opts).Command(),
 		newWorkloadDeautomate(opts).Command(),
@@ -115,6 +134,7 @@ func (opts *rootOpts) PersistentPreRunE(cmd *cobra.Command, _ []string) error {
 	setFromEnvIfNotSet(cmd.Flags(), "k8s-fwd-ns", envVariableNamespace)
 	setFromEnvIfNotSet(cmd.Flags(), "k8s-fwd-labels", envVariableLabels)
 	setFromEnvIfNotSet(cmd.Flags(), "token", envVariableToken, envVariableCloudToken)
+	setFromEnvIfNotSet(cmd.Flags(), "auth-token", envVariableAuthToken)
 	setFromEnvIfNotSet(cmd.Flags(), "url", envVariableURL)
 	setFromEnvIfNotSet(cmd.Flags(), "timeout", envVariableTimeout)
 
@@ -138,17 +158,73 @@ func (opts *rootOpts) PersistentPreRunE(cmd *cobra.Command, _ []string) error {
 			return err
 		}
 
-		opts.URL = fmt.Sprintf("http://127.0.0.1:%d/api/flux", portforwarder.ListenPort)
+		scheme := "http"
+		if opts.TLSCACert != "" {
+			scheme = "https"
+		}
+		opts.URL = fmt.Sprintf("%s://127.0.0.1:%d/api/flux", scheme, portforwarder.ListenPort)
 	}
 
 	if _, err := url.Parse(opts.URL); err != nil {
 		return errors.Wrapf(err, "parsing URL")
 	}
 
-	opts.API = client.New(http.DefaultClient, transport.NewAPIRouter(), opts.URL, client.Token(opts.Token))
+	httpClient, err := opts.httpClient()
+	if err != nil {
+		return err
+	}
+	opts.API = client.New(httpClient, transport.NewAPIRouter(), opts.URL, client.Token(opts.Token))
 	return nil
 }
 
+// httpClient makes the HTTP client for talking to the API, which
+// presents any credentials given for authenticating with fluxd.
+func (opts *rootOpts) httpClient() (*http.Client, error) {
+	if opts.AuthToken == "" && opts.TLSClientCert == "" && opts.TLSCACert == "" {
+		return http.DefaultClient, nil
+	}
+	tx := http.DefaultTransport.(*http.Transport).Clone()
+	if opts.TLSClientCert != "" || opts.TLSCACert != "" {
+		tlsConfig := &tls.Config{}
+		if opts.TLSClientCert != "" {
+			cert, err := tls.LoadX509KeyPair(opts.TLSClientCert, opts.TLSClientKey)
+			if err != nil {
+				return nil, errors.Wrap(err, "loading client certificate")
+			}
+			tlsConfig.Certificates = []tls.Certificate{cert}
+		}
+		if opts.TLSCACert != "" {
+			caCerts, err := ioutil.ReadFile(opts.TLSCACert)
+			if err != nil {
+				return nil, err
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(caCerts) {
+				return nil, fmt.Errorf("no PEM-encoded certificates found in %s", opts.TLSCACert)
+			}
+			tlsConfig.RootCAs = pool
+		}
+		tx.TLSClientConfig = tlsConfig
+	}
+	var rt http.RoundTripper = tx
+	if opts.AuthToken != "" {
+		rt = bearerToken{token: opts.AuthToken, next: tx}
+	}
+	return &http.Client{Transport: rt}, nil
+}
+
+// bearerToken adds an `Authorization: Bearer` header to requests.
+type bearerToken struct {
+	token string
+	next  http.RoundTripper
+}
+
+func (b bearerToken) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+b.token)
+	return b.next.RoundTrip(req)
+}
+
 func setFromEnvIfNotSet(flags *pflag.FlagSet, flagName string, envNames ...string) {
 	if flags.Changed(flagName) {
 		return
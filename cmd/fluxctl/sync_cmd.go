@@ -2,17 +2,25 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"strings"
 
 	"github.com/spf13/cobra"
 
+	v12 "github.com/fluxcd/flux/pkg/api/v12"
+	"github.com/fluxcd/flux/pkg/cluster"
 	"github.com/fluxcd/flux/pkg/git"
 	"github.com/fluxcd/flux/pkg/update"
 )
 
 type syncOpts struct {
 	*rootOpts
+	dryRun       bool
+	ref          string
+	source       string
+	outputFormat string
 }
 
 func newSync(parent *rootOpts) *syncOpts {
@@ -23,8 +31,17 @@ func (opts *syncOpts) Command() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "sync",
 		Short: "synchronize the cluster with the git repository, now",
-		RunE:  opts.RunE,
+		Example: makeExample(
+			"fluxctl sync",
+			"fluxctl sync --dry-run",
+			"fluxctl sync --dry-run --ref=my-feature-branch",
+		),
+		RunE: opts.RunE,
 	}
+	cmd.Flags().BoolVar(&opts.dryRun, "dry-run", false, "Show what a sync would change in the cluster, without applying anything")
+	cmd.Flags().StringVar(&opts.ref, "ref", "", "With --dry-run, the git ref (branch, tag or commit) to plan a sync of (default the head of the branch)")
+	cmd.Flags().StringVar(&opts.source, "source", "", "With --dry-run, the name of the additional git source to plan a sync of (default the main git repo)")
+	cmd.Flags().StringVarP(&opts.outputFormat, "output-format", "o", "tab", "With --dry-run, output format (tab or json)")
 	return cmd
 }
 
@@ -32,6 +49,9 @@ func (opts *syncOpts) RunE(cmd *cobra.Command, args []string) error {
 	if len(args) > 0 {
 		return errorWantedNoArgs
 	}
+	if !opts.dryRun && (opts.ref != "" || opts.source != "") {
+		return newUsageError("--ref and --source can only be used with --dry-run")
+	}
 
 	ctx := context.Background()
 
@@ -54,6 +74,9 @@ func (opts *syncOpts) RunE(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("git repository %s is not ready to sync", gitConfig.Remote.URL)
 	}
 
+	if opts.dryRun {
+		return opts.planSync(ctx, cmd)
+	}
 	fmt.Fprintf(cmd.OutOrStderr(), "Synchronizing with %s\n", gitConfig.Remote.URL)
 
 	updateSpec := update.Spec{
@@ -83,6 +106,76 @@ func (opts *syncOpts) RunE(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+func (opts *syncOpts) planSync(ctx context.Context, cmd *cobra.Command) error {
+	if !outputFormatIsValid(opts.outputFormat) {
+		return errorInvalidOutputFormat
+	}
+	plan, err := opts.API.SyncPlan(ctx, v12.SyncPlanOptions{Ref: opts.ref, Source: opts.source})
+	if err != nil {
+		return err
+	}
+	switch opts.outputFormat {
+	case outputFormatJson:
+		return json.NewEncoder(cmd.OutOrStdout()).Encode(plan)
+	default:
+		outputSyncPlan(plan, cmd.OutOrStdout())
+	}
+	return nil
+}
+
+var planActionSymbols = map[string]string{
+	cluster.PlanCreate: "+",
+	cluster.PlanUpdate: "~",
+	cluster.PlanDelete: "-",
+}
+
+// outputSyncPlan writes a sync plan in a form for people to read:
+// each resource that would change, with the fields that would change,
+// followed by a summary.
+func outputSyncPlan(plan v12.SyncPlan, out io.Writer) {
+	rev := plan.Revision
+	if len(rev) > 7 {
+		rev = rev[:7]
+	}
+	fmt.Fprintf(out, "Sync plan for revision %s:\n", rev)
+
+	counts := map[string]int{}
+	for _, res := range plan.Resources {
+		counts[res.Action]++
+		symbol, ok := planActionSymbols[res.Action]
+		if !ok {
+			continue
+		}
+		if res.Source != "" {
+			fmt.Fprintf(out, "%s %s (%s)\n", symbol, res.ResourceID, res.Source)
+		} else {
+			fmt.Fprintf(out, "%s %s\n", symbol, res.ResourceID)
+		}
+		for _, change := range res.Changes {
+			switch {
+			case change.Redacted:
+				fmt.Fprintf(out, "    ~ %s: (changed)\n", change.Path)
+			case change.Old == nil:
+				fmt.Fprintf(out, "    + %s: %s\n", change.Path, formatPlanValue(change.New))
+			case change.New == nil:
+				fmt.Fprintf(out, "    - %s: %s\n", change.Path, formatPlanValue(change.Old))
+			default:
+				fmt.Fprintf(out, "    ~ %s: %s => %s\n", change.Path, formatPlanValue(change.Old), formatPlanValue(change.New))
+			}
+		}
+	}
+	fmt.Fprintf(out, "%d to create, %d to update, %d to delete, %d unchanged.\n",
+		counts[cluster.PlanCreate], counts[cluster.PlanUpdate], counts[cluster.PlanDelete], counts[cluster.PlanUnchanged])
+}
+
+func formatPlanValue(v interface{}) string {
+	bytes, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(bytes)
+}
+
 func isUnverifiedHead(err error) bool {
 	return err != nil &&
 		(strings.Contains(err.Error(), "branch HEAD in the git repo is not verified") &&
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+
+	v12 "github.com/fluxcd/flux/pkg/api/v12"
+	v6 "github.com/fluxcd/flux/pkg/api/v6"
+	"github.com/fluxcd/flux/pkg/cluster"
+	"github.com/fluxcd/flux/pkg/git"
+	transport "github.com/fluxcd/flux/pkg/http"
+	"github.com/fluxcd/flux/pkg/resource"
+)
+
+var testSyncPlan = v12.SyncPlan{
+	Revision: "0123456789abcdef",
+	Resources: []cluster.ResourcePlan{
+		{ResourceID: resource.MustParseID("default:deployment/new"), Source: "new.yaml", Action: cluster.PlanCreate},
+		{ResourceID: resource.MustParseID("default:deployment/old"), Source: "<cluster>", Action: cluster.PlanDelete},
+		{ResourceID: resource.MustParseID("default:deployment/same"), Source: "same.yaml", Action: cluster.PlanUnchanged},
+		{ResourceID: resource.MustParseID("default:deployment/web"), Source: "web.yaml", Action: cluster.PlanUpdate, Changes: []cluster.FieldChange{
+			{Path: "metadata.labels.tier", Old: "front"},
+			{Path: "spec.replicas", Old: 1, New: 3},
+			{Path: "spec.paused", New: true},
+		}},
+		{ResourceID: resource.MustParseID("default:secret/creds"), Source: "creds.yaml", Action: cluster.PlanUpdate, Changes: []cluster.FieldChange{
+			{Path: "data.password", Redacted: true},
+		}},
+	},
+}
+
+func TestOutputSyncPlan(t *testing.T) {
+	buf := &bytes.Buffer{}
+	outputSyncPlan(testSyncPlan, buf)
+	assert.Equal(t, `Sync plan for revision 0123456:
++ default:deployment/new (new.yaml)
+- default:deployment/old (<cluster>)
+~ default:deployment/web (web.yaml)
+    - metadata.labels.tier: "front"
+    ~ spec.replicas: 1 => 3
+    + spec.paused: true
+~ default:secret/creds (creds.yaml)
+    ~ data.password: (changed)
+1 to create, 2 to update, 1 to delete, 1 unchanged.
+`, buf.String())
+}
+
+func TestSyncCommand_DryRun(t *testing.T) {
+	svc := &genericMockRoundTripper{
+		mockResponses: map[*mux.Route]interface{}{
+			transport.NewAPIRouter().Get(transport.GitRepoConfig): v6.GitConfig{Status: git.RepoReady},
+			transport.NewAPIRouter().Get(transport.SyncPlan):      testSyncPlan,
+		},
+		requestHistory: make(map[string]*http.Request),
+	}
+	cmd := newSync(mockServiceOpts(svc)).Command()
+	cmd.SetOutput(ioutil.Discard)
+	cmd.SetArgs([]string{"--dry-run", "--ref=feature"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if svc.calledRequest(transport.SyncPlan) == nil {
+		t.Fatal("expected fluxctl to request a sync plan")
+	}
+	assert.Equal(t, "feature", svc.calledURL(transport.SyncPlan).Query().Get("ref"))
+	if svc.calledRequest(transport.UpdateManifests) != nil {
+		t.Error("expected fluxctl not to request a sync")
+	}
+}
+
+func TestSyncCommand_RefWithoutDryRun(t *testing.T) {
+	svc := newMockService()
+	cmd := newSync(mockServiceOpts(svc)).Command()
+	cmd.SetOutput(ioutil.Discard)
+	cmd.SetArgs([]string{"--ref=feature"})
+	if err := cmd.Execute(); err == nil {
+		t.Error("expected an error using --ref without --dry-run")
+	}
+}
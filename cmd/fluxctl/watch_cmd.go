@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+
+	v14 "github.com/fluxcd/flux/pkg/api/v14"
+	"github.com/fluxcd/flux/pkg/resource"
+)
+
+type watchOpts struct {
+	*rootOpts
+	namespace    string
+	workloads    []string
+	outputFormat string
+}
+
+func newWatch(parent *rootOpts) *watchOpts {
+	return &watchOpts{rootOpts: parent}
+}
+
+func (opts *watchOpts) Command() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "watch",
+		Short: "Watch jobs, syncs and events as they happen.",
+		Example: makeExample(
+			"fluxctl watch",
+			"fluxctl watch --workload=default:deployment/helloworld",
+			"fluxctl watch -o json",
+		),
+		RunE: opts.RunE,
+	}
+	cmd.Flags().StringVarP(&opts.namespace, "namespace", "n", "", "Workload namespace")
+	cmd.Flags().StringSliceVarP(&opts.workloads, "workload", "w", []string{}, "Only show updates concerning these workloads <namespace>:<kind>/<name>")
+	cmd.Flags().StringVarP(&opts.outputFormat, "output-format", "o", "tab", "Output format (tab or json)")
+	return cmd
+}
+
+var errWatchEnded = errors.New("the daemon stopped sending updates")
+
+func (opts *watchOpts) RunE(cmd *cobra.Command, args []string) error {
+	if len(args) != 0 {
+		return errorWantedNoArgs
+	}
+	if !outputFormatIsValid(opts.outputFormat) {
+		return errorInvalidOutputFormat
+	}
+
+	var watch v14.WatchOptions
+	ns := getKubeConfigContextNamespaceOrDefault(opts.namespace, "default", opts.Context)
+	for _, workload := range opts.workloads {
+		id, err := resource.ParseIDOptionalNamespace(ns, workload)
+		if err != nil {
+			return err
+		}
+		watch.Workloads = append(watch.Workloads, id)
+	}
+
+	// This runs until interrupted, so there's no timeout
+	updates, err := opts.API.Watch(context.Background(), watch)
+	if err != nil {
+		return err
+	}
+	out := cmd.OutOrStdout()
+	for u := range updates {
+		switch opts.outputFormat {
+		case outputFormatJson:
+			if err := json.NewEncoder(out).Encode(u); err != nil {
+				return err
+			}
+		default:
+			outputUpdate(u, out)
+		}
+	}
+	return errWatchEnded
+}
+
+// outputUpdate prints a line describing an update.
+func outputUpdate(u v14.Update, out io.Writer) {
+	switch {
+	case u.Job != nil:
+		status := u.Job.Status
+		switch {
+		case status.Err != "":
+			fmt.Fprintf(out, "job %s %s: %s\n", u.Job.ID, status.StatusString, status.Err)
+		case status.Result.Revision != "":
+			fmt.Fprintf(out, "job %s %s, commit %.7s\n", u.Job.ID, status.StatusString, status.Result.Revision)
+		case status.Result.PullRequest != "":
+			fmt.Fprintf(out, "job %s %s, pull request %s\n", u.Job.ID, status.StatusString, status.Result.PullRequest)
+		default:
+			fmt.Fprintf(out, "job %s %s\n", u.Job.ID, status.StatusString)
+		}
+	case u.Sync != nil:
+		source := "git repo"
+		if u.Sync.Source != "" {
+			source = "source " + u.Sync.Source
+		}
+		switch {
+		case u.Type == v14.UpdateSyncStarted:
+			fmt.Fprintf(out, "sync of %s at %.7s started\n", source, u.Sync.Revision)
+		case u.Sync.Error != "":
+			fmt.Fprintf(out, "sync of %s at %.7s failed: %s\n", source, u.Sync.Revision, u.Sync.Error)
+		default:
+			fmt.Fprintf(out, "sync of %s at %.7s finished\n", source, u.Sync.Revision)
+		}
+	case u.Event != nil:
+		fmt.Fprintf(out, "event: %s\n", u.Event.String())
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	v14 "github.com/fluxcd/flux/pkg/api/v14"
+	"github.com/fluxcd/flux/pkg/job"
+)
+
+func TestOutputUpdate(t *testing.T) {
+	buf := &bytes.Buffer{}
+	for _, u := range []v14.Update{
+		{Type: v14.UpdateJob, Job: &v14.JobUpdate{ID: "job-1", Status: job.Status{StatusString: job.StatusRunning}}},
+		{Type: v14.UpdateJob, Job: &v14.JobUpdate{ID: "job-1", Status: job.Status{StatusString: job.StatusSucceeded, Result: job.Result{Revision: "0123456789abcdef"}}}},
+		{Type: v14.UpdateJob, Job: &v14.JobUpdate{ID: "job-2", Status: job.Status{StatusString: job.StatusFailed, Err: "no changes made"}}},
+		{Type: v14.UpdateJob, Job: &v14.JobUpdate{ID: "job-3", Status: job.Status{StatusString: job.StatusSucceeded, Result: job.Result{PullRequest: "https://github.com/org/repo/pull/1"}}}},
+		{Type: v14.UpdateSyncStarted, Sync: &v14.SyncUpdate{Revision: "0123456789abcdef"}},
+		{Type: v14.UpdateSyncFinished, Sync: &v14.SyncUpdate{Source: "platform", Revision: "fedcba9876543210", Error: "timed out"}},
+	} {
+		outputUpdate(u, buf)
+	}
+	assert.Equal(t, `job job-1 running
+job job-1 succeeded, commit 0123456
+job job-2 failed: no changes made
+job job-3 succeeded, pull request https://github.com/org/repo/pull/1
+sync of git repo at 0123456 started
+sync of source platform at fedcba9 failed: timed out
+`, buf.String())
+}
+
+func TestAwaitUpdate(t *testing.T) {
+	isJob := func(u v14.Update) bool { return u.Job != nil }
+
+	updates := make(chan v14.Update, 2)
+	updates <- v14.Update{Type: v14.UpdateSyncStarted, Sync: &v14.SyncUpdate{}}
+	updates <- v14.Update{Type: v14.UpdateJob, Job: &v14.JobUpdate{ID: "job"}}
+	start := time.Now()
+	assert.NotNil(t, awaitUpdate(updates, isJob, time.Minute), "the stream is still open")
+	assert.True(t, time.Since(start) < time.Minute, "a relevant update ends the wait")
+
+	// Without a relevant update, the wait is the time given
+	assert.NotNil(t, awaitUpdate(updates, isJob, 10*time.Millisecond))
+
+	// Once the stream ends, it's not used any more
+	close(updates)
+	assert.Nil(t, awaitUpdate(updates, isJob, time.Minute))
+}
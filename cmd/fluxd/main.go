@@ -1,6 +1,9 @@
 package main
 
 import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
 	"flag"
 	"fmt"
 	"io/ioutil"
@@ -36,22 +39,32 @@ import (
 	"github.com/fluxcd/flux/pkg/checkpoint"
 	"github.com/fluxcd/flux/pkg/cluster"
 	"github.com/fluxcd/flux/pkg/cluster/kubernetes"
+	"github.com/fluxcd/flux/pkg/commitstatus"
 	"github.com/fluxcd/flux/pkg/daemon"
 	"github.com/fluxcd/flux/pkg/git"
+	"github.com/fluxcd/flux/pkg/githost"
 	"github.com/fluxcd/flux/pkg/gpg"
+	"github.com/fluxcd/flux/pkg/history"
 	transport "github.com/fluxcd/flux/pkg/http"
+	"github.com/fluxcd/flux/pkg/http/auth"
 	"github.com/fluxcd/flux/pkg/http/client"
 	daemonhttp "github.com/fluxcd/flux/pkg/http/daemon"
+	"github.com/fluxcd/flux/pkg/http/webhook"
 	"github.com/fluxcd/flux/pkg/image"
 	"github.com/fluxcd/flux/pkg/job"
 	"github.com/fluxcd/flux/pkg/manifests"
+	"github.com/fluxcd/flux/pkg/notify"
+	"github.com/fluxcd/flux/pkg/pullrequest"
 	"github.com/fluxcd/flux/pkg/registry"
 	"github.com/fluxcd/flux/pkg/registry/cache"
 	registryMemcache "github.com/fluxcd/flux/pkg/registry/cache/memcached"
+	registryMemory "github.com/fluxcd/flux/pkg/registry/cache/memory"
 	registryMiddleware "github.com/fluxcd/flux/pkg/registry/middleware"
 	"github.com/fluxcd/flux/pkg/remote"
+	"github.com/fluxcd/flux/pkg/schedule"
 	"github.com/fluxcd/flux/pkg/ssh"
 	fluxsync "github.com/fluxcd/flux/pkg/sync"
+	"github.com/fluxcd/flux/pkg/update"
 )
 
 var version = "unversioned"
@@ -73,6 +86,10 @@ const (
 
 	RequireECR = "ecr"
 
+	// Where image metadata is cached
+	registryCacheMemcached = "memcached"
+	registryCacheMemory    = "memory"
+
 	k8sInClusterSecretsBaseDir = "/var/run/secrets/kubernetes.io"
 )
 
@@ -113,10 +130,21 @@ func main() {
 		listenMetricsAddr = fs.String("listen-metrics", "", "listen address for /metrics endpoint")
 		kubernetesKubectl = fs.String("kubernetes-kubectl", "", "optional, explicit path to kubectl tool")
 		versionFlag       = fs.Bool("version", false, "get version number")
+		listenTLSCert     = fs.String("listen-tls-cert", "", "path to a PEM-encoded certificate; if given (with --listen-tls-key), the API is served over HTTPS")
+		listenTLSKey      = fs.String("listen-tls-key", "", "path to the PEM-encoded private key for --listen-tls-cert")
+		// API authentication and authorization
+		apiAuthTokenFile   = fs.String("api-auth-token-file", "", "path to a CSV file of bearer tokens (token,user,uid,\"group1,group2\") for authenticating API requests")
+		apiAuthClientCA    = fs.String("api-auth-client-ca", "", "path to PEM-encoded CA certificate(s) for authenticating API requests with TLS client certificates; requires --listen-tls-cert")
+		apiAuthTokenReview = fs.Bool("api-auth-token-review", false, "authenticate API requests bearing tokens by asking Kubernetes to review the token")
+		apiAuthzPolicy     = fs.String("api-authz-policy", "", "path to a YAML file giving which users and groups may use which API routes, in which namespaces; if authentication is enabled and this is not given, authenticated users may do anything")
+		// webhooks
+		webhookSecretFile          = fs.String("webhook-secret-file", "", "path to a file containing the secret that webhooks (from image registries and git hosts) must carry; webhooks are received at /hooks/ only if this, or --webhook-receiver-secret-file, is given")
+		webhookReceiverSecretFiles = fs.StringSlice("webhook-receiver-secret-file", nil, "path to a file containing the secret that webhooks to a particular receiver must carry, instead of that given with --webhook-secret-file, given as <receiver>=<path> (e.g., registry/harbor=/etc/fluxd/harbor-secret); repeat for more than one receiver")
 		// Git repo & key etc.
 		gitURL       = fs.String("git-url", "", "URL of git repo with Kubernetes manifests; e.g., git@github.com:fluxcd/flux-get-started")
 		gitBranch    = fs.String("git-branch", "master", "branch of git repo to use for Kubernetes manifests")
 		gitPath      = fs.StringSlice("git-path", []string{}, "relative paths within the git repo to locate Kubernetes manifests")
+		gitSource    = fs.StringArray("git-source", nil, "additional git repo to sync to the cluster, given as name=<name>,url=<url>[,branch=<branch>][,path=<path>]... (branch defaults to --git-branch); may be given more than once. Additional repos are synced only, and are never committed to")
 		gitReadonly  = fs.Bool("git-readonly", false, fmt.Sprintf("use to prevent Flux from pushing changes to git; implies --sync-state=%s", fluxsync.NativeStateMode))
 		gitUser      = fs.String("git-user", "Weave Flux", "username to use as git committer")
 		gitEmail     = fs.String("git-email", "support@weave.works", "email to use as git committer")
@@ -140,13 +168,22 @@ func main() {
 		gitVerifySignaturesModeStr = fs.String("git-verify-signatures-mode", fluxsync.VerifySignaturesModeDefault, fmt.Sprintf("if git-verify-signatures is set, which strategy to use for signature verification (one of %s)", strings.Join([]string{fluxsync.VerifySignaturesModeNone, fluxsync.VerifySignaturesModeAll, fluxsync.VerifySignaturesModeFirstParent}, ",")))
 
 		// syncing
-		syncInterval = fs.Duration("sync-interval", 5*time.Minute, "apply config in git to cluster at least this often, even if there are no new commits")
-		syncTimeout  = fs.Duration("sync-timeout", 1*time.Minute, "duration after which sync operations time out")
-		syncGC       = fs.Bool("sync-garbage-collection", false, "delete resources that were created by fluxd, but are no longer in the git repo")
-		dryGC        = fs.Bool("sync-garbage-collection-dry", false, "only log what would be garbage collected, rather than deleting. Implies --sync-garbage-collection")
-		syncState    = fs.String("sync-state", fluxsync.GitTagStateMode, fmt.Sprintf("method used by flux for storing state (one of {%s})", strings.Join([]string{fluxsync.GitTagStateMode, fluxsync.NativeStateMode}, ",")))
+		syncInterval   = fs.Duration("sync-interval", 5*time.Minute, "apply config in git to cluster at least this often, even if there are no new commits")
+		syncTimeout    = fs.Duration("sync-timeout", 1*time.Minute, "duration after which sync operations time out")
+		syncGC         = fs.Bool("sync-garbage-collection", false, "delete resources that were created by fluxd, but are no longer in the git repo")
+		dryGC          = fs.Bool("sync-garbage-collection-dry", false, "only log what would be garbage collected, rather than deleting. Implies --sync-garbage-collection")
+		gcMaxDeletions = fs.Int("sync-garbage-collection-max-deletions", 0, "the most resources garbage collection may delete in one sync; if more would be deleted, none are. Zero means no limit")
+		gcKindDenylist = fs.StringSlice("sync-garbage-collection-kind-denylist", nil, "kinds of resource (e.g., Namespace, PersistentVolumeClaim) that garbage collection never deletes")
+		waveTimeout    = fs.Duration("sync-wave-timeout", 5*time.Minute, "how long the resources in each sync wave are given to become healthy before later waves are applied")
+		driftDetect    = fs.Bool("sync-drift-detection", false, "when syncing, look for changes made in the cluster to resources since they were applied, and report them as drift events")
+		syncState      = fs.String("sync-state", fluxsync.GitTagStateMode, fmt.Sprintf("method used by flux for storing state (one of {%s})", strings.Join([]string{fluxsync.GitTagStateMode, fluxsync.NativeStateMode}, ",")))
 
 		// registry
+		registryCache                 = fs.String("registry-cache", registryCacheMemcached, fmt.Sprintf("where to cache image metadata (one of {%s}); %q keeps it in fluxd's memory, so no memcached is needed", strings.Join([]string{registryCacheMemcached, registryCacheMemory}, ","), registryCacheMemory))
+		registryCacheMemoryMaxMB      = fs.Int("registry-cache-memory-max-mb", 128, "the most image metadata, in MiB, to keep in memory when --registry-cache=memory; zero means no limit")
+		registryCacheSnapshotPath     = fs.String("registry-cache-snapshot-path", "", "when --registry-cache=memory, save the cache to this file (e.g., on a persistent volume) and load it again on start; if empty, the cache is not saved")
+		registryCacheSnapshotInterval = fs.Duration("registry-cache-snapshot-interval", registryMemory.DefaultSnapshotInterval, "how often to save the cache to --registry-cache-snapshot-path")
+
 		memcachedHostname = fs.String("memcached-hostname", "memcached", "hostname for memcached service.")
 		memcachedPort     = fs.Int("memcached-port", 11211, "memcached service port.")
 		memcachedTimeout  = fs.Duration("memcached-timeout", time.Second, "maximum time to wait before giving up on memcached requests.")
@@ -154,14 +191,17 @@ func main() {
 
 		registryDisableScanning = fs.Bool("registry-disable-scanning", false, "do not scan container image registries to fill in the registry cache")
 		automationInterval      = fs.Duration("automation-interval", 5*time.Minute, "period at which to check for image updates for automated workloads")
+		rollbackDeadline        = fs.Duration("automation-rollback-deadline", 10*time.Minute, "how long the rollout of an automated release may take, for workloads with the rollback-on-failure policy, before it is rolled back")
 		registryPollInterval    = fs.Duration("registry-poll-interval", 5*time.Minute, "period at which to check for updated images")
 		registryRPS             = fs.Float64("registry-rps", 50, "maximum registry requests per second per host")
 		registryBurst           = fs.Int("registry-burst", defaultRemoteConnections, "maximum number of warmer connections to remote and memcache")
 		registryTrace           = fs.Bool("registry-trace", false, "output trace of image registry requests to log")
 		registryInsecure        = fs.StringSlice("registry-insecure-host", []string{}, "let these registry hosts skip TLS host verification and fall back to using HTTP instead of HTTPS; this allows man-in-the-middle attacks, so use with extreme caution")
+		registryMirrors         = fs.StringSlice("registry-mirror", nil, "ask this mirror for image metadata before the registry it mirrors, given as <registry>=<mirror> (e.g., docker.io=mirror.example.com:5000); repeat for more than one mirror")
 		registryExcludeImage    = fs.StringSlice("registry-exclude-image", []string{"k8s.gcr.io/*"}, "do not scan images that match these glob expressions; the default is to exclude the 'k8s.gcr.io/*' images")
 		registryIncludeImage    = fs.StringSlice("registry-include-image", nil, "if a value or values is given, scan _only_ images matching the glob pattern(s) (less any explicitly excluded)")
 		registryUseLabels       = fs.StringSlice("registry-use-labels", []string{"index.docker.io/weaveworks/*", "index.docker.io/fluxcd/*"}, "use the timestamp (RFC3339) from labels for (canonical) image refs that match these glob expression")
+		registryVerifyKeys      = fs.StringSlice("registry-verify-key", nil, "path(s) to PEM-encoded public key(s); if given, images are only released automatically if they have a cosign signature that can be verified with one of the keys")
 
 		// AWS authentication
 		registryAWSRegions         = fs.StringSlice("registry-ecr-region", nil, "include just these AWS regions when scanning images in ECR; when not supplied, the cluster's region will included if it can be detected through the AWS API")
@@ -182,6 +222,8 @@ func main() {
 		k8sDefaultNamespace   = fs.String("k8s-default-namespace", "", "the namespace to use for resources where a namespace is not specified")
 		k8sExcludeResource    = fs.StringSlice("k8s-unsafe-exclude-resource", []string{"*metrics.k8s.io/*", "webhook.certmanager.k8s.io/*", "v1/Event"}, "do not attempt to obtain cluster resources whose group/version/kind matches these glob expressions. Potentially unsafe, please read its documentation first")
 		k8sVerbosity          = fs.Int("k8s-verbosity", 0, "klog verbosity level")
+		k8sApplier            = fs.String("k8s-applier", "kubectl", "how to apply manifests to the cluster; either 'kubectl', to pipe them to kubectl apply, or 'server-side', to use server-side apply without needing kubectl")
+		k8sForceConflicts     = fs.Bool("k8s-apply-force-conflicts", false, "with --k8s-applier=server-side, take over fields owned by other field managers rather than reporting conflicts")
 
 		// SSH key generation
 		sshKeyBits   = optionalVar(fs, &ssh.KeyBitsValue{}, "ssh-keygen-bits", "-b argument to ssh-keygen (default unspecified)")
@@ -189,14 +231,38 @@ func main() {
 		sshKeygenDir = fs.String("ssh-keygen-dir", "", "directory, ideally on a tmpfs volume, in which to generate new SSH keys when necessary")
 
 		// manifest generation
-		manifestGeneration = fs.Bool("manifest-generation", false, "search for .flux.yaml files to generate manifests")
+		manifestGeneration          = fs.Bool("manifest-generation", false, "search for .flux.yaml files to generate manifests")
+		manifestGenerationKustomize = fs.Bool("manifest-generation-kustomize", false, "with --manifest-generation, build the kustomization in a target path that has no .flux.yaml, rather than looking for YAML files there")
 
 		// upstream connection settings
 		upstreamURL = fs.String("connect", "", "connect to an upstream service e.g., Weave Cloud, at this base address")
 		token       = fs.String("token", "", "authentication token for upstream service")
 		rpcTimeout  = fs.Duration("rpc-timeout", 10*time.Second, "maximum time an operation requested by the upstream may take")
 
-		dockerConfig = fs.String("docker-config", "", "path to a docker config to use for image registry credentials")
+		dockerConfig                 = fs.String("docker-config", "", "path to a docker config to use for image registry credentials")
+		dockerCredentialHelperExpiry = fs.Duration("docker-credential-helper-expiry", registry.DefaultCredentialHelperExpiry, "how long to keep credentials from the credential helpers (credHelpers and credsStore) named in --docker-config, before running the helper again")
+
+		// notifications
+		notificationConfig = fs.String("notification-config", "", "path to a file configuring sinks (webhook, Slack, MS Teams) to send daemon events to")
+
+		// event history
+		eventHistorySize      = fs.Int("event-history-size", 1000, "how many of the most recent events to keep a record of, for fluxctl history; zero disables the event history")
+		eventHistoryConfigMap = fs.String("event-history-configmap", "flux-history", "name of the ConfigMap, in fluxd's namespace, in which to keep the event history; if empty, or fluxd is not running in a cluster, the history is kept in memory only")
+
+		// commit statuses
+		commitStatusProvider  = fs.String("commit-status-provider", "", fmt.Sprintf("if set, report the outcome of each sync as a status on the commit synced, at this kind of git host (one of %s)", strings.Join(githost.Kinds, ",")))
+		commitStatusAPIURL    = fs.String("commit-status-api-url", "", "base URL of the git host's API, for commit statuses; defaults to that of github.com, gitlab.com or bitbucket.org, and must be given for Gitea")
+		commitStatusTokenFile = fs.String("commit-status-token-file", "", "path to a file containing the access token with which to set commit statuses")
+		commitStatusContext   = fs.String("commit-status-context", "flux", "name given to commit statuses, to distinguish them from others (e.g., from CI); for additional git sources, it's suffixed with the name of the source")
+
+		// pull requests
+		pullRequestProvider     = fs.String("pull-request-provider", "", fmt.Sprintf("if set, propose releases and policy changes in pull requests, at this kind of git host (one of %s), rather than pushing them to --git-branch", strings.Join(githost.Kinds, ",")))
+		pullRequestAPIURL       = fs.String("pull-request-api-url", "", "base URL of the git host's API, for pull requests; defaults to that of github.com, gitlab.com or bitbucket.org, and must be given for Gitea")
+		pullRequestTokenFile    = fs.String("pull-request-token-file", "", "path to a file containing the access token with which to open pull requests")
+		pullRequestBranchPrefix = fs.String("pull-request-branch-prefix", "flux/", "prefix for the names of the branches pushed for pull requests")
+
+		// maintenance windows and release freezes
+		scheduleConfig = fs.String("schedule-config", "", "path to a file giving maintenance windows and release freezes for automated releases (and, optionally, syncs); it is read again whenever it changes")
 
 		_ = fs.Duration("registry-cache-expiry", 0, "")
 	)
@@ -302,6 +368,13 @@ func main() {
 		if len(changedGitRelatedFlags) > 0 {
 			logger.Log("warning", fmt.Sprintf("configuring any of {%s} has no effect when --git-readonly is set", strings.Join(changedGitRelatedFlags, ", ")))
 		}
+
+		// Pull requests need branches to be pushed, so rather than
+		// quietly committing nothing, refuse to start
+		if *pullRequestProvider != "" {
+			logger.Log("err", "--pull-request-provider cannot be used with --git-readonly, since pull requests are made by pushing branches")
+			os.Exit(1)
+		}
 	}
 
 	// Maintain backwards compatibility with the --registry-poll-interval
@@ -348,11 +421,22 @@ func main() {
 		}
 	}
 
+	gitSourceSpecs, err := parseGitSources(*gitSource, *gitBranch)
+	if err != nil {
+		logger.Log("err", err)
+		os.Exit(1)
+	}
+
 	// Used to determine if we need to generate a SSH key and setup a keyring
 	var httpGitURL bool
 	if pURL, err := url.Parse(*gitURL); err == nil {
 		httpGitURL = pURL.Scheme == "http" || pURL.Scheme == "https"
 	}
+	for _, spec := range gitSourceSpecs {
+		if pURL, err := url.Parse(spec.URL); err != nil || (pURL.Scheme != "http" && pURL.Scheme != "https") {
+			httpGitURL = false
+		}
+	}
 
 	if *sshKeygenDir == "" && !httpGitURL {
 		logger.Log("info", fmt.Sprintf("SSH keygen dir (--ssh-keygen-dir) not provided, so using the deploy key volume (--k8s-secret-volume-mount-path=%s); this may cause problems if the deploy key volume is mounted read-only", *k8sSecretVolumeMountPath))
@@ -430,12 +514,23 @@ func main() {
 	var k8s cluster.Cluster
 	var k8sManifests manifests.Manifests
 	var imageCreds func() registry.ImageCreds
+	var tokenReviewer auth.TokenReviewer
+	var historyBackend history.Backend
 	{
 		clientset, err := k8sclient.NewForConfig(restClientConfig)
 		if err != nil {
 			logger.Log("err", err)
 			os.Exit(1)
 		}
+		tokenReviewer = clientset.AuthenticationV1().TokenReviews()
+		if *eventHistorySize > 0 && *eventHistoryConfigMap != "" {
+			if namespace, err := ioutil.ReadFile(filepath.Join(k8sInClusterSecretsBaseDir, "serviceaccount/namespace")); err == nil {
+				historyBackend = &history.ConfigMap{
+					API:  clientset.CoreV1().ConfigMaps(string(namespace)),
+					Name: *eventHistoryConfigMap,
+				}
+			}
+		}
 		dynamicClientset, err := k8sclientdynamic.NewForConfig(restClientConfig)
 		if err != nil {
 			logger.Log("err", err)
@@ -502,29 +597,44 @@ func main() {
 
 		logger.Log("host", restClientConfig.Host, "version", clusterVersion)
 
-		kubectl := *kubernetesKubectl
-		if kubectl == "" {
-			kubectl, err = exec.LookPath("kubectl")
-		} else {
-			_, err = os.Stat(kubectl)
-		}
-		if err != nil {
-			logger.Log("err", err)
+		var applier kubernetes.Applier
+		switch *k8sApplier {
+		case "kubectl":
+			kubectl := *kubernetesKubectl
+			if kubectl == "" {
+				kubectl, err = exec.LookPath("kubectl")
+			} else {
+				_, err = os.Stat(kubectl)
+			}
+			if err != nil {
+				logger.Log("err", err)
+				os.Exit(1)
+			}
+			logger.Log("kubectl", kubectl)
+			applier = kubernetes.NewKubectl(kubectl, restClientConfig)
+		case "server-side":
+			logger.Log("applier", "server-side", "field-manager", kubernetes.FieldManager, "force-conflicts", *k8sForceConflicts)
+			applier = kubernetes.NewServerSideApplier(dynamicClientset, discoClientset, *k8sForceConflicts)
+		default:
+			logger.Log("err", fmt.Sprintf("--k8s-applier must be 'kubectl' or 'server-side', got %q", *k8sApplier))
 			os.Exit(1)
 		}
-		logger.Log("kubectl", kubectl)
 
 		client := kubernetes.MakeClusterClientset(clientset, dynamicClientset, fhrClientset, hrClientset, discoClientset)
-		kubectlApplier := kubernetes.NewKubectl(kubectl, restClientConfig)
 		allowedNamespaces := make(map[string]struct{})
 		for _, n := range append(*k8sNamespaceWhitelist, *k8sAllowNamespace...) {
 			allowedNamespaces[n] = struct{}{}
 		}
 
 		imageIncluder := cluster.ExcludeIncludeGlob{Exclude: *registryExcludeImage, Include: *registryIncludeImage}
-		k8sInst := kubernetes.NewCluster(client, kubectlApplier, sshKeyRing, logger, allowedNamespaces, imageIncluder, *k8sExcludeResource)
+		k8sInst := kubernetes.NewCluster(client, applier, sshKeyRing, logger, allowedNamespaces, imageIncluder, *k8sExcludeResource)
 		k8sInst.GC = *syncGC
 		k8sInst.DryGC = *dryGC
+		k8sInst.GCMaxDeletions = *gcMaxDeletions
+		k8sInst.GCKindDenylist = *gcKindDenylist
+		k8sInst.SyncWaveTimeout = *waveTimeout
+		k8sInst.SyncTimeout = *syncTimeout
+		k8sInst.DriftDetection = *driftDetect
 
 		if err := k8sInst.Ping(); err != nil {
 			logger.Log("ping", err)
@@ -566,7 +676,7 @@ func main() {
 		imageCreds = credsWithAWSAuth
 
 		if *dockerConfig != "" {
-			credsWithDefaults, err := registry.ImageCredsWithDefaults(imageCreds, *dockerConfig)
+			credsWithDefaults, err := registry.ImageCredsWithDefaults(imageCreds, log.With(logger, "component", "docker-config"), *dockerConfig, *dockerCredentialHelperExpiry)
 			if err != nil {
 				logger.Log("warning", "--docker-config not used; pre-flight check failed", "err", err)
 			} else {
@@ -577,30 +687,47 @@ func main() {
 
 	// Registry components
 	var imageRegistry registry.Registry = registry.ImageScanDisabledRegistry{}
+	var imageVerifier update.ImageVerifier
 	var cacheWarmer *cache.Warmer
 	if !*registryDisableScanning {
 		// Cache client, for use by registry and cache warmer
 		var cacheClient cache.Client
-		var memcacheClient *registryMemcache.MemcacheClient
-		memcacheConfig := registryMemcache.MemcacheConfig{
-			Host:           *memcachedHostname,
-			Service:        *memcachedService,
-			Timeout:        *memcachedTimeout,
-			UpdateInterval: 1 * time.Minute,
-			Logger:         log.With(logger, "component", "memcached"),
-			MaxIdleConns:   *registryBurst,
-		}
-
-		// if no memcached service is specified use the ClusterIP name instead of SRV records
-		if *memcachedService == "" {
-			memcacheClient = registryMemcache.NewFixedServerMemcacheClient(memcacheConfig,
-				fmt.Sprintf("%s:%d", *memcachedHostname, *memcachedPort))
-		} else {
-			memcacheClient = registryMemcache.NewMemcacheClient(memcacheConfig)
-		}
+		switch *registryCache {
+		case registryCacheMemcached:
+			var memcacheClient *registryMemcache.MemcacheClient
+			memcacheConfig := registryMemcache.MemcacheConfig{
+				Host:           *memcachedHostname,
+				Service:        *memcachedService,
+				Timeout:        *memcachedTimeout,
+				UpdateInterval: 1 * time.Minute,
+				Logger:         log.With(logger, "component", "memcached"),
+				MaxIdleConns:   *registryBurst,
+			}
+
+			// if no memcached service is specified use the ClusterIP name instead of SRV records
+			if *memcachedService == "" {
+				memcacheClient = registryMemcache.NewFixedServerMemcacheClient(memcacheConfig,
+					fmt.Sprintf("%s:%d", *memcachedHostname, *memcachedPort))
+			} else {
+				memcacheClient = registryMemcache.NewMemcacheClient(memcacheConfig)
+			}
 
-		defer memcacheClient.Stop()
-		cacheClient = cache.InstrumentClient(memcacheClient)
+			defer memcacheClient.Stop()
+			cacheClient = cache.InstrumentClient(memcacheClient)
+		case registryCacheMemory:
+			memoryCache := registryMemory.New(registryMemory.Config{
+				MaxSize:          *registryCacheMemoryMaxMB << 20,
+				SnapshotPath:     *registryCacheSnapshotPath,
+				SnapshotInterval: *registryCacheSnapshotInterval,
+				Logger:           log.With(logger, "component", "registry-cache"),
+			})
+			shutdownWg.Add(1)
+			go memoryCache.Loop(shutdown, shutdownWg)
+			cacheClient = cache.InstrumentClient(memoryCache)
+		default:
+			logger.Log("error", "unknown registry cache", "cache", *registryCache)
+			os.Exit(1)
+		}
 
 		imageRegistry = &cache.Cache{
 			Reader: cacheClient,
@@ -617,20 +744,42 @@ func main() {
 			Burst:  *registryBurst,
 			Logger: log.With(logger, "component", "ratelimiter"),
 		}
+		mirrors, err := registry.ParseMirrors(*registryMirrors)
+		if err != nil {
+			logger.Log("err", err)
+			os.Exit(1)
+		}
 		remoteFactory := &registry.RemoteClientFactory{
 			Logger:        registryLogger,
 			Limiters:      registryLimits,
 			Trace:         *registryTrace,
 			InsecureHosts: *registryInsecure,
+			Mirrors:       mirrors,
 		}
 
 		// Warmer
-		var err error
 		cacheWarmer, err = cache.NewWarmer(remoteFactory, cacheClient, *registryBurst)
 		if err != nil {
 			logger.Log("err", err)
 			os.Exit(1)
 		}
+
+		// Signature verification, for automated releases
+		if len(*registryVerifyKeys) > 0 {
+			keys, err := registry.LoadPublicKeys(*registryVerifyKeys)
+			if err != nil {
+				logger.Log("error", "failed to load --registry-verify-key public key(s)", "err", err.Error())
+				os.Exit(1)
+			}
+			imageVerifier = &registry.SignatureVerifier{
+				Keys:        keys,
+				Factory:     remoteFactory,
+				Credentials: imageCreds,
+			}
+			logger.Log("image-verification", "enabled", "keys", len(keys))
+		}
+	} else if len(*registryVerifyKeys) > 0 {
+		logger.Log("warning", "--registry-verify-key has no effect when image scanning is disabled, since there are no automated releases")
 	}
 
 	// Checkpoint: we want to include the fact of whether the daemon
@@ -678,6 +827,7 @@ func main() {
 		"state", *syncState,
 		"readonly", *gitReadonly,
 		"registry-disable-scanning", *registryDisableScanning,
+		"registry-cache", *registryCache,
 		"notes-ref", *gitNotesRef,
 		"set-author", *gitSetAuthor,
 		"git-secret", *gitSecret,
@@ -725,19 +875,127 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Additional git sources, each with its own sync state. Sources
+	// are never written to, so their sync state is kept in the
+	// cluster, whichever --sync-state is used for the main repo.
+	var sourceMirrors *git.Mirrors
+	var gitSources []daemon.GitSource
+	if len(gitSourceSpecs) > 0 {
+		sourceStates, ok := syncProvider.(fluxsync.NativeSyncProvider)
+		if !ok {
+			namespace, err := ioutil.ReadFile(filepath.Join(k8sInClusterSecretsBaseDir, "serviceaccount/namespace"))
+			if err == nil {
+				sourceStates, err = fluxsync.NewNativeSyncProvider(string(namespace), *k8sSecretName)
+			}
+			if err != nil {
+				logger.Log("err", fmt.Sprintf("keeping the sync state of git sources in the cluster: %s", err))
+				os.Exit(1)
+			}
+		}
+		sourceMirrors = git.NewMirrors()
+		for _, spec := range gitSourceSpecs {
+			sourceRemote := git.Remote{URL: spec.URL}
+			sourceConfig := gitConfig
+			sourceConfig.Branch = spec.Branch
+			sourceConfig.Paths = spec.Paths
+
+			sourceMirrors.Mirror(spec.Name, sourceRemote, git.PollInterval(*gitPollInterval), git.Timeout(*gitTimeout), git.Branch(spec.Branch), git.IsReadOnly(true))
+			sourceRepo, _ := sourceMirrors.Get(spec.Name)
+			sourceState := sourceStates.WithSource(spec.Name)
+
+			logger.Log("source", spec.Name, "url", sourceRemote.SafeURL(), "branch", spec.Branch, "paths", strings.Join(spec.Paths, ","), "state", sourceState.String())
+			gitSources = append(gitSources, daemon.GitSource{
+				Name:      spec.Name,
+				Repo:      sourceRepo,
+				GitConfig: sourceConfig,
+				SyncState: sourceState,
+			})
+		}
+		shutdownWg.Add(1)
+		go func() {
+			defer shutdownWg.Done()
+			<-shutdown
+			sourceMirrors.StopAllAndWait()
+		}()
+	}
+
+	var eventHistory *history.History
+	if *eventHistorySize > 0 {
+		eventHistory, err = history.New(*eventHistorySize, historyBackend)
+		if err != nil {
+			// Don't overwrite a history that can't be read; keep
+			// this run's history in memory instead
+			logger.Log("warning", "cannot load event history; keeping it in memory only", "err", err)
+			eventHistory, _ = history.New(*eventHistorySize, nil)
+			historyBackend = nil
+		}
+		if historyBackend != nil {
+			logger.Log("event-history", historyBackend, "size", *eventHistorySize)
+		} else {
+			logger.Log("event-history", "memory", "size", *eventHistorySize)
+		}
+		shutdownWg.Add(1)
+		go eventHistory.Loop(shutdown, shutdownWg, log.With(logger, "component", "event-history"))
+	}
+
+	// Propose changes in pull requests, if asked to, rather than
+	// pushing them to the branch synced
+	var pullRequests *daemon.PullRequestConfig
+	if *pullRequestProvider != "" {
+		token, err := ioutil.ReadFile(*pullRequestTokenFile)
+		if err != nil {
+			logger.Log("err", fmt.Sprintf("reading pull request token: %s", err))
+			os.Exit(1)
+		}
+		host, err := githost.NewClient(*pullRequestProvider, *pullRequestAPIURL, string(bytes.TrimSpace(token)), &http.Client{Timeout: 10 * time.Second})
+		if err != nil {
+			logger.Log("err", err)
+			os.Exit(1)
+		}
+		path, err := githost.RepoPath(repo.Origin().URL)
+		if err != nil {
+			logger.Log("err", err)
+			os.Exit(1)
+		}
+		pullRequests = &daemon.PullRequestConfig{
+			Provider:     pullrequest.NewProvider(host),
+			Repo:         path,
+			BranchPrefix: *pullRequestBranchPrefix,
+		}
+		logger.Log("pull-requests", *pullRequestProvider, "repo", path, "branch-prefix", *pullRequestBranchPrefix)
+	}
+
+	// Hold automated releases outside of maintenance windows, and
+	// during release freezes
+	var scheduleFile *schedule.File
+	if *scheduleConfig != "" {
+		if _, err := schedule.Load(*scheduleConfig); err != nil {
+			logger.Log("err", err)
+			os.Exit(1)
+		}
+		scheduleFile = &schedule.File{Path: *scheduleConfig, Logger: log.With(logger, "component", "schedule")}
+	}
+
 	daemon := &daemon.Daemon{
-		V:                         version,
-		Cluster:                   k8s,
-		Manifests:                 k8sManifests,
-		Registry:                  imageRegistry,
-		ImageRefresh:              make(chan image.Name, 100), // size chosen by fair dice roll
-		Repo:                      repo,
-		GitConfig:                 gitConfig,
-		Jobs:                      jobs,
-		JobStatusCache:            &job.StatusCache{Size: 100},
-		Logger:                    log.With(logger, "component", "daemon"),
-		ManifestGenerationEnabled: *manifestGeneration,
-		GitSecretEnabled:          *gitSecret,
+		V:                           version,
+		Cluster:                     k8s,
+		Manifests:                   k8sManifests,
+		Registry:                    imageRegistry,
+		ImageVerifier:               imageVerifier,
+		ImageRefresh:                make(chan image.Name, 100), // size chosen by fair dice roll
+		Repo:                        repo,
+		GitConfig:                   gitConfig,
+		PullRequests:                pullRequests,
+		Schedule:                    scheduleFile,
+		Sources:                     gitSources,
+		SourceMirrors:               sourceMirrors,
+		Jobs:                        jobs,
+		JobStatusCache:              &job.StatusCache{Size: 100},
+		EventHistory:                eventHistory,
+		Logger:                      log.With(logger, "component", "daemon"),
+		ManifestGenerationEnabled:   *manifestGeneration,
+		ManifestGenerationKustomize: *manifestGenerationKustomize,
+		GitSecretEnabled:            *gitSecret,
 		LoopVars: &daemon.LoopVars{
 			SyncInterval:            *syncInterval,
 			SyncTimeout:             *syncTimeout,
@@ -746,6 +1004,7 @@ func main() {
 			GitTimeout:              *gitTimeout,
 			GitVerifySignaturesMode: gitVerifySignaturesMode,
 			ImageScanDisabled:       *registryDisableScanning,
+			RollbackDeadline:        *rollbackDeadline,
 		},
 	}
 
@@ -778,6 +1037,69 @@ func main() {
 		}
 	}
 
+	// Send events to any notification sinks configured, as well as
+	// upstream
+	if *notificationConfig != "" {
+		notifyLogger := log.With(logger, "component", "notify")
+		config, err := notify.LoadConfig(*notificationConfig)
+		if err != nil {
+			logger.Log("err", err)
+			os.Exit(1)
+		}
+		notifier, err := notify.NewNotifier(config, &http.Client{Timeout: 10 * time.Second}, notifyLogger)
+		if err != nil {
+			logger.Log("err", err)
+			os.Exit(1)
+		}
+		for _, sink := range config.Sinks {
+			notifyLogger.Log("sink", sink.Name, "type", sink.Type, "event-types", strings.Join(sink.EventTypes, ","), "namespaces", strings.Join(sink.Namespaces, ","))
+		}
+		if daemon.EventWriter != nil {
+			daemon.EventWriter = notify.MultiEventWriter(daemon.EventWriter, notifier)
+		} else {
+			daemon.EventWriter = notifier
+		}
+		shutdownWg.Add(1)
+		go notifier.Loop(shutdown, shutdownWg)
+	}
+
+	// Report syncs back to the git host. This must be started before
+	// the daemon's loop, so as not to miss the first sync.
+	if *commitStatusProvider != "" {
+		token, err := ioutil.ReadFile(*commitStatusTokenFile)
+		if err != nil {
+			logger.Log("err", fmt.Sprintf("reading commit status token: %s", err))
+			os.Exit(1)
+		}
+		host, err := githost.NewClient(*commitStatusProvider, *commitStatusAPIURL, string(bytes.TrimSpace(token)), &http.Client{Timeout: 10 * time.Second})
+		if err != nil {
+			logger.Log("err", err)
+			os.Exit(1)
+		}
+		repos := map[string]string{}
+		remotes := map[string]string{"": repo.Origin().URL}
+		for _, src := range gitSources {
+			remotes[src.Name] = src.Repo.Origin().URL
+		}
+		for name, gitURL := range remotes {
+			path, err := githost.RepoPath(gitURL)
+			if err != nil {
+				logger.Log("err", err)
+				os.Exit(1)
+			}
+			repos[name] = path
+		}
+		reporter := &commitstatus.Reporter{
+			Provider: commitstatus.NewProvider(host),
+			Context:  *commitStatusContext,
+			Repos:    repos,
+			Logger:   log.With(logger, "component", "commit-status"),
+		}
+		logger.Log("commit-status", *commitStatusProvider, "repo", repos[""])
+		shutdownWg.Add(1)
+		go reporter.Loop(daemon, shutdown, shutdownWg)
+	}
+
 	shutdownWg.Add(1)
 	go daemon.Loop(shutdown, shutdownWg, log.With(logger, "component", "sync-loop"))
 
@@ -789,15 +1111,150 @@ func main() {
 		go cacheWarmer.Loop(log.With(logger, "component", "warmer"), shutdown, shutdownWg, imageCreds)
 	}
 
+	// API authentication and authorization
+	var apiAuthenticators auth.Authenticators
+	var apiAuthorizer auth.Authorizer
+	var apiTLSConfig *tls.Config
+	{
+		if *apiAuthTokenFile != "" {
+			tokens, err := auth.LoadTokenFile(*apiAuthTokenFile)
+			if err != nil {
+				logger.Log("err", err)
+				os.Exit(1)
+			}
+			apiAuthenticators = append(apiAuthenticators, tokens)
+		}
+		if *apiAuthTokenReview {
+			apiAuthenticators = append(apiAuthenticators, &auth.TokenReview{
+				Reviewer: tokenReviewer,
+				CacheTTL: time.Minute,
+			})
+		}
+		if (*listenTLSCert == "") != (*listenTLSKey == "") {
+			logger.Log("err", "--listen-tls-cert and --listen-tls-key must be given together")
+			os.Exit(1)
+		}
+		if *apiAuthClientCA != "" {
+			if *listenTLSCert == "" {
+				logger.Log("err", "--api-auth-client-ca requires the API to be served over HTTPS (--listen-tls-cert and --listen-tls-key)")
+				os.Exit(1)
+			}
+			caCerts, err := ioutil.ReadFile(*apiAuthClientCA)
+			if err != nil {
+				logger.Log("err", err)
+				os.Exit(1)
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(caCerts) {
+				logger.Log("err", fmt.Sprintf("no PEM-encoded certificates found in %s", *apiAuthClientCA))
+				os.Exit(1)
+			}
+			apiTLSConfig = &tls.Config{
+				ClientCAs:  pool,
+				ClientAuth: tls.VerifyClientCertIfGiven,
+			}
+			apiAuthenticators = append(apiAuthenticators, auth.ClientCertificates{})
+		}
+		if *apiAuthzPolicy != "" {
+			policy, err := auth.LoadPolicy(*apiAuthzPolicy)
+			if err != nil {
+				logger.Log("err", err)
+				os.Exit(1)
+			}
+			apiAuthorizer = policy
+		} else if len(apiAuthenticators) > 0 {
+			apiAuthorizer = auth.DefaultPolicy
+		}
+	}
+
+	var webhooks *webhook.Handler
+	if *webhookSecretFile != "" || len(*webhookReceiverSecretFiles) > 0 {
+		receivers := webhook.GitReceivers()
+		// Image notifications are acted on by the cache warmer, so
+		// there's no point receiving them if it's not running
+		if !*registryDisableScanning {
+			for path, receiver := range webhook.RegistryReceivers() {
+				receivers[path] = receiver
+			}
+		}
+
+		var secret []byte
+		if *webhookSecretFile != "" {
+			var err error
+			if secret, err = readWebhookSecret(*webhookSecretFile); err != nil {
+				logger.Log("err", err)
+				os.Exit(1)
+			}
+		}
+		secrets := map[string][]byte{}
+		for _, s := range *webhookReceiverSecretFiles {
+			parts := strings.SplitN(s, "=", 2)
+			if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+				logger.Log("err", fmt.Sprintf("--webhook-receiver-secret-file %q is not of the form <receiver>=<path>", s))
+				os.Exit(1)
+			}
+			path := strings.Trim(parts[0], "/")
+			if _, ok := receivers[path]; !ok {
+				logger.Log("err", fmt.Sprintf("--webhook-receiver-secret-file: no webhook receiver %q", parts[0]))
+				os.Exit(1)
+			}
+			receiverSecret, err := readWebhookSecret(parts[1])
+			if err != nil {
+				logger.Log("err", err)
+				os.Exit(1)
+			}
+			secrets[path] = receiverSecret
+		}
+		// Without a shared secret, only the receivers given their own
+		// secret are served
+		if secret == nil {
+			for path := range receivers {
+				if _, ok := secrets[path]; !ok {
+					delete(receivers, path)
+				}
+			}
+		}
+
+		webhooks = &webhook.Handler{
+			Notifier:  daemon,
+			Secret:    secret,
+			Secrets:   secrets,
+			Receivers: receivers,
+			Logger:    log.With(logger, "component", "webhooks"),
+		}
+		for path := range webhooks.Receivers {
+			logger.Log("webhook", "/hooks/"+path)
+		}
+	}
+
 	go func() {
 		mux := http.DefaultServeMux
 		// Serve /metrics alongside API
 		if *listenMetricsAddr == "" {
 			mux.Handle("/metrics", promhttp.Handler())
 		}
-		handler := daemonhttp.NewHandler(daemon, daemonhttp.NewRouter())
+		router := daemonhttp.NewRouter()
+		if apiAuthorizer != nil {
+			router.Use((&auth.Middleware{
+				Authenticator: apiAuthenticators,
+				Authorizer:    apiAuthorizer,
+				Logger:        log.With(logger, "component", "api-auth"),
+			}).Wrap)
+			logger.Log("api-auth", "enabled", "authenticators", len(apiAuthenticators))
+		}
+		handler := daemonhttp.NewHandler(daemon, router)
 		mux.Handle("/api/flux/", http.StripPrefix("/api/flux", handler))
+		// Webhooks carry their own secret, so are not subject to API
+		// authentication.
+		if webhooks != nil {
+			mux.Handle("/hooks/", http.StripPrefix("/hooks", webhooks))
+		}
 		logger.Log("addr", *listenAddr)
+		if *listenTLSCert != "" {
+			server := &http.Server{Addr: *listenAddr, Handler: mux, TLSConfig: apiTLSConfig}
+			errc <- server.ListenAndServeTLS(*listenTLSCert, *listenTLSKey)
+			return
+		}
 		errc <- http.ListenAndServe(*listenAddr, mux)
 	}()
 
@@ -827,3 +1284,17 @@ func homeDir() string {
 	}
 	return ""
 }
+
+// readWebhookSecret reads a webhook secret from the file given, less
+// any surrounding whitespace (e.g., a trailing newline).
+func readWebhookSecret(path string) ([]byte, error) {
+	secret, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	secret = bytes.TrimSpace(secret)
+	if len(secret) == 0 {
+		return nil, fmt.Errorf("webhook secret file %s is empty", path)
+	}
+	return secret, nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// gitSourceSpec is the parsed form of a `--git-source` flag value.
+type gitSourceSpec struct {
+	Name   string
+	URL    string
+	Branch string
+	Paths  []string
+}
+
+// Source names end up in sync tag names and annotation keys, so keep
+// them to something that's valid in both.
+var gitSourceNameRegexp = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+// parseGitSource parses a git source given as a comma-separated list
+// of key=value pairs, e.g.,
+//
+//	name=platform,url=git@github.com:org/platform,branch=main,path=clusters/prod,path=infra
+//
+// `name` and `url` are mandatory; `branch` defaults to the branch
+// given, and `path` may be supplied any number of times.
+func parseGitSource(value, defaultBranch string) (gitSourceSpec, error) {
+	spec := gitSourceSpec{Branch: defaultBranch}
+	for _, field := range strings.Split(value, ",") {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			return spec, fmt.Errorf("git source %q: expected key=value, got %q", value, field)
+		}
+		key, val := strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])
+		switch key {
+		case "name":
+			spec.Name = val
+		case "url":
+			spec.URL = val
+		case "branch":
+			spec.Branch = val
+		case "path":
+			if len(val) > 0 && val[0] == '/' {
+				return spec, fmt.Errorf("git source %q: path %q should not have leading forward slash", value, val)
+			}
+			spec.Paths = append(spec.Paths, val)
+		default:
+			return spec, fmt.Errorf("git source %q: unknown key %q", value, key)
+		}
+	}
+	switch {
+	case spec.Name == "":
+		return spec, fmt.Errorf("git source %q: name is required", value)
+	case !gitSourceNameRegexp.MatchString(spec.Name):
+		return spec, fmt.Errorf("git source %q: name %q must consist of lower case alphanumeric characters or '-'", value, spec.Name)
+	case spec.URL == "":
+		return spec, fmt.Errorf("git source %q: url is required", value)
+	case spec.Branch == "":
+		return spec, fmt.Errorf("git source %q: branch must not be empty", value)
+	}
+	return spec, nil
+}
+
+// parseGitSources parses all the `--git-source` values given, and
+// checks that their names are unique.
+func parseGitSources(values []string, defaultBranch string) ([]gitSourceSpec, error) {
+	var specs []gitSourceSpec
+	names := map[string]struct{}{}
+	for _, value := range values {
+		spec, err := parseGitSource(value, defaultBranch)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := names[spec.Name]; ok {
+			return nil, fmt.Errorf("git source name %q given more than once", spec.Name)
+		}
+		names[spec.Name] = struct{}{}
+		specs = append(specs, spec)
+	}
+	return specs, nil
+}
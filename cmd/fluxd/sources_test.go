@@ -0,0 +1,57 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseGitSource(t *testing.T) {
+	spec, err := parseGitSource("name=platform,url=git@github.com:org/platform,path=clusters/prod,path=infra", "master")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := gitSourceSpec{
+		Name:   "platform",
+		URL:    "git@github.com:org/platform",
+		Branch: "master",
+		Paths:  []string{"clusters/prod", "infra"},
+	}
+	if !reflect.DeepEqual(spec, expected) {
+		t.Errorf("expected %#v, got %#v", expected, spec)
+	}
+
+	spec, err = parseGitSource("name=apps,url=https://example.com/apps.git,branch=main", "master")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spec.Branch != "main" {
+		t.Errorf("expected branch to be overridden, got %q", spec.Branch)
+	}
+}
+
+func TestParseGitSource_Invalid(t *testing.T) {
+	for _, value := range []string{
+		"",
+		"url=git@github.com:org/platform",
+		"name=platform",
+		"name=Platform,url=git@github.com:org/platform",
+		"name=platform,url=git@github.com:org/platform,path=/abs",
+		"name=platform,url=git@github.com:org/platform,colour=blue",
+		"name=platform,url=git@github.com:org/platform,branch=",
+		"name=platform;url=git@github.com:org/platform",
+	} {
+		if _, err := parseGitSource(value, "master"); err == nil {
+			t.Errorf("expected error for %q", value)
+		}
+	}
+}
+
+func TestParseGitSources_DuplicateNames(t *testing.T) {
+	_, err := parseGitSources([]string{
+		"name=platform,url=git@github.com:org/platform",
+		"name=platform,url=git@github.com:org/other",
+	}, "master")
+	if err == nil {
+		t.Error("expected error for duplicate source names")
+	}
+}
@@ -0,0 +1,33 @@
+// This package defines the types for Flux API version 12.
+package v12
+
+import (
+	"context"
+
+	"github.com/fluxcd/flux/pkg/api/v11"
+	"github.com/fluxcd/flux/pkg/cluster"
+)
+
+type SyncPlanOptions struct {
+	// Ref is the git ref (e.g., a branch, tag or commit) to plan a
+	// sync of; if empty, the head of the branch is used. It's fetched
+	// from the upstream repo first, so it needn't be one fluxd has
+	// seen already.
+	Ref string
+	// Source names the git source to plan a sync of; if empty, the
+	// main git repo is used.
+	Source string
+}
+
+// SyncPlan is what a sync of a particular revision would do to the
+// cluster.
+type SyncPlan struct {
+	Revision  string
+	Resources []cluster.ResourcePlan
+}
+
+type Server interface {
+	v11.Server
+
+	SyncPlan(ctx context.Context, opts SyncPlanOptions) (SyncPlan, error)
+}
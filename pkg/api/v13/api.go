@@ -0,0 +1,30 @@
+// This package defines the types for Flux API version 13.
+package v13
+
+import (
+	"context"
+
+	"github.com/fluxcd/flux/pkg/api/v12"
+	"github.com/fluxcd/flux/pkg/cluster"
+	"github.com/fluxcd/flux/pkg/resource"
+)
+
+type ListResourcesOptions struct {
+	// Namespace restricts the resources listed to those in the
+	// namespace; if empty, resources in all namespaces are listed.
+	Namespace string
+}
+
+// ResourceStatus reports both whether a resource was synced without
+// error, and whether it is healthy in the cluster.
+type ResourceStatus struct {
+	ID        resource.ID
+	Health    cluster.Health
+	SyncError string
+}
+
+type Server interface {
+	v12.Server
+
+	ListResources(ctx context.Context, opts ListResourcesOptions) ([]ResourceStatus, error)
+}
@@ -0,0 +1,63 @@
+// This package defines the types for Flux API version 14.
+package v14
+
+import (
+	"context"
+
+	"github.com/fluxcd/flux/pkg/api/v13"
+	"github.com/fluxcd/flux/pkg/event"
+	"github.com/fluxcd/flux/pkg/job"
+	"github.com/fluxcd/flux/pkg/resource"
+)
+
+// UpdateType says what an Update is about.
+type UpdateType string
+
+const (
+	UpdateJob          UpdateType = "job"
+	UpdateSyncStarted  UpdateType = "sync-started"
+	UpdateSyncFinished UpdateType = "sync-finished"
+	UpdateEvent        UpdateType = "event"
+)
+
+type WatchOptions struct {
+	// Workloads restricts the updates sent to those concerning the
+	// workloads given; if empty, all updates are sent. Updates about
+	// syncs, and about jobs that haven't finished (so don't yet have
+	// a result saying which workloads they concern) are always sent.
+	Workloads []resource.ID
+}
+
+// JobUpdate is a change in the status of a job.
+type JobUpdate struct {
+	ID     job.ID
+	Status job.Status
+}
+
+// SyncUpdate is the start or finish of a sync of a git source (the
+// main git repo, if Source is empty).
+type SyncUpdate struct {
+	Source   string
+	Revision string
+	// Error is set if a sync finished unsuccessfully.
+	Error string `json:",omitempty"`
+}
+
+// Update is something that happened in the daemon. Exactly one of
+// Job, Sync and Event is set, according to the Type.
+type Update struct {
+	Type  UpdateType
+	Job   *JobUpdate   `json:",omitempty"`
+	Sync  *SyncUpdate  `json:",omitempty"`
+	Event *event.Event `json:",omitempty"`
+}
+
+type Server interface {
+	v13.Server
+
+	// Watch sends updates as they happen, until the context is
+	// cancelled, at which point the channel is closed. The channel
+	// may also be closed if the receiver falls too far behind, or
+	// the connection to the daemon is lost.
+	Watch(ctx context.Context, opts WatchOptions) (<-chan Update, error)
+}
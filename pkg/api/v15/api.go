@@ -0,0 +1,31 @@
+// This package defines the types for Flux API version 15.
+package v15
+
+import (
+	"context"
+	"time"
+
+	"github.com/fluxcd/flux/pkg/api/v14"
+	"github.com/fluxcd/flux/pkg/event"
+	"github.com/fluxcd/flux/pkg/resource"
+)
+
+type HistoryOptions struct {
+	// Workloads restricts the history to events concerning any of
+	// the workloads given; if empty, all events are included.
+	Workloads []resource.ID
+	// Before restricts the history to events from before the time
+	// given, if it's not zero, for paging back through the history.
+	Before time.Time
+	// Limit is the maximum number of events to return; if zero, the
+	// daemon decides.
+	Limit int
+}
+
+type Server interface {
+	v14.Server
+
+	// History returns the events recorded by the daemon, most recent
+	// first.
+	History(ctx context.Context, opts HistoryOptions) ([]event.Event, error)
+}
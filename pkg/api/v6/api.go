@@ -2,6 +2,7 @@ package v6
 
 import (
 	"context"
+	"time"
 
 	"github.com/fluxcd/flux/pkg/cluster"
 	"github.com/fluxcd/flux/pkg/git"
@@ -38,12 +39,26 @@ type ControllerStatus struct {
 	Status     string
 	Rollout    cluster.RolloutStatus
 	SyncError  string
+	Health     cluster.Health
 	Antecedent resource.ID
 	Labels     map[string]string
 	Automated  bool
 	Locked     bool
 	Ignore     bool
 	Policies   map[string]string
+	// Frozen is given if the workload is automated, but automated
+	// releases are held by a maintenance window or release freeze.
+	Frozen *Freeze `json:",omitempty"`
+	// Drift is given if the last sync found the workload had been
+	// changed in the cluster since it was applied.
+	Drift *cluster.ResourceDrift `json:",omitempty"`
+}
+
+// Freeze says why automated releases of a workload are held, and
+// until when (if it's known).
+type Freeze struct {
+	Until  time.Time
+	Reason string
 }
 
 // --- config types
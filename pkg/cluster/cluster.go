@@ -3,7 +3,9 @@ package cluster
 import (
 	"context"
 	"errors"
+	"time"
 
+	"github.com/fluxcd/flux/pkg/image"
 	"github.com/fluxcd/flux/pkg/policy"
 	"github.com/fluxcd/flux/pkg/resource"
 	"github.com/fluxcd/flux/pkg/ssh"
@@ -76,6 +78,18 @@ type Workload struct {
 	// Errors during the recurring sync from the Git repository to the
 	// cluster will surface here.
 	SyncError error
+	// Drift found during the last sync, if drift detection is
+	// enabled, will surface here.
+	Drift *ResourceDrift
+	// The health of the workload, as distinct from whether it was
+	// synced without error.
+	Health Health
+	// The platforms (operating system and CPU architecture) of the
+	// nodes the workload's pods can be scheduled onto, if known.
+	Platforms []image.Platform
+	// When the workload completed the rollout of its current
+	// definition, if its status says so; zero otherwise.
+	ReadySince time.Time
 
 	Containers ContainersOrExcuse
 }
@@ -0,0 +1,23 @@
+package cluster
+
+import (
+	"github.com/fluxcd/flux/pkg/resource"
+)
+
+// ResourceDrift describes how a resource in the cluster was found to
+// differ from its definition in git, when git had not changed since
+// the definition was last applied -- i.e., someone or something
+// changed the resource in the cluster. Each change has the value in
+// the cluster as `Old`, and the value in git as `New`.
+type ResourceDrift struct {
+	ResourceID resource.ID
+	Source     string
+	Changes    []FieldChange
+	// Corrected is true if the definition in git was applied again,
+	// undoing the changes; it's false for resources that are only to
+	// have drift reported.
+	Corrected bool
+	// Repeated is true if the same drift was reported by the
+	// previous sync, and left uncorrected.
+	Repeated bool
+}
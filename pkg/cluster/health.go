@@ -0,0 +1,41 @@
+package cluster
+
+import (
+	"context"
+
+	"github.com/fluxcd/flux/pkg/resource"
+)
+
+// Constants for resource health. Health is distinct from whether a
+// resource was synced: a resource can be applied without error, and
+// still fail to become healthy (e.g., a job whose pods crash, or a
+// service still waiting for a load balancer).
+const (
+	HealthHealthy     = "healthy"
+	HealthProgressing = "progressing"
+	HealthDegraded    = "degraded"
+	HealthUnknown     = "unknown"
+)
+
+// Health is an assessment of whether a resource is doing what it's
+// supposed to. The message gives the reason, if it isn't healthy.
+type Health struct {
+	Status  string
+	Message string
+}
+
+// ResourceHealth is the health of a resource synced to the cluster,
+// along with the error from the last attempt to sync it, if any.
+type ResourceHealth struct {
+	ResourceID resource.ID
+	Health     Health
+	SyncError  error
+}
+
+// HealthChecker is a cluster that can assess the health of the
+// resources that have been synced to it.
+type HealthChecker interface {
+	// ResourceHealth returns the health of all synced resources
+	// (optionally, from a specific namespace).
+	ResourceHealth(ctx context.Context, maybeNamespace string) ([]ResourceHealth, error)
+}
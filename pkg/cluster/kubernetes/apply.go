@@ -0,0 +1,150 @@
+package kubernetes
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"github.com/go-kit/kit/log"
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/restmapper"
+
+	"github.com/fluxcd/flux/pkg/cluster"
+	"github.com/fluxcd/flux/pkg/resource"
+)
+
+// FieldManager is the name under which the server-side applier
+// claims ownership of the fields it applies.
+const FieldManager = "flux"
+
+// ServerSideApplier is an Applier that applies each resource with
+// Kubernetes' server-side apply, using the dynamic client. Unlike
+// `kubectl apply`, it doesn't rely on the last-applied-configuration
+// annotation: the API server records which fields flux manages, so
+// fields set by other controllers (e.g., the replicas of a
+// deployment scaled by a HorizontalPodAutoscaler) are left alone,
+// unless flux's manifests also set them, in which case it's reported
+// as a conflict.
+type ServerSideApplier struct {
+	client dynamic.Interface
+	mapper *restmapper.DeferredDiscoveryRESTMapper
+	// force means fields owned by other managers are taken over,
+	// rather than reported as conflicts
+	force bool
+}
+
+func NewServerSideApplier(client dynamic.Interface, disco discovery.CachedDiscoveryInterface, force bool) *ServerSideApplier {
+	return &ServerSideApplier{
+		client: client,
+		mapper: restmapper.NewDeferredDiscoveryRESTMapper(disco),
+		force:  force,
+	}
+}
+
+func (a *ServerSideApplier) apply(logger log.Logger, cs changeSet, errored map[resource.ID]error) (errs cluster.SyncError) {
+	f := func(objs []applyObject, cmd string, op func(dynamic.ResourceInterface, *unstructured.Unstructured, []byte) error) {
+		if len(objs) == 0 {
+			return
+		}
+		logger.Log("cmd", cmd, "count", len(objs))
+		for _, obj := range objs {
+			begin := time.Now()
+			err := a.do(obj, op)
+			logger.Log("cmd", cmd, "resource", obj.ResourceID, "took", time.Since(begin), "err", err)
+			if err != nil {
+				errs = append(errs, cluster.ResourceError{
+					ResourceID: obj.ResourceID,
+					Source:     obj.Source,
+					Error:      err,
+				})
+			}
+		}
+	}
+
+	// As with kubectl, delete in the reverse dependency order, to
+	// avoid deleting things that Kubernetes' GC will delete anyway.
+	objs := cs.objs["delete"]
+	sort.Sort(sort.Reverse(applyOrder(objs)))
+	f(objs, "delete", a.deleteObject)
+
+	objs = cs.objs["apply"]
+	sort.Sort(applyOrder(objs))
+	f(objs, "apply", a.applyObject)
+	return errs
+}
+
+// do finds the API resource for the object given, and calls op with
+// a client for it.
+func (a *ServerSideApplier) do(obj applyObject, op func(dynamic.ResourceInterface, *unstructured.Unstructured, []byte) error) error {
+	data, err := yaml.YAMLToJSON(obj.Payload)
+	if err != nil {
+		return errors.Wrap(err, "converting manifest to JSON")
+	}
+	u := &unstructured.Unstructured{}
+	if err := u.UnmarshalJSON(data); err != nil {
+		return errors.Wrap(err, "parsing manifest")
+	}
+	gvk := u.GroupVersionKind()
+	mapping, err := a.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if meta.IsNoMatchError(err) {
+		// The kind may have been defined since the mapping was
+		// cached (e.g., by a CRD applied earlier in this sync), so
+		// have one more go with fresh API discovery.
+		a.mapper.Reset()
+		mapping, err = a.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	}
+	if err != nil {
+		return err
+	}
+
+	var client dynamic.ResourceInterface = a.client.Resource(mapping.Resource)
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		client = a.client.Resource(mapping.Resource).Namespace(u.GetNamespace())
+	}
+	return op(client, u, data)
+}
+
+func (a *ServerSideApplier) applyObject(client dynamic.ResourceInterface, u *unstructured.Unstructured, data []byte) error {
+	force := a.force
+	_, err := client.Patch(u.GetName(), types.ApplyPatchType, data, meta_v1.PatchOptions{
+		FieldManager: FieldManager,
+		Force:        &force,
+	})
+	if apierrors.IsConflict(err) {
+		return applyConflictError(err)
+	}
+	return err
+}
+
+func (a *ServerSideApplier) deleteObject(client dynamic.ResourceInterface, u *unstructured.Unstructured, _ []byte) error {
+	propagation := meta_v1.DeletePropagationBackground
+	err := client.Delete(u.GetName(), &meta_v1.DeleteOptions{PropagationPolicy: &propagation})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// applyConflictError explains a conflict from server-side apply,
+// listing the fields that are owned by other managers.
+func applyConflictError(err error) error {
+	status, ok := err.(apierrors.APIStatus)
+	if !ok || status.Status().Details == nil || len(status.Status().Details.Causes) == 0 {
+		return errors.Wrap(err, "conflict applying resource")
+	}
+	var conflicts []string
+	for _, cause := range status.Status().Details.Causes {
+		conflicts = append(conflicts, fmt.Sprintf("%s (%s)", cause.Field, cause.Message))
+	}
+	return fmt.Errorf("fields owned by other managers: %s; remove them from the manifest, or force flux to take them over",
+		strings.Join(conflicts, ", "))
+}
@@ -0,0 +1,87 @@
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	crdfake "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/fake"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8s_testing "k8s.io/client-go/testing"
+
+	"github.com/fluxcd/flux/pkg/resource"
+)
+
+func TestServerSideApply(t *testing.T) {
+	clients, cancel := fakeClients()
+	defer cancel()
+	shutdown := make(chan struct{})
+	defer close(shutdown)
+	disco := MakeCachedDiscovery(clients.coreClient.Discovery(), crdfake.NewSimpleClientset(), shutdown)
+
+	var patched, deleted []string
+	dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+	dynamicClient.PrependReactor("patch", "*", func(action k8s_testing.Action) (bool, runtime.Object, error) {
+		patch := action.(k8s_testing.PatchAction)
+		assert.Equal(t, types.ApplyPatchType, patch.GetPatchType())
+		patched = append(patched, patch.GetResource().Resource+"/"+patch.GetNamespace()+"/"+patch.GetName())
+		if patch.GetName() == "conflicted" {
+			return true, nil, errors.NewApplyConflict([]metav1.StatusCause{
+				{Type: metav1.CauseType("FieldManagerConflict"), Field: ".spec.replicas", Message: `conflict with "hpa-controller"`},
+			}, "Apply failed with 1 conflict")
+		}
+		obj := &unstructured.Unstructured{}
+		obj.SetGroupVersionKind(schema.GroupVersionKind{Version: "v1", Kind: "Namespace"})
+		return true, obj, nil
+	})
+	dynamicClient.PrependReactor("delete", "*", func(action k8s_testing.Action) (bool, runtime.Object, error) {
+		del := action.(k8s_testing.DeleteAction)
+		deleted = append(deleted, del.GetResource().Resource+"/"+del.GetNamespace()+"/"+del.GetName())
+		return true, nil, errors.NewNotFound(del.GetResource().GroupResource(), del.GetName())
+	})
+
+	applier := NewServerSideApplier(dynamicClient, disco, false)
+	cs := makeChangeSet()
+	cs.stage("apply", resource.MustParseID("foo:deployment/app"), "app.yaml", []byte(`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+  namespace: foo
+`))
+	cs.stage("apply", resource.MustParseID("<cluster>:namespace/foo"), "ns.yaml", []byte(`apiVersion: v1
+kind: Namespace
+metadata:
+  name: foo
+`))
+	cs.stage("apply", resource.MustParseID("foo:deployment/conflicted"), "conflicted.yaml", []byte(`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: conflicted
+  namespace: foo
+spec:
+  replicas: 2
+`))
+	cs.stage("delete", resource.MustParseID("foo:deployment/gone"), "gone.yaml", []byte(`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: gone
+  namespace: foo
+`))
+
+	errs := applier.apply(log.NewNopLogger(), cs, nil)
+	// The namespace is applied first; and deleting something already
+	// gone is not an error
+	assert.Equal(t, []string{"namespaces//foo", "deployments/foo/app", "deployments/foo/conflicted"}, patched)
+	assert.Equal(t, []string{"deployments/foo/gone"}, deleted)
+	if assert.Len(t, errs, 1) {
+		assert.Equal(t, resource.MustParseID("foo:deployment/conflicted"), errs[0].ResourceID)
+		assert.Equal(t, "conflicted.yaml", errs[0].Source)
+		assert.Contains(t, errs[0].Error.Error(), `.spec.replicas (conflict with "hpa-controller")`)
+	}
+}
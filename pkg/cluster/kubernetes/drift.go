@@ -0,0 +1,102 @@
+package kubernetes
+
+import (
+	"reflect"
+	"sort"
+
+	"github.com/ghodss/yaml"
+	"github.com/go-kit/kit/metrics/prometheus"
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
+
+	"github.com/fluxcd/flux/pkg/cluster"
+	"github.com/fluxcd/flux/pkg/resource"
+)
+
+var resourceDrifted = prometheus.NewGaugeFrom(stdprometheus.GaugeOpts{
+	Namespace: "flux",
+	Name:      "resource_drifted",
+	Help:      "Whether the resource was found to have drifted from its definition in git during the last sync (1) or not (0).",
+}, []string{"namespace", "kind", "name"})
+
+// detectDrift compares a resource in the cluster with the definition
+// about to be applied to it. If the checksum shows the same
+// definition was applied last time, any difference must have been
+// made in the cluster, and is reported as drift. Otherwise, the
+// definition has changed in git, and differences are to be expected.
+func detectDrift(live *kuberesource, checksum string, resBytes []byte) ([]cluster.FieldChange, error) {
+	if live.GetChecksum() != checksum {
+		return nil, nil
+	}
+	var desired map[string]interface{}
+	if err := yaml.Unmarshal(resBytes, &desired); err != nil {
+		return nil, err
+	}
+	return diffResource(live, desired)
+}
+
+// recordDrift replaces the drift recorded for the sync set given, and
+// updates the drift metric. Drift in a resource that then failed to
+// apply was not corrected, whatever was intended.
+func (c *Cluster) recordDrift(syncSetName string, drifts []cluster.ResourceDrift, errs cluster.SyncError) {
+	failed := map[resource.ID]bool{}
+	for _, e := range errs {
+		failed[e.ResourceID] = true
+	}
+
+	c.muDrift.Lock()
+	defer c.muDrift.Unlock()
+	if c.drift == nil {
+		c.drift = map[string]map[resource.ID]cluster.ResourceDrift{}
+	}
+	last := c.drift[syncSetName]
+	setDrift := map[resource.ID]cluster.ResourceDrift{}
+	for _, d := range drifts {
+		if failed[d.ResourceID] {
+			d.Corrected = false
+		}
+		if l, ok := last[d.ResourceID]; ok && !l.Corrected && !d.Corrected && reflect.DeepEqual(l.Changes, d.Changes) {
+			d.Repeated = true
+		}
+		setDrift[d.ResourceID] = d
+		setDriftMetric(d.ResourceID, 1)
+	}
+	for id := range last {
+		if _, ok := setDrift[id]; !ok {
+			setDriftMetric(id, 0)
+		}
+	}
+	c.drift[syncSetName] = setDrift
+}
+
+func setDriftMetric(id resource.ID, value float64) {
+	ns, kind, name := id.Components()
+	resourceDrifted.With("namespace", ns, "kind", kind, "name", name).Set(value)
+}
+
+// Drifted returns the drift found during the last sync of the sync set
+// given.
+func (c *Cluster) Drifted(syncSetName string) []cluster.ResourceDrift {
+	c.muDrift.RLock()
+	defer c.muDrift.RUnlock()
+	var drifts []cluster.ResourceDrift
+	for _, d := range c.drift[syncSetName] {
+		drifts = append(drifts, d)
+	}
+	sort.Slice(drifts, func(i, j int) bool {
+		return drifts[i].ResourceID.String() < drifts[j].ResourceID.String()
+	})
+	return drifts
+}
+
+// driftFor returns the drift found in the resource given during the
+// last sync of any sync set, or nil if there was none.
+func (c *Cluster) driftFor(id resource.ID) *cluster.ResourceDrift {
+	c.muDrift.RLock()
+	defer c.muDrift.RUnlock()
+	for _, setDrift := range c.drift {
+		if d, ok := setDrift[id]; ok {
+			return &d
+		}
+	}
+	return nil
+}
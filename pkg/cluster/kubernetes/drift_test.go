@@ -0,0 +1,250 @@
+package kubernetes
+
+import (
+	"encoding/base64"
+	"os"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	corefake "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/fluxcd/flux/pkg/cluster"
+	kresource "github.com/fluxcd/flux/pkg/cluster/kubernetes/resource"
+	"github.com/fluxcd/flux/pkg/resource"
+	"github.com/fluxcd/flux/pkg/sync"
+)
+
+func TestSyncDrift(t *testing.T) {
+	const ns = `---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: foobar
+`
+	const dep1 = `---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: dep1
+  namespace: foobar
+spec:
+  replicas: 1
+`
+	const dep1ReportOnly = `---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: dep1
+  namespace: foobar
+  annotations:
+    fluxcd.io/drift-report-only: "true"
+spec:
+  replicas: 1
+`
+
+	kube, _, cancel := setup(t)
+	defer cancel()
+	kube.DriftDetection = true
+
+	namespacer, err := NewNamespacer(kube.client.coreClient.Discovery(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifests := NewManifests(namespacer, log.NewLogfmtLogger(os.Stdout))
+	syncDefs := func(defs string) {
+		kms, err := kresource.ParseMultidoc([]byte(defs), "test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resources, err := manifests.setEffectiveNamespaces(kms)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := sync.Sync("testset", resources, kube); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	deployments := kube.client.dynamicClient.Resource(schema.GroupVersionResource{
+		Group:    "apps",
+		Version:  "v1",
+		Resource: "deployments",
+	}).Namespace("foobar")
+	// scale is the equivalent of `kubectl scale`
+	scale := func(replicas int64) {
+		res, err := deployments.Get("dep1", metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := unstructured.SetNestedField(res.Object, replicas, "spec", "replicas"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = deployments.Update(res, metav1.UpdateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// replicas gives the number of replicas in the cluster; the
+	// fake applier stores numbers as parsed from JSON
+	replicas := func() int64 {
+		res, err := deployments.Get("dep1", metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, _, _ := unstructured.NestedFieldNoCopy(res.Object, "spec", "replicas")
+		switch r := r.(type) {
+		case int64:
+			return r
+		case float64:
+			return int64(r)
+		}
+		return 0
+	}
+
+	// Nothing has changed in the cluster, so nothing has drifted
+	syncDefs(ns + dep1)
+	syncDefs(ns + dep1)
+	assert.Empty(t, kube.Drifted("testset"))
+
+	// Changed in the cluster: drift, which is corrected
+	scale(5)
+	syncDefs(ns + dep1)
+	assert.Equal(t, []cluster.ResourceDrift{{
+		ResourceID: resource.MustParseID("foobar:deployment/dep1"),
+		Source:     "test",
+		Changes:    []cluster.FieldChange{{Path: "spec.replicas", Old: float64(5), New: float64(1)}},
+		Corrected:  true,
+	}}, kube.Drifted("testset"))
+	assert.Equal(t, int64(1), replicas())
+	assert.NotNil(t, kube.driftFor(resource.MustParseID("foobar:deployment/dep1")))
+
+	// A change in git is not drift
+	syncDefs(ns + dep1ReportOnly)
+	assert.Empty(t, kube.Drifted("testset"))
+
+	// Report-only: the drift is left in place, and noted as such
+	// when it's found again
+	scale(5)
+	syncDefs(ns + dep1ReportOnly)
+	drifts := kube.Drifted("testset")
+	if assert.Len(t, drifts, 1) {
+		assert.False(t, drifts[0].Corrected)
+		assert.False(t, drifts[0].Repeated)
+	}
+	assert.Equal(t, int64(5), replicas())
+	syncDefs(ns + dep1ReportOnly)
+	drifts = kube.Drifted("testset")
+	if assert.Len(t, drifts, 1) {
+		assert.True(t, drifts[0].Repeated)
+	}
+	assert.Equal(t, int64(5), replicas())
+}
+
+// The API server doesn't give back resources exactly as they were
+// applied; that alone mustn't be taken as drift.
+func TestSyncDrift_Normalised(t *testing.T) {
+	const ns = `---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: foobar
+`
+	const secret = `---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: creds
+  namespace: foobar
+stringData:
+  password: hunter2
+`
+	const dep1 = `---
+apiVersion: apps/v1beta2
+kind: Deployment
+metadata:
+  name: dep1
+  namespace: foobar
+spec:
+  replicas: 1
+`
+
+	kube, _, cancel := setup(t)
+	defer cancel()
+	kube.DriftDetection = true
+
+	// Secrets, and a version of apps other than the preferred
+	// version (which is the first given)
+	fakeClient := kube.client.coreClient.(*corefake.Clientset)
+	for _, list := range fakeClient.Resources {
+		if list.GroupVersion == "v1" {
+			list.APIResources = append(list.APIResources, metav1.APIResource{Name: "secrets", SingularName: "secret", Namespaced: true, Kind: "Secret", Verbs: metav1.Verbs{"get", "list"}})
+		}
+	}
+	fakeClient.Resources = append(fakeClient.Resources, &metav1.APIResourceList{
+		GroupVersion: "apps/v1beta2",
+		APIResources: []metav1.APIResource{
+			{Name: "deployments", SingularName: "deployment", Namespaced: true, Kind: "Deployment", Verbs: metav1.Verbs{"get", "list"}},
+		},
+	})
+
+	namespacer, err := NewNamespacer(kube.client.coreClient.Discovery(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifests := NewManifests(namespacer, log.NewLogfmtLogger(os.Stdout))
+	syncDefs := func(defs string) {
+		kms, err := kresource.ParseMultidoc([]byte(defs), "test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resources, err := manifests.setEffectiveNamespaces(kms)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := sync.Sync("testset", resources, kube); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// store does to what's been applied what the API server would:
+	// the Secret's stringData is folded into its data, and the
+	// Deployment is served at the preferred version too.
+	store := func() {
+		secrets := kube.client.dynamicClient.Resource(schema.GroupVersionResource{Version: "v1", Resource: "secrets"}).Namespace("foobar")
+		s, err := secrets.Get("creds", metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := s.Object["stringData"]; ok {
+			delete(s.Object, "stringData")
+			s.Object["data"] = map[string]interface{}{"password": base64.StdEncoding.EncodeToString([]byte("hunter2"))}
+			if _, err := secrets.Update(s, metav1.UpdateOptions{}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		d, err := kube.client.dynamicClient.Resource(schema.GroupVersionResource{Group: "apps", Version: "v1beta2", Resource: "deployments"}).Namespace("foobar").Get("dep1", metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		d.SetAPIVersion("apps/v1")
+		d.SetResourceVersion("")
+		preferred := kube.client.dynamicClient.Resource(schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}).Namespace("foobar")
+		if _, err := preferred.Get("dep1", metav1.GetOptions{}); err == nil {
+			_, err = preferred.Update(d, metav1.UpdateOptions{})
+		} else {
+			_, err = preferred.Create(d, metav1.CreateOptions{})
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	syncDefs(ns + secret + dep1)
+	store()
+	syncDefs(ns + secret + dep1)
+	assert.Empty(t, kube.Drifted("testset"))
+}
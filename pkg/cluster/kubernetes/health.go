@@ -0,0 +1,366 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	apiapps "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/fluxcd/flux/pkg/cluster"
+	"github.com/fluxcd/flux/pkg/resource"
+)
+
+/////////////////////////////////////////////////////////////////////////////
+// Health check registry
+
+// healthCheck assesses the health of a resource of a particular kind,
+// from the resource as it is in the cluster. Kinds without a health
+// check are assessed by their status conditions, if they have any.
+type healthCheck func(obj *unstructured.Unstructured) cluster.Health
+
+var (
+	healthChecks = make(map[string]healthCheck)
+)
+
+func init() {
+	healthChecks["deployment"] = deploymentHealth
+	healthChecks["daemonset"] = daemonSetHealth
+	healthChecks["statefulset"] = statefulSetHealth
+	healthChecks["job"] = jobHealth
+	healthChecks["pod"] = podHealth
+	healthChecks["persistentvolumeclaim"] = persistentVolumeClaimHealth
+	healthChecks["service"] = serviceHealth
+	healthChecks["ingress"] = ingressHealth
+	healthChecks["helmrelease"] = helmReleaseHealth
+	healthChecks["fluxhelmrelease"] = helmReleaseHealth
+}
+
+// assessHealth works out the health of a resource in the cluster.
+func assessHealth(obj *unstructured.Unstructured) cluster.Health {
+	if obj.GetDeletionTimestamp() != nil {
+		return progressing("being deleted")
+	}
+	if check, ok := healthChecks[strings.ToLower(obj.GetKind())]; ok {
+		return check(obj)
+	}
+	return conditionsHealth(obj)
+}
+
+func healthy() cluster.Health {
+	return cluster.Health{Status: cluster.HealthHealthy}
+}
+
+func progressing(msg string) cluster.Health {
+	return cluster.Health{Status: cluster.HealthProgressing, Message: msg}
+}
+
+func degraded(msg string) cluster.Health {
+	return cluster.Health{Status: cluster.HealthDegraded, Message: msg}
+}
+
+func unknownHealth(err error) cluster.Health {
+	return cluster.Health{Status: cluster.HealthUnknown, Message: err.Error()}
+}
+
+// health assesses a workload from the typed object it was made from.
+func (w workload) health() cluster.Health {
+	obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(w.k8sObject)
+	if err != nil {
+		return unknownHealth(err)
+	}
+	return assessHealth(&unstructured.Unstructured{Object: obj})
+}
+
+// workloadHealth translates the status of a workload, as worked out
+// for reporting rollouts, into its health.
+func workloadHealth(w workload) cluster.Health {
+	switch w.status {
+	case cluster.StatusReady:
+		return healthy()
+	case cluster.StatusError:
+		return degraded(strings.Join(w.rollout.Messages, "; "))
+	case cluster.StatusStarted:
+		return progressing("latest change not yet observed by the controller")
+	default:
+		return progressing(fmt.Sprintf("%d of %d replicas updated, %d available",
+			w.rollout.Updated, w.rollout.Desired, w.rollout.Available))
+	}
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// Conditions, for any kind following the API conventions
+
+type condition struct {
+	Type    string
+	Status  string
+	Reason  string
+	Message string
+}
+
+func conditions(obj *unstructured.Unstructured) []condition {
+	items, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	var conds []condition
+	for _, item := range items {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		var c condition
+		c.Type, _, _ = unstructured.NestedString(m, "type")
+		c.Status, _, _ = unstructured.NestedString(m, "status")
+		c.Reason, _, _ = unstructured.NestedString(m, "reason")
+		c.Message, _, _ = unstructured.NestedString(m, "message")
+		conds = append(conds, c)
+	}
+	return conds
+}
+
+func findCondition(conds []condition, typ string) (condition, bool) {
+	for _, c := range conds {
+		if c.Type == typ {
+			return c, true
+		}
+	}
+	return condition{}, false
+}
+
+func (c condition) describe() string {
+	switch {
+	case c.Message != "":
+		return c.Message
+	case c.Reason != "":
+		return c.Reason
+	}
+	return fmt.Sprintf("%s is %s", c.Type, c.Status)
+}
+
+// conditionsHealth is the fallback health check. It looks at whether
+// the controller has seen the latest generation of the resource, then
+// at the `Ready` (or failing that, `Available`) condition, then for
+// any condition that indicates a problem. A resource without
+// conditions is considered healthy, since there is nothing to say
+// otherwise.
+func conditionsHealth(obj *unstructured.Unstructured) cluster.Health {
+	if observed, found, _ := unstructured.NestedInt64(obj.Object, "status", "observedGeneration"); found && observed < obj.GetGeneration() {
+		return progressing("latest change not yet observed by the controller")
+	}
+
+	conds := conditions(obj)
+	for _, typ := range []string{"Ready", "Available"} {
+		if c, ok := findCondition(conds, typ); ok {
+			switch c.Status {
+			case "True":
+				return healthy()
+			case "False":
+				return degraded(c.describe())
+			default:
+				return progressing(c.describe())
+			}
+		}
+	}
+	for _, c := range conds {
+		if c.Status != "True" {
+			continue
+		}
+		switch c.Type {
+		case "Failed", "Degraded", "Stalled":
+			return degraded(c.describe())
+		case "Progressing", "Reconciling":
+			return progressing(c.describe())
+		}
+	}
+	return healthy()
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// Workloads, using the same assessment as for rollouts
+
+func deploymentHealth(obj *unstructured.Unstructured) cluster.Health {
+	var deployment apiapps.Deployment
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &deployment); err != nil {
+		return unknownHealth(err)
+	}
+	if deployment.Spec.Replicas == nil {
+		// as defaulted by the API server
+		one := int32(1)
+		deployment.Spec.Replicas = &one
+	}
+	return workloadHealth(makeDeploymentWorkload(&deployment))
+}
+
+func daemonSetHealth(obj *unstructured.Unstructured) cluster.Health {
+	var daemonSet apiapps.DaemonSet
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &daemonSet); err != nil {
+		return unknownHealth(err)
+	}
+	return workloadHealth(makeDaemonSetWorkload(&daemonSet))
+}
+
+func statefulSetHealth(obj *unstructured.Unstructured) cluster.Health {
+	var statefulSet apiapps.StatefulSet
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &statefulSet); err != nil {
+		return unknownHealth(err)
+	}
+	return workloadHealth(makeStatefulSetWorkload(&statefulSet))
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// Other built-in kinds
+
+func jobHealth(obj *unstructured.Unstructured) cluster.Health {
+	conds := conditions(obj)
+	if c, ok := findCondition(conds, "Failed"); ok && c.Status == "True" {
+		return degraded(c.describe())
+	}
+	if c, ok := findCondition(conds, "Complete"); ok && c.Status == "True" {
+		return healthy()
+	}
+	active, _, _ := unstructured.NestedInt64(obj.Object, "status", "active")
+	succeeded, _, _ := unstructured.NestedInt64(obj.Object, "status", "succeeded")
+	failed, _, _ := unstructured.NestedInt64(obj.Object, "status", "failed")
+	return progressing(fmt.Sprintf("%d active, %d succeeded, %d failed", active, succeeded, failed))
+}
+
+func podHealth(obj *unstructured.Unstructured) cluster.Health {
+	phase, _, _ := unstructured.NestedString(obj.Object, "status", "phase")
+	switch phase {
+	case "Succeeded":
+		return healthy()
+	case "Failed":
+		msg, _, _ := unstructured.NestedString(obj.Object, "status", "message")
+		if msg == "" {
+			msg = "pod failed"
+		}
+		return degraded(msg)
+	case "Running":
+		if c, ok := findCondition(conditions(obj), "Ready"); ok && c.Status != "True" {
+			return progressing(c.describe())
+		}
+		return healthy()
+	default:
+		return progressing(fmt.Sprintf("pod is %s", strings.ToLower(phase)))
+	}
+}
+
+func persistentVolumeClaimHealth(obj *unstructured.Unstructured) cluster.Health {
+	phase, _, _ := unstructured.NestedString(obj.Object, "status", "phase")
+	switch phase {
+	case "Bound":
+		return healthy()
+	case "Lost":
+		return degraded("claim has lost its volume")
+	default:
+		return progressing("waiting for the claim to be bound")
+	}
+}
+
+func loadBalancerIngress(obj *unstructured.Unstructured) bool {
+	ingress, _, _ := unstructured.NestedSlice(obj.Object, "status", "loadBalancer", "ingress")
+	return len(ingress) > 0
+}
+
+func serviceHealth(obj *unstructured.Unstructured) cluster.Health {
+	typ, _, _ := unstructured.NestedString(obj.Object, "spec", "type")
+	if typ == "LoadBalancer" && !loadBalancerIngress(obj) {
+		return progressing("waiting for a load balancer to be provisioned")
+	}
+	return healthy()
+}
+
+func ingressHealth(obj *unstructured.Unstructured) cluster.Health {
+	if !loadBalancerIngress(obj) {
+		return progressing("waiting for the ingress to be given an address")
+	}
+	return healthy()
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// HelmReleases, of all versions
+
+func helmReleaseHealth(obj *unstructured.Unstructured) cluster.Health {
+	if observed, found, _ := unstructured.NestedInt64(obj.Object, "status", "observedGeneration"); found && observed < obj.GetGeneration() {
+		return progressing("latest change not yet observed by the Helm operator")
+	}
+	if c, ok := findCondition(conditions(obj), "Released"); ok {
+		switch c.Status {
+		case "True":
+			return healthy()
+		case "False":
+			return degraded(c.describe())
+		}
+	}
+	releaseStatus, _, _ := unstructured.NestedString(obj.Object, "status", "releaseStatus")
+	switch strings.ToLower(releaseStatus) {
+	case "deployed":
+		return healthy()
+	case "failed":
+		return degraded("release failed")
+	case "":
+		return progressing("waiting for the release")
+	default:
+		return progressing(fmt.Sprintf("release is %s", strings.ToLower(releaseStatus)))
+	}
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// cluster.HealthChecker
+
+// ResourceHealth assesses the health of each resource that has been
+// synced to the cluster; that is, those with the mark label. Resources
+// that failed to sync may not be in the cluster at all; these are
+// reported with their sync error, and an unknown health.
+func (c *Cluster) ResourceHealth(ctx context.Context, maybeNamespace string) ([]cluster.ResourceHealth, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	synced, err := c.getAllowedResourcesBySelector(gcMarkLabel)
+	if err != nil {
+		return nil, errors.Wrap(err, "collating synced resources in cluster")
+	}
+
+	inNamespace := func(id resource.ID) bool {
+		ns, _, _ := id.Components()
+		return maybeNamespace == "" || ns == maybeNamespace
+	}
+
+	var result []cluster.ResourceHealth
+	seen := map[resource.ID]bool{}
+	for _, res := range synced {
+		id := res.ResourceID()
+		seen[id] = true
+		if !inNamespace(id) {
+			continue
+		}
+		result = append(result, cluster.ResourceHealth{
+			ResourceID: id,
+			Health:     assessHealth(res.obj),
+			SyncError:  c.syncErrorFor(id),
+		})
+	}
+
+	c.muSyncErrors.RLock()
+	for _, setErrors := range c.syncErrors {
+		for id, syncErr := range setErrors {
+			if seen[id] || !inNamespace(id) || !c.IsAllowedResource(id) {
+				continue
+			}
+			seen[id] = true
+			result = append(result, cluster.ResourceHealth{
+				ResourceID: id,
+				Health:     cluster.Health{Status: cluster.HealthUnknown, Message: "not found in cluster"},
+				SyncError:  syncErr,
+			})
+		}
+	}
+	c.muSyncErrors.RUnlock()
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ResourceID.String() < result[j].ResourceID.String()
+	})
+	return result, nil
+}
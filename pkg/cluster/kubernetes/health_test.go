@@ -0,0 +1,250 @@
+package kubernetes
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/ghodss/yaml"
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/fluxcd/flux/pkg/cluster"
+	kresource "github.com/fluxcd/flux/pkg/cluster/kubernetes/resource"
+	"github.com/fluxcd/flux/pkg/resource"
+	"github.com/fluxcd/flux/pkg/sync"
+)
+
+func mustUnstructured(t *testing.T, def string) *unstructured.Unstructured {
+	data, err := yaml.YAMLToJSON([]byte(def))
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj := &unstructured.Unstructured{}
+	if err := obj.UnmarshalJSON(data); err != nil {
+		t.Fatal(err)
+	}
+	return obj
+}
+
+// syncDefs syncs the multidoc YAML given to the cluster, as the sync
+// set "testset".
+func syncDefs(t *testing.T, kube *Cluster, defs string) error {
+	namespacer, err := NewNamespacer(kube.client.coreClient.Discovery(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifests := NewManifests(namespacer, log.NewLogfmtLogger(os.Stdout))
+	parsed, err := kresource.ParseMultidoc([]byte(defs), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resources, err := manifests.setEffectiveNamespaces(parsed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resourcesByID := map[string]resource.Resource{}
+	for _, r := range resources {
+		resourcesByID[r.ResourceID().String()] = r
+	}
+	return sync.Sync("testset", resourcesByID, kube)
+}
+
+func TestAssessHealth(t *testing.T) {
+	for _, c := range []struct {
+		name     string
+		def      string
+		expected cluster.Health
+	}{
+		{"configmap", `
+apiVersion: v1
+kind: ConfigMap
+metadata: {name: config}
+`, cluster.Health{Status: cluster.HealthHealthy}},
+		{"deployment rolled out", `
+apiVersion: apps/v1
+kind: Deployment
+metadata: {name: app, generation: 2}
+spec: {replicas: 2}
+status: {observedGeneration: 2, replicas: 2, updatedReplicas: 2, readyReplicas: 2, availableReplicas: 2}
+`, cluster.Health{Status: cluster.HealthHealthy}},
+		{"deployment rolling out", `
+apiVersion: apps/v1
+kind: Deployment
+metadata: {name: app, generation: 2}
+spec: {replicas: 2}
+status: {observedGeneration: 2, replicas: 3, updatedReplicas: 1, readyReplicas: 2, availableReplicas: 2}
+`, cluster.Health{Status: cluster.HealthProgressing, Message: "1 of 2 replicas updated, 2 available"}},
+		{"deployment stuck", `
+apiVersion: apps/v1
+kind: Deployment
+metadata: {name: app, generation: 2}
+spec: {replicas: 2}
+status:
+  observedGeneration: 2
+  conditions:
+  - {type: Progressing, status: "False", message: "progress deadline exceeded"}
+`, cluster.Health{Status: cluster.HealthDegraded, Message: "progress deadline exceeded"}},
+		{"job complete", `
+apiVersion: batch/v1
+kind: Job
+metadata: {name: migrate}
+status:
+  succeeded: 1
+  conditions:
+  - {type: Complete, status: "True"}
+`, cluster.Health{Status: cluster.HealthHealthy}},
+		{"job failed", `
+apiVersion: batch/v1
+kind: Job
+metadata: {name: migrate}
+status:
+  failed: 6
+  conditions:
+  - {type: Failed, status: "True", reason: BackoffLimitExceeded, message: "Job has reached the specified backoff limit"}
+`, cluster.Health{Status: cluster.HealthDegraded, Message: "Job has reached the specified backoff limit"}},
+		{"job running", `
+apiVersion: batch/v1
+kind: Job
+metadata: {name: migrate}
+status: {active: 1}
+`, cluster.Health{Status: cluster.HealthProgressing, Message: "1 active, 0 succeeded, 0 failed"}},
+		{"pvc pending", `
+apiVersion: v1
+kind: PersistentVolumeClaim
+metadata: {name: data}
+status: {phase: Pending}
+`, cluster.Health{Status: cluster.HealthProgressing, Message: "waiting for the claim to be bound"}},
+		{"pvc bound", `
+apiVersion: v1
+kind: PersistentVolumeClaim
+metadata: {name: data}
+status: {phase: Bound}
+`, cluster.Health{Status: cluster.HealthHealthy}},
+		{"service waiting for load balancer", `
+apiVersion: v1
+kind: Service
+metadata: {name: web}
+spec: {type: LoadBalancer}
+status: {loadBalancer: {}}
+`, cluster.Health{Status: cluster.HealthProgressing, Message: "waiting for a load balancer to be provisioned"}},
+		{"service with load balancer", `
+apiVersion: v1
+kind: Service
+metadata: {name: web}
+spec: {type: LoadBalancer}
+status: {loadBalancer: {ingress: [{ip: 10.0.0.1}]}}
+`, cluster.Health{Status: cluster.HealthHealthy}},
+		{"cluster IP service", `
+apiVersion: v1
+kind: Service
+metadata: {name: web}
+spec: {type: ClusterIP}
+`, cluster.Health{Status: cluster.HealthHealthy}},
+		{"ingress without address", `
+apiVersion: extensions/v1beta1
+kind: Ingress
+metadata: {name: web}
+`, cluster.Health{Status: cluster.HealthProgressing, Message: "waiting for the ingress to be given an address"}},
+		{"helmrelease failed", `
+apiVersion: helm.fluxcd.io/v1
+kind: HelmRelease
+metadata: {name: podinfo, generation: 1}
+status:
+  observedGeneration: 1
+  releaseStatus: failed
+  conditions:
+  - {type: Released, status: "False", message: "chart install failed"}
+`, cluster.Health{Status: cluster.HealthDegraded, Message: "chart install failed"}},
+		{"custom resource ready", `
+apiVersion: example.com/v1
+kind: Widget
+metadata: {name: w, generation: 3}
+status:
+  observedGeneration: 3
+  conditions:
+  - {type: Ready, status: "True"}
+`, cluster.Health{Status: cluster.HealthHealthy}},
+		{"custom resource not ready", `
+apiVersion: example.com/v1
+kind: Widget
+metadata: {name: w}
+status:
+  conditions:
+  - {type: Ready, status: "False", reason: DependencyMissing}
+`, cluster.Health{Status: cluster.HealthDegraded, Message: "DependencyMissing"}},
+		{"custom resource not yet observed", `
+apiVersion: example.com/v1
+kind: Widget
+metadata: {name: w, generation: 4}
+status:
+  observedGeneration: 3
+  conditions:
+  - {type: Ready, status: "True"}
+`, cluster.Health{Status: cluster.HealthProgressing, Message: "latest change not yet observed by the controller"}},
+		{"custom resource stalled", `
+apiVersion: example.com/v1
+kind: Widget
+metadata: {name: w}
+status:
+  conditions:
+  - {type: Stalled, status: "True", message: "quota exceeded"}
+`, cluster.Health{Status: cluster.HealthDegraded, Message: "quota exceeded"}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			assert.Equal(t, c.expected, assessHealth(mustUnstructured(t, c.def)))
+		})
+	}
+}
+
+func TestResourceHealth(t *testing.T) {
+	kube, _, cancel := setup(t)
+	defer cancel()
+
+	const defs = `---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: foobar
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: dep1
+  namespace: foobar
+spec:
+  replicas: 1
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: broken
+  namespace: foobar
+  annotations:
+    error: fails to apply
+`
+	assert.Error(t, syncDefs(t, kube, defs))
+
+	health, err := kube.ResourceHealth(context.Background(), "")
+	assert.NoError(t, err)
+	if !assert.Len(t, health, 3) {
+		return
+	}
+	assert.Equal(t, resource.MustParseID("<cluster>:namespace/foobar"), health[0].ResourceID)
+	assert.Equal(t, cluster.HealthHealthy, health[0].Health.Status)
+	assert.NoError(t, health[0].SyncError)
+
+	assert.Equal(t, resource.MustParseID("foobar:deployment/broken"), health[1].ResourceID)
+	assert.Equal(t, cluster.HealthUnknown, health[1].Health.Status)
+	assert.EqualError(t, health[1].SyncError, "fails to apply")
+
+	assert.Equal(t, resource.MustParseID("foobar:deployment/dep1"), health[2].ResourceID)
+	assert.Equal(t, cluster.Health{Status: cluster.HealthProgressing, Message: "0 of 1 replicas updated, 0 available"}, health[2].Health)
+	assert.NoError(t, health[2].SyncError)
+
+	// Restricting to a namespace leaves out cluster-scoped resources
+	health, err = kube.ResourceHealth(context.Background(), "foobar")
+	assert.NoError(t, err)
+	assert.Len(t, health, 2)
+}
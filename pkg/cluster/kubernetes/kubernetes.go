@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"sync"
+	"time"
 
 	hrclient "github.com/fluxcd/helm-operator/pkg/client/clientset/versioned"
 	"github.com/go-kit/kit/log"
@@ -93,6 +94,21 @@ type Cluster struct {
 	GC bool
 	// dry run garbage collection without syncing
 	DryGC bool
+	// The most resources garbage collection may delete in one sync;
+	// if more would be deleted, none are. Zero means no limit.
+	GCMaxDeletions int
+	// Kinds of resource (e.g., Namespace) that garbage collection
+	// never deletes
+	GCKindDenylist []string
+	// How long each sync wave is given to become healthy before the
+	// sync gives up on applying later waves
+	SyncWaveTimeout time.Duration
+	// How long a sync may wait for sync waves to become healthy;
+	// waves still to be applied after that are left to the next sync
+	SyncTimeout time.Duration
+	// Look for changes made to resources in the cluster since they
+	// were applied, when syncing
+	DriftDetection bool
 
 	client  ExtendedClient
 	applier Applier
@@ -102,10 +118,25 @@ type Cluster struct {
 	sshKeyRing ssh.KeyRing
 
 	// syncErrors keeps a record of all per-resource errors during
-	// the sync from Git repo to the cluster.
-	syncErrors   map[resource.ID]error
+	// the sync from Git repo to the cluster, by sync set name.
+	syncErrors   map[string]map[resource.ID]error
 	muSyncErrors sync.RWMutex
 
+	// drift keeps a record of the drift found during the last sync of
+	// each sync set, when drift detection is enabled.
+	drift   map[string]map[resource.ID]cluster.ResourceDrift
+	muDrift sync.RWMutex
+
+	// waves records when each sync wave not yet healthy was first
+	// waited for, by sync set name and wave.
+	waves   map[string]waveProgress
+	muWaves sync.Mutex
+
+	// pruneAborted records the resources garbage collection last
+	// refused to delete, by sync set name.
+	pruneAborted   map[string][]resource.ID
+	muPruneAborted sync.Mutex
+
 	allowedNamespaces map[string]struct{}
 	loggedAllowedNS   map[string]bool // to keep track of whether we've logged a problem with seeing an allowed namespace
 
@@ -141,6 +172,7 @@ func NewCluster(client ExtendedClient, applier Applier, sshKeyRing ssh.KeyRing,
 // They do not necessarily have to be returned in the order requested.
 func (c *Cluster) SomeWorkloads(ctx context.Context, ids []resource.ID) (res []cluster.Workload, err error) {
 	var workloads []cluster.Workload
+	nodePlatforms := c.nodePlatforms()
 	for _, id := range ids {
 		if !c.IsAllowedResource(id) {
 			continue
@@ -162,9 +194,9 @@ func (c *Cluster) SomeWorkloads(ctx context.Context, ids []resource.ID) (res []c
 		}
 
 		if !isAddon(workload) {
-			c.muSyncErrors.RLock()
-			workload.syncError = c.syncErrors[id]
-			c.muSyncErrors.RUnlock()
+			workload.syncError = c.syncErrorFor(id)
+			workload.drift = c.driftFor(id)
+			workload.platforms = schedulablePlatforms(workload.podTemplate.Spec, nodePlatforms)
 			workloads = append(workloads, workload.toClusterWorkload(id))
 		}
 	}
@@ -192,6 +224,7 @@ func (c *Cluster) AllWorkloads(ctx context.Context, restrictToNamespace string)
 	}
 
 	var allworkloads []cluster.Workload
+	nodePlatforms := c.nodePlatforms()
 	for _, ns := range namespaces {
 		for kind, resourceKind := range resourceKinds {
 			workloads, err := resourceKind.getWorkloads(ctx, c, ns)
@@ -212,9 +245,9 @@ func (c *Cluster) AllWorkloads(ctx context.Context, restrictToNamespace string)
 			for _, workload := range workloads {
 				if !isAddon(workload) {
 					id := resource.MakeID(workload.GetNamespace(), kind, workload.GetName())
-					c.muSyncErrors.RLock()
-					workload.syncError = c.syncErrors[id]
-					c.muSyncErrors.RUnlock()
+					workload.syncError = c.syncErrorFor(id)
+					workload.drift = c.driftFor(id)
+					workload.platforms = schedulablePlatforms(workload.podTemplate.Spec, nodePlatforms)
 					allworkloads = append(allworkloads, workload.toClusterWorkload(id))
 				}
 			}
@@ -224,13 +257,33 @@ func (c *Cluster) AllWorkloads(ctx context.Context, restrictToNamespace string)
 	return allworkloads, nil
 }
 
-func (c *Cluster) setSyncErrors(errs cluster.SyncError) {
+// setSyncErrors replaces the errors recorded for the sync set
+// given. Errors are kept per sync set, so that syncing one set
+// doesn't forget the errors from another.
+func (c *Cluster) setSyncErrors(syncSetName string, errs cluster.SyncError) {
 	c.muSyncErrors.Lock()
 	defer c.muSyncErrors.Unlock()
-	c.syncErrors = make(map[resource.ID]error)
+	if c.syncErrors == nil {
+		c.syncErrors = make(map[string]map[resource.ID]error)
+	}
+	setErrors := make(map[resource.ID]error)
 	for _, e := range errs {
-		c.syncErrors[e.ResourceID] = e.Error
+		setErrors[e.ResourceID] = e.Error
+	}
+	c.syncErrors[syncSetName] = setErrors
+}
+
+// syncErrorFor returns the error recorded for the resource given
+// during the last sync of any sync set, or nil if there is none.
+func (c *Cluster) syncErrorFor(id resource.ID) error {
+	c.muSyncErrors.RLock()
+	defer c.muSyncErrors.RUnlock()
+	for _, setErrors := range c.syncErrors {
+		if err, ok := setErrors[id]; ok {
+			return err
+		}
 	}
+	return nil
 }
 
 func (c *Cluster) Ping() error {
@@ -0,0 +1,333 @@
+package kubernetes
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+	apiresource "k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/fluxcd/flux/pkg/cluster"
+	"github.com/fluxcd/flux/pkg/policy"
+)
+
+// kubectl records the configuration it last applied in this
+// annotation; we use it to tell which fields would be removed by an
+// apply.
+const lastAppliedAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+
+// Fields that are expected to differ between the cluster and git, and
+// so are left out of plans. The checksum changes with any change to
+// the manifest, including those (like comments) which make no
+// difference to the resource. Resources are read from the cluster at
+// the preferred version of their API group, which needn't be the
+// version given in git.
+var ignoredPlanFields = map[string]bool{
+	"apiVersion": true,
+	fieldPath(fieldPath("metadata", "annotations"), checksumAnnotation):    true,
+	fieldPath(fieldPath("metadata", "annotations"), lastAppliedAnnotation): true,
+}
+
+// PlanSync works out what Sync would do with the sync set given,
+// without applying anything. Each resource is compared with the
+// corresponding resource in the cluster, if there is one; and if
+// garbage collection is enabled, the resources it would delete are
+// included.
+func (c *Cluster) PlanSync(syncSet cluster.SyncSet) ([]cluster.ResourcePlan, error) {
+	clusterResources, err := c.getAllowedResourcesBySelector("")
+	if err != nil {
+		return nil, errors.Wrap(err, "collating resources in cluster for sync plan")
+	}
+
+	var plan []cluster.ResourcePlan
+	checksums := map[string]string{}
+	for _, res := range syncSet.Resources {
+		resID := res.ResourceID()
+		id := resID.String()
+		if !c.IsAllowedResource(resID) {
+			continue
+		}
+		checkHex := resourceChecksum(res)
+		checksums[id] = checkHex
+		if res.Policies().Has(policy.Ignore) {
+			continue
+		}
+		cres, exists := clusterResources[id]
+		if exists && cres.Policies().Has(policy.Ignore) {
+			continue
+		}
+
+		resBytes, err := applyMetadata(res, syncSet.Name, checkHex)
+		if err != nil {
+			return nil, err
+		}
+		var desired map[string]interface{}
+		if err := yaml.Unmarshal(resBytes, &desired); err != nil {
+			return nil, errors.Wrapf(err, "parsing resource %s from %s", id, res.Source())
+		}
+
+		p := cluster.ResourcePlan{ResourceID: resID, Source: res.Source()}
+		if !exists {
+			p.Action = cluster.PlanCreate
+		} else {
+			changes, err := diffResource(cres, desired)
+			if err != nil {
+				return nil, errors.Wrapf(err, "comparing resource %s with cluster", id)
+			}
+			p.Changes = changes
+			if len(changes) == 0 {
+				p.Action = cluster.PlanUnchanged
+			} else {
+				p.Action = cluster.PlanUpdate
+			}
+		}
+		plan = append(plan, p)
+	}
+
+	if c.GC {
+		marked, err := c.getAllowedGCMarkedResourcesInSyncSet(syncSet.Name)
+		if err != nil {
+			return nil, errors.Wrap(err, "collating resources in cluster for calculating garbage collection")
+		}
+		for id, res := range marked {
+			if _, ok := checksums[id]; !ok && c.pruneProtected(res) == "" {
+				plan = append(plan, cluster.ResourcePlan{
+					ResourceID: res.ResourceID(),
+					Source:     "<cluster>",
+					Action:     cluster.PlanDelete,
+				})
+			}
+		}
+	}
+
+	sort.Slice(plan, func(i, j int) bool {
+		return plan[i].ResourceID.String() < plan[j].ResourceID.String()
+	})
+	return plan, nil
+}
+
+// diffResource compares a resource in the cluster with the definition
+// that would be applied to it. Only the fields given in the definition
+// are considered, since the cluster fills in defaults and status; in
+// addition, fields that were last applied but are no longer in the
+// definition are reported as removed. The values of fields in Secrets,
+// and in the data of any resource, are redacted.
+func diffResource(live *kuberesource, desired map[string]interface{}) ([]cluster.FieldChange, error) {
+	// Round-trip through JSON so that numbers etc. are represented
+	// the same way on both sides.
+	liveBytes, err := json.Marshal(live.obj.Object)
+	if err != nil {
+		return nil, err
+	}
+	var liveObj map[string]interface{}
+	if err := json.Unmarshal(liveBytes, &liveObj); err != nil {
+		return nil, err
+	}
+
+	secret := live.obj.GetKind() == "Secret"
+	if secret {
+		desired = foldStringData(desired)
+	}
+
+	var changes []cluster.FieldChange
+	diffValues("", liveObj, desired, &changes)
+
+	if lastApplied := live.obj.GetAnnotations()[lastAppliedAnnotation]; lastApplied != "" {
+		var lastAppliedObj map[string]interface{}
+		if err := json.Unmarshal([]byte(lastApplied), &lastAppliedObj); err == nil {
+			diffRemoved("", liveObj, desired, lastAppliedObj, &changes)
+		}
+	}
+
+	for i := range changes {
+		if secret || isDataPath(changes[i].Path) {
+			changes[i] = cluster.FieldChange{Path: changes[i].Path, Redacted: true}
+		}
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes, nil
+}
+
+// foldStringData does to a Secret what the API server does: the
+// entries of `stringData` are encoded and put in `data`, and
+// `stringData` itself is dropped, since it's never returned.
+func foldStringData(secret map[string]interface{}) map[string]interface{} {
+	stringData, ok := secret["stringData"].(map[string]interface{})
+	if !ok {
+		return secret
+	}
+	folded := map[string]interface{}{}
+	for k, v := range secret {
+		if k != "stringData" {
+			folded[k] = v
+		}
+	}
+	data := map[string]interface{}{}
+	if d, ok := secret["data"].(map[string]interface{}); ok {
+		for k, v := range d {
+			data[k] = v
+		}
+	}
+	for k, v := range stringData {
+		if s, ok := v.(string); ok {
+			data[k] = base64.StdEncoding.EncodeToString([]byte(s))
+		}
+	}
+	folded["data"] = data
+	return folded
+}
+
+// isDataPath says whether a field path is in the data of a resource
+// (e.g., of a Secret or ConfigMap). Values there may be secret, so
+// they are not reported in plans, or as drift, which end up in events
+// and notifications.
+func isDataPath(path string) bool {
+	for _, field := range []string{"data", "stringData"} {
+		if path == field || strings.HasPrefix(path, field+".") || strings.HasPrefix(path, field+"[") {
+			return true
+		}
+	}
+	return false
+}
+
+// diffValues records the fields of `desired` that differ from `live`.
+func diffValues(path string, live, desired interface{}, changes *[]cluster.FieldChange) {
+	if ignoredPlanFields[path] {
+		return
+	}
+	switch d := desired.(type) {
+	case map[string]interface{}:
+		l, ok := live.(map[string]interface{})
+		if !ok {
+			break
+		}
+		for _, k := range sortedKeys(d) {
+			p := fieldPath(path, k)
+			if lv, ok := l[k]; ok {
+				diffValues(p, lv, d[k], changes)
+			} else if !ignoredPlanFields[p] && d[k] != nil {
+				*changes = append(*changes, cluster.FieldChange{Path: p, New: d[k]})
+			}
+		}
+		return
+	case []interface{}:
+		// Lists are compared item by item only when they are the
+		// same length; otherwise, the list as a whole is replaced.
+		l, ok := live.([]interface{})
+		if !ok || len(l) != len(d) {
+			break
+		}
+		for i := range d {
+			diffValues(fmt.Sprintf("%s[%d]", path, i), l[i], d[i], changes)
+		}
+		return
+	default:
+		if scalarsEqual(path, live, desired) {
+			return
+		}
+	}
+	*changes = append(*changes, cluster.FieldChange{Path: path, Old: live, New: desired})
+}
+
+// Resource quantities (e.g., in container resource limits and
+// requests) are normalised by the API server, so `cpu: 0.5` in a
+// manifest comes back as `cpu: 500m`, and `memory: 1024Mi` as
+// `memory: 1Gi`. This matches the paths of such fields.
+var quantityPath = regexp.MustCompile(`(^|\.)(limits|requests|hard|capacity|allocatable|default|defaultRequest|max|min|maxLimitRequestRatio)(\.[^.\[]+|\[".*"\])$|(^|\.)sizeLimit$`)
+
+// scalarsEqual says whether a value in the cluster is the same as the
+// value given in git, allowing for the normalisation done by the API
+// server: quantities are compared as quantities, and a number given as
+// a string (or vice versa, as for an int-or-string field) is compared
+// as a number.
+func scalarsEqual(path string, live, desired interface{}) bool {
+	if reflect.DeepEqual(live, desired) {
+		return true
+	}
+	l, lok := scalarString(live)
+	d, dok := scalarString(desired)
+	if !lok || !dok {
+		return false
+	}
+	if quantityPath.MatchString(path) {
+		lq, lerr := apiresource.ParseQuantity(l)
+		dq, derr := apiresource.ParseQuantity(d)
+		if lerr == nil && derr == nil {
+			return lq.Cmp(dq) == 0
+		}
+	}
+	_, lnum := live.(float64)
+	_, dnum := desired.(float64)
+	if lnum != dnum {
+		lf, lerr := strconv.ParseFloat(l, 64)
+		df, derr := strconv.ParseFloat(d, 64)
+		return lerr == nil && derr == nil && lf == df
+	}
+	return false
+}
+
+// scalarString gives the string form of a number or string, as
+// unmarshalled from JSON.
+func scalarString(v interface{}) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	}
+	return "", false
+}
+
+// diffRemoved records the fields that were last applied, but are not
+// in `desired`; applying `desired` would remove them.
+func diffRemoved(path string, live, desired, lastApplied map[string]interface{}, changes *[]cluster.FieldChange) {
+	for _, k := range sortedKeys(lastApplied) {
+		p := fieldPath(path, k)
+		lv, inLive := live[k]
+		if !inLive || ignoredPlanFields[p] {
+			continue
+		}
+		dv, inDesired := desired[k]
+		if !inDesired {
+			*changes = append(*changes, cluster.FieldChange{Path: p, Old: lv})
+			continue
+		}
+		lam, ok1 := lastApplied[k].(map[string]interface{})
+		lm, ok2 := lv.(map[string]interface{})
+		dm, ok3 := dv.(map[string]interface{})
+		if ok1 && ok2 && ok3 {
+			diffRemoved(p, lm, dm, lam, changes)
+		}
+	}
+}
+
+// fieldPath appends a field name to a path. Names that contain dots
+// (e.g., annotation keys) are quoted, so the path isn't ambiguous.
+func fieldPath(path, name string) string {
+	if strings.Contains(name, ".") {
+		return fmt.Sprintf("%s[%q]", path, name)
+	}
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,271 @@
+package kubernetes
+
+import (
+	"os"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/fluxcd/flux/pkg/cluster"
+	kresource "github.com/fluxcd/flux/pkg/cluster/kubernetes/resource"
+	"github.com/fluxcd/flux/pkg/resource"
+	"github.com/fluxcd/flux/pkg/sync"
+)
+
+func TestDiffResource(t *testing.T) {
+	live := &kuberesource{obj: &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata": map[string]interface{}{
+			"name":      "dep",
+			"namespace": "default",
+			"uid":       "1234",
+			"annotations": map[string]interface{}{
+				checksumAnnotation:    "old",
+				lastAppliedAnnotation: `{"metadata":{"labels":{"app":"dep"}},"spec":{"replicas":1,"paused":false}}`,
+			},
+			"labels": map[string]interface{}{"app": "dep"},
+		},
+		"spec": map[string]interface{}{
+			"replicas": int64(1),
+			"paused":   false,
+			"template": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"containers": []interface{}{
+						map[string]interface{}{"name": "app", "image": "app:1", "imagePullPolicy": "IfNotPresent"},
+					},
+				},
+			},
+		},
+		"status": map[string]interface{}{"replicas": int64(1)},
+	}}}
+
+	desired := map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata": map[string]interface{}{
+			"name":        "dep",
+			"namespace":   "default",
+			"annotations": map[string]interface{}{checksumAnnotation: "new"},
+		},
+		"spec": map[string]interface{}{
+			"replicas": float64(1),
+			"template": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"containers": []interface{}{
+						map[string]interface{}{"name": "app", "image": "app:2"},
+					},
+				},
+			},
+		},
+	}
+
+	changes, err := diffResource(live, desired)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []cluster.FieldChange{
+		{Path: "metadata.labels", Old: map[string]interface{}{"app": "dep"}},
+		{Path: "spec.paused", Old: false},
+		{Path: "spec.template.spec.containers[0].image", Old: "app:1", New: "app:2"},
+	}, changes)
+}
+
+func TestDiffResource_Normalised(t *testing.T) {
+	liveRequests := map[string]interface{}{"cpu": "500m", "memory": "512Mi"}
+	live := &kuberesource{obj: &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata":   map[string]interface{}{"name": "dep", "namespace": "default"},
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"containers": []interface{}{
+						map[string]interface{}{
+							"name": "app",
+							"resources": map[string]interface{}{
+								"limits":   map[string]interface{}{"cpu": "1", "memory": "1Gi"},
+								"requests": liveRequests,
+							},
+						},
+					},
+				},
+			},
+		},
+	}}}
+
+	// The API server normalises quantities, so these are the same
+	// as what's in the cluster ...
+	desired := map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata":   map[string]interface{}{"name": "dep", "namespace": "default"},
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"containers": []interface{}{
+						map[string]interface{}{
+							"name": "app",
+							"resources": map[string]interface{}{
+								"limits":   map[string]interface{}{"cpu": float64(1), "memory": "1024Mi"},
+								"requests": map[string]interface{}{"cpu": 0.5, "memory": "512Mi"},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	changes, err := diffResource(live, desired)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, changes)
+
+	// ... but a different quantity is still a change
+	liveRequests["cpu"] = "250m"
+	changes, err = diffResource(live, desired)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []cluster.FieldChange{
+		{Path: "spec.template.spec.containers[0].resources.requests.cpu", Old: "250m", New: 0.5},
+	}, changes)
+}
+
+func TestDiffResource_Redacted(t *testing.T) {
+	live := &kuberesource{obj: &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"metadata":   map[string]interface{}{"name": "creds", "namespace": "default"},
+		"type":       "Opaque",
+		"data":       map[string]interface{}{"password": "aHVudGVyMg=="},
+	}}}
+	desired := map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"metadata":   map[string]interface{}{"name": "creds", "namespace": "default"},
+		"type":       "kubernetes.io/basic-auth",
+		"data":       map[string]interface{}{"password": "c3dvcmRmaXNo"},
+		"stringData": map[string]interface{}{"username": "admin"},
+	}
+	changes, err := diffResource(live, desired)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// stringData is compared as the API server stores it, in data
+	assert.Equal(t, []cluster.FieldChange{
+		{Path: "data.password", Redacted: true},
+		{Path: "data.username", Redacted: true},
+		{Path: "type", Redacted: true},
+	}, changes)
+
+	// Data in other kinds of resource is redacted too
+	live.obj.SetKind("ConfigMap")
+	desired["kind"] = "ConfigMap"
+	changes, err = diffResource(live, desired)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []cluster.FieldChange{
+		{Path: "data.password", Redacted: true},
+		{Path: "stringData", Redacted: true},
+		{Path: "type", Old: "Opaque", New: "kubernetes.io/basic-auth"},
+	}, changes)
+}
+
+func TestPlanSync(t *testing.T) {
+	const ns = `---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: foobar
+`
+	const dep1 = `---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: dep1
+  namespace: foobar
+`
+	const dep1Scaled = `---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: dep1
+  namespace: foobar
+spec:
+  replicas: 3
+`
+	const dep2 = `---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: dep2
+  namespace: foobar
+`
+	const dep3 = `---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: dep3
+  namespace: foobar
+`
+
+	kube, _, cancel := setup(t)
+	defer cancel()
+	kube.GC = true
+
+	namespacer, err := NewNamespacer(kube.client.coreClient.Discovery(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifests := NewManifests(namespacer, log.NewLogfmtLogger(os.Stdout))
+	parse := func(defs string) map[string]resource.Resource {
+		kms, err := kresource.ParseMultidoc([]byte(defs), "test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resources, err := manifests.setEffectiveNamespaces(kms)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resources
+	}
+
+	if err := sync.Sync("testset", parse(ns+dep1+dep2), kube); err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := sync.Plan("testset", parse(ns+dep1Scaled+dep3), kube)
+	if err != nil {
+		t.Fatal(err)
+	}
+	actions := map[string]string{}
+	for _, p := range plan {
+		actions[p.ResourceID.String()] = p.Action
+	}
+	assert.Equal(t, map[string]string{
+		"<cluster>:namespace/foobar": cluster.PlanUnchanged,
+		"foobar:deployment/dep1":     cluster.PlanUpdate,
+		"foobar:deployment/dep2":     cluster.PlanDelete,
+		"foobar:deployment/dep3":     cluster.PlanCreate,
+	}, actions)
+	for _, p := range plan {
+		if p.ResourceID.String() == "foobar:deployment/dep1" {
+			assert.Equal(t, []cluster.FieldChange{{Path: "spec", New: map[string]interface{}{"replicas": float64(3)}}}, p.Changes)
+		}
+	}
+
+	// Nothing should have been applied
+	marked, err := kube.getAllowedGCMarkedResourcesInSyncSet("testset")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, hasDep2 := marked["foobar:deployment/dep2"]
+	_, hasDep3 := marked["foobar:deployment/dep3"]
+	assert.True(t, hasDep2)
+	assert.False(t, hasDep3)
+}
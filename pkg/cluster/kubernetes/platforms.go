@@ -0,0 +1,197 @@
+package kubernetes
+
+import (
+	"sort"
+
+	apiv1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/fluxcd/flux/pkg/image"
+)
+
+// The node labels giving a node's operating system and CPU
+// architecture; the beta labels are still set, and may be used in
+// node selectors, in older clusters.
+var (
+	osLabels   = []string{"kubernetes.io/os", "beta.kubernetes.io/os"}
+	archLabels = []string{"kubernetes.io/arch", "beta.kubernetes.io/arch"}
+)
+
+// The operating system pods are taken to run on, if the nodes aren't
+// known and the pod spec doesn't name one.
+const defaultOS = "linux"
+
+// nodePlatforms returns the platforms (operating system and CPU
+// architecture) of the nodes in the cluster, or nil if they can't be
+// listed (e.g., because fluxd isn't allowed to).
+func (c *Cluster) nodePlatforms() []image.Platform {
+	nodes, err := c.client.CoreV1().Nodes().List(meta_v1.ListOptions{})
+	if err != nil {
+		if !apierrors.IsForbidden(err) {
+			c.logger.Log("warning", "unable to list nodes to find their platforms", "err", err)
+		}
+		return nil
+	}
+	found := map[image.Platform]bool{}
+	for _, node := range nodes.Items {
+		p := image.Platform{
+			OS:   nodeLabel(node, osLabels, node.Status.NodeInfo.OperatingSystem),
+			Arch: nodeLabel(node, archLabels, node.Status.NodeInfo.Architecture),
+		}
+		if p.OS != "" && p.Arch != "" {
+			found[p] = true
+		}
+	}
+	var platforms []image.Platform
+	for p := range found {
+		platforms = append(platforms, p)
+	}
+	sort.Slice(platforms, func(i, j int) bool {
+		return platforms[i].String() < platforms[j].String()
+	})
+	return platforms
+}
+
+// nodeLabel returns the value of the first of the labels given that
+// the node has, or the value given if it has none of them.
+func nodeLabel(node apiv1.Node, labels []string, otherwise string) string {
+	for _, label := range labels {
+		if v, ok := node.GetLabels()[label]; ok {
+			return v
+		}
+	}
+	return otherwise
+}
+
+// schedulablePlatforms works out the platforms, of those given for
+// the nodes, that pods with the spec given can be scheduled onto;
+// i.e., which aren't ruled out by the pod's node selector or required
+// node affinity. Only the operating system and architecture labels
+// are considered. If the nodes' platforms aren't known, those named
+// in the spec are returned (which may be none), on Linux unless the
+// spec names another operating system.
+func schedulablePlatforms(spec apiv1.PodSpec, nodePlatforms []image.Platform) []image.Platform {
+	candidates := nodePlatforms
+	if candidates == nil {
+		candidates = namedPlatforms(spec)
+	}
+	var platforms []image.Platform
+	for _, p := range candidates {
+		if platformSchedulable(spec, p) {
+			platforms = append(platforms, p)
+		}
+	}
+	return platforms
+}
+
+// namedPlatforms returns the platforms made from the operating
+// systems and architectures mentioned in a pod spec's node selector,
+// or its required node affinity.
+func namedPlatforms(spec apiv1.PodSpec) []image.Platform {
+	oss := namedValues(spec, osLabels)
+	if len(oss) == 0 {
+		oss = []string{defaultOS}
+	}
+	var platforms []image.Platform
+	for _, osName := range oss {
+		for _, arch := range namedValues(spec, archLabels) {
+			platforms = append(platforms, image.Platform{OS: osName, Arch: arch})
+		}
+	}
+	return platforms
+}
+
+// namedValues returns the values given for any of the labels in a pod
+// spec's node selector, or its required node affinity.
+func namedValues(spec apiv1.PodSpec, labels []string) []string {
+	found := map[string]bool{}
+	for _, label := range labels {
+		if v, ok := spec.NodeSelector[label]; ok {
+			found[v] = true
+		}
+	}
+	for _, term := range requiredNodeSelectorTerms(spec) {
+		for _, expr := range term.MatchExpressions {
+			if contains(labels, expr.Key) && expr.Operator == apiv1.NodeSelectorOpIn {
+				for _, v := range expr.Values {
+					found[v] = true
+				}
+			}
+		}
+	}
+	var values []string
+	for v := range found {
+		values = append(values, v)
+	}
+	sort.Strings(values)
+	return values
+}
+
+// platformSchedulable says whether a node with the platform given
+// would satisfy the pod spec's node selector and required node
+// affinity, as far as the operating system and architecture go.
+func platformSchedulable(spec apiv1.PodSpec, p image.Platform) bool {
+	for key, v := range spec.NodeSelector {
+		if value, ok := platformLabel(p, key); ok && v != value {
+			return false
+		}
+	}
+	terms := requiredNodeSelectorTerms(spec)
+	if len(terms) == 0 {
+		return true
+	}
+	// The terms are ORed, and the expressions in each term ANDed.
+terms:
+	for _, term := range terms {
+		for _, expr := range term.MatchExpressions {
+			value, ok := platformLabel(p, expr.Key)
+			if !ok {
+				continue
+			}
+			switch expr.Operator {
+			case apiv1.NodeSelectorOpIn:
+				if !contains(expr.Values, value) {
+					continue terms
+				}
+			case apiv1.NodeSelectorOpNotIn:
+				if contains(expr.Values, value) {
+					continue terms
+				}
+			case apiv1.NodeSelectorOpDoesNotExist:
+				continue terms
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// platformLabel gives the value a node with the platform given would
+// have for the label, if it's one of the operating system or
+// architecture labels.
+func platformLabel(p image.Platform, key string) (string, bool) {
+	switch {
+	case contains(osLabels, key):
+		return p.OS, true
+	case contains(archLabels, key):
+		return p.Arch, true
+	}
+	return "", false
+}
+
+func requiredNodeSelectorTerms(spec apiv1.PodSpec) []apiv1.NodeSelectorTerm {
+	if spec.Affinity == nil || spec.Affinity.NodeAffinity == nil || spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+		return nil
+	}
+	return spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,112 @@
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	apiv1 "k8s.io/api/core/v1"
+
+	"github.com/fluxcd/flux/pkg/image"
+)
+
+func TestSchedulablePlatforms(t *testing.T) {
+	require := func(op apiv1.NodeSelectorOperator, archs ...string) *apiv1.Affinity {
+		return &apiv1.Affinity{
+			NodeAffinity: &apiv1.NodeAffinity{
+				RequiredDuringSchedulingIgnoredDuringExecution: &apiv1.NodeSelector{
+					NodeSelectorTerms: []apiv1.NodeSelectorTerm{
+						{
+							MatchExpressions: []apiv1.NodeSelectorRequirement{
+								{Key: "kubernetes.io/os", Operator: apiv1.NodeSelectorOpIn, Values: []string{"linux"}},
+								{Key: "kubernetes.io/arch", Operator: op, Values: archs},
+							},
+						},
+					},
+				},
+			},
+		}
+	}
+	linux := func(arch string) image.Platform {
+		return image.Platform{OS: "linux", Arch: arch}
+	}
+	windows := image.Platform{OS: "windows", Arch: "amd64"}
+	nodePlatforms := []image.Platform{linux("amd64"), linux("arm"), linux("arm64")}
+
+	for _, tc := range []struct {
+		name          string
+		spec          apiv1.PodSpec
+		nodePlatforms []image.Platform
+		expected      []image.Platform
+	}{
+		{
+			name:          "unconstrained",
+			spec:          apiv1.PodSpec{},
+			nodePlatforms: nodePlatforms,
+			expected:      nodePlatforms,
+		},
+		{
+			name:          "node selector",
+			spec:          apiv1.PodSpec{NodeSelector: map[string]string{"kubernetes.io/arch": "arm64"}},
+			nodePlatforms: nodePlatforms,
+			expected:      []image.Platform{linux("arm64")},
+		},
+		{
+			name:          "beta node selector",
+			spec:          apiv1.PodSpec{NodeSelector: map[string]string{"beta.kubernetes.io/arch": "amd64"}},
+			nodePlatforms: nodePlatforms,
+			expected:      []image.Platform{linux("amd64")},
+		},
+		{
+			name:          "node affinity in",
+			spec:          apiv1.PodSpec{Affinity: require(apiv1.NodeSelectorOpIn, "amd64", "arm64", "s390x")},
+			nodePlatforms: nodePlatforms,
+			expected:      []image.Platform{linux("amd64"), linux("arm64")},
+		},
+		{
+			name:          "node affinity not in",
+			spec:          apiv1.PodSpec{Affinity: require(apiv1.NodeSelectorOpNotIn, "arm")},
+			nodePlatforms: nodePlatforms,
+			expected:      []image.Platform{linux("amd64"), linux("arm64")},
+		},
+		{
+			name:          "mixed operating systems, unconstrained",
+			spec:          apiv1.PodSpec{},
+			nodePlatforms: []image.Platform{linux("amd64"), windows},
+			expected:      []image.Platform{linux("amd64"), windows},
+		},
+		{
+			name:          "mixed operating systems, node selector",
+			spec:          apiv1.PodSpec{NodeSelector: map[string]string{"kubernetes.io/os": "windows"}},
+			nodePlatforms: []image.Platform{linux("amd64"), windows},
+			expected:      []image.Platform{windows},
+		},
+		{
+			name:          "mixed operating systems, node affinity",
+			spec:          apiv1.PodSpec{Affinity: require(apiv1.NodeSelectorOpIn, "amd64")},
+			nodePlatforms: []image.Platform{linux("amd64"), linux("arm64"), windows},
+			expected:      []image.Platform{linux("amd64")},
+		},
+		{
+			name:          "nodes unknown, unconstrained",
+			spec:          apiv1.PodSpec{},
+			nodePlatforms: nil,
+			expected:      nil,
+		},
+		{
+			name:          "nodes unknown, node affinity",
+			spec:          apiv1.PodSpec{Affinity: require(apiv1.NodeSelectorOpIn, "arm64", "amd64")},
+			nodePlatforms: nil,
+			expected:      []image.Platform{linux("amd64"), linux("arm64")},
+		},
+		{
+			name:          "nodes unknown, node selector",
+			spec:          apiv1.PodSpec{NodeSelector: map[string]string{"kubernetes.io/os": "windows", "kubernetes.io/arch": "amd64"}},
+			nodePlatforms: nil,
+			expected:      []image.Platform{windows},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, schedulablePlatforms(tc.spec, tc.nodePlatforms))
+		})
+	}
+}
@@ -103,13 +103,13 @@ func (c ContainerImageMap) MapImageRef(image image.Ref) (map[string]string, bool
 	case c.AllDefined():
 		m[c.GetRegistry()] = image.Domain
 		m[c.GetRepository()] = image.Image
-		m[c.GetTag()] = image.Tag
+		m[c.GetTag()] = tagOf(image)
 	case c.RegistryRepository():
 		m[c.GetRegistry()] = image.Domain
-		m[c.GetRepository()] = image.Image + ":" + image.Tag
+		m[c.GetRepository()] = image.Image + ":" + tagOf(image)
 	case c.RepositoryTag():
 		m[c.GetRepository()] = image.Name.String()
-		m[c.GetTag()] = image.Tag
+		m[c.GetTag()] = tagOf(image)
 	case c.RepositoryOnly():
 		m[c.GetRepository()] = image.String()
 	default:
@@ -118,6 +118,16 @@ func (c ContainerImageMap) MapImageRef(image image.Ref) (map[string]string, bool
 	return m, true
 }
 
+// tagOf gives the value to use for an image's tag. If the image is
+// pinned, the digest goes after the tag, since charts usually put the
+// tag after the image name as it is.
+func tagOf(ref image.Ref) string {
+	if ref.Digest != "" {
+		return ref.Tag + "@" + ref.Digest
+	}
+	return ref.Tag
+}
+
 // HelmRelease echoes the generated type for the custom resource
 // definition. It's here so we can 1. get `baseObject` in there, and
 // 3. control the YAML serialisation of fields, which we can't do
@@ -274,14 +284,14 @@ func interpretAsContainer(m mapper) (image.Ref, ImageSetter, bool) {
 			case reggy && taggy:
 				m.set("registry", ref.Domain)
 				m.set("image", ref.Image)
-				m.set("tag", ref.Tag)
+				m.set("tag", tagOf(ref))
 				return
 			case reggy:
 				m.set("registry", ref.Domain)
-				m.set("image", ref.Name.Image+":"+ref.Tag)
+				m.set("image", ref.Name.Image+":"+tagOf(ref))
 			case taggy:
 				m.set("image", ref.Name.String())
-				m.set("tag", ref.Tag)
+				m.set("tag", tagOf(ref))
 			default:
 				m.set("image", ref.String())
 			}
@@ -340,14 +350,14 @@ func interpretAsImage(m mapper) (image.Ref, ImageSetter, bool) {
 			case reggy && taggy:
 				m.set("registry", ref.Domain)
 				m.set("repository", ref.Image)
-				m.set("tag", ref.Tag)
+				m.set("tag", tagOf(ref))
 				return
 			case reggy:
 				m.set("registry", ref.Domain)
-				m.set("repository", ref.Name.Image+":"+ref.Tag)
+				m.set("repository", ref.Name.Image+":"+tagOf(ref))
 			case taggy:
 				m.set("repository", ref.Name.String())
-				m.set("tag", ref.Tag)
+				m.set("tag", tagOf(ref))
 			default:
 				m.set("repository", ref.String())
 			}
@@ -418,7 +428,7 @@ func interpretMappedContainerImage(values map[string]interface{}, cim ContainerI
 						return imgRef, func(ref image.Ref) {
 							v.SetP(ref.Domain, cim.Registry)
 							v.SetP(ref.Image, cim.Repository)
-							v.SetP(ref.Tag, cim.Tag)
+							v.SetP(tagOf(ref), cim.Tag)
 						}, true
 					}
 				}
@@ -429,7 +439,7 @@ func interpretMappedContainerImage(values map[string]interface{}, cim ContainerI
 				if imgRef, err := image.ParseRef(reg + "/" + img); err == nil {
 					return imgRef, func(ref image.Ref) {
 						v.SetP(ref.Domain, cim.Registry)
-						v.SetP(ref.Name.Image+":"+ref.Tag, cim.Repository)
+						v.SetP(ref.Name.Image+":"+tagOf(ref), cim.Repository)
 					}, true
 				}
 			}
@@ -439,7 +449,7 @@ func interpretMappedContainerImage(values map[string]interface{}, cim ContainerI
 				if imgRef, err := image.ParseRef(img + ":" + tag); err == nil {
 					return imgRef, func(ref image.Ref) {
 						v.SetP(ref.Name.String(), cim.Repository)
-						v.SetP(ref.Tag, cim.Tag)
+						v.SetP(tagOf(ref), cim.Tag)
 					}, true
 				}
 			}
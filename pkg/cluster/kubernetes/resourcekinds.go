@@ -3,6 +3,7 @@ package kubernetes
 import (
 	"context"
 	"strings"
+	"time"
 
 	hr_v1 "github.com/fluxcd/helm-operator/pkg/apis/helm.fluxcd.io/v1"
 	apiapps "k8s.io/api/apps/v1"
@@ -53,6 +54,9 @@ type workload struct {
 	status      string
 	rollout     cluster.RolloutStatus
 	syncError   error
+	drift       *cluster.ResourceDrift
+	platforms   []image.Platform
+	readySince  time.Time
 	podTemplate apiv1.PodTemplateSpec
 }
 
@@ -103,6 +107,10 @@ func (w workload) toClusterWorkload(resourceID resource.ID) cluster.Workload {
 		Status:     w.status,
 		Rollout:    w.rollout,
 		SyncError:  w.syncError,
+		Drift:      w.drift,
+		Health:     w.health(),
+		Platforms:  w.platforms,
+		ReadySince: w.readySince,
 		Antecedent: antecedent,
 		Labels:     w.GetLabels(),
 		Policies:   policies,
@@ -159,6 +167,24 @@ func deploymentErrors(d *apiapps.Deployment) []string {
 	return errs
 }
 
+// The reason the deployment controller gives in the Progressing
+// condition once a rollout is complete.
+const newReplicaSetAvailableReason = "NewReplicaSetAvailable"
+
+// deploymentReadySince returns when a Deployment completed its latest
+// rollout, according to its Progressing condition; or zero, if it
+// hasn't (or doesn't say). The deployment controller updates the
+// condition only when its reason changes, so this doesn't move on
+// while the rollout stays complete.
+func deploymentReadySince(d *apiapps.Deployment) time.Time {
+	for _, cond := range d.Status.Conditions {
+		if cond.Type == apiapps.DeploymentProgressing && cond.Status == apiv1.ConditionTrue && cond.Reason == newReplicaSetAvailableReason {
+			return cond.LastUpdateTime.Time
+		}
+	}
+	return time.Time{}
+}
+
 func makeDeploymentWorkload(deployment *apiapps.Deployment) workload {
 	var status string
 	objectMeta, deploymentStatus := deployment.ObjectMeta, deployment.Status
@@ -183,12 +209,17 @@ func makeDeploymentWorkload(deployment *apiapps.Deployment) workload {
 			status = cluster.StatusError
 		}
 	}
+	var readySince time.Time
+	if status == cluster.StatusReady {
+		readySince = deploymentReadySince(deployment)
+	}
 	// apiVersion & kind must be set, since TypeMeta is not populated
 	deployment.APIVersion = "apps/v1"
 	deployment.Kind = "Deployment"
 	return workload{
 		status:      status,
 		rollout:     rollout,
+		readySince:  readySince,
 		podTemplate: deployment.Spec.Template,
 		k8sObject:   deployment}
 }
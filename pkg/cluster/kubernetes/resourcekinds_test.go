@@ -0,0 +1,57 @@
+package kubernetes
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	apiapps "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/fluxcd/flux/pkg/cluster"
+)
+
+func TestDeploymentReadySince(t *testing.T) {
+	completed := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	replicas := int32(2)
+	deployment := func(reason string) *apiapps.Deployment {
+		return &apiapps.Deployment{
+			Spec: apiapps.DeploymentSpec{Replicas: &replicas},
+			Status: apiapps.DeploymentStatus{
+				Replicas:          replicas,
+				UpdatedReplicas:   replicas,
+				ReadyReplicas:     replicas,
+				AvailableReplicas: replicas,
+				Conditions: []apiapps.DeploymentCondition{
+					{
+						Type:           apiapps.DeploymentAvailable,
+						Status:         apiv1.ConditionTrue,
+						LastUpdateTime: meta_v1.NewTime(completed.Add(-time.Hour)),
+					},
+					{
+						Type:           apiapps.DeploymentProgressing,
+						Status:         apiv1.ConditionTrue,
+						Reason:         reason,
+						LastUpdateTime: meta_v1.NewTime(completed),
+					},
+				},
+			},
+		}
+	}
+
+	w := makeDeploymentWorkload(deployment(newReplicaSetAvailableReason))
+	assert.Equal(t, cluster.StatusReady, w.status)
+	assert.True(t, completed.Equal(w.readySince), "ready since %s", w.readySince)
+
+	// Still rolling out, so not ready since any time
+	w = makeDeploymentWorkload(deployment("ReplicaSetUpdated"))
+	assert.True(t, w.readySince.IsZero(), "ready since %s", w.readySince)
+
+	// Not (yet) ready by its replica counts
+	d := deployment(newReplicaSetAvailableReason)
+	d.Status.UpdatedReplicas = 1
+	w = makeDeploymentWorkload(d)
+	assert.Equal(t, cluster.StatusUpdating, w.status)
+	assert.True(t, w.readySince.IsZero(), "ready since %s", w.readySince)
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/ryanuber/go-glob"
 	"io"
 	"os/exec"
+	"reflect"
 	"sort"
 	"strings"
 	"time"
@@ -48,6 +49,9 @@ const (
 // malformed).
 func (c *Cluster) Sync(syncSet cluster.SyncSet) error {
 	logger := log.With(c.logger, "method", "Sync")
+	// Waiting for sync waves to become healthy is given up on after
+	// this, and left to the next sync.
+	deadline := time.Now().Add(c.syncTimeout())
 
 	// Keep track of the checksum of each resource, so we can compare
 	// them during garbage collection.
@@ -60,9 +64,10 @@ func (c *Cluster) Sync(syncSet cluster.SyncSet) error {
 		return errors.Wrap(err, "collating resources in cluster for sync")
 	}
 
-	cs := makeChangeSet()
+	waves := syncWaves{}
 	var errs cluster.SyncError
 	var excluded []string
+	var drifts []cluster.ResourceDrift
 	for _, res := range syncSet.Resources {
 		resID := res.ResourceID()
 		id := resID.String()
@@ -72,8 +77,7 @@ func (c *Cluster) Sync(syncSet cluster.SyncSet) error {
 		}
 		// make a record of the checksum, whether we stage it to
 		// be applied or not, so that we don't delete it later.
-		csum := sha1.Sum(res.Bytes())
-		checkHex := hex.EncodeToString(csum[:])
+		checkHex := resourceChecksum(res)
 		checksums[id] = checkHex
 		if res.Policies().Has(policy.Ignore) {
 			logger.Log("info", "not applying resource; ignore annotation in file", "resource", res.ResourceID(), "source", res.Source())
@@ -86,29 +90,60 @@ func (c *Cluster) Sync(syncSet cluster.SyncSet) error {
 			logger.Log("info", "not applying resource; ignore annotation in cluster resource", "resource", cres.ResourceID())
 			continue
 		}
+		wave, err := syncWave(res)
+		if err != nil {
+			errs = append(errs, cluster.ResourceError{ResourceID: res.ResourceID(), Source: res.Source(), Error: err})
+			continue
+		}
 		resBytes, err := applyMetadata(res, syncSet.Name, checkHex)
-		if err == nil {
-			cs.stage("apply", res.ResourceID(), res.Source(), resBytes)
-		} else {
+		if err != nil {
 			errs = append(errs, cluster.ResourceError{ResourceID: res.ResourceID(), Source: res.Source(), Error: err})
 			break
 		}
+		if cres, ok := clusterResources[id]; ok && c.DriftDetection {
+			changes, err := detectDrift(cres, checkHex, resBytes)
+			if err != nil {
+				logger.Log("warning", "unable to check resource for drift", "resource", resID, "err", err)
+			} else if len(changes) > 0 {
+				reportOnly := res.Policies().Has(policy.DriftReportOnly) || cres.Policies().Has(policy.DriftReportOnly)
+				drifts = append(drifts, cluster.ResourceDrift{ResourceID: resID, Source: res.Source(), Changes: changes, Corrected: !reportOnly})
+				if reportOnly {
+					logger.Log("info", "not applying resource; drift is to be reported only", "resource", resID, "changes", len(changes))
+					continue
+				}
+				logger.Log("info", "correcting drift in resource", "resource", resID, "changes", len(changes))
+			}
+		}
+		waves.stage(wave, res.ResourceID(), res.Source(), resBytes)
 	}
 
 	if len(excluded) > 0 {
 		logger.Log("warning", "not applying resources; excluded by namespace constraints", "resources", strings.Join(excluded, ","))
 	}
 
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	// The errors are replaced rather than updated by a sync, so it's
+	// safe to keep using them after unlocking; which matters, since
+	// applying in waves may take a while.
 	c.muSyncErrors.RLock()
-	if applyErrs := c.applier.apply(logger, cs, c.syncErrors); len(applyErrs) > 0 {
+	errored := c.syncErrors[syncSet.Name]
+	c.muSyncErrors.RUnlock()
+	if applyErrs := c.applyWaves(logger, syncSet.Name, waves, errored, deadline); len(applyErrs) > 0 {
 		errs = append(errs, applyErrs...)
 	}
-	c.muSyncErrors.RUnlock()
+	c.recordDrift(syncSet.Name, drifts, errs)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
 	if c.GC || c.DryGC {
 		deleteErrs, gcFailure := c.collectGarbage(syncSet, checksums, logger, c.DryGC)
+		if aborted, ok := gcFailure.(cluster.PruneAbortedError); ok {
+			// The rest of the sync went ahead, so its errors
+			// still count
+			c.setSyncErrors(syncSet.Name, errs)
+			aborted.Errors = errs
+			return aborted
+		}
 		if gcFailure != nil {
 			return gcFailure
 		}
@@ -120,9 +155,10 @@ func (c *Cluster) Sync(syncSet cluster.SyncSet) error {
 		return nil
 	}
 
-	// It is expected that Cluster.Sync is invoked with *all* resources.
-	// Otherwise it will override previously recorded sync errors.
-	c.setSyncErrors(errs)
+	// It is expected that Cluster.Sync is invoked with *all* resources
+	// of the sync set. Otherwise it will override previously recorded
+	// sync errors.
+	c.setSyncErrors(syncSet.Name, errs)
 	return errs
 }
 
@@ -139,16 +175,18 @@ func (c *Cluster) collectGarbage(
 		return nil, errors.Wrap(err, "collating resources in cluster for calculating garbage collection")
 	}
 
+	var orphans []*kuberesource
 	for resourceID, res := range clusterResources {
 		actual := res.GetChecksum()
 		expected, ok := checksums[resourceID]
 
 		switch {
 		case !ok: // was not recorded as having been staged for application
-			c.logger.Log("info", "cluster resource not in resources to be synced; deleting", "dry-run", dryRun, "resource", resourceID)
-			if !dryRun {
-				orphanedResources.stage("delete", res.ResourceID(), "<cluster>", res.IdentifyingBytes())
+			if reason := c.pruneProtected(res); reason != "" {
+				c.logger.Log("info", "cluster resource not in resources to be synced; not deleting", "reason", reason, "dry-run", dryRun, "resource", resourceID)
+				continue
 			}
+			orphans = append(orphans, res)
 		case actual != expected:
 			c.logger.Log("warning", "resource to be synced has not been updated; skipping", "dry-run", dryRun, "resource", resourceID)
 			continue
@@ -158,9 +196,71 @@ func (c *Cluster) collectGarbage(
 		}
 	}
 
+	// A commit that removes (or moves) a whole directory of
+	// manifests would see everything in it deleted; refuse to delete
+	// more than the limit at once.
+	if c.GCMaxDeletions > 0 && len(orphans) > c.GCMaxDeletions {
+		aborted := cluster.PruneAbortedError{Max: c.GCMaxDeletions}
+		for _, res := range orphans {
+			aborted.Resources = append(aborted.Resources, res.ResourceID())
+		}
+		sort.Slice(aborted.Resources, func(i, j int) bool {
+			return aborted.Resources[i].String() < aborted.Resources[j].String()
+		})
+		if dryRun {
+			c.logger.Log("warning", aborted.Error(), "dry-run", dryRun)
+			return nil, nil
+		}
+		aborted.Repeated = c.recordPruneAborted(syncSet.Name, aborted.Resources)
+		return nil, aborted
+	}
+	if !dryRun {
+		c.recordPruneAborted(syncSet.Name, nil)
+	}
+
+	for _, res := range orphans {
+		c.logger.Log("info", "cluster resource not in resources to be synced; deleting", "dry-run", dryRun, "resource", res.ResourceID())
+		if !dryRun {
+			orphanedResources.stage("delete", res.ResourceID(), "<cluster>", res.IdentifyingBytes())
+		}
+	}
+
 	return c.applier.apply(logger, orphanedResources, nil), nil
 }
 
+// recordPruneAborted records the resources garbage collection of the
+// sync set given was aborted for (or none, if it went ahead), and
+// says whether it was aborted for the same resources last time.
+func (c *Cluster) recordPruneAborted(syncSetName string, ids []resource.ID) bool {
+	c.muPruneAborted.Lock()
+	defer c.muPruneAborted.Unlock()
+	if len(ids) == 0 {
+		delete(c.pruneAborted, syncSetName)
+		return false
+	}
+	if c.pruneAborted == nil {
+		c.pruneAborted = map[string][]resource.ID{}
+	}
+	last := c.pruneAborted[syncSetName]
+	c.pruneAborted[syncSetName] = ids
+	return reflect.DeepEqual(last, ids)
+}
+
+// pruneProtected says why the resource given must not be garbage
+// collected, or returns an empty string if it may be.
+func (c *Cluster) pruneProtected(res *kuberesource) string {
+	if v, _ := res.Policies().Get(policy.Prune); v == policy.PruneDisabled {
+		return fmt.Sprintf("%s%s annotation is %q", kresource.PolicyPrefix, policy.Prune, v)
+	}
+	kind := res.obj.GetKind()
+	for _, denied := range c.GCKindDenylist {
+		if strings.EqualFold(denied, kind) {
+			return fmt.Sprintf("kind %s is never garbage collected", kind)
+		}
+	}
+	return ""
+}
+
 // --- internals in support of Sync
 
 type kuberesource struct {
@@ -403,6 +503,13 @@ func applyMetadata(res resource.Resource, syncSetName, checksum string) ([]byte,
 	return bytes, nil
 }
 
+// resourceChecksum gives the checksum of a resource's manifest, as
+// recorded in the checksum annotation.
+func resourceChecksum(res resource.Resource) string {
+	csum := sha1.Sum(res.Bytes())
+	return hex.EncodeToString(csum[:])
+}
+
 func makeGCMark(syncSetName, resourceID string) string {
 	hasher := sha256.New()
 	hasher.Write([]byte(syncSetName))
@@ -589,6 +589,74 @@ metadata:
 		assert.NoError(t, err)
 	})
 
+	t.Run("sync won't delete resources protected from pruning", func(t *testing.T) {
+		kube, _, cancel := setup(t)
+		defer cancel()
+		kube.GC = true
+		kube.GCKindDenylist = []string{"namespace"}
+
+		const defs1Protected = `---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: dep1
+  namespace: foobar
+  annotations:
+    fluxcd.io/prune: disabled
+`
+		test(t, kube, ns1+defs1Protected+defs2, ns1+defs1Protected+defs2, false)
+		// dep2 is deleted; the namespace is denylisted, and dep1
+		// is annotated, so both are left alone.
+		test(t, kube, "", ns1+defs1Protected, false)
+	})
+
+	t.Run("sync aborts garbage collection with too many deletions", func(t *testing.T) {
+		kube, _, cancel := setup(t)
+		defer cancel()
+		kube.GC = true
+		kube.GCMaxDeletions = 2
+
+		test(t, kube, ns1+defs1+defs2+ns3+defs3, ns1+defs1+defs2+ns3+defs3, false)
+
+		// Removing four resources is over the limit, so none are
+		// deleted.
+		namespacer, err := NewNamespacer(kube.client.coreClient.Discovery(), "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		manifests := NewManifests(namespacer, log.NewLogfmtLogger(os.Stdout))
+		kms, err := kresource.ParseMultidoc([]byte(ns1), "test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resources, err := manifests.setEffectiveNamespaces(kms)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = sync.Sync("testset", resources, kube)
+		aborted, ok := err.(cluster.PruneAbortedError)
+		if !ok {
+			t.Fatalf("expected PruneAbortedError, got %v", err)
+		}
+		assert.Equal(t, 2, aborted.Max)
+		assert.Len(t, aborted.Resources, 4)
+		assert.Empty(t, aborted.Errors)
+		assert.False(t, aborted.Repeated)
+
+		// The same again is marked as repeated, so it's not reported
+		// every sync
+		err = sync.Sync("testset", resources, kube)
+		aborted, ok = err.(cluster.PruneAbortedError)
+		if !ok {
+			t.Fatalf("expected PruneAbortedError, got %v", err)
+		}
+		assert.True(t, aborted.Repeated)
+		test(t, kube, ns1+defs1+defs2+ns3+defs3, ns1+defs1+defs2+ns3+defs3, false)
+
+		// Within the limit, deletions go ahead.
+		test(t, kube, ns1+defs1+ns3, ns1+defs1+ns3, false)
+	})
+
 	t.Run("sync won't delete if apply failed", func(t *testing.T) {
 		kube, _, cancel := setup(t)
 		defer cancel()
@@ -0,0 +1,281 @@
+package kubernetes
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"gopkg.in/yaml.v2"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/fluxcd/flux/pkg/cluster"
+	"github.com/fluxcd/flux/pkg/policy"
+	"github.com/fluxcd/flux/pkg/resource"
+)
+
+// How long each sync wave is given to become healthy, if not
+// configured.
+const defaultSyncWaveTimeout = 5 * time.Minute
+
+// How long a sync may spend waiting for sync waves to become healthy,
+// if not configured. This is the default for `--sync-timeout`.
+const defaultSyncTimeout = time.Minute
+
+// How often the resources in a sync wave are checked while waiting
+// for them to become healthy. This is a variable so tests can
+// shorten it.
+var syncWavePollInterval = 5 * time.Second
+
+// syncWave returns the sync wave a resource belongs to, as given by
+// its `sync-wave` annotation. Resources without the annotation are in
+// wave 0; waves may be negative, to go before those.
+func syncWave(res resource.Resource) (int, error) {
+	v, ok := res.Policies().Get(policy.SyncWave)
+	if !ok || v == "" {
+		return 0, nil
+	}
+	wave, err := strconv.Atoi(strings.TrimSpace(v))
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s annotation %q: must be an integer", policy.SyncWave, v)
+	}
+	return wave, nil
+}
+
+// syncWaves splits the resources to be applied by the wave they're
+// in.
+type syncWaves map[int]changeSet
+
+func (w syncWaves) stage(wave int, id resource.ID, source string, bytes []byte) {
+	cs, ok := w[wave]
+	if !ok {
+		cs = makeChangeSet()
+		w[wave] = cs
+	}
+	cs.stage("apply", id, source, bytes)
+}
+
+func (w syncWaves) order() []int {
+	var waves []int
+	for n := range w {
+		waves = append(waves, n)
+	}
+	sort.Ints(waves)
+	return waves
+}
+
+func (c *Cluster) syncWaveTimeout() time.Duration {
+	if c.SyncWaveTimeout > 0 {
+		return c.SyncWaveTimeout
+	}
+	return defaultSyncWaveTimeout
+}
+
+func (c *Cluster) syncTimeout() time.Duration {
+	if c.SyncTimeout > 0 {
+		return c.SyncTimeout
+	}
+	return defaultSyncTimeout
+}
+
+// applyWaves applies each sync wave in turn. Before going on to the
+// next wave, it waits for all the resources in a wave to be healthy,
+// but only until the deadline given, so that a sync doesn't hold up
+// everything else; if a wave isn't healthy by then, later waves are
+// left to a later sync (which applies each wave again, and carries on
+// from where this one stopped). If the wave has errors, or doesn't
+// become healthy within the sync wave timeout (counted from the
+// first sync that waited for it), the remaining waves are not
+// applied, and an error is reported for each of their resources.
+// With only one wave (as when no resources are annotated), this is
+// just an apply.
+func (c *Cluster) applyWaves(logger log.Logger, syncSetName string, waves syncWaves, errored map[resource.ID]error, deadline time.Time) cluster.SyncError {
+	var errs cluster.SyncError
+	order := waves.order()
+	for i, n := range order {
+		cs := waves[n]
+		if len(order) > 1 {
+			logger.Log("info", "applying sync wave", "wave", n, "count", len(cs.objs["apply"]))
+		}
+		c.mu.Lock()
+		waveErrs := c.applier.apply(logger, cs, errored)
+		c.mu.Unlock()
+		if i == len(order)-1 {
+			return append(errs, waveErrs...)
+		}
+		if len(waveErrs) == 0 {
+			var waiting bool
+			waveErrs, waiting = c.waitForWave(logger, syncSetName, n, cs, deadline)
+			if waiting {
+				logger.Log("info", "sync wave not yet healthy; later waves will be applied by a later sync", "wave", n)
+				return errs
+			}
+		}
+		if len(waveErrs) > 0 {
+			errs = append(errs, waveErrs...)
+			for _, m := range order[i+1:] {
+				for _, obj := range waves[m].objs["apply"] {
+					errs = append(errs, cluster.ResourceError{
+						ResourceID: obj.ResourceID,
+						Source:     obj.Source,
+						Error:      fmt.Errorf("sync wave %d not applied, since sync wave %d did not complete", m, n),
+					})
+				}
+			}
+			logger.Log("warning", "sync wave did not complete; not applying later waves", "wave", n, "errors", len(waveErrs))
+			return errs
+		}
+	}
+	return errs
+}
+
+// waitForWave waits for the resources applied in a sync wave to
+// become healthy. It returns an error for each of those that aren't
+// healthy by the end of the sync wave timeout; or, if the deadline
+// for this sync comes first, true to say the wave is still to be
+// waited for.
+func (c *Cluster) waitForWave(logger log.Logger, syncSetName string, wave int, cs changeSet, deadline time.Time) (cluster.SyncError, bool) {
+	timeout := c.syncWaveTimeout()
+	waveDeadline := c.waveStarted(syncSetName, wave, cs).Add(timeout)
+	pending := cs.objs["apply"]
+	health := map[resource.ID]cluster.Health{}
+	for {
+		var unhealthy []applyObject
+		for _, obj := range pending {
+			h := c.appliedResourceHealth(obj)
+			if h.Status != cluster.HealthHealthy {
+				health[obj.ResourceID] = h
+				unhealthy = append(unhealthy, obj)
+			}
+		}
+		pending = unhealthy
+		if len(pending) == 0 {
+			logger.Log("info", "sync wave healthy", "wave", wave)
+			c.forgetWave(syncSetName, wave)
+			return nil, false
+		}
+		now := time.Now()
+		if !now.Before(waveDeadline) {
+			break
+		}
+		if !now.Before(deadline) {
+			return nil, true
+		}
+		wait := syncWavePollInterval
+		if until := deadline.Sub(now); until < wait {
+			wait = until
+		}
+		if until := waveDeadline.Sub(now); until < wait {
+			wait = until
+		}
+		time.Sleep(wait)
+	}
+
+	var errs cluster.SyncError
+	for _, obj := range pending {
+		h := health[obj.ResourceID]
+		msg := h.Status
+		if h.Message != "" {
+			msg += ": " + h.Message
+		}
+		errs = append(errs, cluster.ResourceError{
+			ResourceID: obj.ResourceID,
+			Source:     obj.Source,
+			Error:      fmt.Errorf("sync wave %d: not healthy after %s (%s)", wave, timeout, msg),
+		})
+	}
+	return errs, false
+}
+
+// waveStarted returns when a sync first waited for the sync wave
+// given, as it's defined now, to become healthy; so the time a wave
+// is given runs across syncs, and starts again if its definition
+// changes.
+func (c *Cluster) waveStarted(syncSetName string, wave int, cs changeSet) time.Time {
+	// The resources aren't staged in any particular order
+	objs := append([]applyObject(nil), cs.objs["apply"]...)
+	sort.Slice(objs, func(i, j int) bool {
+		return objs[i].ResourceID.String() < objs[j].ResourceID.String()
+	})
+	hash := sha256.New()
+	for _, obj := range objs {
+		hash.Write(obj.Payload)
+	}
+	digest := hex.EncodeToString(hash.Sum(nil))
+	key := fmt.Sprintf("%s/%d", syncSetName, wave)
+
+	c.muWaves.Lock()
+	defer c.muWaves.Unlock()
+	if c.waves == nil {
+		c.waves = map[string]waveProgress{}
+	}
+	if p, ok := c.waves[key]; ok && p.digest == digest {
+		return p.started
+	}
+	started := time.Now()
+	c.waves[key] = waveProgress{digest: digest, started: started}
+	return started
+}
+
+func (c *Cluster) forgetWave(syncSetName string, wave int) {
+	c.muWaves.Lock()
+	defer c.muWaves.Unlock()
+	delete(c.waves, fmt.Sprintf("%s/%d", syncSetName, wave))
+}
+
+// waveProgress records when a sync wave was first waited for.
+type waveProgress struct {
+	digest  string
+	started time.Time
+}
+
+func (c *Cluster) appliedResourceHealth(obj applyObject) cluster.Health {
+	res, err := c.getAppliedResource(obj)
+	switch {
+	case apierrors.IsNotFound(err):
+		return cluster.Health{Status: cluster.HealthUnknown, Message: "not found in cluster"}
+	case err != nil:
+		return unknownHealth(err)
+	}
+	return assessHealth(res)
+}
+
+// getAppliedResource fetches the resource applied from a manifest
+// from the cluster.
+func (c *Cluster) getAppliedResource(obj applyObject) (*unstructured.Unstructured, error) {
+	var typeMeta struct {
+		APIVersion string `yaml:"apiVersion"`
+		Kind       string `yaml:"kind"`
+	}
+	if err := yaml.Unmarshal(obj.Payload, &typeMeta); err != nil {
+		return nil, err
+	}
+	gv, err := schema.ParseGroupVersion(typeMeta.APIVersion)
+	if err != nil {
+		return nil, err
+	}
+	resources, err := c.client.discoveryClient.ServerResourcesForGroupVersion(typeMeta.APIVersion)
+	if err != nil {
+		return nil, err
+	}
+	ns, _, name := obj.ResourceID.Components()
+	for _, apiResource := range resources.APIResources {
+		// subresources (e.g., `deployments/status`) have the same kind
+		if apiResource.Kind != typeMeta.Kind || strings.Contains(apiResource.Name, "/") {
+			continue
+		}
+		client := c.client.dynamicClient.Resource(gv.WithResource(apiResource.Name))
+		if apiResource.Namespaced {
+			return client.Namespace(ns).Get(name, meta_v1.GetOptions{})
+		}
+		return client.Get(name, meta_v1.GetOptions{})
+	}
+	return nil, fmt.Errorf("no API resource found for %s %s", typeMeta.APIVersion, typeMeta.Kind)
+}
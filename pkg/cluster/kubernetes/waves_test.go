@@ -0,0 +1,175 @@
+package kubernetes
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/fluxcd/flux/pkg/cluster"
+	kresource "github.com/fluxcd/flux/pkg/cluster/kubernetes/resource"
+	"github.com/fluxcd/flux/pkg/resource"
+)
+
+func TestSyncWave(t *testing.T) {
+	for _, c := range []struct {
+		annotation string
+		wave       int
+		err        bool
+	}{
+		{"", 0, false},
+		{`fluxcd.io/sync-wave: "2"`, 2, false},
+		{`fluxcd.io/sync-wave: "-1"`, -1, false},
+		{`fluxcd.io/sync-wave: first`, 0, true},
+	} {
+		def := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+  annotations: {` + c.annotation + `}
+`
+		res := parseResource(t, def)
+		wave, err := syncWave(res)
+		if c.err {
+			assert.Error(t, err, c.annotation)
+			continue
+		}
+		assert.NoError(t, err, c.annotation)
+		assert.Equal(t, c.wave, wave, c.annotation)
+	}
+}
+
+func parseResource(t *testing.T, def string) resource.Resource {
+	resources, err := kresource.ParseMultidoc([]byte(def), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, res := range resources {
+		return res
+	}
+	t.Fatal("no resource parsed")
+	return nil
+}
+
+func shortSyncWaves(kube *Cluster) func() {
+	saved := syncWavePollInterval
+	syncWavePollInterval = 10 * time.Millisecond
+	kube.SyncWaveTimeout = 100 * time.Millisecond
+	return func() { syncWavePollInterval = saved }
+}
+
+func TestSyncWaves_Healthy(t *testing.T) {
+	kube, _, cancel := setup(t)
+	defer cancel()
+	defer shortSyncWaves(kube)()
+
+	// The namespace is healthy as soon as it exists, so the
+	// deployment in the next wave is applied
+	const defs = `---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: foobar
+  annotations:
+    fluxcd.io/sync-wave: "-1"
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: dep1
+  namespace: foobar
+`
+	assert.NoError(t, syncDefs(t, kube, defs))
+	synced, err := kube.getAllowedGCMarkedResourcesInSyncSet("testset")
+	assert.NoError(t, err)
+	assert.Contains(t, synced, "foobar:deployment/dep1")
+}
+
+func TestSyncWaves_Unhealthy(t *testing.T) {
+	kube, _, cancel := setup(t)
+	defer cancel()
+	defer shortSyncWaves(kube)()
+
+	// The first deployment never rolls out (the fake cluster has no
+	// controllers), so the second is not applied
+	const defs = `---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: first
+  namespace: ` + defaultTestNamespace + `
+spec:
+  replicas: 1
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: second
+  namespace: ` + defaultTestNamespace + `
+  annotations:
+    fluxcd.io/sync-wave: "1"
+`
+	err := syncDefs(t, kube, defs)
+	syncErr, ok := err.(cluster.SyncError)
+	if !ok {
+		t.Fatalf("expected cluster.SyncError, got %#v", err)
+	}
+	if !assert.Len(t, syncErr, 2) {
+		return
+	}
+	assert.Equal(t, resource.MakeID(defaultTestNamespace, "deployment", "first"), syncErr[0].ResourceID)
+	assert.Contains(t, syncErr[0].Error.Error(), "sync wave 0: not healthy")
+	assert.Equal(t, resource.MakeID(defaultTestNamespace, "deployment", "second"), syncErr[1].ResourceID)
+	assert.Contains(t, syncErr[1].Error.Error(), "since sync wave 0 did not complete")
+
+	synced, err := kube.getAllowedGCMarkedResourcesInSyncSet("testset")
+	assert.NoError(t, err)
+	assert.Contains(t, synced, defaultTestNamespace+":deployment/first")
+	assert.NotContains(t, synced, defaultTestNamespace+":deployment/second")
+}
+
+func TestSyncWaves_ResumedByLaterSync(t *testing.T) {
+	kube, _, cancel := setup(t)
+	defer cancel()
+	defer shortSyncWaves(kube)()
+
+	// The sync runs out of time before the wave's timeout, so it
+	// finishes without applying the second wave, and without errors
+	kube.SyncTimeout = 30 * time.Millisecond
+	kube.SyncWaveTimeout = time.Hour
+	const defs = `---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: first
+  namespace: ` + defaultTestNamespace + `
+spec:
+  replicas: 1
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: second
+  namespace: ` + defaultTestNamespace + `
+  annotations:
+    fluxcd.io/sync-wave: "1"
+`
+	assert.NoError(t, syncDefs(t, kube, defs))
+	synced, err := kube.getAllowedGCMarkedResourcesInSyncSet("testset")
+	assert.NoError(t, err)
+	assert.Contains(t, synced, defaultTestNamespace+":deployment/first")
+	assert.NotContains(t, synced, defaultTestNamespace+":deployment/second")
+
+	// The wave's time runs on from the first sync, so a later sync
+	// finds it's run out
+	time.Sleep(20 * time.Millisecond)
+	kube.SyncWaveTimeout = 10 * time.Millisecond
+	err = syncDefs(t, kube, defs)
+	syncErr, ok := err.(cluster.SyncError)
+	if !ok {
+		t.Fatalf("expected cluster.SyncError, got %#v", err)
+	}
+	if assert.Len(t, syncErr, 2) {
+		assert.Contains(t, syncErr[0].Error.Error(), "sync wave 0: not healthy")
+	}
+}
@@ -19,6 +19,8 @@ type Mock struct {
 	PingFunc                      func() error
 	ExportFunc                    func(ctx context.Context) ([]byte, error)
 	SyncFunc                      func(cluster.SyncSet) error
+	PlanSyncFunc                  func(cluster.SyncSet) ([]cluster.ResourcePlan, error)
+	ResourceHealthFunc            func(ctx context.Context, maybeNamespace string) ([]cluster.ResourceHealth, error)
 	PublicSSHKeyFunc              func(regenerate bool) (ssh.PublicKey, error)
 	SetWorkloadContainerImageFunc func(def []byte, id resource.ID, container string, newImageID image.Ref) ([]byte, error)
 	LoadManifestsFunc             func(base string, paths []string) (map[string]resource.Resource, error)
@@ -56,6 +58,14 @@ func (m *Mock) Sync(c cluster.SyncSet) error {
 	return m.SyncFunc(c)
 }
 
+func (m *Mock) PlanSync(c cluster.SyncSet) ([]cluster.ResourcePlan, error) {
+	return m.PlanSyncFunc(c)
+}
+
+func (m *Mock) ResourceHealth(ctx context.Context, maybeNamespace string) ([]cluster.ResourceHealth, error) {
+	return m.ResourceHealthFunc(ctx, maybeNamespace)
+}
+
 func (m *Mock) PublicSSHKey(regenerate bool) (ssh.PublicKey, error) {
 	return m.PublicSSHKeyFunc(regenerate)
 }
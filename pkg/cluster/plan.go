@@ -0,0 +1,32 @@
+package cluster
+
+import (
+	"github.com/fluxcd/flux/pkg/resource"
+)
+
+// The actions a sync would take for a resource.
+const (
+	PlanCreate    = "create"
+	PlanUpdate    = "update"
+	PlanDelete    = "delete"
+	PlanUnchanged = "unchanged"
+)
+
+// FieldChange describes a difference in a single field between the
+// resource in the cluster and the resource as it would be applied. The
+// path is dotted, with list indices in brackets, e.g.,
+// `spec.template.spec.containers[0].image`. `Old` is nil for fields
+// being added and `New` is nil for fields being removed.
+type FieldChange struct {
+	Path string
+	Old  interface{}
+	New  interface{}
+}
+
+// ResourcePlan describes what a sync would do to a single resource.
+type ResourcePlan struct {
+	ResourceID resource.ID
+	Source     string
+	Action     string
+	Changes    []FieldChange
+}
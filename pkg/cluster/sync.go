@@ -1,6 +1,7 @@
 package cluster
 
 import (
+	"fmt"
 	"strings"
 
 	"github.com/fluxcd/flux/pkg/resource"
@@ -35,3 +36,27 @@ func (err SyncError) Error() string {
 	}
 	return strings.Join(errs, "; ")
 }
+
+// PruneAbortedError is returned from a sync in which garbage
+// collection would have deleted more resources than allowed, and so
+// deleted none of them. The rest of the sync went ahead, and any
+// errors from it are included.
+type PruneAbortedError struct {
+	// Resources are those that would have been deleted
+	Resources []resource.ID
+	// Max is the most resources allowed to be deleted by a sync
+	Max    int
+	Errors SyncError
+	// Repeated is set if garbage collection was aborted for the same
+	// resources in the previous sync, and so has been reported
+	// already
+	Repeated bool
+}
+
+func (err PruneAbortedError) Error() string {
+	msg := fmt.Sprintf("garbage collection aborted: %d resources to delete, more than the maximum of %d", len(err.Resources), err.Max)
+	if len(err.Errors) > 0 {
+		msg += "; " + err.Errors.Error()
+	}
+	return msg
+}
@@ -0,0 +1,228 @@
+// Package commitstatus reports the outcome of syncs back to the git
+// host, as a status on the commit synced. This lets people see on a
+// commit (or a pull request) whether it's made it to the cluster,
+// and if not, why not.
+//
+// The Reporter follows the daemon's syncs as they start and finish,
+// along with the sync events logged along the way, and sets the
+// status of the revision synced: pending while it's being synced,
+// then success, or failure with the errors.
+package commitstatus
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+
+	"github.com/fluxcd/flux/pkg/api/v14"
+	"github.com/fluxcd/flux/pkg/event"
+)
+
+// State is the state of a commit status.
+type State string
+
+const (
+	Pending State = "pending"
+	Success State = "success"
+	Failure State = "failure"
+)
+
+// Git hosts limit the length of a status description; GitHub's limit
+// of 140 characters is the least.
+const maxDescription = 140
+
+// How long to wait before following the daemon again, if the reporter
+// falls behind.
+const resubscribeDelay = time.Second
+
+// Status is a status to set on a commit.
+type Status struct {
+	State State
+	// Context distinguishes this status from others (e.g., from CI)
+	// set on the same commit.
+	Context     string
+	Description string
+}
+
+// Provider sets statuses on commits, at a particular git host.
+type Provider interface {
+	// SetStatus sets a status on the revision given, in the repo
+	// given by its path at the host (e.g., "org/repo").
+	SetStatus(ctx context.Context, repo, revision string, status Status) error
+}
+
+// Watcher is something whose syncs can be followed; the daemon is
+// one.
+type Watcher interface {
+	Watch(ctx context.Context, opts v14.WatchOptions) (<-chan v14.Update, error)
+}
+
+// Reporter sets commit statuses for the syncs it's told about.
+type Reporter struct {
+	Provider Provider
+	// Context is used for the statuses of syncs of the main git repo;
+	// for additional git sources, it's suffixed with the name of the
+	// source.
+	Context string
+	// Repos gives the repo path of each git source, keyed by the
+	// name of the source ("" for the main git repo). Syncs of sources
+	// not given here aren't reported.
+	Repos  map[string]string
+	Logger log.Logger
+
+	syncing *sourceState
+	sources map[string]*sourceState
+}
+
+// sourceState keeps track of what's been reported for a git source.
+type sourceState struct {
+	name string
+	// The status last set, and on which revision
+	revision string
+	status   Status
+	// Whether the last sync failed outright (rather than with errors
+	// for some resources)
+	failed bool
+	// Whether a sync event was seen for the sync in progress, and
+	// the errors it had
+	synced bool
+	errors []event.ResourceError
+}
+
+// Loop follows the syncs of the watcher given, and reports them,
+// until told to stop.
+func (r *Reporter) Loop(w Watcher, stop <-chan struct{}, wg *sync.WaitGroup) {
+	defer wg.Done()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-stop
+		cancel()
+	}()
+
+	for {
+		updates, err := w.Watch(ctx, v14.WatchOptions{})
+		if err != nil {
+			r.Logger.Log("err", err)
+		} else {
+			for u := range updates {
+				r.Update(ctx, u)
+			}
+		}
+		// The updates stop if we're stopping, or fell too far behind
+		select {
+		case <-stop:
+			return
+		case <-time.After(resubscribeDelay):
+			r.syncing = nil
+		}
+	}
+}
+
+// Update takes account of an update from the daemon, setting a commit
+// status if it calls for one.
+func (r *Reporter) Update(ctx context.Context, u v14.Update) {
+	switch {
+	case u.Type == v14.UpdateSyncStarted && u.Sync != nil:
+		src := r.source(u.Sync.Source)
+		if src == nil {
+			return
+		}
+		r.syncing = src
+		src.synced, src.errors = false, nil
+		// A revision is synced again and again; it's only pending the
+		// first time.
+		if u.Sync.Revision != src.revision {
+			r.report(ctx, src, u.Sync.Revision, Pending, "Syncing to the cluster")
+		}
+	case u.Type == v14.UpdateEvent && u.Event != nil && u.Event.Type == event.EventSync:
+		if r.syncing == nil {
+			return
+		}
+		r.syncing.synced = true
+		if meta, ok := u.Event.Metadata.(*event.SyncEventMetadata); ok {
+			r.syncing.errors = meta.Errors
+		}
+	case u.Type == v14.UpdateSyncFinished && u.Sync != nil:
+		src := r.source(u.Sync.Source)
+		if src == nil {
+			return
+		}
+		r.syncing = nil
+		failed := src.failed
+		src.failed = u.Sync.Error != ""
+		switch {
+		case u.Sync.Error != "":
+			r.report(ctx, src, u.Sync.Revision, Failure, "Sync failed: "+u.Sync.Error)
+		case len(src.errors) > 0:
+			r.report(ctx, src, u.Sync.Revision, Failure, describeErrors(src.errors))
+		// If there was no sync event, there were no new commits to
+		// sync; so, unless it's a new revision, still pending, or
+		// recovering from a failed sync, what was reported before
+		// stands.
+		case src.synced || u.Sync.Revision != src.revision || src.status.State == Pending || failed:
+			r.report(ctx, src, u.Sync.Revision, Success, "Synced to the cluster")
+		}
+	}
+}
+
+func (r *Reporter) source(name string) *sourceState {
+	if _, ok := r.Repos[name]; !ok {
+		return nil
+	}
+	if r.sources == nil {
+		r.sources = map[string]*sourceState{}
+	}
+	src, ok := r.sources[name]
+	if !ok {
+		src = &sourceState{name: name}
+		r.sources[name] = src
+	}
+	return src
+}
+
+// report sets the status of a revision, unless it's already been set.
+func (r *Reporter) report(ctx context.Context, src *sourceState, revision string, state State, description string) {
+	status := Status{
+		State:       state,
+		Context:     r.Context,
+		Description: truncate(description, maxDescription),
+	}
+	if src.name != "" {
+		status.Context = r.Context + "/" + src.name
+	}
+	if revision == src.revision && status == src.status {
+		return
+	}
+	repo := r.Repos[src.name]
+	if err := r.Provider.SetStatus(ctx, repo, revision, status); err != nil {
+		r.Logger.Log("repo", repo, "revision", revision, "state", state, "err", err)
+		return
+	}
+	src.revision, src.status = revision, status
+}
+
+// describeErrors summarises the errors from syncing resources.
+func describeErrors(errs []event.ResourceError) string {
+	noun := "resources"
+	if len(errs) == 1 {
+		noun = "resource"
+	}
+	var details []string
+	for _, e := range errs {
+		details = append(details, fmt.Sprintf("%s: %s", e.ID, e.Error))
+	}
+	return fmt.Sprintf("%d %s failed to sync; %s", len(errs), noun, strings.Join(details, "; "))
+}
+
+func truncate(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return string(runes[:max-3]) + "..."
+}
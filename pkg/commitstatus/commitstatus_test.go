@@ -0,0 +1,168 @@
+package commitstatus
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/fluxcd/flux/pkg/api/v14"
+	"github.com/fluxcd/flux/pkg/event"
+	"github.com/fluxcd/flux/pkg/githost"
+	"github.com/fluxcd/flux/pkg/githost/githosttest"
+	"github.com/fluxcd/flux/pkg/resource"
+)
+
+type setStatus struct {
+	repo, revision string
+	status         Status
+}
+
+type recordingProvider struct {
+	set []setStatus
+}
+
+func (p *recordingProvider) SetStatus(ctx context.Context, repo, revision string, status Status) error {
+	p.set = append(p.set, setStatus{repo, revision, status})
+	return nil
+}
+
+func started(source, revision string) v14.Update {
+	return v14.Update{Type: v14.UpdateSyncStarted, Sync: &v14.SyncUpdate{Source: source, Revision: revision}}
+}
+
+func finished(source, revision, err string) v14.Update {
+	return v14.Update{Type: v14.UpdateSyncFinished, Sync: &v14.SyncUpdate{Source: source, Revision: revision, Error: err}}
+}
+
+func synced(errs ...event.ResourceError) v14.Update {
+	return v14.Update{Type: v14.UpdateEvent, Event: &event.Event{
+		Type:     event.EventSync,
+		Metadata: &event.SyncEventMetadata{Errors: errs},
+	}}
+}
+
+func TestReporter(t *testing.T) {
+	provider := &recordingProvider{}
+	r := &Reporter{
+		Provider: provider,
+		Context:  "flux",
+		Repos:    map[string]string{"": "org/config", "apps": "org/apps"},
+		Logger:   log.NewNopLogger(),
+	}
+	ctx := context.Background()
+	app := resource.MustParseID("default:deployment/app")
+
+	for _, u := range []v14.Update{
+		// A new revision: pending, then success
+		started("", "rev1"),
+		synced(),
+		finished("", "rev1", ""),
+		// Synced again without new commits: nothing to report
+		started("", "rev1"),
+		finished("", "rev1", ""),
+		// A new revision with errors
+		started("", "rev2"),
+		synced(event.ResourceError{ID: app, Path: "app.yaml", Error: "invalid"}),
+		finished("", "rev2", ""),
+		// The errors stand, if there's nothing new synced
+		started("", "rev2"),
+		finished("", "rev2", ""),
+		// An additional source, failing to sync at all
+		started("apps", "rev3"),
+		finished("apps", "rev3", "git clone failed"),
+		// ... and recovering
+		started("apps", "rev3"),
+		finished("apps", "rev3", ""),
+		// An unknown source isn't reported
+		started("other", "rev4"),
+		synced(),
+		finished("other", "rev4", ""),
+	} {
+		r.Update(ctx, u)
+	}
+
+	assert.Equal(t, []setStatus{
+		{"org/config", "rev1", Status{Pending, "flux", "Syncing to the cluster"}},
+		{"org/config", "rev1", Status{Success, "flux", "Synced to the cluster"}},
+		{"org/config", "rev2", Status{Pending, "flux", "Syncing to the cluster"}},
+		{"org/config", "rev2", Status{Failure, "flux", "1 resource failed to sync; default:deployment/app: invalid"}},
+		{"org/apps", "rev3", Status{Pending, "flux/apps", "Syncing to the cluster"}},
+		{"org/apps", "rev3", Status{Failure, "flux/apps", "Sync failed: git clone failed"}},
+		{"org/apps", "rev3", Status{Success, "flux/apps", "Synced to the cluster"}},
+	}, provider.set)
+}
+
+func TestTruncate(t *testing.T) {
+	assert.Equal(t, "short", truncate("short", 10))
+	assert.Equal(t, "a longe...", truncate("a longer description", 10))
+	assert.Len(t, []rune(truncate(strings.Repeat("é", 200), maxDescription)), maxDescription)
+}
+
+func TestProviders(t *testing.T) {
+	server, requests := githosttest.FakeHost(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "missing") {
+			http.Error(w, "Not Found", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	})
+	defer server.Close()
+
+	status := Status{State: Failure, Context: "flux", Description: "1 resource failed to sync"}
+	for _, c := range []struct {
+		kind, apiPath, token string
+		expected             githosttest.Request
+	}{
+		{
+			kind:  githost.GitHub,
+			token: "ghtoken",
+			expected: githosttest.Request{Method: "POST", Path: "/repos/org/repo/statuses/abc123", Auth: "token ghtoken", Body: map[string]interface{}{
+				"state": "failure", "context": "flux", "description": "1 resource failed to sync",
+			}},
+		},
+		{
+			kind:    githost.GitLab,
+			apiPath: "/api/v4",
+			token:   "gltoken",
+			expected: githosttest.Request{Method: "POST", Path: "/api/v4/projects/org%2Frepo/statuses/abc123", Auth: "Private-Token gltoken", Body: map[string]interface{}{
+				"state": "failed", "name": "flux", "description": "1 resource failed to sync",
+			}},
+		},
+		{
+			kind:  githost.Bitbucket,
+			token: "user:apppassword",
+			expected: githosttest.Request{Method: "POST", Path: "/repositories/org/repo/commit/abc123/statuses/build", Auth: "Basic dXNlcjphcHBwYXNzd29yZA==", Body: map[string]interface{}{
+				"state": "FAILED", "key": "flux", "name": "flux", "description": "1 resource failed to sync",
+				"url": "https://bitbucket.org/org/repo/commits/abc123",
+			}},
+		},
+		{
+			kind:    githost.Gitea,
+			apiPath: "/api/v1",
+			token:   "gteatoken",
+			expected: githosttest.Request{Method: "POST", Path: "/api/v1/repos/org/repo/statuses/abc123", Auth: "token gteatoken", Body: map[string]interface{}{
+				"state": "failure", "context": "flux", "description": "1 resource failed to sync",
+			}},
+		},
+	} {
+		t.Run(c.kind, func(t *testing.T) {
+			*requests = nil
+			host, err := githost.NewClient(c.kind, server.URL+c.apiPath+"/", c.token, server.Client())
+			if err != nil {
+				t.Fatal(err)
+			}
+			provider := NewProvider(host)
+			assert.NoError(t, provider.SetStatus(context.Background(), "org/repo", "abc123", status))
+			assert.Equal(t, []githosttest.Request{c.expected}, *requests)
+
+			err = provider.SetStatus(context.Background(), "org/missing", "abc123", status)
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), "404")
+			}
+		})
+	}
+}
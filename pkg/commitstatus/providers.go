@@ -0,0 +1,115 @@
+package commitstatus
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/fluxcd/flux/pkg/githost"
+)
+
+// NewProvider constructs a provider that sets commit statuses at the
+// git host given.
+func NewProvider(host *githost.Client) Provider {
+	switch host.Kind {
+	case githost.GitLab:
+		return &GitLab{host}
+	case githost.Bitbucket:
+		return &Bitbucket{host}
+	case githost.Gitea:
+		return &Gitea{host}
+	default:
+		return &GitHub{host}
+	}
+}
+
+// GitHub sets commit statuses with GitHub's (or GitHub Enterprise's)
+// API, using a personal access token.
+type GitHub struct {
+	host *githost.Client
+}
+
+type githubStatus struct {
+	State       string `json:"state"`
+	Context     string `json:"context"`
+	Description string `json:"description"`
+}
+
+func (g *GitHub) SetStatus(ctx context.Context, repo, revision string, status Status) error {
+	return g.host.Do(ctx, "POST", "/repos/"+repo+"/statuses/"+revision, githubStatus{
+		State:       string(status.State),
+		Context:     status.Context,
+		Description: status.Description,
+	}, nil)
+}
+
+// GitLab sets commit statuses with GitLab's API, using a personal or
+// project access token.
+type GitLab struct {
+	host *githost.Client
+}
+
+type gitlabStatus struct {
+	State       string `json:"state"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+var gitlabStates = map[State]string{
+	Pending: "running",
+	Success: "success",
+	Failure: "failed",
+}
+
+func (g *GitLab) SetStatus(ctx context.Context, repo, revision string, status Status) error {
+	return g.host.Do(ctx, "POST", "/projects/"+url.PathEscape(repo)+"/statuses/"+revision, gitlabStatus{
+		State:       gitlabStates[status.State],
+		Name:        status.Context,
+		Description: status.Description,
+	}, nil)
+}
+
+// Bitbucket sets build statuses with Bitbucket Cloud's API, using an
+// access token, or a username and app password.
+type Bitbucket struct {
+	host *githost.Client
+}
+
+type bitbucketStatus struct {
+	State       string `json:"state"`
+	Key         string `json:"key"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	URL         string `json:"url"`
+}
+
+var bitbucketStates = map[State]string{
+	Pending: "INPROGRESS",
+	Success: "SUCCESSFUL",
+	Failure: "FAILED",
+}
+
+func (b *Bitbucket) SetStatus(ctx context.Context, repo, revision string, status Status) error {
+	return b.host.Do(ctx, "POST", "/repositories/"+repo+"/commit/"+revision+"/statuses/build", bitbucketStatus{
+		State:       bitbucketStates[status.State],
+		Key:         status.Context,
+		Name:        status.Context,
+		Description: status.Description,
+		// A link is required; there's nowhere better to point
+		// than the commit itself.
+		URL: "https://bitbucket.org/" + repo + "/commits/" + revision,
+	}, nil)
+}
+
+// Gitea sets commit statuses with Gitea's API, using an access token.
+type Gitea struct {
+	host *githost.Client
+}
+
+func (g *Gitea) SetStatus(ctx context.Context, repo, revision string, status Status) error {
+	// Gitea's statuses are the same as GitHub's
+	return g.host.Do(ctx, "POST", "/repos/"+repo+"/statuses/"+revision, githubStatus{
+		State:       string(status.State),
+		Context:     status.Context,
+		Description: status.Description,
+	}, nil)
+}
@@ -14,12 +14,16 @@ import (
 	"github.com/fluxcd/flux/pkg/api"
 	"github.com/fluxcd/flux/pkg/api/v10"
 	"github.com/fluxcd/flux/pkg/api/v11"
+	"github.com/fluxcd/flux/pkg/api/v12"
+	"github.com/fluxcd/flux/pkg/api/v13"
+	"github.com/fluxcd/flux/pkg/api/v15"
 	"github.com/fluxcd/flux/pkg/api/v6"
 	"github.com/fluxcd/flux/pkg/api/v9"
 	"github.com/fluxcd/flux/pkg/cluster"
 	"github.com/fluxcd/flux/pkg/event"
 	"github.com/fluxcd/flux/pkg/git"
 	"github.com/fluxcd/flux/pkg/guid"
+	"github.com/fluxcd/flux/pkg/history"
 	"github.com/fluxcd/flux/pkg/image"
 	"github.com/fluxcd/flux/pkg/job"
 	"github.com/fluxcd/flux/pkg/manifests"
@@ -27,6 +31,7 @@ import (
 	"github.com/fluxcd/flux/pkg/registry"
 	"github.com/fluxcd/flux/pkg/release"
 	"github.com/fluxcd/flux/pkg/resource"
+	"github.com/fluxcd/flux/pkg/schedule"
 	"github.com/fluxcd/flux/pkg/sync"
 	"github.com/fluxcd/flux/pkg/update"
 )
@@ -34,21 +39,29 @@ import (
 // Daemon is the fully-functional state of a daemon (compare to
 // `NotReadyDaemon`).
 type Daemon struct {
-	V                         string
-	Cluster                   cluster.Cluster
-	Manifests                 manifests.Manifests
-	Registry                  registry.Registry
-	ImageRefresh              chan image.Name
-	Repo                      *git.Repo
-	GitConfig                 git.Config
-	Jobs                      *job.Queue
-	JobStatusCache            *job.StatusCache
-	EventWriter               event.EventWriter
-	Logger                    log.Logger
-	ManifestGenerationEnabled bool
-	GitSecretEnabled          bool
+	V                           string
+	Cluster                     cluster.Cluster
+	Manifests                   manifests.Manifests
+	Registry                    registry.Registry
+	ImageVerifier               update.ImageVerifier
+	ImageRefresh                chan image.Name
+	Repo                        *git.Repo
+	GitConfig                   git.Config
+	PullRequests                *PullRequestConfig
+	Schedule                    *schedule.File
+	Sources                     []GitSource
+	SourceMirrors               *git.Mirrors
+	Jobs                        *job.Queue
+	JobStatusCache              *job.StatusCache
+	EventWriter                 event.EventWriter
+	EventHistory                *history.History
+	Logger                      log.Logger
+	ManifestGenerationEnabled   bool
+	ManifestGenerationKustomize bool
+	GitSecretEnabled            bool
 	// bookkeeping
 	*LoopVars
+	watchers watchers
 }
 
 // Invariant.
@@ -71,9 +84,13 @@ type repo interface {
 }
 
 func (d *Daemon) getManifestStore(r repo) (manifests.Store, error) {
-	absPaths := git.MakeAbsolutePaths(r, d.GitConfig.Paths)
+	return d.getManifestStoreWithPaths(r, d.GitConfig.Paths)
+}
+
+func (d *Daemon) getManifestStoreWithPaths(r repo, paths []string) (manifests.Store, error) {
+	absPaths := git.MakeAbsolutePaths(r, paths)
 	if d.ManifestGenerationEnabled {
-		return manifests.NewConfigAware(r.Dir(), absPaths, d.Manifests)
+		return manifests.NewConfigAware(r.Dir(), absPaths, d.Manifests, d.ManifestGenerationKustomize)
 	}
 	return manifests.NewRawFiles(r.Dir(), absPaths, d.Manifests), nil
 }
@@ -132,6 +149,9 @@ func (d *Daemon) ListServicesWithOptions(ctx context.Context, opts v11.ListServi
 		return nil, err
 	}
 
+	sched := d.Schedule.Schedule()
+	now := time.Now()
+
 	var res []v6.ControllerStatus
 	for _, workload := range clusterWorkloads {
 		readOnly := v6.ReadOnlyOK
@@ -153,6 +173,12 @@ func (d *Daemon) ListServicesWithOptions(ctx context.Context, opts v11.ListServi
 		if workload.SyncError != nil {
 			syncError = workload.SyncError.Error()
 		}
+		var frozen *v6.Freeze
+		if policies.Has(policy.Automated) {
+			if freeze, ok, _ := workloadFrozen(sched, policies, now); ok {
+				frozen = &v6.Freeze{Until: freeze.Until, Reason: freeze.Reason}
+			}
+		}
 		res = append(res, v6.ControllerStatus{
 			ID:         workload.ID,
 			Containers: containers2containers(workload.ContainersOrNil()),
@@ -160,12 +186,15 @@ func (d *Daemon) ListServicesWithOptions(ctx context.Context, opts v11.ListServi
 			Status:     workload.Status,
 			Rollout:    workload.Rollout,
 			SyncError:  syncError,
+			Health:     workload.Health,
 			Antecedent: workload.Antecedent,
 			Labels:     workload.Labels,
 			Automated:  policies.Has(policy.Automated),
 			Locked:     policies.Has(policy.Locked),
 			Ignore:     policies.Has(policy.Ignore),
 			Policies:   policies.ToStringMap(),
+			Frozen:     frozen,
+			Drift:      workload.Drift,
 		})
 	}
 
@@ -270,13 +299,13 @@ func (d *Daemon) makeJobFromUpdate(update updateFunc) jobFunc {
 func (d *Daemon) executeJob(id job.ID, do jobFunc, logger log.Logger) (job.Result, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), d.SyncTimeout)
 	defer cancel()
-	d.JobStatusCache.SetStatus(id, job.Status{StatusString: job.StatusRunning})
+	d.setJobStatus(id, job.Status{StatusString: job.StatusRunning})
 	result, err := do(ctx, id, logger)
 	if err != nil {
-		d.JobStatusCache.SetStatus(id, job.Status{StatusString: job.StatusFailed, Err: err.Error(), Result: result})
+		d.setJobStatus(id, job.Status{StatusString: job.StatusFailed, Err: err.Error(), Result: result})
 		return result, err
 	}
-	d.JobStatusCache.SetStatus(id, job.Status{StatusString: job.StatusSucceeded, Result: result})
+	d.setJobStatus(id, job.Status{StatusString: job.StatusSucceeded, Result: result})
 	return result, nil
 }
 
@@ -333,7 +362,7 @@ func (d *Daemon) queueJob(do jobFunc) job.ID {
 		},
 	})
 	queueLength.Set(float64(d.Jobs.Len()))
-	d.JobStatusCache.SetStatus(id, job.Status{StatusString: job.StatusQueued})
+	d.setJobStatus(id, job.Status{StatusString: job.StatusQueued})
 	return id
 }
 
@@ -457,7 +486,9 @@ func (d *Daemon) updatePolicies(spec update.Spec, updates resource.PolicyUpdates
 			Author:  commitAuthor,
 			Message: policyCommitMessage(updates, spec.Cause),
 		}
-		if err := working.CommitAndPush(ctx, commitAction, &note{JobID: jobID, Spec: spec}, d.ManifestGenerationEnabled); err != nil {
+		var err error
+		result.Revision, result.PullRequest, err = d.push(ctx, working, commitAction, &note{JobID: jobID, Spec: spec}, jobBranch("policy", jobID), result.Result)
+		if err != nil {
 			// On the chance pushing failed because it was not
 			// possible to fast-forward, ask for a sync so the
 			// next attempt is more likely to succeed.
@@ -467,12 +498,6 @@ func (d *Daemon) updatePolicies(spec update.Spec, updates resource.PolicyUpdates
 		if anythingAutomated {
 			d.AskForAutomatedWorkloadImageUpdates()
 		}
-
-		var err error
-		result.Revision, err = working.HeadRevision(ctx)
-		if err != nil {
-			return result, err
-		}
 		return result, nil
 	}
 }
@@ -484,13 +509,13 @@ func (d *Daemon) release(spec update.Spec, c release.Changes) updateFunc {
 		if err != nil {
 			return zero, err
 		}
-		rc := release.NewReleaseContext(d.Cluster, rs, d.Registry)
+		rc := release.NewReleaseContext(d.Cluster, rs, d.Registry, d.ImageVerifier)
 		result, err := release.Release(ctx, rc, c, logger)
 		if err != nil {
 			return zero, err
 		}
 
-		var revision string
+		var revision, pullRequest string
 
 		if c.ReleaseKind() == update.ReleaseKindExecute {
 			commitMsg := spec.Cause.Message
@@ -505,7 +530,12 @@ func (d *Daemon) release(spec update.Spec, c release.Changes) updateFunc {
 				Author:  commitAuthor,
 				Message: commitMsg,
 			}
-			if err := working.CommitAndPush(ctx, commitAction, &note{JobID: jobID, Spec: spec, Result: result}, d.ManifestGenerationEnabled); err != nil {
+			branch := jobBranch("release", jobID)
+			if spec.Type == update.Auto {
+				branch = automatedBranch
+			}
+			revision, pullRequest, err = d.push(ctx, working, commitAction, &note{JobID: jobID, Spec: spec, Result: result}, branch, result)
+			if err != nil {
 				// On the chance pushing failed because it was not
 				// possible to fast-forward, ask the repo to fetch
 				// from upstream ASAP, so the next attempt is more
@@ -513,15 +543,12 @@ func (d *Daemon) release(spec update.Spec, c release.Changes) updateFunc {
 				d.Repo.Notify()
 				return zero, err
 			}
-			revision, err = working.HeadRevision(ctx)
-			if err != nil {
-				return zero, err
-			}
 		}
 		return job.Result{
-			Revision: revision,
-			Spec:     &spec,
-			Result:   result,
+			Revision:    revision,
+			PullRequest: pullRequest,
+			Spec:        &spec,
+			Result:      result,
 		}, nil
 	}
 }
@@ -534,18 +561,39 @@ func (d *Daemon) NotifyChange(ctx context.Context, change v9.Change) error {
 	switch change.Kind {
 	case v9.GitChange:
 		gitUpdate := change.Source.(v9.GitUpdate)
-		if d.Repo.Origin().Equivalent(gitUpdate.URL) && gitUpdate.Branch != d.GitConfig.Branch {
+		// Without a URL, it's just a nudge to look at the main repo
+		if gitUpdate.URL == "" {
+			d.Repo.Notify()
+			break
+		}
+		// Otherwise, only refresh the repo (or repos) in question, and
+		// only if it's the branch we use, rather than fetching for
+		// every push anywhere.
+		var notified bool
+		for _, src := range append([]GitSource{d.mainSource()}, d.Sources...) {
+			if src.Repo.Origin().Equivalent(gitUpdate.URL) && gitUpdate.Branch == src.GitConfig.Branch {
+				src.Repo.Notify()
+				notified = true
+			}
+		}
+		if !notified {
 			// It isn't strictly an _error_ to be notified about a repo/branch pair
 			// that isn't ours, but it's worth logging anyway for debugging.
 			d.Logger.Log("msg", "notified about unrelated change",
 				"url", gitUpdate.URL,
 				"branch", gitUpdate.Branch)
-			break
 		}
-		d.Repo.Notify()
 	case v9.ImageChange:
 		imageUpdate := change.Source.(v9.ImageUpdate)
-		d.ImageRefresh <- imageUpdate.Name
+		// Registry webhooks can arrive faster than images can be
+		// looked up; rather than holding up the caller, drop the
+		// notification, since the image will be looked at in the
+		// normal course of things anyway.
+		select {
+		case d.ImageRefresh <- imageUpdate.Name:
+		default:
+			d.Logger.Log("msg", "too many image refreshes queued; dropping notification", "image", imageUpdate.Name)
+		}
 	}
 	return nil
 }
@@ -615,6 +663,116 @@ func (d *Daemon) SyncStatus(ctx context.Context, commitRef string) ([]string, er
 	return revs, nil
 }
 
+// SyncPlan reports what syncing a revision would do to the cluster,
+// without applying anything.
+func (d *Daemon) SyncPlan(ctx context.Context, opts v12.SyncPlanOptions) (v12.SyncPlan, error) {
+	var plan v12.SyncPlan
+	planner, ok := d.Cluster.(sync.Planner)
+	if !ok {
+		return plan, errors.New("sync plans are not supported by this cluster")
+	}
+
+	src := d.mainSource()
+	if opts.Source != "" {
+		if src, ok = d.source(opts.Source); !ok {
+			return plan, fmt.Errorf("unknown git source %q", opts.Source)
+		}
+	}
+
+	var rev string
+	var err error
+	if opts.Ref == "" {
+		rev, err = src.Repo.BranchHead(ctx)
+	} else {
+		// The ref may be one the mirror doesn't have yet (e.g., a
+		// branch pushed since it was last refreshed), so fetch it
+		ctxt, cancel := context.WithTimeout(ctx, d.GitTimeout)
+		rev, err = src.Repo.FetchRevision(ctxt, opts.Ref)
+		cancel()
+	}
+	if err != nil {
+		return plan, err
+	}
+
+	ctxt, cancel := context.WithTimeout(ctx, d.GitTimeout)
+	working, err := src.Repo.Export(ctxt, rev)
+	cancel()
+	if err != nil {
+		return plan, err
+	}
+	defer func() {
+		if err := working.Clean(); err != nil {
+			d.Logger.Log("error", fmt.Sprintf("cannot clean sync plan clone: %s", err))
+		}
+	}()
+	if d.GitSecretEnabled {
+		ctxt, cancel := context.WithTimeout(ctx, d.GitTimeout)
+		err := working.SecretUnseal(ctxt)
+		cancel()
+		if err != nil {
+			return plan, err
+		}
+	}
+
+	store, err := d.getManifestStoreWithPaths(working, src.GitConfig.Paths)
+	if err != nil {
+		return plan, errors.Wrap(err, "reading the repository checkout")
+	}
+	resources, err := store.GetAllResourcesByID(ctx)
+	if err != nil {
+		return plan, manifestLoadError(err)
+	}
+	plan.Revision = rev
+	plan.Resources, err = sync.Plan(src.syncSetName(), resources, planner)
+	return plan, err
+}
+
+// ListResources reports the health of the resources synced to the
+// cluster, along with any errors from syncing them.
+func (d *Daemon) ListResources(ctx context.Context, opts v13.ListResourcesOptions) ([]v13.ResourceStatus, error) {
+	checker, ok := d.Cluster.(cluster.HealthChecker)
+	if !ok {
+		return nil, errors.New("resource health is not supported by this cluster")
+	}
+	healths, err := checker.ResourceHealth(ctx, opts.Namespace)
+	if err != nil {
+		return nil, errors.Wrap(err, "getting resource health from cluster")
+	}
+	var res []v13.ResourceStatus
+	for _, h := range healths {
+		var syncError string
+		if h.SyncError != nil {
+			syncError = h.SyncError.Error()
+		}
+		res = append(res, v13.ResourceStatus{
+			ID:        h.ResourceID,
+			Health:    h.Health,
+			SyncError: syncError,
+		})
+	}
+	return res, nil
+}
+
+// defaultHistoryLimit is how many events are returned from the
+// history, if no limit is given.
+const defaultHistoryLimit = 100
+
+// History returns the events recorded in the daemon's event history.
+func (d *Daemon) History(ctx context.Context, opts v15.HistoryOptions) ([]event.Event, error) {
+	if d.EventHistory == nil {
+		return nil, errNoHistory
+	}
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = defaultHistoryLimit
+	}
+	return d.EventHistory.Events(history.Query{
+		Workloads: opts.Workloads,
+		Before:    opts.Before,
+		Limit:     limit,
+	}), nil
+}
+
 func (d *Daemon) GitRepoConfig(ctx context.Context, regenerate bool) (v6.GitConfig, error) {
 	publicSSHKey, err := d.Cluster.PublicSSHKey(regenerate)
 	if err != nil {
@@ -697,6 +855,14 @@ func (d *Daemon) WithReadonlyClone(ctx context.Context, fn func(*git.Export) err
 }
 
 func (d *Daemon) LogEvent(ev event.Event) error {
+	d.publishEvent(ev)
+	if d.EventHistory != nil {
+		// Failing to record the event shouldn't stop it being sent
+		// elsewhere
+		if err := d.EventHistory.LogEvent(ev); err != nil {
+			d.Logger.Log("event", ev, "err", errors.Wrap(err, "recording event in history"))
+		}
+	}
 	if d.EventWriter == nil {
 		d.Logger.Log("event", ev, "logupstream", "false")
 		return nil
@@ -17,6 +17,8 @@ import (
 
 	"github.com/fluxcd/flux/pkg/api/v10"
 	"github.com/fluxcd/flux/pkg/api/v11"
+	"github.com/fluxcd/flux/pkg/api/v12"
+	"github.com/fluxcd/flux/pkg/api/v13"
 	"github.com/fluxcd/flux/pkg/api/v6"
 	"github.com/fluxcd/flux/pkg/api/v9"
 	"github.com/fluxcd/flux/pkg/cluster"
@@ -549,6 +551,74 @@ func TestDaemon_SyncStatus(t *testing.T) {
 	w.ForSyncStatus(d, stat.Result.Revision, 0)
 }
 
+// When I ask for a sync plan, it should plan the resources at the
+// head of the branch
+func TestDaemon_SyncPlan(t *testing.T) {
+	d, start, clean, k8s, _, _ := mockDaemon(t)
+	var planned cluster.SyncSet
+	k8s.PlanSyncFunc = func(set cluster.SyncSet) ([]cluster.ResourcePlan, error) {
+		planned = set
+		return []cluster.ResourcePlan{{ResourceID: resource.MustParseID(wl), Action: cluster.PlanUpdate}}, nil
+	}
+	start()
+	defer clean()
+
+	ctx := context.Background()
+	head, err := d.Repo.BranchHead(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan, err := d.SyncPlan(ctx, v12.SyncPlanOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Revision != head {
+		t.Errorf("expected plan of revision %s, got %s", head, plan.Revision)
+	}
+	if len(plan.Resources) != 1 || plan.Resources[0].Action != cluster.PlanUpdate {
+		t.Errorf("unexpected plan: %#v", plan.Resources)
+	}
+	if planned.Name != d.mainSource().syncSetName() {
+		t.Errorf("expected sync set %q, got %q", d.mainSource().syncSetName(), planned.Name)
+	}
+	if len(planned.Resources) == 0 {
+		t.Error("expected resources from the repo to be planned")
+	}
+
+	if _, err := d.SyncPlan(ctx, v12.SyncPlanOptions{Source: "nonexistent"}); err == nil {
+		t.Error("expected error planning an unknown source")
+	}
+}
+
+// When I list resources, I should get the health and sync error of
+// each resource, as reported by the cluster
+func TestDaemon_ListResources(t *testing.T) {
+	d, start, clean, k8s, _, _ := mockDaemon(t)
+	var namespace string
+	k8s.ResourceHealthFunc = func(ctx context.Context, maybeNamespace string) ([]cluster.ResourceHealth, error) {
+		namespace = maybeNamespace
+		return []cluster.ResourceHealth{
+			{ResourceID: resource.MustParseID(wl), Health: cluster.Health{Status: cluster.HealthHealthy}},
+			{ResourceID: resource.MustParseID(anotherWl), Health: cluster.Health{Status: cluster.HealthUnknown}, SyncError: fmt.Errorf("apply failed")},
+		}, nil
+	}
+	start()
+	defer clean()
+
+	res, err := d.ListResources(context.Background(), v13.ListResourcesOptions{Namespace: ns})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if namespace != ns {
+		t.Errorf("expected resources in namespace %q, got %q", ns, namespace)
+	}
+	expected := []v13.ResourceStatus{
+		{ID: resource.MustParseID(wl), Health: cluster.Health{Status: cluster.HealthHealthy}},
+		{ID: resource.MustParseID(anotherWl), Health: cluster.Health{Status: cluster.HealthUnknown}, SyncError: "apply failed"},
+	}
+	assert.Equal(t, expected, res)
+}
+
 // When I restart fluxd, there won't be any jobs in the cache
 func TestDaemon_JobStatusWithNoCache(t *testing.T) {
 	d, start, clean, _, _, restart := mockDaemon(t)
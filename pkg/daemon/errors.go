@@ -1,6 +1,7 @@
 package daemon
 
 import (
+	"errors"
 	"fmt"
 	"sync"
 
@@ -85,3 +86,13 @@ HEAD is
 `,
 	}
 }
+
+var errNoHistory = &fluxerr.Error{
+	Type: fluxerr.User,
+	Err:  errors.New("event history is not enabled"),
+	Help: `Event history is not enabled
+
+This daemon is not keeping a record of events. To have it do so, run
+fluxd with --event-history-size set to more than zero.
+`,
+}
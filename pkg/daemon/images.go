@@ -3,11 +3,15 @@ package daemon
 import (
 	"context"
 	"fmt"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/go-kit/kit/log"
 	"github.com/pkg/errors"
 
 	"github.com/fluxcd/flux/pkg/cluster"
+	"github.com/fluxcd/flux/pkg/image"
 	"github.com/fluxcd/flux/pkg/policy"
 	"github.com/fluxcd/flux/pkg/resource"
 	"github.com/fluxcd/flux/pkg/update"
@@ -23,6 +27,8 @@ func (d *Daemon) pollForNewAutomatedWorkloadImages(logger log.Logger) {
 		logger.Log("error", errors.Wrap(err, "getting unlocked automated resources"))
 		return
 	}
+	// Leave out those held by maintenance windows or release freezes
+	d.filterFrozen(logger, candidateWorkloads, time.Now())
 	if len(candidateWorkloads) == 0 {
 		logger.Log("msg", "no automated workloads")
 		return
@@ -40,7 +46,19 @@ func (d *Daemon) pollForNewAutomatedWorkloadImages(logger log.Logger) {
 		return
 	}
 
-	changes := calculateChanges(logger, candidateWorkloads, workloads, imageRepos)
+	// Find the workloads any images are to be promoted from
+	sources := promotionSources{}
+	if sourceIDs, err := promotionSourceIDs(candidateWorkloads); err != nil {
+		logger.Log("warning", errors.Wrap(err, "parsing promote-from policy"), "action", "skip promotions")
+	} else if len(sourceIDs) > 0 {
+		if sourceWorkloads, err := d.Cluster.SomeWorkloads(ctx, sourceIDs); err != nil {
+			logger.Log("error", errors.Wrap(err, "checking workloads to promote images from"), "action", "skip promotions")
+		} else {
+			sources = d.getPromotionSources(sourceWorkloads, time.Now())
+		}
+	}
+
+	changes := calculateChanges(logger, candidateWorkloads, workloads, imageRepos, sources, &d.platformSkips)
 
 	if len(changes.Changes) > 0 {
 		d.UpdateManifests(ctx, update.Spec{Type: update.Auto, Spec: changes})
@@ -75,7 +93,7 @@ func (d *Daemon) getAllowedAutomatedResources(ctx context.Context) (resources, e
 	return result, nil
 }
 
-func calculateChanges(logger log.Logger, candidateWorkloads resources, workloads []cluster.Workload, imageRepos update.ImageRepos) *update.Automated {
+func calculateChanges(logger log.Logger, candidateWorkloads resources, workloads []cluster.Workload, imageRepos update.ImageRepos, sources promotionSources, skips *platformSkipTracker) *update.Automated {
 	changes := &update.Automated{}
 
 	for _, workload := range workloads {
@@ -83,9 +101,16 @@ func calculateChanges(logger log.Logger, candidateWorkloads resources, workloads
 		if resource, ok := candidateWorkloads[workload.ID]; ok {
 			p = resource.Policies()
 		}
+		if _, ok := p.Get(policy.PromoteFrom); ok {
+			calculatePromotions(logger, p, workload, sources, changes)
+			continue
+		}
+		pin := p.Has(policy.PinDigest)
 	containers:
 		for _, container := range workload.ContainersOrNil() {
 			currentImageID := container.Image
+			// the image as it's known in the image metadata, i.e., by tag
+			currentTagged := currentImageID.WithDigest("")
 			pattern := policy.GetTagPattern(p, container.Name)
 			repo := currentImageID.Name
 			logger := log.With(logger, "workload", workload.ID, "container", container.Name, "repo", repo, "pattern", pattern, "current", currentImageID)
@@ -95,23 +120,168 @@ func calculateChanges(logger log.Logger, candidateWorkloads resources, workloads
 				logger.Log("warning", fmt.Sprintf("inconsistent repository metadata: %s", err), "action", "skip container")
 				continue containers
 			}
+			platforms := workload.Platforms
+			if len(platforms) == 0 {
+				platforms = defaultPlatforms
+			}
+			var skipped image.Ref
+			if newest, ok := images.Latest(); ok && newest.ID != currentTagged && !newest.SupportsPlatforms(platforms) {
+				skipped = newest.ID
+			}
+			if skips.observe(workload.ID, container.Name, skipped) {
+				logger.Log("info", "newest image is not built for every platform the workload can run on", "image", skipped, "platforms", platformsString(platforms), "action", "skip image")
+			}
+			images = supportingPlatforms(images, platforms, currentTagged)
 
-			if latest, ok := images.Latest(); ok && latest.ID != currentImageID {
-				if latest.ID.Tag == "" {
-					logger.Log("warning", "untagged image in available images", "action", "skip container")
-					continue containers
-				}
-				current := repoMetadata.FindImageWithRef(currentImageID)
-				if pattern.RequiresTimestamp() && (current.CreatedAt.IsZero() || latest.CreatedAt.IsZero()) {
-					logger.Log("warning", "image with zero created timestamp", "current", fmt.Sprintf("%s (%s)", current.ID, current.CreatedAt), "latest", fmt.Sprintf("%s (%s)", latest.ID, latest.CreatedAt), "action", "skip container")
-					continue containers
-				}
-				newImage := currentImageID.WithNewTag(latest.ID.Tag)
-				changes.Add(workload.ID, container, newImage)
-				logger.Log("info", "added update to automation run", "new", newImage, "reason", fmt.Sprintf("latest %s (%s) > current %s (%s)", latest.ID.Tag, latest.CreatedAt, currentImageID.Tag, current.CreatedAt))
+			latest, ok := images.Latest()
+			if !ok || (latest.ID == currentTagged && !pin) {
+				continue containers
+			}
+			if latest.ID.Tag == "" {
+				logger.Log("warning", "untagged image in available images", "action", "skip container")
+				continue containers
+			}
+			if pin && latest.Digest == "" {
+				logger.Log("warning", "digest of image not known, so it can't be pinned", "latest", latest.ID, "action", "skip container")
+				continue containers
+			}
+			newImage, ok := update.UpdatedImage(currentImageID, latest, pin)
+			if !ok {
+				continue containers
 			}
+			current := repoMetadata.FindImageWithRef(currentImageID)
+			if pattern.RequiresTimestamp() && (current.CreatedAt.IsZero() || latest.CreatedAt.IsZero()) {
+				logger.Log("warning", "image with zero created timestamp", "current", fmt.Sprintf("%s (%s)", current.ID, current.CreatedAt), "latest", fmt.Sprintf("%s (%s)", latest.ID, latest.CreatedAt), "action", "skip container")
+				continue containers
+			}
+			changes.Add(workload.ID, container, newImage)
+			reason := fmt.Sprintf("latest %s (%s) > current %s (%s)", latest.ID.Tag, latest.CreatedAt, currentImageID.Tag, current.CreatedAt)
+			if latest.ID.Tag == currentImageID.Tag {
+				reason = fmt.Sprintf("tag %s now refers to %s", latest.ID.Tag, latest.Digest)
+			}
+			logger.Log("info", "added update to automation run", "new", newImage, "reason", reason)
 		}
 	}
 
 	return changes
 }
+
+// The platforms images must be built for, when it isn't known where a
+// workload can run. Before platforms were recorded, only images built
+// for linux/amd64 were considered at all.
+var defaultPlatforms = []image.Platform{{OS: "linux", Arch: "amd64"}}
+
+// supportingPlatforms leaves out the images that aren't built for
+// every one of the platforms given; except the current image, so that
+// an older image is never picked in place of it.
+func supportingPlatforms(images update.SortedImageInfos, platforms []image.Platform, current image.Ref) update.SortedImageInfos {
+	var supported update.SortedImageInfos
+	for _, im := range images {
+		if im.ID == current || im.SupportsPlatforms(platforms) {
+			supported = append(supported, im)
+		}
+	}
+	return supported
+}
+
+// platformSkipTracker remembers, for each container, the newest image
+// passed over because it isn't built for every platform, so that's
+// logged when the image changes rather than at every automation run.
+type platformSkipTracker struct {
+	mu      sync.Mutex
+	skipped map[string]image.Ref
+}
+
+// observe records the image passed over for a container (or none, if
+// the ref is empty), and says whether that's to be logged; i.e., it's
+// not the image passed over last time. With a nil tracker, every
+// image passed over is logged.
+func (t *platformSkipTracker) observe(workload resource.ID, container string, skipped image.Ref) bool {
+	if t == nil {
+		return skipped != image.Ref{}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	key := workload.String() + "/" + container
+	if skipped == (image.Ref{}) {
+		delete(t.skipped, key)
+		return false
+	}
+	if t.skipped == nil {
+		t.skipped = map[string]image.Ref{}
+	}
+	last, ok := t.skipped[key]
+	t.skipped[key] = skipped
+	return !ok || last != skipped
+}
+
+func platformsString(platforms []image.Platform) string {
+	var ps []string
+	for _, p := range platforms {
+		ps = append(ps, p.String())
+	}
+	return strings.Join(ps, ",")
+}
+
+// calculatePromotions adds to the changes the images of the workload's
+// promotion source, once the source has completed its rollout and
+// (if asked) soaked for long enough. Tag patterns still apply, so an
+// image is only promoted if it would be an allowed update.
+func calculatePromotions(logger log.Logger, p policy.Set, workload cluster.Workload, sources promotionSources, changes *update.Automated) {
+	logger = log.With(logger, "workload", workload.ID)
+	sourceID, _, err := promoteFrom(p)
+	if err != nil {
+		logger.Log("warning", fmt.Sprintf("invalid promote-from policy: %s", err), "action", "skip workload")
+		return
+	}
+	soak, err := promoteSoak(p)
+	if err != nil {
+		logger.Log("warning", fmt.Sprintf("invalid promote-soak policy: %s", err), "action", "skip workload")
+		return
+	}
+	logger = log.With(logger, "source", sourceID)
+	source, ok := sources[sourceID]
+	if !ok {
+		logger.Log("warning", "workload to promote from not found", "action", "skip workload")
+		return
+	}
+	if source.readySince.IsZero() {
+		logger.Log("info", "rollout of workload to promote from not complete", "action", "skip workload")
+		return
+	}
+	if soaked := time.Since(source.readySince); soaked < soak {
+		logger.Log("info", "images of workload to promote from not yet soaked", "soaked", soaked, "soak", soak, "action", "skip workload")
+		return
+	}
+
+	pin := p.Has(policy.PinDigest)
+	for _, container := range workload.ContainersOrNil() {
+		currentImageID := container.Image
+		logger := log.With(logger, "container", container.Name, "current", currentImageID)
+		sourceContainer, ok := sourceContainer(source.workload, container)
+		if !ok {
+			logger.Log("info", "no container with the same image in workload to promote from", "action", "skip container")
+			continue
+		}
+		tag := sourceContainer.Image.Tag
+		if tag == "" {
+			continue
+		}
+		// An image can only be pinned if the workload it's promoted
+		// from runs it by digest.
+		if pin && sourceContainer.Image.Digest == "" {
+			logger.Log("warning", "image to promote is not pinned to a digest, so it can't be pinned", "image", sourceContainer.Image, "action", "skip container")
+			continue
+		}
+		newImage, ok := update.UpdatedImage(currentImageID, image.Info{ID: sourceContainer.Image, Digest: sourceContainer.Image.Digest}, pin)
+		if !ok {
+			continue
+		}
+		if pattern := policy.GetTagPattern(p, container.Name); !pattern.Matches(tag) {
+			logger.Log("info", "image to promote does not match tag pattern", "tag", tag, "pattern", pattern, "action", "skip container")
+			continue
+		}
+		changes.Add(workload.ID, container, newImage)
+		logger.Log("info", "added update to automation run", "new", newImage, "reason", fmt.Sprintf("promoted from %s, ready since %s", sourceID, source.readySince))
+	}
+}
@@ -1,6 +1,7 @@
 package daemon
 
 import (
+	"reflect"
 	"testing"
 	"time"
 
@@ -92,7 +93,7 @@ func TestCalculateChanges_Automated(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	changes := calculateChanges(logger, candidateWorkloads, workloads, imageRepos)
+	changes := calculateChanges(logger, candidateWorkloads, workloads, imageRepos, nil, nil)
 
 	if len := len(changes.Changes); len != 1 {
 		t.Errorf("Expected exactly 1 change, got %d changes", len)
@@ -100,6 +101,241 @@ func TestCalculateChanges_Automated(t *testing.T) {
 		t.Errorf("Expected changed image to be %s, got %s", newContainer1Image, newImage)
 	}
 }
+func TestCalculateChanges_Platforms(t *testing.T) {
+	logger := log.NewNopLogger()
+	resourceID := resource.MakeID(ns, "deployment", "application")
+	candidateWorkloads := resources{
+		resourceID: candidate{
+			resourceID: resourceID,
+			policies: policy.Set{
+				policy.Automated: "true",
+			},
+		},
+	}
+	linuxAMD64 := image.Platform{OS: "linux", Arch: "amd64"}
+	linuxARM64 := image.Platform{OS: "linux", Arch: "arm64"}
+	amd64 := []image.Platform{linuxAMD64}
+	arm64 := []image.Platform{linuxARM64}
+	multiArch := []image.Platform{linuxAMD64, linuxARM64}
+	windows := []image.Platform{{OS: "windows", Arch: "amd64"}}
+	info := func(ref string, created time.Time, platforms []image.Platform) image.Info {
+		i := makeImageInfo(ref, created)
+		i.Platforms = platforms
+		return i
+	}
+
+	for _, tc := range []struct {
+		name      string
+		platforms []image.Platform
+		images    []image.Info
+		expected  string
+	}{
+		{
+			name:      "newest image not built for all platforms is passed over",
+			platforms: multiArch,
+			images: []image.Info{
+				info(currentContainer1Image, time.Now(), multiArch),
+				info("container1/application:multiarch", time.Now().Add(1*time.Second), multiArch),
+				info(newContainer1Image, time.Now().Add(2*time.Second), amd64),
+			},
+			expected: "container1/application:multiarch",
+		},
+		{
+			name:      "platforms unknown, so linux/amd64 is required",
+			platforms: nil,
+			images: []image.Info{
+				info(currentContainer1Image, time.Now(), multiArch),
+				info("container1/application:amd64", time.Now().Add(1*time.Second), amd64),
+				info(newContainer1Image, time.Now().Add(2*time.Second), arm64),
+			},
+			expected: "container1/application:amd64",
+		},
+		{
+			name:      "operating system must match",
+			platforms: amd64,
+			images: []image.Info{
+				info(currentContainer1Image, time.Now(), amd64),
+				info(newContainer1Image, time.Now().Add(1*time.Second), windows),
+			},
+			expected: "",
+		},
+		{
+			name:      "older image is not picked in place of the current one",
+			platforms: arm64,
+			images: []image.Info{
+				info("container1/application:old", time.Now().Add(-1*time.Second), multiArch),
+				info(currentContainer1Image, time.Now(), amd64),
+				info(newContainer1Image, time.Now().Add(1*time.Second), amd64),
+			},
+			expected: "",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			workloads := []cluster.Workload{
+				cluster.Workload{
+					ID:        resourceID,
+					Platforms: tc.platforms,
+					Containers: cluster.ContainersOrExcuse{
+						Containers: []resource.Container{
+							{
+								Name:  container1,
+								Image: mustParseImageRef(currentContainer1Image),
+							},
+						},
+					},
+				},
+			}
+			imageRegistry := &registryMock.Registry{Images: tc.images}
+			imageRepos, err := update.FetchImageRepos(imageRegistry, clusterContainers(workloads), logger)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			changes := calculateChanges(logger, candidateWorkloads, workloads, imageRepos, nil, nil)
+
+			switch {
+			case tc.expected == "" && len(changes.Changes) != 0:
+				t.Errorf("Expected no changes, got %v", changes.Changes)
+			case tc.expected == "":
+			case len(changes.Changes) != 1:
+				t.Errorf("Expected exactly 1 change, got %d changes", len(changes.Changes))
+			case changes.Changes[0].ImageID.String() != tc.expected:
+				t.Errorf("Expected changed image to be %s, got %s", tc.expected, changes.Changes[0].ImageID)
+			}
+		})
+	}
+}
+
+func TestPlatformSkipTracker(t *testing.T) {
+	var skips platformSkipTracker
+	id := resource.MustParseID("default:deployment/app")
+	ref1 := image.Ref{Name: image.Name{Image: "org/app"}, Tag: "1.1"}
+	ref2 := image.Ref{Name: image.Name{Image: "org/app"}, Tag: "1.2"}
+
+	if !skips.observe(id, "app", ref1) {
+		t.Error("expected an image passed over to be logged the first time")
+	}
+	if skips.observe(id, "app", ref1) {
+		t.Error("expected the same image passed over not to be logged again")
+	}
+	if !skips.observe(id, "other", ref1) {
+		t.Error("expected each container to be tracked separately")
+	}
+	if !skips.observe(id, "app", ref2) {
+		t.Error("expected a different image passed over to be logged")
+	}
+	skips.observe(id, "app", image.Ref{})
+	if !skips.observe(id, "app", ref2) {
+		t.Error("expected an image passed over again after none was, to be logged")
+	}
+}
+
+func TestCalculateChanges_PinDigest(t *testing.T) {
+	logger := log.NewNopLogger()
+	resourceID := resource.MakeID(ns, "deployment", "application")
+	candidateWorkloads := resources{
+		resourceID: candidate{
+			resourceID: resourceID,
+			policies: policy.Set{
+				policy.Automated: "true",
+				policy.PinDigest: "true",
+			},
+		},
+	}
+	const (
+		digest1 = "sha256:1111111111111111111111111111111111111111111111111111111111111111"
+		digest2 = "sha256:2222222222222222222222222222222222222222222222222222222222222222"
+	)
+	info := func(ref string, created time.Time, digest string) image.Info {
+		i := makeImageInfo(ref, created)
+		i.Digest = digest
+		return i
+	}
+
+	for _, tc := range []struct {
+		name     string
+		current  string
+		images   []image.Info
+		expected string
+	}{
+		{
+			name:    "newer image is pinned",
+			current: currentContainer1Image,
+			images: []image.Info{
+				info(currentContainer1Image, time.Now(), digest1),
+				info(newContainer1Image, time.Now().Add(1*time.Second), digest2),
+			},
+			expected: newContainer1Image + "@" + digest2,
+		},
+		{
+			name:    "current image is pinned",
+			current: currentContainer1Image,
+			images: []image.Info{
+				info(currentContainer1Image, time.Now(), digest1),
+			},
+			expected: currentContainer1Image + "@" + digest1,
+		},
+		{
+			name:    "tag pushed again",
+			current: currentContainer1Image + "@" + digest1,
+			images: []image.Info{
+				info(currentContainer1Image, time.Now(), digest2),
+			},
+			expected: currentContainer1Image + "@" + digest2,
+		},
+		{
+			name:    "pinned image up to date",
+			current: currentContainer1Image + "@" + digest1,
+			images: []image.Info{
+				info(currentContainer1Image, time.Now(), digest1),
+			},
+			expected: "",
+		},
+		{
+			name:    "digest not known",
+			current: currentContainer1Image + "@" + digest1,
+			images: []image.Info{
+				info(currentContainer1Image, time.Now(), digest1),
+				info(newContainer1Image, time.Now().Add(1*time.Second), ""),
+			},
+			expected: "",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			workloads := []cluster.Workload{
+				cluster.Workload{
+					ID: resourceID,
+					Containers: cluster.ContainersOrExcuse{
+						Containers: []resource.Container{
+							{
+								Name:  container1,
+								Image: mustParseImageRef(tc.current),
+							},
+						},
+					},
+				},
+			}
+			imageRegistry := &registryMock.Registry{Images: tc.images}
+			imageRepos, err := update.FetchImageRepos(imageRegistry, clusterContainers(workloads), logger)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			changes := calculateChanges(logger, candidateWorkloads, workloads, imageRepos, nil, nil)
+
+			switch {
+			case tc.expected == "" && len(changes.Changes) != 0:
+				t.Errorf("Expected no changes, got %v", changes.Changes)
+			case tc.expected == "":
+			case len(changes.Changes) != 1:
+				t.Errorf("Expected exactly 1 change, got %d changes", len(changes.Changes))
+			case changes.Changes[0].ImageID.String() != tc.expected:
+				t.Errorf("Expected changed image to be %s, got %s", tc.expected, changes.Changes[0].ImageID)
+			}
+		})
+	}
+}
+
 func TestCalculateChanges_UntaggedImage(t *testing.T) {
 	logger := log.NewNopLogger()
 	resourceID := resource.MakeID(ns, "deployment", "application")
@@ -148,7 +384,7 @@ func TestCalculateChanges_UntaggedImage(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	changes := calculateChanges(logger, candidateWorkloads, workloads, imageRepos)
+	changes := calculateChanges(logger, candidateWorkloads, workloads, imageRepos, nil, nil)
 
 	if len := len(changes.Changes); len != 1 {
 		t.Errorf("Expected exactly 1 change, got %d changes", len)
@@ -217,7 +453,7 @@ func TestCalculateChanges_ZeroTimestamp(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	changes := calculateChanges(logger, candidateWorkloads, workloads, imageRepos)
+	changes := calculateChanges(logger, candidateWorkloads, workloads, imageRepos, nil, nil)
 
 	if len := len(changes.Changes); len != 2 {
 		t.Fatalf("Expected exactly 2 changes, got %d changes: %v", len, changes.Changes)
@@ -229,3 +465,117 @@ func TestCalculateChanges_ZeroTimestamp(t *testing.T) {
 		t.Errorf("Expected changed image to be %s, got %s", newContainer3Image, newImage)
 	}
 }
+
+func TestCalculateChanges_Promotion(t *testing.T) {
+	logger := log.NewNopLogger()
+	stagingID := resource.MakeID("staging", "deployment", "application")
+	resourceID := resource.MakeID(ns, "deployment", "application")
+	candidateWorkloads := resources{
+		resourceID: candidate{
+			resourceID: resourceID,
+			policies: policy.Set{
+				policy.Automated:   "true",
+				policy.PromoteFrom: stagingID.String(),
+				policy.PromoteSoak: "1h",
+			},
+		},
+	}
+	workloads := []cluster.Workload{
+		cluster.Workload{
+			ID: resourceID,
+			Containers: cluster.ContainersOrExcuse{
+				Containers: []resource.Container{
+					{
+						Name:  container1,
+						Image: mustParseImageRef(currentContainer1Image),
+					},
+					{
+						Name:  container2,
+						Image: mustParseImageRef(currentContainer2Image),
+					},
+				},
+			},
+		},
+	}
+	staging := cluster.Workload{
+		ID:     stagingID,
+		Status: cluster.StatusReady,
+		Containers: cluster.ContainersOrExcuse{
+			Containers: []resource.Container{
+				{
+					// a different name, but the same image repository
+					Name:  "app",
+					Image: mustParseImageRef(newContainer1Image),
+				},
+			},
+		},
+	}
+	// Newer images in the registry are not considered
+	imageRegistry := &registryMock.Registry{
+		Images: []image.Info{
+			makeImageInfo(currentContainer2Image, time.Now()),
+			makeImageInfo(newContainer2Image, time.Now().Add(1*time.Second)),
+		},
+	}
+	imageRepos, err := update.FetchImageRepos(imageRegistry, clusterContainers(workloads), logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		name       string
+		readySince time.Time
+		expected   []string
+	}{
+		{"not ready", time.Time{}, nil},
+		{"not soaked", time.Now().Add(-time.Minute), nil},
+		{"soaked", time.Now().Add(-2 * time.Hour), []string{newContainer1Image}},
+	} {
+		sources := promotionSources{stagingID: {workload: staging, readySince: c.readySince}}
+		changes := calculateChanges(logger, candidateWorkloads, workloads, imageRepos, sources, nil)
+		var images []string
+		for _, change := range changes.Changes {
+			images = append(images, change.ImageID.String())
+		}
+		if !reflect.DeepEqual(images, c.expected) {
+			t.Errorf("%s: expected changes to %v, got %v", c.name, c.expected, images)
+		}
+	}
+}
+
+func TestPromotionTracker(t *testing.T) {
+	var tracker promotionTracker
+	start := time.Now()
+	workload := cluster.Workload{
+		ID:     resource.MakeID("staging", "deployment", "application"),
+		Status: cluster.StatusUpdating,
+		Containers: cluster.ContainersOrExcuse{
+			Containers: []resource.Container{
+				{Name: container1, Image: mustParseImageRef(newContainer1Image)},
+			},
+		},
+	}
+	if since := tracker.observe(workload, start); !since.IsZero() {
+		t.Errorf("expected workload that is updating not to be ready, got ready since %s", since)
+	}
+	workload.Status = cluster.StatusReady
+	if since := tracker.observe(workload, start); !since.Equal(start) {
+		t.Errorf("expected workload to be ready since %s, got %s", start, since)
+	}
+	if since := tracker.observe(workload, start.Add(time.Minute)); !since.Equal(start) {
+		t.Errorf("expected workload to still be ready since %s, got %s", start, since)
+	}
+	// A new image starts the clock again
+	workload.Containers.Containers[0].Image = mustParseImageRef(currentContainer1Image)
+	later := start.Add(2 * time.Minute)
+	if since := tracker.observe(workload, later); !since.Equal(later) {
+		t.Errorf("expected workload to be ready since %s, got %s", later, since)
+	}
+	// The time the workload's status gives is used, as is, rather
+	// than when it was first seen to be ready
+	completed := start.Add(-time.Hour)
+	workload.ReadySince = completed
+	if since := tracker.observe(workload, later.Add(time.Minute)); !since.Equal(completed) {
+		t.Errorf("expected workload to be ready since %s, got %s", completed, since)
+	}
+}
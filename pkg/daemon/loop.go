@@ -21,6 +21,14 @@ type LoopVars struct {
 	GitVerifySignaturesMode fluxsync.VerifySignaturesMode
 	SyncState               fluxsync.State
 	ImageScanDisabled       bool
+	// How long the rollout of an automated release may take, for
+	// workloads with the `rollback-on-failure` policy, before it is
+	// rolled back
+	RollbackDeadline time.Duration
+
+	rollouts      rolloutTracker
+	promotions    promotionTracker
+	platformSkips platformSkipTracker
 
 	initOnce               sync.Once
 	syncSoon               chan struct{}
@@ -44,6 +52,10 @@ func (d *Daemon) Loop(stop chan struct{}, wg *sync.WaitGroup, logger log.Logger)
 	// Similarly checking to see if any controllers have new images
 	// available.
 	automatedWorkloadTimer := time.NewTimer(d.AutomationInterval)
+	// And checking the rollouts of automated releases, for those
+	// workloads that can be rolled back.
+	rolloutTicker := time.NewTicker(rolloutCheckInterval)
+	defer rolloutTicker.Stop()
 
 	// Keep track of current, verified (if signature verification is
 	// enabled), HEAD, so we can know when to treat a repo
@@ -54,6 +66,17 @@ func (d *Daemon) Loop(stop chan struct{}, wg *sync.WaitGroup, logger log.Logger)
 	// In-memory sync tag state
 	ratchet := &lastKnownSyncState{logger: logger, state: d.SyncState}
 
+	// The same again, for each additional git source
+	sourceHeads := map[string]string{}
+	sourceRatchets := map[string]*lastKnownSyncState{}
+	for _, src := range d.Sources {
+		sourceRatchets[src.Name] = &lastKnownSyncState{logger: log.With(logger, "source", src.Name), state: src.SyncState}
+	}
+	var sourceChanges <-chan map[string]struct{}
+	if d.SourceMirrors != nil {
+		sourceChanges = d.SourceMirrors.Changes()
+	}
+
 	// If the git repo is read-only, the image updates will fail; to
 	// avoid repeated failures in the log, mention it here and
 	// otherwise skip it when it comes around.
@@ -91,6 +114,8 @@ func (d *Daemon) Loop(stop chan struct{}, wg *sync.WaitGroup, logger log.Logger)
 			automatedWorkloadTimer.Reset(d.AutomationInterval)
 		case <-automatedWorkloadTimer.C:
 			d.AskForAutomatedWorkloadImageUpdates()
+		case <-rolloutTicker.C:
+			d.checkRollouts(logger)
 		case <-d.syncSoon:
 			if !syncTimer.Stop() {
 				select {
@@ -98,6 +123,11 @@ func (d *Daemon) Loop(stop chan struct{}, wg *sync.WaitGroup, logger log.Logger)
 				default:
 				}
 			}
+			if freeze, ok := d.syncFrozen(time.Now()); ok {
+				logger.Log("info", "sync held", "reason", freeze.Reason, "until", untilString(freeze))
+				syncTimer.Reset(d.SyncInterval)
+				continue
+			}
 			started := time.Now().UTC()
 			err := d.Sync(context.Background(), started, syncHead, ratchet)
 			syncDuration.With(
@@ -106,6 +136,7 @@ func (d *Daemon) Loop(stop chan struct{}, wg *sync.WaitGroup, logger log.Logger)
 			if err != nil {
 				logger.Log("err", err)
 			}
+			d.syncSources(sourceHeads, sourceRatchets, logger)
 			syncTimer.Reset(d.SyncInterval)
 		case <-syncTimer.C:
 			d.AskForSync()
@@ -135,6 +166,28 @@ func (d *Daemon) Loop(stop chan struct{}, wg *sync.WaitGroup, logger log.Logger)
 				syncHead = newSyncHead
 				d.AskForSync()
 			}
+		case changed := <-sourceChanges:
+			for name := range changed {
+				src, ok := d.source(name)
+				if !ok {
+					continue
+				}
+				newSyncHead, err := d.sourceSyncHead(src, logger)
+				if err != nil {
+					logger.Log("source", src.Name, "url", src.Repo.Origin().SafeURL(), "err", err)
+					continue
+				}
+				logger.Log("event", "refreshed", "source", src.Name, "url", src.Repo.Origin().SafeURL(), "branch", src.GitConfig.Branch, "HEAD", newSyncHead)
+				if newSyncHead != sourceHeads[src.Name] {
+					sourceHeads[src.Name] = newSyncHead
+					// If syncs are held, this will be synced with
+					// the next sync after they're not
+					if _, held := d.syncFrozen(time.Now()); held {
+						continue
+					}
+					d.syncSourceAndLog(src, newSyncHead, sourceRatchets[src.Name], logger)
+				}
+			}
 		case job := <-d.Jobs.Ready():
 			queueLength.Set(float64(d.Jobs.Len()))
 			jobLogger := log.With(logger, "jobID", job.ID)
@@ -162,6 +215,54 @@ func (d *Daemon) Loop(stop chan struct{}, wg *sync.WaitGroup, logger log.Logger)
 	}
 }
 
+// sourceSyncHead returns the revision an additional git source
+// should be synced to; that is, the HEAD of its branch, or the latest
+// valid revision if signature verification is enabled.
+func (d *Daemon) sourceSyncHead(src GitSource, logger log.Logger) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), d.GitTimeout)
+	defer cancel()
+	if d.GitVerifySignaturesMode == fluxsync.VerifySignaturesModeNone {
+		return src.Repo.BranchHead(ctx)
+	}
+	head, invalidCommit, err := latestValidRevision(ctx, src.Repo, src.SyncState, d.GitVerifySignaturesMode)
+	if invalidCommit.Revision != "" {
+		logger.Log("err", "found invalid GPG signature for commit", "source", src.Name, "revision", invalidCommit.Revision, "key", invalidCommit.Signature.Key)
+	}
+	return head, err
+}
+
+// syncSources syncs each additional git source that's ready, at the
+// HEAD it has now. This is done with every sync of the main repo, so
+// that sources are synced at least every `SyncInterval` too, rather
+// than only when their mirrors see new commits.
+func (d *Daemon) syncSources(heads map[string]string, ratchets map[string]*lastKnownSyncState, logger log.Logger) {
+	for _, src := range d.Sources {
+		if status, _ := src.Repo.Status(); status != git.RepoReady {
+			continue
+		}
+		head, err := d.sourceSyncHead(src, logger)
+		if err != nil {
+			logger.Log("source", src.Name, "url", src.Repo.Origin().SafeURL(), "err", err)
+			continue
+		}
+		heads[src.Name] = head
+		d.syncSourceAndLog(src, head, ratchets[src.Name], logger)
+	}
+}
+
+// syncSourceAndLog syncs an additional git source, recording the
+// outcome in the same way as for the main source.
+func (d *Daemon) syncSourceAndLog(src GitSource, head string, ratchet revisionRatchet, logger log.Logger) {
+	started := time.Now().UTC()
+	err := d.syncSource(context.Background(), started, src, head, ratchet)
+	syncDuration.With(
+		fluxmetrics.LabelSuccess, fmt.Sprint(err == nil),
+	).Observe(time.Since(started).Seconds())
+	if err != nil {
+		logger.Log("source", src.Name, "err", err)
+	}
+}
+
 // Ask for a sync, or if there's one waiting, let that happen.
 func (d *LoopVars) AskForSync() {
 	d.ensureInit()
@@ -0,0 +1,150 @@
+package daemon
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fluxcd/flux/pkg/cluster"
+	"github.com/fluxcd/flux/pkg/policy"
+	"github.com/fluxcd/flux/pkg/resource"
+)
+
+// promotionSource is a workload that another workload takes its
+// images from (by way of the `promote-from` policy), along with the
+// time since which it has been seen running those images with its
+// rollout complete. The time is zero if the rollout isn't complete.
+type promotionSource struct {
+	workload   cluster.Workload
+	readySince time.Time
+}
+
+type promotionSources map[resource.ID]promotionSource
+
+// promotionTracker works out since when each promotion source has
+// completed the rollout of its current images, so that promotions
+// can be held back until the images have soaked for a while. Where
+// the workload's status says when its rollout completed (as a
+// Deployment's does), that is used; otherwise, it remembers when the
+// workload was first seen to be ready. That is kept in memory only,
+// so if the daemon restarts, soak times for those start again.
+type promotionTracker struct {
+	mu    sync.Mutex
+	ready map[resource.ID]readyImages
+}
+
+type readyImages struct {
+	images string
+	since  time.Time
+}
+
+// observe records the state of a workload, and returns the time
+// since which it has been ready with the images it's running now.
+// If the workload's status says when that was, it's taken as given.
+func (t *promotionTracker) observe(workload cluster.Workload, now time.Time) time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.ready == nil {
+		t.ready = map[resource.ID]readyImages{}
+	}
+	if workload.Status != cluster.StatusReady {
+		delete(t.ready, workload.ID)
+		return time.Time{}
+	}
+	if !workload.ReadySince.IsZero() {
+		delete(t.ready, workload.ID)
+		return workload.ReadySince
+	}
+	images := workloadImages(workload)
+	if r, ok := t.ready[workload.ID]; ok && r.images == images {
+		return r.since
+	}
+	t.ready[workload.ID] = readyImages{images: images, since: now}
+	return now
+}
+
+func workloadImages(workload cluster.Workload) string {
+	var images []string
+	for _, c := range workload.ContainersOrNil() {
+		images = append(images, c.Name+"="+c.Image.String())
+	}
+	return strings.Join(images, ",")
+}
+
+// getPromotionSources looks up the workloads that the candidates
+// given are to be promoted from, and when each was last seen to have
+// completed its rollout.
+func (d *Daemon) getPromotionSources(workloads []cluster.Workload, now time.Time) promotionSources {
+	sources := promotionSources{}
+	for _, workload := range workloads {
+		sources[workload.ID] = promotionSource{
+			workload:   workload,
+			readySince: d.promotions.observe(workload, now),
+		}
+	}
+	return sources
+}
+
+// promotionSourceIDs returns the IDs of the workloads named in the
+// `promote-from` policies of the resources given.
+func promotionSourceIDs(candidates resources) ([]resource.ID, error) {
+	seen := resource.IDSet{}
+	var ids []resource.ID
+	for _, res := range candidates {
+		id, ok, err := promoteFrom(res.Policies())
+		if err != nil {
+			return nil, err
+		}
+		if ok && !seen.Contains(id) {
+			seen.Add([]resource.ID{id})
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// promoteFrom returns the workload named by a `promote-from` policy,
+// if there is one.
+func promoteFrom(p policy.Set) (resource.ID, bool, error) {
+	v, ok := p.Get(policy.PromoteFrom)
+	if !ok || v == "" {
+		return resource.ID{}, false, nil
+	}
+	id, err := resource.ParseID(v)
+	if err != nil {
+		return resource.ID{}, false, err
+	}
+	return id, true, nil
+}
+
+// promoteSoak returns the time a promotion source must have been
+// running its images before they are promoted; zero if not given.
+func promoteSoak(p policy.Set) (time.Duration, error) {
+	v, ok := p.Get(policy.PromoteSoak)
+	if !ok || v == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(v)
+}
+
+// sourceContainer finds the container in the source workload that
+// corresponds to the container given: the one with the same name,
+// if that uses the same image repository; or otherwise, the only
+// one using the same image repository.
+func sourceContainer(source cluster.Workload, container resource.Container) (resource.Container, bool) {
+	name := container.Image.CanonicalName()
+	var sameRepo []resource.Container
+	for _, c := range source.ContainersOrNil() {
+		if c.Image.CanonicalName() != name {
+			continue
+		}
+		if c.Name == container.Name {
+			return c, true
+		}
+		sameRepo = append(sameRepo, c)
+	}
+	if len(sameRepo) == 1 {
+		return sameRepo[0], true
+	}
+	return resource.Container{}, false
+}
@@ -0,0 +1,110 @@
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/fluxcd/flux/pkg/git"
+	"github.com/fluxcd/flux/pkg/job"
+	"github.com/fluxcd/flux/pkg/pullrequest"
+	"github.com/fluxcd/flux/pkg/resource"
+	"github.com/fluxcd/flux/pkg/update"
+)
+
+// PullRequestConfig has what's needed to propose changes in pull
+// requests, rather than pushing them to the branch synced.
+type PullRequestConfig struct {
+	Provider pullrequest.Provider
+	// Repo is the path of the git repo at its host (e.g., "org/repo")
+	Repo string
+	// BranchPrefix is prepended to the name of each branch pushed for
+	// a pull request
+	BranchPrefix string
+}
+
+// The branch for automated releases is always the same, so that each
+// automation run updates the pull request left open by the last.
+const automatedBranch = "automated"
+
+// jobBranch names the branch for the changes made by a job.
+func jobBranch(kind string, id job.ID) string {
+	return fmt.Sprintf("%s-%.8s", kind, id)
+}
+
+// push commits the changes in the working clone, and pushes them to
+// the branch synced; or, if pull requests are configured, pushes them
+// to the branch given (with the prefix configured) and proposes them
+// in a pull request. It returns the revision pushed to the branch
+// synced, or the URL of the pull request.
+func (d *Daemon) push(ctx context.Context, working *git.Checkout, commitAction git.CommitAction, n *note, branch string, result update.Result) (revision string, pullRequest string, err error) {
+	if d.PullRequests == nil {
+		if err := working.CommitAndPush(ctx, commitAction, n, d.ManifestGenerationEnabled); err != nil {
+			return "", "", err
+		}
+		revision, err := working.HeadRevision(ctx)
+		return revision, "", err
+	}
+
+	head := d.PullRequests.BranchPrefix + branch
+	// If the branch already has these changes, it's still worth making
+	// sure there's a pull request for it; e.g., in case opening it
+	// failed last time.
+	if _, err := working.CommitAndPushBranch(ctx, commitAction, n, d.ManifestGenerationEnabled, head); err != nil {
+		return "", "", err
+	}
+	title, message := splitMessage(commitAction.Message)
+	url, err := pullrequest.Propose(ctx, d.PullRequests.Provider, d.PullRequests.Repo, pullrequest.PullRequest{
+		Head:  head,
+		Base:  d.GitConfig.Branch,
+		Title: title,
+		Body:  pullRequestBody(message, result, branch == automatedBranch),
+	})
+	if err != nil {
+		return "", "", errors.Wrapf(err, "proposing the changes in branch %s", head)
+	}
+	return "", url, nil
+}
+
+// splitMessage splits a commit message into its first line, and the
+// rest.
+func splitMessage(message string) (string, string) {
+	parts := strings.SplitN(strings.TrimSpace(message), "\n", 2)
+	if len(parts) == 1 {
+		return parts[0], ""
+	}
+	return parts[0], strings.Trim(parts[1], "\n")
+}
+
+// pullRequestBody renders the body of a pull request: the rest of the
+// commit message, and a table of the workloads changed.
+func pullRequestBody(message string, result update.Result, automated bool) string {
+	buf := &bytes.Buffer{}
+	if message != "" {
+		fmt.Fprintf(buf, "%s\n\n", message)
+	}
+	fmt.Fprintln(buf, "| Workload | Status | Updates |")
+	fmt.Fprintln(buf, "| --- | --- | --- |")
+	for _, id := range result.WorkloadIDs() {
+		r := result[resource.MustParseID(id)]
+		if r.Status != update.ReleaseStatusSuccess {
+			continue
+		}
+		if len(r.PerContainer) == 0 {
+			fmt.Fprintf(buf, "| `%s` | %s | |\n", id, r.Status)
+		}
+		for _, c := range r.PerContainer {
+			fmt.Fprintf(buf, "| `%s` | %s | %s: `%s` → `%s` |\n", id, r.Status, c.Container, c.Current, c.Target)
+		}
+	}
+	fmt.Fprintln(buf)
+	if automated {
+		fmt.Fprint(buf, "This pull request was opened by Flux for automated workloads. While it's open, Flux will update it with any further image releases.\n")
+	} else {
+		fmt.Fprint(buf, "This pull request was opened by Flux.\n")
+	}
+	return buf.String()
+}
@@ -0,0 +1,44 @@
+package daemon
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/fluxcd/flux/pkg/image"
+	"github.com/fluxcd/flux/pkg/resource"
+	"github.com/fluxcd/flux/pkg/update"
+)
+
+func TestSplitMessage(t *testing.T) {
+	title, rest := splitMessage("Auto-release multiple images\n\n - a\n - b\n")
+	assert.Equal(t, "Auto-release multiple images", title)
+	assert.Equal(t, " - a\n - b", rest)
+
+	title, rest = splitMessage("Release alpine:3.12 to default:deployment/app")
+	assert.Equal(t, "Release alpine:3.12 to default:deployment/app", title)
+	assert.Equal(t, "", rest)
+}
+
+func TestPullRequestBody(t *testing.T) {
+	result := update.Result{
+		resource.MustParseID("default:deployment/app"): update.WorkloadResult{
+			Status: update.ReleaseStatusSuccess,
+			PerContainer: []update.ContainerUpdate{{
+				Container: "app",
+				Current:   image.Ref{Name: image.Name{Image: "org/app"}, Tag: "1.0"},
+				Target:    image.Ref{Name: image.Name{Image: "org/app"}, Tag: "1.1"},
+			}},
+		},
+		resource.MustParseID("default:deployment/locked"): update.WorkloadResult{
+			Status: update.ReleaseStatusSkipped,
+			Error:  "locked",
+		},
+	}
+	assert.Equal(t, "| Workload | Status | Updates |\n"+
+		"| --- | --- | --- |\n"+
+		"| `default:deployment/app` | success | app: `org/app:1.0` → `org/app:1.1` |\n"+
+		"\n"+
+		"This pull request was opened by Flux for automated workloads. While it's open, Flux will update it with any further image releases.\n",
+		pullRequestBody("", result, true))
+}
@@ -0,0 +1,270 @@
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/pkg/errors"
+
+	"github.com/fluxcd/flux/pkg/cluster"
+	"github.com/fluxcd/flux/pkg/event"
+	"github.com/fluxcd/flux/pkg/git"
+	"github.com/fluxcd/flux/pkg/job"
+	"github.com/fluxcd/flux/pkg/policy"
+	"github.com/fluxcd/flux/pkg/resource"
+	"github.com/fluxcd/flux/pkg/update"
+)
+
+const (
+	// How long a rollout is given to complete, if not configured
+	defaultRollbackDeadline = 10 * time.Minute
+	// How often tracked rollouts are checked
+	rolloutCheckInterval = 15 * time.Second
+	// Who the rollback commits are attributed to
+	rollbackUser = "Flux"
+)
+
+// rolloutCheck is an automated release of a workload with the
+// `rollback-on-failure` policy, whose rollout is being watched.
+type rolloutCheck struct {
+	workloadID resource.ID
+	// the commit of the automated release
+	revision string
+	updates  []update.ContainerUpdate
+	// when the release was synced
+	since time.Time
+}
+
+// rolloutTracker keeps the rollouts being watched, at most one per
+// workload; a later release of a workload supersedes an earlier one.
+type rolloutTracker struct {
+	mu     sync.Mutex
+	checks map[resource.ID]rolloutCheck
+}
+
+func (t *rolloutTracker) track(check rolloutCheck) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.checks == nil {
+		t.checks = map[resource.ID]rolloutCheck{}
+	}
+	t.checks[check.workloadID] = check
+}
+
+func (t *rolloutTracker) done(id resource.ID) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.checks, id)
+}
+
+func (t *rolloutTracker) pending() []rolloutCheck {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var checks []rolloutCheck
+	for _, check := range t.checks {
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+func (d *Daemon) rollbackDeadline() time.Duration {
+	if d.RollbackDeadline > 0 {
+		return d.RollbackDeadline
+	}
+	return defaultRollbackDeadline
+}
+
+// trackAutomatedRollouts starts watching the rollouts of any
+// automated releases in the events given, for those workloads that
+// have the `rollback-on-failure` policy.
+func (d *Daemon) trackAutomatedRollouts(noteEvents []event.Event, resources map[string]resource.Resource, logger log.Logger) {
+	now := time.Now()
+	for _, ev := range noteEvents {
+		metadata, ok := ev.Metadata.(*event.AutoReleaseEventMetadata)
+		if !ok {
+			continue
+		}
+		for id, result := range metadata.Result {
+			if result.Status != update.ReleaseStatusSuccess || len(result.PerContainer) == 0 {
+				continue
+			}
+			res, ok := resources[id.String()]
+			if !ok || !res.Policies().Has(policy.RollbackOnFailure) {
+				continue
+			}
+			d.rollouts.track(rolloutCheck{
+				workloadID: id,
+				revision:   metadata.Revision,
+				updates:    result.PerContainer,
+				since:      now,
+			})
+			logger.Log("info", "watching rollout of automated release", "workload", id, "revision", metadata.Revision, "deadline", d.rollbackDeadline())
+		}
+	}
+}
+
+// checkRollouts looks at the rollouts being watched, and rolls back
+// those that haven't completed by the deadline.
+func (d *Daemon) checkRollouts(logger log.Logger) {
+	checks := d.rollouts.pending()
+	if len(checks) == 0 {
+		return
+	}
+	var ids []resource.ID
+	for _, check := range checks {
+		ids = append(ids, check.workloadID)
+	}
+	workloads, err := d.Cluster.SomeWorkloads(context.Background(), ids)
+	if err != nil {
+		logger.Log("error", errors.Wrap(err, "checking rollouts of automated releases"))
+		return
+	}
+	workloadsByID := map[resource.ID]cluster.Workload{}
+	for _, workload := range workloads {
+		workloadsByID[workload.ID] = workload
+	}
+
+	deadline := d.rollbackDeadline()
+	for _, check := range checks {
+		workload, found := workloadsByID[check.workloadID]
+		switch {
+		case found && !runningImages(workload, check.updates):
+			// The workload has been changed since the release, so
+			// whatever happens now isn't down to the release.
+			d.rollouts.done(check.workloadID)
+			logger.Log("info", "workload changed since automated release; no longer watching rollout", "workload", check.workloadID, "revision", check.revision)
+		case found && workload.Status == cluster.StatusReady:
+			d.rollouts.done(check.workloadID)
+			logger.Log("info", "rollout of automated release completed", "workload", check.workloadID, "revision", check.revision)
+		case time.Since(check.since) >= deadline:
+			d.rollouts.done(check.workloadID)
+			reason := rolloutFailureReason(workload, found, deadline)
+			logger.Log("warning", "rollout of automated release did not complete; rolling back", "workload", check.workloadID, "revision", check.revision, "reason", reason)
+			d.queueJob(d.makeLoggingJobFunc(d.makeJobFromUpdate(d.rollback(check, reason))))
+		}
+	}
+}
+
+// runningImages reports whether the workload is running the images
+// the updates given were released to.
+func runningImages(workload cluster.Workload, updates []update.ContainerUpdate) bool {
+	for _, u := range updates {
+		found := false
+		for _, c := range workload.ContainersOrNil() {
+			if c.Name == u.Container {
+				found = c.Image.CanonicalRef() == u.Target.CanonicalRef()
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func rolloutFailureReason(workload cluster.Workload, found bool, deadline time.Duration) string {
+	if !found {
+		return fmt.Sprintf("workload not found in cluster %s after release", deadline)
+	}
+	rollout := workload.Rollout
+	reason := fmt.Sprintf("rollout did not complete within %s (%d of %d replicas updated, %d available)",
+		deadline, rollout.Updated, rollout.Desired, rollout.Available)
+	if len(rollout.Messages) > 0 {
+		reason += ": " + strings.Join(rollout.Messages, "; ")
+	}
+	return reason
+}
+
+// rollback returns an update that reverts the images of an automated
+// release, and locks the workload so the release isn't just made
+// again. The commit has a note with a containers release, so it is
+// reported as a release when it is synced.
+func (d *Daemon) rollback(check rolloutCheck, reason string) updateFunc {
+	return func(ctx context.Context, jobID job.ID, working *git.Checkout, logger log.Logger) (job.Result, error) {
+		var zero job.Result
+		store, err := d.getManifestStore(working)
+		if err != nil {
+			return zero, err
+		}
+
+		var reverts []update.ContainerUpdate
+		for _, u := range check.updates {
+			if err := store.SetWorkloadContainerImage(ctx, check.workloadID, u.Container, u.Current); err != nil {
+				return zero, errors.Wrapf(err, "reverting image of container %s in %s", u.Container, check.workloadID)
+			}
+			reverts = append(reverts, update.ContainerUpdate{Container: u.Container, Current: u.Target, Target: u.Current})
+		}
+		lockMsg := fmt.Sprintf("automated release %.7s rolled back: %s", check.revision, reason)
+		if _, err := store.UpdateWorkloadPolicies(ctx, check.workloadID, resource.PolicyUpdate{
+			Add: policy.Set{policy.Locked: "true", policy.LockedMsg: lockMsg, policy.LockedUser: rollbackUser},
+		}); err != nil {
+			return zero, errors.Wrapf(err, "locking %s", check.workloadID)
+		}
+
+		spec := update.Spec{
+			Type:  update.Containers,
+			Cause: update.Cause{User: rollbackUser, Message: lockMsg},
+			Spec: update.ReleaseContainersSpec{
+				Kind:           update.ReleaseKindExecute,
+				ContainerSpecs: map[resource.ID][]update.ContainerUpdate{check.workloadID: reverts},
+			},
+		}
+		result := update.Result{
+			check.workloadID: update.WorkloadResult{
+				Status:       update.ReleaseStatusSuccess,
+				PerContainer: reverts,
+			},
+		}
+		commitAction := git.CommitAction{
+			Message: rollbackCommitMessage(check, reverts, reason),
+		}
+		revision, pullRequest, err := d.push(ctx, working, commitAction, &note{JobID: jobID, Spec: spec, Result: result}, jobBranch("rollback", jobID), result)
+		if err != nil {
+			// As for releases, ask for a fetch so the next attempt
+			// is more likely to succeed.
+			d.Repo.Notify()
+			return zero, err
+		}
+
+		now := time.Now().UTC()
+		if err := d.LogEvent(event.Event{
+			ServiceIDs: []resource.ID{check.workloadID},
+			Type:       event.EventRollback,
+			StartedAt:  now,
+			EndedAt:    now,
+			LogLevel:   event.LogLevelWarn,
+			Metadata: &event.RollbackEventMetadata{
+				Revision:        revision,
+				PullRequest:     pullRequest,
+				ReleaseRevision: check.revision,
+				Reason:          reason,
+				Result:          result,
+			},
+		}); err != nil {
+			logger.Log("err", err)
+		}
+		return job.Result{
+			Revision:    revision,
+			PullRequest: pullRequest,
+			Spec:        &spec,
+			Result:      result,
+		}, nil
+	}
+}
+
+func rollbackCommitMessage(check rolloutCheck, reverts []update.ContainerUpdate, reason string) string {
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "Roll back automated release of %s\n\n", check.workloadID)
+	fmt.Fprintf(buf, "The rollout of %.7s %s.\n\n", check.revision, reason)
+	for _, u := range reverts {
+		fmt.Fprintf(buf, " - %s: %s -> %s\n", u.Container, u.Current, u.Target)
+	}
+	fmt.Fprintf(buf, "\nThe workload has been locked; unlock it to resume automated releases.\n")
+	return buf.String()
+}
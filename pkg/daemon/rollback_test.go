@@ -0,0 +1,150 @@
+package daemon
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/fluxcd/flux/pkg/cluster"
+	"github.com/fluxcd/flux/pkg/event"
+	"github.com/fluxcd/flux/pkg/manifests"
+	"github.com/fluxcd/flux/pkg/policy"
+	"github.com/fluxcd/flux/pkg/resource"
+	"github.com/fluxcd/flux/pkg/update"
+)
+
+func rolloutWorkload(img string, status string) cluster.Workload {
+	return cluster.Workload{
+		ID:     resource.MustParseID(wl),
+		Status: status,
+		Rollout: cluster.RolloutStatus{
+			Desired:   2,
+			Updated:   1,
+			Available: 1,
+			Messages:  []string{"pod crashing"},
+		},
+		Containers: cluster.ContainersOrExcuse{
+			Containers: []resource.Container{
+				{Name: container, Image: mustParseImageRef(img)},
+			},
+		},
+	}
+}
+
+func TestCheckRollouts_Completed(t *testing.T) {
+	d, _, clean, k8s, _, _ := mockDaemon(t)
+	defer clean()
+
+	k8s.SomeWorkloadsFunc = func(ctx context.Context, ids []resource.ID) ([]cluster.Workload, error) {
+		return []cluster.Workload{rolloutWorkload(newHelloImage, cluster.StatusReady)}, nil
+	}
+	d.rollouts.track(rolloutCheck{
+		workloadID: resource.MustParseID(wl),
+		revision:   "abc",
+		updates: []update.ContainerUpdate{
+			{Container: container, Current: mustParseImageRef(currentHelloImage), Target: mustParseImageRef(newHelloImage)},
+		},
+		since: time.Now().Add(-time.Hour),
+	})
+	d.checkRollouts(log.NewNopLogger())
+	assert.Empty(t, d.rollouts.pending())
+	assert.Equal(t, 0, d.Jobs.Len())
+}
+
+func TestCheckRollouts_Superseded(t *testing.T) {
+	d, _, clean, k8s, _, _ := mockDaemon(t)
+	defer clean()
+
+	k8s.SomeWorkloadsFunc = func(ctx context.Context, ids []resource.ID) ([]cluster.Workload, error) {
+		return []cluster.Workload{rolloutWorkload(oldHelloImage, cluster.StatusUpdating)}, nil
+	}
+	d.rollouts.track(rolloutCheck{
+		workloadID: resource.MustParseID(wl),
+		revision:   "abc",
+		updates: []update.ContainerUpdate{
+			{Container: container, Current: mustParseImageRef(currentHelloImage), Target: mustParseImageRef(newHelloImage)},
+		},
+		since: time.Now().Add(-time.Hour),
+	})
+	d.checkRollouts(log.NewNopLogger())
+	assert.Empty(t, d.rollouts.pending())
+	assert.Equal(t, 0, d.Jobs.Len())
+}
+
+func TestCheckRollouts_Pending(t *testing.T) {
+	d, _, clean, k8s, _, _ := mockDaemon(t)
+	defer clean()
+
+	k8s.SomeWorkloadsFunc = func(ctx context.Context, ids []resource.ID) ([]cluster.Workload, error) {
+		return []cluster.Workload{rolloutWorkload(newHelloImage, cluster.StatusUpdating)}, nil
+	}
+	d.rollouts.track(rolloutCheck{
+		workloadID: resource.MustParseID(wl),
+		revision:   "abc",
+		updates: []update.ContainerUpdate{
+			{Container: container, Current: mustParseImageRef(currentHelloImage), Target: mustParseImageRef(newHelloImage)},
+		},
+		since: time.Now(),
+	})
+	d.checkRollouts(log.NewNopLogger())
+	assert.Len(t, d.rollouts.pending(), 1)
+	assert.Equal(t, 0, d.Jobs.Len())
+}
+
+func TestDaemon_RollbackOnFailure(t *testing.T) {
+	d, start, clean, k8s, events, _ := mockDaemon(t)
+	defer clean()
+	w := newWait(t)
+
+	// Keep automated releases out of the way
+	d.ImageScanDisabled = true
+	// The release is of the image in the repo; the rollback restores
+	// the one before
+	k8s.SomeWorkloadsFunc = func(ctx context.Context, ids []resource.ID) ([]cluster.Workload, error) {
+		return []cluster.Workload{rolloutWorkload(currentHelloImage, cluster.StatusUpdating)}, nil
+	}
+	start()
+
+	d.rollouts.track(rolloutCheck{
+		workloadID: resource.MustParseID(wl),
+		revision:   "0123456789",
+		updates: []update.ContainerUpdate{
+			{Container: container, Current: mustParseImageRef(oldHelloImage), Target: mustParseImageRef(currentHelloImage)},
+		},
+		since: time.Now().Add(-2 * defaultRollbackDeadline),
+	})
+	d.checkRollouts(log.NewNopLogger())
+	assert.Empty(t, d.rollouts.pending())
+
+	w.ForImageTag(t, d, wl, container, "3")
+
+	co, err := d.Repo.Clone(context.Background(), d.GitConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer co.Clean()
+	cm := manifests.NewRawFiles(co.Dir(), co.AbsolutePaths(), d.Manifests)
+	resources, err := cm.GetAllResourcesByID(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	policies := resources[wl].Policies()
+	assert.True(t, policies.Has(policy.Locked))
+	msg, _ := policies.Get(policy.LockedMsg)
+	assert.Contains(t, msg, "automated release 0123456 rolled back")
+	assert.Contains(t, msg, "pod crashing")
+
+	w.Eventually(func() bool {
+		events.Lock()
+		defer events.Unlock()
+		for _, e := range events.events {
+			if e.Type == event.EventRollback {
+				return true
+			}
+		}
+		return false
+	}, "Waiting for rollback event")
+}
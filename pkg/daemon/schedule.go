@@ -0,0 +1,54 @@
+package daemon
+
+import (
+	"time"
+
+	"github.com/go-kit/kit/log"
+
+	"github.com/fluxcd/flux/pkg/policy"
+	"github.com/fluxcd/flux/pkg/schedule"
+)
+
+// workloadFrozen says whether automated releases of a workload with
+// the policies given are held by the schedule, at the time given. The
+// schedule used is that named by the workload's `schedule` policy, if
+// it has one; if there's no schedule by that name, the default
+// schedule is used, and an error returned along with the result.
+func workloadFrozen(s *schedule.Schedule, p policy.Set, now time.Time) (schedule.Freeze, bool, error) {
+	name, _ := p.Get(policy.Schedule)
+	s, err := s.For(name)
+	freeze, frozen := s.FrozenAt(now)
+	return freeze, frozen, err
+}
+
+// filterFrozen removes from the candidates those workloads whose
+// automated releases are held by the schedule.
+func (d *Daemon) filterFrozen(logger log.Logger, candidates resources, now time.Time) {
+	s := d.Schedule.Schedule()
+	if s == nil {
+		return
+	}
+	for id, res := range candidates {
+		freeze, frozen, err := workloadFrozen(s, res.Policies(), now)
+		if err != nil {
+			logger.Log("warning", err, "workload", id, "action", "use default schedule")
+		}
+		if frozen {
+			logger.Log("info", "automated releases held", "workload", id, "reason", freeze.Reason, "until", untilString(freeze))
+			delete(candidates, id)
+		}
+	}
+}
+
+// syncFrozen says whether syncs are held by the schedule, at the time
+// given.
+func (d *Daemon) syncFrozen(now time.Time) (schedule.Freeze, bool) {
+	return d.Schedule.Schedule().SyncFrozenAt(now)
+}
+
+func untilString(freeze schedule.Freeze) string {
+	if freeze.Until.IsZero() {
+		return "unknown"
+	}
+	return freeze.Until.Format(time.RFC3339)
+}
@@ -0,0 +1,47 @@
+package daemon
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/fluxcd/flux/pkg/policy"
+	"github.com/fluxcd/flux/pkg/schedule"
+)
+
+func TestWorkloadFrozen(t *testing.T) {
+	start := time.Date(2020, 12, 18, 17, 0, 0, 0, time.UTC)
+	end := time.Date(2021, 1, 4, 9, 0, 0, 0, time.UTC)
+	s, err := schedule.New(schedule.Config{
+		PeriodsConfig: schedule.PeriodsConfig{
+			Freezes: []schedule.PeriodConfig{{Start: &start, End: &end, Reason: "end of year"}},
+		},
+		Schedules: map[string]schedule.PeriodsConfig{"unfrozen": {}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2020, 12, 25, 0, 0, 0, 0, time.UTC)
+
+	freeze, frozen, err := workloadFrozen(s, policy.Set{policy.Automated: "true"}, now)
+	assert.NoError(t, err)
+	assert.True(t, frozen)
+	assert.Equal(t, schedule.Freeze{Until: end, Reason: "end of year"}, freeze)
+
+	_, frozen, err = workloadFrozen(s, policy.Set{policy.Schedule: "unfrozen"}, now)
+	assert.NoError(t, err)
+	assert.False(t, frozen)
+
+	_, frozen, err = workloadFrozen(s, policy.Set{policy.Schedule: schedule.None}, now)
+	assert.NoError(t, err)
+	assert.False(t, frozen)
+
+	_, frozen, err = workloadFrozen(s, policy.Set{policy.Schedule: "unknown"}, now)
+	assert.Error(t, err)
+	assert.True(t, frozen)
+
+	_, frozen, err = workloadFrozen(nil, policy.Set{}, now)
+	assert.NoError(t, err)
+	assert.False(t, frozen)
+}
@@ -0,0 +1,63 @@
+package daemon
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+
+	"github.com/fluxcd/flux/pkg/git"
+	fluxsync "github.com/fluxcd/flux/pkg/sync"
+)
+
+// GitSource is a git repo that is synced to the cluster. The daemon
+// always has a main source (given by the `Repo`, `GitConfig` and
+// `SyncState` fields); additional sources can be given in `Sources`.
+//
+// Each source has its own branch, paths and sync state, and the
+// resources from each source are applied as a sync set of their
+// own. Garbage collection only ever considers the resources marked
+// as belonging to the sync set being synced, so a source will not
+// delete resources that came from a sibling source.
+//
+// Additional sources are only synced; releases, automation and
+// policy changes are committed to the main source.
+type GitSource struct {
+	Name      string
+	Repo      *git.Repo
+	GitConfig git.Config
+	SyncState fluxsync.State
+}
+
+// mainSource returns the main git source of the daemon.
+func (d *Daemon) mainSource() GitSource {
+	return GitSource{
+		Repo:      d.Repo,
+		GitConfig: d.GitConfig,
+		SyncState: d.SyncState,
+	}
+}
+
+// source returns the additional source with the name given.
+func (d *Daemon) source(name string) (GitSource, bool) {
+	for _, src := range d.Sources {
+		if src.Name == name {
+			return src, true
+		}
+	}
+	return GitSource{}, false
+}
+
+// syncSetName returns the name of the sync set the resources of this
+// source are applied as. The main source keeps the name it has always
+// had, so that the garbage collection marks of existing resources
+// remain valid; additional sources include their name, so that two
+// sources can never end up with the same sync set.
+func (s GitSource) syncSetName() string {
+	hash := makeGitConfigHash(s.Repo.Origin(), s.GitConfig)
+	if s.Name == "" {
+		return hash
+	}
+	namehash := sha256.New()
+	namehash.Write([]byte(s.Name))
+	namehash.Write([]byte(hash))
+	return base64.RawURLEncoding.EncodeToString(namehash.Sum(nil))
+}
@@ -11,6 +11,7 @@ import (
 	"path/filepath"
 	"time"
 
+	"github.com/fluxcd/flux/pkg/api/v14"
 	"github.com/fluxcd/flux/pkg/cluster"
 	"github.com/fluxcd/flux/pkg/event"
 	"github.com/fluxcd/flux/pkg/git"
@@ -42,16 +43,32 @@ type changeSet struct {
 
 // Sync starts the synchronization of the cluster with git.
 func (d *Daemon) Sync(ctx context.Context, started time.Time, newRevision string, ratchet revisionRatchet) error {
+	return d.syncSource(ctx, started, d.mainSource(), newRevision, ratchet)
+}
+
+// syncSource synchronises the cluster with the given git source, as
+// of the revision given.
+func (d *Daemon) syncSource(ctx context.Context, started time.Time, src GitSource, newRevision string, ratchet revisionRatchet) (err error) {
+	d.publishSync(v14.UpdateSyncStarted, src, newRevision, nil)
+	defer func() {
+		d.publishSync(v14.UpdateSyncFinished, src, newRevision, err)
+	}()
+
+	logger := d.Logger
+	if src.Name != "" {
+		logger = log.With(logger, "source", src.Name)
+	}
+
 	// Make a read-only clone used for this sync
 	ctxt, cancel := context.WithTimeout(ctx, d.GitTimeout)
-	working, err := d.Repo.Export(ctxt, newRevision)
+	working, err := src.Repo.Export(ctxt, newRevision)
 	if err != nil {
 		return err
 	}
 	cancel()
 	defer func() {
 		if err := working.Clean(); err != nil {
-			d.Logger.Log("error", fmt.Sprintf("cannot clean sync clone: %s", err))
+			logger.Log("error", fmt.Sprintf("cannot clean sync clone: %s", err))
 		}
 	}()
 
@@ -65,55 +82,67 @@ func (d *Daemon) Sync(ctx context.Context, started time.Time, newRevision string
 	}
 
 	// Retrieve change set of commits we need to sync
-	c, err := getChangeSet(ctx, ratchet, newRevision, d.Repo, d.GitTimeout, d.GitConfig.Paths)
+	c, err := getChangeSet(ctx, ratchet, newRevision, src.Repo, d.GitTimeout, src.GitConfig.Paths)
 	if err != nil {
 		return err
 	}
 
-	d.Logger.Log("info", "trying to sync git changes to the cluster", "old", c.oldTagRev, "new", c.newTagRev)
+	logger.Log("info", "trying to sync git changes to the cluster", "old", c.oldTagRev, "new", c.newTagRev)
 
 	// Run actual sync of resources on cluster
-	syncSetName := makeGitConfigHash(d.Repo.Origin(), d.GitConfig)
-	resourceStore, err := d.getManifestStore(working)
+	syncSetName := src.syncSetName()
+	resourceStore, err := d.getManifestStoreWithPaths(working, src.GitConfig.Paths)
 	if err != nil {
 		return errors.Wrap(err, "reading the repository checkout")
 	}
-	resources, resourceErrors, err := doSync(ctx, resourceStore, d.Cluster, syncSetName, d.Logger)
+	resources, resourceErrors, err := doSync(ctx, resourceStore, d.Cluster, syncSetName, d, logger)
 	if err != nil {
 		return err
 	}
 
 	// Determine what resources changed during the sync
-	changedResources, err := d.getChangedResources(ctx, c, d.GitTimeout, working, resourceStore, resources)
+	changedResources, err := d.getChangedResources(ctx, c, d.GitTimeout, working, src.GitConfig.Paths, resourceStore, resources)
 	serviceIDs := resource.IDSet{}
 	for _, r := range changedResources {
 		serviceIDs.Add([]resource.ID{r.ResourceID()})
 	}
 
 	// Retrieve git notes and collect events from them
-	notes, err := d.getNotes(ctx, d.GitTimeout)
+	notes, err := d.getNotes(ctx, src, d.GitTimeout)
 	if err != nil {
 		return err
 	}
-	noteEvents, includesEvents, err := d.collectNoteEvents(ctx, c, notes, d.GitTimeout, started, d.Logger)
+	noteEvents, includesEvents, err := d.collectNoteEvents(ctx, src, c, notes, d.GitTimeout, started, logger)
 	if err != nil {
 		return err
 	}
 
 	// Report all synced commits
-	if err := logCommitEvent(d, c, serviceIDs, started, includesEvents, resourceErrors, d.Logger); err != nil {
+	if err := logCommitEvent(d, c, serviceIDs, started, includesEvents, resourceErrors, logger); err != nil {
 		return err
 	}
 
+	// Report any drift found, other than that already reported
+	if reporter, ok := d.Cluster.(fluxsync.DriftReporter); ok {
+		logDriftEvent(d, reporter.Drifted(syncSetName), started, logger)
+	}
+
 	// Report all collected events
 	for _, event := range noteEvents {
 		if err = d.LogEvent(event); err != nil {
-			d.Logger.Log("err", err)
+			logger.Log("err", err)
 			// Abort early to ensure at least once delivery of events
 			return err
 		}
 	}
 
+	// Watch the rollouts of automated releases, where asked to. Only
+	// the main repo is written to, so only its releases can be
+	// rolled back.
+	if src.Name == "" {
+		d.trackAutomatedRollouts(noteEvents, resources, logger)
+	}
+
 	// Move the revision the sync state points to
 	if ok, err := ratchet.Update(ctx, c.oldTagRev, c.newTagRev); err != nil {
 		return err
@@ -121,7 +150,7 @@ func (d *Daemon) Sync(ctx context.Context, started time.Time, newRevision string
 		return nil
 	}
 
-	err = refresh(ctx, d.GitTimeout, d.Repo)
+	err = refresh(ctx, d.GitTimeout, src.Repo)
 	return err
 }
 
@@ -153,15 +182,31 @@ func getChangeSet(ctx context.Context, state revisionRatchet, headRev string, re
 
 // doSync runs the actual sync of workloads on the cluster. It returns
 // a map with all resources it applied and sync errors it encountered.
+// If garbage collection was aborted, that's reported as an event,
+// since the rest of the sync went ahead.
 func doSync(ctx context.Context, manifestsStore manifests.Store, clus cluster.Cluster, syncSetName string,
-	logger log.Logger) (map[string]resource.Resource, []event.ResourceError, error) {
+	el eventLogger, logger log.Logger) (map[string]resource.Resource, []event.ResourceError, error) {
 	resources, err := manifestsStore.GetAllResourcesByID(ctx)
 	if err != nil {
 		return nil, nil, errors.Wrap(err, "loading resources from repo")
 	}
 
+	err = fluxsync.Sync(syncSetName, resources, clus)
+	if aborted, ok := err.(cluster.PruneAbortedError); ok {
+		logger.Log("err", aborted)
+		// Garbage collection stays aborted until someone acts, so
+		// only report it when the resources in question change
+		if !aborted.Repeated {
+			logPruneAbortedEvent(el, aborted, logger)
+		}
+		err = nil
+		if len(aborted.Errors) > 0 {
+			err = aborted.Errors
+		}
+	}
+
 	var resourceErrors []event.ResourceError
-	if err := fluxsync.Sync(syncSetName, resources, clus); err != nil {
+	if err != nil {
 		switch syncerr := err.(type) {
 		case cluster.SyncError:
 			logger.Log("err", err)
@@ -190,14 +235,14 @@ func updateSyncManifestsMetric(success, failure int) {
 // getChangedResources calculates what resources are modified during
 // this sync.
 func (d *Daemon) getChangedResources(ctx context.Context, c changeSet, timeout time.Duration, working *git.Export,
-	manifestsStore manifests.Store, resources map[string]resource.Resource) (map[string]resource.Resource, error) {
+	paths []string, manifestsStore manifests.Store, resources map[string]resource.Resource) (map[string]resource.Resource, error) {
 	if c.initialSync {
 		return resources, nil
 	}
 
 	errorf := func(err error) error { return errors.Wrap(err, "loading resources from repo") }
 	ctx, cancel := context.WithTimeout(ctx, timeout)
-	changedFiles, err := working.ChangedFiles(ctx, c.oldTagRev, d.GitConfig.Paths)
+	changedFiles, err := working.ChangedFiles(ctx, c.oldTagRev, paths)
 	if err != nil {
 		return nil, errorf(err)
 	}
@@ -235,10 +280,10 @@ func (d *Daemon) getChangedResources(ctx context.Context, c changeSet, timeout t
 	return changedResources, nil
 }
 
-// getNotes retrieves the git notes from the working clone.
-func (d *Daemon) getNotes(ctx context.Context, timeout time.Duration) (map[string]struct{}, error) {
+// getNotes retrieves the git notes from the source's repo.
+func (d *Daemon) getNotes(ctx context.Context, src GitSource, timeout time.Duration) (map[string]struct{}, error) {
 	ctx, cancel := context.WithTimeout(ctx, timeout)
-	notes, err := d.Repo.NoteRevList(ctx, d.GitConfig.NotesRef)
+	notes, err := src.Repo.NoteRevList(ctx, src.GitConfig.NotesRef)
 	cancel()
 	if err != nil {
 		return nil, errors.Wrap(err, "loading notes from repo")
@@ -251,7 +296,7 @@ func (d *Daemon) getNotes(ctx context.Context, timeout time.Duration) (map[strin
 // of what other things this sync includes e.g., releases and
 // autoreleases, that we're already posting as events, so upstream
 // can skip the sync event if it wants to.
-func (d *Daemon) collectNoteEvents(ctx context.Context, c changeSet, notes map[string]struct{}, timeout time.Duration,
+func (d *Daemon) collectNoteEvents(ctx context.Context, src GitSource, c changeSet, notes map[string]struct{}, timeout time.Duration,
 	started time.Time, logger log.Logger) ([]event.Event, map[string]bool, error) {
 	if len(c.commits) == 0 {
 		return nil, nil, nil
@@ -268,7 +313,7 @@ func (d *Daemon) collectNoteEvents(ctx context.Context, c changeSet, notes map[s
 		}
 		var n note
 		ctx, cancel := context.WithTimeout(ctx, timeout)
-		ok, err := d.Repo.GetNote(ctx, c.commits[i].Revision, d.GitConfig.NotesRef, &n)
+		ok, err := src.Repo.GetNote(ctx, c.commits[i].Revision, src.GitConfig.NotesRef, &n)
 		cancel()
 		if err != nil {
 			return nil, nil, errors.Wrap(err, "loading notes from repo")
@@ -398,6 +443,61 @@ func logCommitEvent(el eventLogger, c changeSet, serviceIDs resource.IDSet, star
 	return nil
 }
 
+// logPruneAbortedEvent reports that garbage collection was aborted,
+// and what it would have deleted.
+func logPruneAbortedEvent(el eventLogger, aborted cluster.PruneAbortedError, logger log.Logger) {
+	now := time.Now().UTC()
+	if err := el.LogEvent(event.Event{
+		ServiceIDs: aborted.Resources,
+		Type:       event.EventPruneAborted,
+		StartedAt:  now,
+		EndedAt:    now,
+		LogLevel:   event.LogLevelError,
+		Metadata: &event.PruneAbortedEventMetadata{
+			Resources: aborted.Resources,
+			Max:       aborted.Max,
+		},
+	}); err != nil {
+		logger.Log("err", err)
+	}
+}
+
+// logDriftEvent reports the drift found by a sync. Drift that was left
+// in place, and reported already, is not reported again.
+func logDriftEvent(el eventLogger, drifts []cluster.ResourceDrift, started time.Time, logger log.Logger) {
+	var ids []resource.ID
+	var resources []event.ResourceDrift
+	for _, d := range drifts {
+		if d.Repeated {
+			continue
+		}
+		changes := make([]event.FieldChange, len(d.Changes))
+		for i, c := range d.Changes {
+			changes[i] = event.FieldChange{Path: c.Path, Old: c.Old, New: c.New, Redacted: c.Redacted}
+		}
+		ids = append(ids, d.ResourceID)
+		resources = append(resources, event.ResourceDrift{
+			ID:        d.ResourceID,
+			Path:      d.Source,
+			Changes:   changes,
+			Corrected: d.Corrected,
+		})
+	}
+	if len(resources) == 0 {
+		return
+	}
+	if err := el.LogEvent(event.Event{
+		ServiceIDs: ids,
+		Type:       event.EventDrift,
+		StartedAt:  started,
+		EndedAt:    started,
+		LogLevel:   event.LogLevelWarn,
+		Metadata:   &event.DriftEventMetadata{Resources: resources},
+	}); err != nil {
+		logger.Log("err", err)
+	}
+}
+
 // refresh refreshes the repository, notifying the daemon we have a new
 // sync head.
 func refresh(ctx context.Context, timeout time.Duration, repo *git.Repo) error {
@@ -515,3 +515,107 @@ func TestDoSync_WithErrors(t *testing.T) {
 	// Check 2 sync error in stats
 	checkSyncManifestsMetrics(t, len(expectedResourceIDs)-2, 2)
 }
+
+func TestSyncSource_SeparateSyncSet(t *testing.T) {
+	d, cleanup := daemon(t)
+	defer cleanup()
+
+	sourceRepo, sourceCleanup := gittest.Repo(t)
+	defer sourceCleanup()
+	ctx := context.Background()
+	if err := sourceRepo.Ready(ctx); err != nil {
+		t.Fatal(err)
+	}
+	src := GitSource{
+		Name:      "platform",
+		Repo:      sourceRepo,
+		GitConfig: d.GitConfig,
+	}
+	d.Sources = []GitSource{src}
+
+	var syncSetNames []string
+	k8s.SyncFunc = func(def cluster.SyncSet) error {
+		syncSetNames = append(syncSetNames, def.Name)
+		return nil
+	}
+
+	head, err := d.Repo.BranchHead(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gitSync, _ := fluxsync.NewGitTagSyncProvider(d.Repo, "sync", "", fluxsync.VerifySignaturesModeNone, d.GitConfig)
+	if err := d.Sync(ctx, time.Now().UTC(), head, &lastKnownSyncState{logger: d.Logger, state: gitSync}); err != nil {
+		t.Fatal(err)
+	}
+
+	sourceHead, err := sourceRepo.BranchHead(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sourceSync, _ := fluxsync.NewGitTagSyncProvider(sourceRepo, "sync-platform", "", fluxsync.VerifySignaturesModeNone, src.GitConfig)
+	if err := d.syncSource(ctx, time.Now().UTC(), src, sourceHead, &lastKnownSyncState{logger: d.Logger, state: sourceSync}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(syncSetNames) != 2 {
+		t.Fatalf("expected two syncs, got %d", len(syncSetNames))
+	}
+	if syncSetNames[0] != makeGitConfigHash(d.Repo.Origin(), d.GitConfig) {
+		t.Errorf("main source sync set name changed: %q", syncSetNames[0])
+	}
+	if syncSetNames[0] == syncSetNames[1] {
+		t.Errorf("expected the additional source to have its own sync set, both are %q", syncSetNames[0])
+	}
+
+	// The source's sync tag has moved, independently of the main one
+	if rev, err := sourceSync.GetRevision(ctx); err != nil {
+		t.Error(err)
+	} else if rev != sourceHead {
+		t.Errorf("expected source sync state to be at %s, got %s", sourceHead, rev)
+	}
+}
+
+func TestSyncSources_WithoutChanges(t *testing.T) {
+	d, cleanup := daemon(t)
+	defer cleanup()
+
+	sourceRepo, sourceCleanup := gittest.Repo(t)
+	defer sourceCleanup()
+	ctx := context.Background()
+	if err := sourceRepo.Ready(ctx); err != nil {
+		t.Fatal(err)
+	}
+	src := GitSource{
+		Name:      "platform",
+		Repo:      sourceRepo,
+		GitConfig: d.GitConfig,
+	}
+	d.Sources = []GitSource{src}
+	sourceSync, _ := fluxsync.NewGitTagSyncProvider(sourceRepo, "sync-platform", "", fluxsync.VerifySignaturesModeNone, src.GitConfig)
+	ratchets := map[string]*lastKnownSyncState{
+		src.Name: {logger: d.Logger, state: sourceSync},
+	}
+
+	var syncs int
+	k8s.SyncFunc = func(def cluster.SyncSet) error {
+		syncs++
+		return nil
+	}
+
+	// A source is synced each time it's asked to be, whether or not
+	// it has new commits
+	heads := map[string]string{}
+	d.syncSources(heads, ratchets, d.Logger)
+	d.syncSources(heads, ratchets, d.Logger)
+	if syncs != 2 {
+		t.Errorf("expected two syncs of the source, got %d", syncs)
+	}
+
+	sourceHead, err := sourceRepo.BranchHead(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if heads[src.Name] != sourceHead {
+		t.Errorf("expected source head to be %s, got %s", sourceHead, heads[src.Name])
+	}
+}
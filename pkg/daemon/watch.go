@@ -0,0 +1,141 @@
+package daemon
+
+import (
+	"context"
+	"sync"
+
+	"github.com/fluxcd/flux/pkg/api/v14"
+	"github.com/fluxcd/flux/pkg/event"
+	"github.com/fluxcd/flux/pkg/job"
+	"github.com/fluxcd/flux/pkg/resource"
+	"github.com/fluxcd/flux/pkg/update"
+)
+
+// watchBufferSize is how many updates can be waiting to be received
+// by a watcher. A watcher that falls further behind than this is
+// dropped, rather than holding up everything else.
+const watchBufferSize = 64
+
+type watcher struct {
+	opts    v14.WatchOptions
+	updates chan v14.Update
+}
+
+// visible returns the update as the watcher may see it, and whether
+// it may see it at all. A watcher that named workloads is only
+// authorised for the namespaces of those workloads (see
+// pkg/http/auth), so it's sent the events and job results concerning
+// them, with the results of other workloads left out; and not syncs,
+// which concern the whole repo, nor jobs that don't say (yet) which
+// workloads they concern.
+func (w *watcher) visible(u v14.Update) (v14.Update, bool) {
+	if len(w.opts.Workloads) == 0 {
+		return u, true
+	}
+	switch {
+	case u.Event != nil:
+		return u, w.watching(u.Event.ServiceIDs)
+	case u.Job != nil:
+		result := update.Result{}
+		for id, r := range u.Job.Status.Result.Result {
+			if w.watching([]resource.ID{id}) {
+				result[id] = r
+			}
+		}
+		if len(result) == 0 {
+			return u, false
+		}
+		jobUpdate := *u.Job
+		jobUpdate.Status.Result.Result = result
+		u.Job = &jobUpdate
+		return u, true
+	}
+	return u, false
+}
+
+func (w *watcher) watching(ids []resource.ID) bool {
+	for _, id := range ids {
+		for _, wanted := range w.opts.Workloads {
+			if id == wanted {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// watchers keeps track of who is watching the daemon, and sends each
+// update to those interested in it. The zero value is ready to use.
+type watchers struct {
+	mu       sync.Mutex
+	watchers map[*watcher]struct{}
+}
+
+func (ws *watchers) add(opts v14.WatchOptions) *watcher {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	if ws.watchers == nil {
+		ws.watchers = map[*watcher]struct{}{}
+	}
+	w := &watcher{opts: opts, updates: make(chan v14.Update, watchBufferSize)}
+	ws.watchers[w] = struct{}{}
+	return w
+}
+
+func (ws *watchers) remove(w *watcher) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	if _, ok := ws.watchers[w]; ok {
+		delete(ws.watchers, w)
+		close(w.updates)
+	}
+}
+
+func (ws *watchers) publish(u v14.Update) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	for w := range ws.watchers {
+		visible, ok := w.visible(u)
+		if !ok {
+			continue
+		}
+		select {
+		case w.updates <- visible:
+		default:
+			delete(ws.watchers, w)
+			close(w.updates)
+		}
+	}
+}
+
+// Watch sends updates about jobs, syncs and events as they happen.
+func (d *Daemon) Watch(ctx context.Context, opts v14.WatchOptions) (<-chan v14.Update, error) {
+	w := d.watchers.add(opts)
+	go func() {
+		<-ctx.Done()
+		d.watchers.remove(w)
+	}()
+	return w.updates, nil
+}
+
+// setJobStatus records the status of a job, so it can be looked up,
+// and lets any watchers know.
+func (d *Daemon) setJobStatus(id job.ID, status job.Status) {
+	d.JobStatusCache.SetStatus(id, status)
+	d.watchers.publish(v14.Update{
+		Type: v14.UpdateJob,
+		Job:  &v14.JobUpdate{ID: id, Status: status},
+	})
+}
+
+func (d *Daemon) publishSync(typ v14.UpdateType, src GitSource, revision string, err error) {
+	update := &v14.SyncUpdate{Source: src.Name, Revision: revision}
+	if err != nil {
+		update.Error = err.Error()
+	}
+	d.watchers.publish(v14.Update{Type: typ, Sync: update})
+}
+
+func (d *Daemon) publishEvent(ev event.Event) {
+	d.watchers.publish(v14.Update{Type: v14.UpdateEvent, Event: &ev})
+}
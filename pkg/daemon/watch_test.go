@@ -0,0 +1,96 @@
+package daemon
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/fluxcd/flux/pkg/api/v14"
+	"github.com/fluxcd/flux/pkg/event"
+	"github.com/fluxcd/flux/pkg/job"
+	"github.com/fluxcd/flux/pkg/resource"
+	"github.com/fluxcd/flux/pkg/update"
+)
+
+func TestWatchers_Workloads(t *testing.T) {
+	app := resource.MustParseID("default:deployment/app")
+	other := resource.MustParseID("default:deployment/other")
+
+	var ws watchers
+	all := ws.add(v14.WatchOptions{})
+	some := ws.add(v14.WatchOptions{Workloads: []resource.ID{app}})
+
+	queued := v14.Update{Type: v14.UpdateJob, Job: &v14.JobUpdate{ID: "job", Status: job.Status{StatusString: job.StatusQueued}}}
+	finished := v14.Update{Type: v14.UpdateJob, Job: &v14.JobUpdate{ID: "job", Status: job.Status{
+		StatusString: job.StatusSucceeded,
+		Result:       job.Result{Result: update.Result{other: update.WorkloadResult{Status: update.ReleaseStatusSuccess}}},
+	}}}
+	both := v14.Update{Type: v14.UpdateJob, Job: &v14.JobUpdate{ID: "both", Status: job.Status{
+		StatusString: job.StatusSucceeded,
+		Result: job.Result{Result: update.Result{
+			app:   update.WorkloadResult{Status: update.ReleaseStatusSuccess},
+			other: update.WorkloadResult{Status: update.ReleaseStatusSuccess},
+		}},
+	}}}
+	sync := v14.Update{Type: v14.UpdateSyncStarted, Sync: &v14.SyncUpdate{Revision: "abcdef0"}}
+	appEvent := v14.Update{Type: v14.UpdateEvent, Event: &event.Event{Type: event.EventAutomate, ServiceIDs: []resource.ID{app}}}
+	otherEvent := v14.Update{Type: v14.UpdateEvent, Event: &event.Event{Type: event.EventLock, ServiceIDs: []resource.ID{other}}}
+
+	for _, u := range []v14.Update{queued, finished, both, sync, appEvent, otherEvent} {
+		ws.publish(u)
+	}
+	ws.remove(all)
+	ws.remove(some)
+
+	var gotAll, gotSome []v14.Update
+	for u := range all.updates {
+		gotAll = append(gotAll, u)
+	}
+	for u := range some.updates {
+		gotSome = append(gotSome, u)
+	}
+	assert.Equal(t, []v14.Update{queued, finished, both, sync, appEvent, otherEvent}, gotAll)
+
+	// A watcher naming workloads sees only what concerns them; the
+	// results of other workloads are left out
+	bothApp := v14.Update{Type: v14.UpdateJob, Job: &v14.JobUpdate{ID: "both", Status: job.Status{
+		StatusString: job.StatusSucceeded,
+		Result:       job.Result{Result: update.Result{app: update.WorkloadResult{Status: update.ReleaseStatusSuccess}}},
+	}}}
+	assert.Equal(t, []v14.Update{bothApp, appEvent}, gotSome)
+	assert.Len(t, both.Job.Status.Result.Result, 2, "the update published is left as it was")
+}
+
+func TestWatchers_FallenBehind(t *testing.T) {
+	var ws watchers
+	w := ws.add(v14.WatchOptions{})
+	for i := 0; i <= watchBufferSize; i++ {
+		ws.publish(v14.Update{Type: v14.UpdateSyncStarted, Sync: &v14.SyncUpdate{}})
+	}
+	n := 0
+	for range w.updates {
+		n++
+	}
+	assert.Equal(t, watchBufferSize, n, "the watcher is dropped once its buffer is full")
+	assert.Empty(t, ws.watchers)
+}
+
+func TestDaemon_Watch(t *testing.T) {
+	d := &Daemon{JobStatusCache: &job.StatusCache{Size: 10}}
+	ctx, cancel := context.WithCancel(context.Background())
+	updates, err := d.Watch(ctx, v14.WatchOptions{})
+	assert.NoError(t, err)
+
+	d.setJobStatus("job", job.Status{StatusString: job.StatusRunning})
+	u := <-updates
+	if assert.NotNil(t, u.Job) {
+		assert.Equal(t, job.ID("job"), u.Job.ID)
+		assert.Equal(t, job.StatusRunning, u.Job.Status.StatusString)
+	}
+
+	// Cancelling the context ends the stream
+	cancel()
+	for range updates {
+	}
+}
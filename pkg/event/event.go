@@ -23,6 +23,9 @@ const (
 	EventLock         = "lock"
 	EventUnlock       = "unlock"
 	EventUpdatePolicy = "update_policy"
+	EventRollback     = "rollback"
+	EventDrift        = "drift"
+	EventPruneAborted = "prune_aborted"
 
 	// This is used to label e.g., commits that we _don't_ consider an event in themselves.
 	NoneOfTheAbove = "other"
@@ -165,6 +168,45 @@ func (e Event) String() string {
 		return fmt.Sprintf("Unlocked: %s", strings.Join(strWorkloadIDs, ", "))
 	case EventUpdatePolicy:
 		return fmt.Sprintf("Updated policies: %s", strings.Join(strWorkloadIDs, ", "))
+	case EventRollback:
+		metadata := e.Metadata.(*RollbackEventMetadata)
+		if metadata.PullRequest != "" {
+			return fmt.Sprintf(
+				"Proposed rolling back automated release of %s: %s",
+				strings.Join(strWorkloadIDs, ", "),
+				metadata.Reason,
+			)
+		}
+		return fmt.Sprintf(
+			"Rolled back automated release of %s: %s",
+			strings.Join(strWorkloadIDs, ", "),
+			metadata.Reason,
+		)
+	case EventDrift:
+		metadata := e.Metadata.(*DriftEventMetadata)
+		var corrected, left []string
+		for _, r := range metadata.Resources {
+			if r.Corrected {
+				corrected = append(corrected, r.ID.String())
+			} else {
+				left = append(left, r.ID.String())
+			}
+		}
+		var parts []string
+		if len(corrected) > 0 {
+			parts = append(parts, "corrected in "+strings.Join(corrected, ", "))
+		}
+		if len(left) > 0 {
+			parts = append(parts, "left in "+strings.Join(left, ", "))
+		}
+		return fmt.Sprintf("Drift %s", strings.Join(parts, "; "))
+	case EventPruneAborted:
+		metadata := e.Metadata.(*PruneAbortedEventMetadata)
+		return fmt.Sprintf(
+			"Garbage collection aborted: %d resources to delete, more than the maximum of %d",
+			len(metadata.Resources),
+			metadata.Max,
+		)
 	default:
 		return fmt.Sprintf("Unknown event: %s", e.Type)
 	}
@@ -315,6 +357,62 @@ type AutoReleaseEventMetadata struct {
 	Spec update.Automated `json:"spec"`
 }
 
+// RollbackEventMetadata is for when an automated release is reverted,
+// because the rollout it started did not complete in time
+type RollbackEventMetadata struct {
+	// Revision is the commit which reverts the release
+	Revision string `json:"revision"`
+	// PullRequest is the URL of the pull request proposing the
+	// revert, if it was proposed rather than pushed
+	PullRequest string `json:"pullRequest,omitempty"`
+	// ReleaseRevision is the commit of the automated release
+	ReleaseRevision string `json:"releaseRevision"`
+	// Reason says why the rollout was considered to have failed
+	Reason string `json:"reason"`
+	// Result records the images restored; i.e., the target of each
+	// container update is the image from before the release
+	Result update.Result `json:"result"`
+}
+
+// DriftEventMetadata is for when resources in the cluster are found
+// to differ from their definitions in git, without git having changed
+// since they were applied
+type DriftEventMetadata struct {
+	Resources []ResourceDrift `json:"resources"`
+}
+
+// ResourceDrift is how a resource in the cluster differed from git.
+// As for Commit, this mirrors the cluster type so that serialised
+// events don't depend on an internal API.
+type ResourceDrift struct {
+	ID   resource.ID `json:"id"`
+	Path string      `json:"path"`
+	// Changes has the value of each field in the cluster as `Old`,
+	// and in git as `New`
+	Changes []FieldChange `json:"changes"`
+	// Corrected is true if the definition in git was applied again
+	Corrected bool `json:"corrected"`
+}
+
+// FieldChange is a difference in a single field, given as a dotted
+// path (e.g., `spec.replicas`). Values that may be secret are left
+// out, and the change is marked as redacted.
+type FieldChange struct {
+	Path     string      `json:"path"`
+	Old      interface{} `json:"old,omitempty"`
+	New      interface{} `json:"new,omitempty"`
+	Redacted bool        `json:"redacted,omitempty"`
+}
+
+// PruneAbortedEventMetadata is for when garbage collection would have
+// deleted more resources than allowed in a sync, and so deleted none
+type PruneAbortedEventMetadata struct {
+	// Resources are those that would have been deleted
+	Resources []resource.ID `json:"resources"`
+	// Max is the most resources allowed to be deleted by a sync
+	Max int `json:"max"`
+}
+
 type UnknownEventMetadata map[string]interface{}
 
 func (e *Event) UnmarshalJSON(in []byte) error {
@@ -364,6 +462,27 @@ func (e *Event) UnmarshalJSON(in []byte) error {
 		}
 		e.Metadata = &metadata
 		break
+	case EventRollback:
+		var metadata RollbackEventMetadata
+		if err := json.Unmarshal(wireEvent.MetadataBytes, &metadata); err != nil {
+			return err
+		}
+		e.Metadata = &metadata
+		break
+	case EventDrift:
+		var metadata DriftEventMetadata
+		if err := json.Unmarshal(wireEvent.MetadataBytes, &metadata); err != nil {
+			return err
+		}
+		e.Metadata = &metadata
+		break
+	case EventPruneAborted:
+		var metadata PruneAbortedEventMetadata
+		if err := json.Unmarshal(wireEvent.MetadataBytes, &metadata); err != nil {
+			return err
+		}
+		e.Metadata = &metadata
+		break
 	default:
 		if len(wireEvent.MetadataBytes) > 0 {
 			var metadata UnknownEventMetadata
@@ -403,6 +522,18 @@ func (rem *AutoReleaseEventMetadata) Type() string {
 	return EventAutoRelease
 }
 
+func (rem *RollbackEventMetadata) Type() string {
+	return EventRollback
+}
+
+func (dem *DriftEventMetadata) Type() string {
+	return EventDrift
+}
+
+func (pem *PruneAbortedEventMetadata) Type() string {
+	return EventPruneAborted
+}
+
 // Special exception from pointer receiver rule, as UnknownEventMetadata is a
 // type alias for a map
 func (uem UnknownEventMetadata) Type() string {
@@ -2,8 +2,10 @@ package event
 
 import (
 	"encoding/json"
+	"reflect"
 	"testing"
 
+	"github.com/fluxcd/flux/pkg/resource"
 	"github.com/fluxcd/flux/pkg/update"
 )
 
@@ -95,3 +97,38 @@ func TestEvent_ParseOldReleaseMetaData(t *testing.T) {
 		t.Error("expected service specs of len 1")
 	}
 }
+
+func TestEvent_ParseDriftMetadata(t *testing.T) {
+	origEvent := Event{
+		Type: EventDrift,
+		Metadata: &DriftEventMetadata{
+			Resources: []ResourceDrift{
+				{
+					ID:        resource.MustParseID("default:deployment/a"),
+					Path:      "a.yaml",
+					Changes:   []FieldChange{{Path: "spec.replicas", Old: float64(5), New: float64(1)}},
+					Corrected: true,
+				},
+				{
+					ID:      resource.MustParseID("default:deployment/b"),
+					Path:    "b.yaml",
+					Changes: []FieldChange{{Path: "metadata.labels", Old: map[string]interface{}{"debug": "true"}}},
+				},
+			},
+		},
+	}
+
+	bytes, _ := json.Marshal(origEvent)
+
+	e := Event{}
+	err := e.UnmarshalJSON(bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(origEvent.Metadata, e.Metadata) {
+		t.Fatalf("Drift event wasn't marshalled/unmarshalled; got %#v", e.Metadata)
+	}
+	if s := e.String(); s != "Drift corrected in default:deployment/a; left in default:deployment/b" {
+		t.Errorf("Unexpected summary: %q", s)
+	}
+}
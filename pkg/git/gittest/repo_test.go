@@ -69,6 +69,113 @@ func TestCommit(t *testing.T) {
 	}
 }
 
+func TestCommitAndPushBranch(t *testing.T) {
+	checkout, repo, cleanup := CheckoutWithConfig(t, TestConfig, testSyncTag)
+	defer cleanup()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	head, err := repo.BranchHead(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	propose := func(checkout *git.Checkout, content string) bool {
+		path := filepath.Join(checkout.Dir(), "proposed.yaml")
+		if err := ioutil.WriteFile(path, []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+		pushed, err := checkout.CommitAndPushBranch(ctx, git.CommitAction{Message: "Proposed change"}, nil, true, "flux/proposed")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pushed
+	}
+	clone := func() *git.Checkout {
+		checkout, err := repo.Clone(ctx, TestConfig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return checkout
+	}
+
+	if !propose(checkout, "FIRST CHANGE") {
+		t.Error("expected the branch to be pushed")
+	}
+	if err := repo.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+	proposed, err := repo.Revision(ctx, "heads/flux/proposed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newHead, err := repo.BranchHead(ctx); err != nil {
+		t.Fatal(err)
+	} else if newHead != head {
+		t.Errorf("expected the branch synced to be left at %s, but it's at %s", head, newHead)
+	}
+
+	// The same changes again aren't pushed ...
+	again := clone()
+	defer again.Clean()
+	if propose(again, "FIRST CHANGE") {
+		t.Error("expected the same changes not to be pushed again")
+	}
+
+	// ... but different changes replace those on the branch
+	different := clone()
+	defer different.Clean()
+	if !propose(different, "SECOND CHANGE") {
+		t.Error("expected different changes to be pushed")
+	}
+	if err := repo.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if replaced, err := repo.Revision(ctx, "heads/flux/proposed"); err != nil {
+		t.Fatal(err)
+	} else if replaced == proposed {
+		t.Error("expected the branch to have been replaced")
+	}
+}
+
+func TestFetchRevision(t *testing.T) {
+	checkout, repo, cleanup := CheckoutWithConfig(t, TestConfig, testSyncTag)
+	defer cleanup()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	path := filepath.Join(checkout.Dir(), "feature.yaml")
+	if err := ioutil.WriteFile(path, []byte("FEATURE"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := checkout.CommitAndPushBranch(ctx, git.CommitAction{Message: "Feature"}, nil, true, "feature"); err != nil {
+		t.Fatal(err)
+	}
+	pushed, err := checkout.HeadRevision(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The mirror hasn't been refreshed, so doesn't know the branch ...
+	if _, err := repo.Revision(ctx, "feature"); err == nil {
+		t.Error("expected the branch not to be in the mirror yet")
+	}
+	// ... until it's fetched
+	rev, err := repo.FetchRevision(ctx, "feature")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rev != pushed {
+		t.Errorf("expected the branch to be at %s, but got %s", pushed, rev)
+	}
+
+	if _, err := repo.FetchRevision(ctx, "nonexistent"); err == nil {
+		t.Error("expected an error for a ref that isn't upstream")
+	}
+}
+
 func TestSignedCommit(t *testing.T) {
 	gpgHome, signingKey, gpgCleanup := gpgtest.GPGKey(t)
 	defer gpgCleanup()
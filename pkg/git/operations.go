@@ -475,6 +475,12 @@ func check(ctx context.Context, workingDir string, subdirs []string, checkFullRe
 	return execGitCmd(ctx, args, gitCmdConfig{dir: workingDir}) != nil
 }
 
+// sameTree returns true if the two refs given have the same files.
+func sameTree(ctx context.Context, workingDir, ref1, ref2 string) bool {
+	args := []string{"diff", "--quiet", ref1, ref2, "--"}
+	return execGitCmd(ctx, args, gitCmdConfig{dir: workingDir}) == nil
+}
+
 func findErrorMessage(output io.Reader) string {
 	sc := bufio.NewScanner(output)
 	for sc.Scan() {
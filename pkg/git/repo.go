@@ -222,6 +222,25 @@ func (r *Repo) Revision(ctx context.Context, ref string) (string, error) {
 	return refRevision(ctx, r.dir, ref)
 }
 
+// FetchRevision fetches a ref (e.g., a branch, tag, or other ref, or
+// a commit) from upstream, then returns the revision (SHA1) it refers
+// to. Unlike Revision, this finds refs that are newer than the last
+// refresh. If the fetch fails, but the ref is already in the mirror,
+// the revision it has there is returned.
+func (r *Repo) FetchRevision(ctx context.Context, ref string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.errorIfNotReady(); err != nil {
+		return "", err
+	}
+	fetchErr := fetch(ctx, r.dir, "origin", ref)
+	rev, err := refRevision(ctx, r.dir, ref)
+	if err != nil && fetchErr != nil {
+		return "", fetchErr
+	}
+	return rev, err
+}
+
 // BranchHead returns the HEAD revision (SHA1) of the configured branch
 func (r *Repo) BranchHead(ctx context.Context) (string, error) {
 	r.mu.RLock()
@@ -133,6 +133,60 @@ func (c *Checkout) AbsolutePaths() []string {
 // CommitAndPush commits changes made in this checkout, along with any
 // extra data as a note, and pushes the commit and note to the remote repo.
 func (c *Checkout) CommitAndPush(ctx context.Context, commitAction CommitAction, note interface{}, addUntracked bool) error {
+	if err := c.commit(ctx, commitAction, note, addUntracked); err != nil {
+		return err
+	}
+
+	refs, err := c.withNotesRef(ctx, c.config.Branch)
+	if err != nil {
+		return err
+	}
+	if err := push(ctx, c.Dir(), c.upstream.URL, refs); err != nil {
+		return PushError(c.upstream.URL, err)
+	}
+	return nil
+}
+
+// CommitAndPushBranch commits changes made in this checkout, along
+// with any extra data as a note, as CommitAndPush does; but pushes the
+// commit to the branch given rather than to the branch synced, e.g.,
+// to propose the changes in a pull request. Whatever was on the branch
+// is replaced, unless it already has the same files as the commit, in
+// which case it's left alone. It returns whether the branch was
+// pushed.
+func (c *Checkout) CommitAndPushBranch(ctx context.Context, commitAction CommitAction, note interface{}, addUntracked bool, branch string) (bool, error) {
+	if err := c.commit(ctx, commitAction, note, addUntracked); err != nil {
+		return false, err
+	}
+
+	// Pushing the same changes again would only make a new commit,
+	// and trigger CI, etc., for nothing.
+	if err := fetch(ctx, c.Dir(), c.upstream.URL, "+refs/heads/"+branch+":"+proposedRef); err != nil {
+		return false, err
+	}
+	ok, err := refExists(ctx, c.Dir(), proposedRef)
+	if err != nil {
+		return false, err
+	}
+	if ok && sameTree(ctx, c.Dir(), proposedRef, "HEAD") {
+		return false, nil
+	}
+
+	refs, err := c.withNotesRef(ctx, "+HEAD:refs/heads/"+branch)
+	if err != nil {
+		return false, err
+	}
+	if err := push(ctx, c.Dir(), c.upstream.URL, refs); err != nil {
+		return false, PushError(c.upstream.URL, err)
+	}
+	return true, nil
+}
+
+// proposedRef is where the branch to be pushed by CommitAndPushBranch
+// is fetched to, to compare with.
+const proposedRef = "refs/flux/proposed"
+
+func (c *Checkout) commit(ctx context.Context, commitAction CommitAction, note interface{}, addUntracked bool) error {
 	if addUntracked {
 		if err := add(ctx, c.Dir(), "."); err != nil {
 			return err
@@ -161,19 +215,19 @@ func (c *Checkout) CommitAndPush(ctx context.Context, commitAction CommitAction,
 			return err
 		}
 	}
+	return nil
+}
 
-	refs := []string{c.config.Branch}
+// withNotesRef adds the notes ref to the refs to push, if there are
+// notes to push.
+func (c *Checkout) withNotesRef(ctx context.Context, refs ...string) ([]string, error) {
 	ok, err := refExists(ctx, c.Dir(), c.realNotesRef)
 	if ok {
 		refs = append(refs, c.realNotesRef)
 	} else if err != nil {
-		return err
-	}
-
-	if err := push(ctx, c.Dir(), c.upstream.URL, refs); err != nil {
-		return PushError(c.upstream.URL, err)
+		return nil, err
 	}
-	return nil
+	return refs, nil
 }
 
 func (c *Checkout) HeadRevision(ctx context.Context) (string, error) {
@@ -0,0 +1,145 @@
+// Package githost makes requests of the APIs of git hosts -- GitHub,
+// GitLab, Bitbucket Cloud and Gitea -- as used to set commit statuses
+// and open pull requests there.
+package githost
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/whilp/git-urls"
+)
+
+// The kinds of git host there are clients for.
+const (
+	GitHub    = "github"
+	GitLab    = "gitlab"
+	Bitbucket = "bitbucket"
+	Gitea     = "gitea"
+)
+
+// Kinds lists the kinds of git host, e.g., for flag descriptions.
+var Kinds = []string{GitHub, GitLab, Bitbucket, Gitea}
+
+// The APIs of the hosted services, used if no other is given.
+var defaultAPIURLs = map[string]string{
+	GitHub:    "https://api.github.com",
+	GitLab:    "https://gitlab.com/api/v4",
+	Bitbucket: "https://api.bitbucket.org/2.0",
+}
+
+// Client makes requests of a git host's API, authenticating with an
+// access token as that kind of host expects.
+type Client struct {
+	// Kind is the kind of git host, which says which API it has.
+	Kind string
+
+	client  *http.Client
+	baseURL string
+	token   string
+}
+
+// NewClient constructs a client for the kind of git host given, which
+// uses the API at the URL given (or, if empty, that of the hosted
+// service), authenticating with the token given. For Bitbucket, the
+// token is either an access token, or a username and app password
+// given as `<username>:<app password>`.
+func NewClient(kind, apiURL, token string, client *http.Client) (*Client, error) {
+	switch kind {
+	case GitHub, GitLab, Bitbucket, Gitea:
+	default:
+		return nil, fmt.Errorf("unknown git host %q (expected one of %s)", kind, strings.Join(Kinds, ", "))
+	}
+	if apiURL == "" {
+		apiURL = defaultAPIURLs[kind]
+	}
+	if apiURL == "" {
+		return nil, fmt.Errorf("the API URL must be given for %s", kind)
+	}
+	return &Client{
+		Kind:    kind,
+		client:  client,
+		baseURL: strings.TrimSuffix(apiURL, "/"),
+		token:   token,
+	}, nil
+}
+
+func (c *Client) header() http.Header {
+	header := http.Header{}
+	switch c.Kind {
+	case GitHub:
+		header.Set("Authorization", "token "+c.token)
+		header.Set("Accept", "application/vnd.github.v3+json")
+	case Gitea:
+		header.Set("Authorization", "token "+c.token)
+	case GitLab:
+		header.Set("Private-Token", c.token)
+	case Bitbucket:
+		if strings.Contains(c.token, ":") {
+			header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(c.token)))
+		} else {
+			header.Set("Authorization", "Bearer "+c.token)
+		}
+	}
+	return header
+}
+
+// Do makes a request of the API, at the path given relative to its
+// base URL, sending the payload (if not nil) and decoding the
+// response into out (if not nil).
+func (c *Client) Do(ctx context.Context, method, path string, payload, out interface{}) error {
+	var body io.Reader
+	if payload != nil {
+		bs, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(bs)
+	}
+	req, err := http.NewRequest(method, c.baseURL+path, body)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header = c.header()
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s %s: %s responded with status %d: %s", method, req.URL.Path, req.URL.Host, resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("decoding response to %s %s: %s", method, req.URL.Path, err)
+		}
+	}
+	return nil
+}
+
+// RepoPath gives the path of a repo at its git host (e.g., "org/repo"
+// for `git@github.com:org/repo.git`), as used to identify it in the
+// host's API.
+func RepoPath(gitURL string) (string, error) {
+	u, err := giturls.Parse(gitURL)
+	if err != nil {
+		return "", err
+	}
+	path := strings.TrimSuffix(strings.Trim(u.Path, "/"), ".git")
+	if path == "" {
+		return "", fmt.Errorf("git URL %q has no repository path", gitURL)
+	}
+	return path, nil
+}
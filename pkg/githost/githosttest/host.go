@@ -0,0 +1,47 @@
+// Package githosttest has a fake git host API, for testing the code
+// that uses one.
+package githosttest
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Request is what the fake git host received.
+type Request struct {
+	Method string
+	// Path is the escaped path, with the query if there is one
+	Path string
+	// Auth is the Authorization header, or for GitLab, the
+	// Private-Token header prefixed with "Private-Token "
+	Auth string
+	Body map[string]interface{}
+}
+
+// FakeHost records the requests it receives, and answers each with
+// the handler given.
+func FakeHost(t *testing.T, respond http.HandlerFunc) (*httptest.Server, *[]Request) {
+	var requests []Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		if bytes, _ := ioutil.ReadAll(r.Body); len(bytes) > 0 {
+			if err := json.Unmarshal(bytes, &body); err != nil {
+				t.Error(err)
+			}
+		}
+		auth := r.Header.Get("Authorization")
+		if token := r.Header.Get("Private-Token"); token != "" {
+			auth = "Private-Token " + token
+		}
+		path := r.URL.EscapedPath()
+		if r.URL.RawQuery != "" {
+			path += "?" + r.URL.RawQuery
+		}
+		requests = append(requests, Request{r.Method, path, auth, body})
+		respond(w, r)
+	}))
+	return server, &requests
+}
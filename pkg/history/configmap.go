@@ -0,0 +1,81 @@
+package history
+
+import (
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	v1 "k8s.io/client-go/kubernetes/typed/core/v1"
+
+	"github.com/fluxcd/flux/pkg/event"
+)
+
+const (
+	configMapDataKey = "events.json"
+	// A ConfigMap can be at most 1MiB; leave some room for its
+	// metadata.
+	maxConfigMapData = 900 * 1024
+)
+
+// ConfigMap keeps the history in a ConfigMap, as a JSON array of
+// events. If the events won't all fit, the oldest are dropped.
+type ConfigMap struct {
+	API  v1.ConfigMapInterface
+	Name string
+}
+
+func (c *ConfigMap) String() string {
+	return "configmap/" + c.Name
+}
+
+func (c *ConfigMap) Load() ([]event.Event, error) {
+	cm, err := c.API.Get(c.Name, metav1.GetOptions{})
+	switch {
+	case apierrors.IsNotFound(err):
+		return nil, nil
+	case err != nil:
+		return nil, errors.Wrapf(err, "getting event history from %s", c)
+	}
+	data, ok := cm.Data[configMapDataKey]
+	if !ok {
+		return nil, nil
+	}
+	var events []event.Event
+	if err := json.Unmarshal([]byte(data), &events); err != nil {
+		return nil, errors.Wrapf(err, "decoding event history from %s", c)
+	}
+	return events, nil
+}
+
+func (c *ConfigMap) Save(events []event.Event) error {
+	data, err := json.Marshal(events)
+	if err != nil {
+		return err
+	}
+	for len(data) > maxConfigMapData && len(events) > 0 {
+		// Drop a tenth (or at least one) of the events, oldest
+		// first, until it fits
+		events = events[len(events)/10+1:]
+		if data, err = json.Marshal(events); err != nil {
+			return err
+		}
+	}
+
+	cm, err := c.API.Get(c.Name, metav1.GetOptions{})
+	switch {
+	case apierrors.IsNotFound(err):
+		_, err = c.API.Create(&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: c.Name},
+			Data:       map[string]string{configMapDataKey: string(data)},
+		})
+	case err == nil:
+		if cm.Data == nil {
+			cm.Data = map[string]string{}
+		}
+		cm.Data[configMapDataKey] = string(data)
+		_, err = c.API.Update(cm)
+	}
+	return errors.Wrapf(err, "saving event history to %s", c)
+}
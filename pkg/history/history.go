@@ -0,0 +1,192 @@
+// Package history keeps a record of the events fluxd logs (syncs,
+// commits, releases and so on), so they can be looked up later, e.g.,
+// with `fluxctl history`. The record is bounded, keeping only the
+// most recent events, and can be persisted so that it survives
+// restarts.
+package history
+
+import (
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+
+	"github.com/fluxcd/flux/pkg/event"
+	"github.com/fluxcd/flux/pkg/resource"
+)
+
+// DefaultSaveInterval is how often the history is saved to its
+// backend, if there are new events.
+const DefaultSaveInterval = 10 * time.Second
+
+// Backend persists the events in the history.
+type Backend interface {
+	// Load returns the events persisted, oldest first.
+	Load() ([]event.Event, error)
+	// Save persists the events given (oldest first), replacing any
+	// saved before. It may drop the oldest events, if it can't keep
+	// them all.
+	Save([]event.Event) error
+}
+
+// Query says which events to return from the history.
+type Query struct {
+	// Workloads restricts the events to those concerning any of the
+	// workloads; if empty, all events are included.
+	Workloads []resource.ID
+	// Before restricts the events to those that started before the
+	// time given, if it is not zero. This is for paging back through
+	// the history.
+	Before time.Time
+	// Limit is the maximum number of events to return; if zero or
+	// less, there's no limit.
+	Limit int
+}
+
+// History is a bounded record of events, kept in memory and saved
+// to a backend (if there is one) in the background, so that logging
+// an event never waits on the backend.
+type History struct {
+	size         int
+	backend      Backend
+	saveInterval time.Duration
+
+	mu     sync.Mutex
+	events []event.Event
+	nextID event.EventID
+	// set when there are events not yet saved to the backend
+	dirty bool
+}
+
+// New creates a history keeping at most `size` events, loading any
+// already saved in the backend. The backend may be nil, in which case
+// the history is kept in memory only.
+func New(size int, backend Backend) (*History, error) {
+	h := &History{size: size, backend: backend, saveInterval: DefaultSaveInterval, nextID: 1}
+	if backend != nil {
+		events, err := backend.Load()
+		if err != nil {
+			return nil, err
+		}
+		h.events = events
+		h.trim()
+		for _, e := range h.events {
+			if e.ID >= h.nextID {
+				h.nextID = e.ID + 1
+			}
+		}
+	}
+	return h, nil
+}
+
+// LogEvent records an event in the history, giving it an ID if it
+// doesn't have one. The history is saved to the backend later, by
+// Loop.
+func (h *History) LogEvent(e event.Event) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if e.ID == 0 {
+		e.ID = h.nextID
+	}
+	if e.ID >= h.nextID {
+		h.nextID = e.ID + 1
+	}
+	h.events = append(h.events, e)
+	h.trim()
+	h.dirty = true
+	return nil
+}
+
+// Loop saves the history to the backend periodically, if there are
+// new events, and once more when told to stop. Saving many events at
+// once means a busy daemon doesn't rewrite the whole history for
+// every event. If there's no backend, it just waits to be told to
+// stop.
+func (h *History) Loop(stop <-chan struct{}, wg *sync.WaitGroup, logger log.Logger) {
+	defer wg.Done()
+	if h.backend == nil {
+		<-stop
+		return
+	}
+	ticker := time.NewTicker(h.saveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := h.Save(); err != nil {
+				logger.Log("err", err)
+			}
+		case <-stop:
+			if err := h.Save(); err != nil {
+				logger.Log("err", err)
+			}
+			return
+		}
+	}
+}
+
+// Save saves the history to the backend, if there are events that
+// haven't been saved. The events are copied, so that the backend can
+// take its time without holding up LogEvent.
+func (h *History) Save() error {
+	if h.backend == nil {
+		return nil
+	}
+	h.mu.Lock()
+	if !h.dirty {
+		h.mu.Unlock()
+		return nil
+	}
+	events := append([]event.Event(nil), h.events...)
+	h.dirty = false
+	h.mu.Unlock()
+
+	if err := h.backend.Save(events); err != nil {
+		// Try again next time
+		h.mu.Lock()
+		h.dirty = true
+		h.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// trim drops the oldest events, if there are more than the history
+// should keep.
+func (h *History) trim() {
+	if excess := len(h.events) - h.size; excess > 0 {
+		h.events = append([]event.Event(nil), h.events[excess:]...)
+	}
+}
+
+// Events returns the events matching the query, most recent first.
+func (h *History) Events(q Query) []event.Event {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var res []event.Event
+	for i := len(h.events) - 1; i >= 0; i-- {
+		if q.Limit > 0 && len(res) >= q.Limit {
+			break
+		}
+		e := h.events[i]
+		if !q.Before.IsZero() && !e.StartedAt.Before(q.Before) {
+			continue
+		}
+		if len(q.Workloads) > 0 && !concerns(e, q.Workloads) {
+			continue
+		}
+		res = append(res, e)
+	}
+	return res
+}
+
+func concerns(e event.Event, workloads []resource.ID) bool {
+	for _, id := range e.ServiceIDs {
+		for _, w := range workloads {
+			if id == w {
+				return true
+			}
+		}
+	}
+	return false
+}
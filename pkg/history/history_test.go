@@ -0,0 +1,138 @@
+package history
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	corefake "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/fluxcd/flux/pkg/event"
+	"github.com/fluxcd/flux/pkg/resource"
+)
+
+var (
+	app   = resource.MustParseID("default:deployment/app")
+	other = resource.MustParseID("default:deployment/other")
+	start = time.Date(2019, 10, 1, 12, 0, 0, 0, time.UTC)
+)
+
+func testEvent(minutes int, ids ...resource.ID) event.Event {
+	at := start.Add(time.Duration(minutes) * time.Minute)
+	return event.Event{
+		ServiceIDs: ids,
+		Type:       event.EventLock,
+		StartedAt:  at,
+		EndedAt:    at,
+		LogLevel:   event.LogLevelInfo,
+	}
+}
+
+func ids(events []event.Event) []event.EventID {
+	var res []event.EventID
+	for _, e := range events {
+		res = append(res, e.ID)
+	}
+	return res
+}
+
+// memoryBackend is a backend that keeps what's saved, so it can be
+// loaded again.
+type memoryBackend struct {
+	events []event.Event
+}
+
+func (m *memoryBackend) Load() ([]event.Event, error) {
+	return m.events, nil
+}
+
+func (m *memoryBackend) Save(events []event.Event) error {
+	m.events = append([]event.Event(nil), events...)
+	return nil
+}
+
+func TestHistory(t *testing.T) {
+	backend := &memoryBackend{}
+	h, err := New(3, backend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, id := range []resource.ID{app, other, app, other} {
+		assert.NoError(t, h.LogEvent(testEvent(i, id)))
+	}
+
+	// Only the most recent three are kept, most recent first
+	assert.Equal(t, []event.EventID{4, 3, 2}, ids(h.Events(Query{})))
+	assert.Equal(t, []event.EventID{3}, ids(h.Events(Query{Workloads: []resource.ID{app}})))
+	assert.Equal(t, []event.EventID{4}, ids(h.Events(Query{Limit: 1})))
+	assert.Equal(t, []event.EventID{2}, ids(h.Events(Query{Before: start.Add(2 * time.Minute)})))
+
+	// Nothing is saved until it's asked for
+	assert.Empty(t, backend.events)
+	assert.NoError(t, h.Save())
+
+	// A history loaded from the backend carries on where it left off
+	assert.Len(t, backend.events, 3)
+	h, err = New(2, backend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, h.LogEvent(testEvent(5, app)))
+	assert.Equal(t, []event.EventID{5, 4}, ids(h.Events(Query{})))
+}
+
+func TestHistoryLoop(t *testing.T) {
+	backend := &memoryBackend{}
+	h, err := New(3, backend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stop := make(chan struct{})
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	go h.Loop(stop, wg, log.NewNopLogger())
+
+	// The events logged are saved when the loop stops, if not before
+	assert.NoError(t, h.LogEvent(testEvent(0, app)))
+	assert.NoError(t, h.LogEvent(testEvent(1, other)))
+	close(stop)
+	wg.Wait()
+	assert.Equal(t, []event.EventID{1, 2}, ids(backend.events))
+}
+
+func TestConfigMap(t *testing.T) {
+	client := corefake.NewSimpleClientset()
+	cm := &ConfigMap{API: client.CoreV1().ConfigMaps("flux"), Name: "flux-history"}
+
+	// Nothing saved yet
+	events, err := cm.Load()
+	assert.NoError(t, err)
+	assert.Empty(t, events)
+
+	saved := []event.Event{testEvent(0, app), testEvent(1, other)}
+	assert.NoError(t, cm.Save(saved))
+	saved = append(saved, testEvent(2, app))
+	assert.NoError(t, cm.Save(saved))
+	events, err = cm.Load()
+	assert.NoError(t, err)
+	assert.Equal(t, saved, events)
+
+	// Events that won't all fit in a ConfigMap are dropped, oldest
+	// first
+	var big []event.Event
+	for i := 0; i < 20; i++ {
+		e := testEvent(i, app)
+		e.Message = strings.Repeat("x", 100*1024)
+		big = append(big, e)
+	}
+	assert.NoError(t, cm.Save(big))
+	events, err = cm.Load()
+	assert.NoError(t, err)
+	if assert.NotEmpty(t, events) {
+		assert.True(t, len(events) < len(big))
+		assert.Equal(t, big[len(big)-1], events[len(events)-1])
+	}
+}
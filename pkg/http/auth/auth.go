@@ -0,0 +1,175 @@
+// Package auth provides authentication and authorization for the
+// daemon's HTTP API.
+//
+// An Authenticator establishes who made a request, from the
+// credentials it carries (a bearer token, a client certificate). The
+// Authorizer then decides whether that identity is allowed to make
+// the request, given the API route and the namespaces the request is
+// concerned with. Requests without credentials are treated as being
+// from the anonymous identity, so the authorizer decides what (if
+// anything) is allowed without authenticating.
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-kit/kit/log"
+	"github.com/gorilla/mux"
+
+	fluxerr "github.com/fluxcd/flux/pkg/errors"
+	transport "github.com/fluxcd/flux/pkg/http"
+)
+
+// These follow the names Kubernetes uses, so that identities from a
+// TokenReview and from elsewhere can be treated alike in a policy.
+const (
+	AnonymousUser      = "system:anonymous"
+	AuthenticatedGroup = "system:authenticated"
+	AnonymousGroup     = "system:unauthenticated"
+)
+
+// Identity is who made a request.
+type Identity struct {
+	Name   string
+	Groups []string
+}
+
+// Anonymous is the identity given to requests without credentials.
+var Anonymous = Identity{Name: AnonymousUser, Groups: []string{AnonymousGroup}}
+
+func (id Identity) inGroup(group string) bool {
+	for _, g := range id.Groups {
+		if g == group {
+			return true
+		}
+	}
+	return false
+}
+
+// Authenticator establishes the identity behind a request. If the
+// request doesn't carry credentials of the kind it understands, or
+// carries credentials it doesn't recognise, it returns false, so
+// another authenticator can have a go. An error means the
+// credentials could not be checked, or are positively invalid.
+type Authenticator interface {
+	Authenticate(r *http.Request) (Identity, bool, error)
+}
+
+// Authenticators tries each authenticator in turn, and uses the first
+// identity established.
+type Authenticators []Authenticator
+
+func (as Authenticators) Authenticate(r *http.Request) (Identity, bool, error) {
+	var firstErr error
+	for _, a := range as {
+		id, ok, err := a.Authenticate(r)
+		if ok {
+			return id, true, nil
+		}
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return Identity{}, false, firstErr
+}
+
+// bearerToken extracts the token from an `Authorization: Bearer`
+// header, if there is one.
+func bearerToken(r *http.Request) (string, bool) {
+	header := r.Header.Get("Authorization")
+	const prefix = "bearer "
+	if len(header) <= len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return "", false
+	}
+	token := strings.TrimSpace(header[len(prefix):])
+	return token, token != ""
+}
+
+// hasCredentials says whether a request carries any credentials at
+// all, so that a request with credentials nobody recognises can be
+// refused outright, rather than treated as anonymous.
+func hasCredentials(r *http.Request) bool {
+	if _, ok := bearerToken(r); ok {
+		return true
+	}
+	return r.TLS != nil && len(r.TLS.PeerCertificates) > 0
+}
+
+type contextKey int
+
+const identityKey contextKey = 0
+
+// WithIdentity returns a context carrying the authenticated identity.
+func WithIdentity(ctx context.Context, id Identity) context.Context {
+	return context.WithValue(ctx, identityKey, id)
+}
+
+// IdentityFrom returns the authenticated identity that made the
+// request, if there is one. Anonymous requests have none.
+func IdentityFrom(ctx context.Context) (Identity, bool) {
+	id, ok := ctx.Value(identityKey).(Identity)
+	return id, ok
+}
+
+var errForbidden = errors.New("request not allowed")
+
+func forbidden(id Identity, route string) error {
+	return &fluxerr.Error{
+		Type: fluxerr.User,
+		Help: `The request was refused, since ` + id.Name + ` is not allowed to make
+it (` + route + `). Ask whoever administers Flux to allow it, in the
+API policy (see the daemon's --api-authz-policy flag).
+`,
+		Err: errForbidden,
+	}
+}
+
+// Middleware authenticates and authorizes requests. It must be used
+// on the API router (with `router.Use(...)`), since it needs the name
+// of the matched route.
+type Middleware struct {
+	Authenticator Authenticator
+	Authorizer    Authorizer
+	Logger        log.Logger
+}
+
+func (m *Middleware) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var route string
+		if current := mux.CurrentRoute(r); current != nil {
+			route = current.GetName()
+		}
+		logger := log.With(m.Logger, "route", route)
+
+		id, ok, err := m.Authenticator.Authenticate(r)
+		switch {
+		case ok:
+			r = r.WithContext(WithIdentity(r.Context(), id))
+			id.Groups = append(append([]string{}, id.Groups...), AuthenticatedGroup)
+		case err != nil || hasCredentials(r):
+			if err != nil {
+				logger.Log("err", err)
+			}
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			transport.WriteError(w, r, http.StatusUnauthorized, transport.ErrorUnauthorized)
+			return
+		default:
+			id = Anonymous
+		}
+
+		namespaces, err := requestNamespaces(route, r)
+		if err != nil {
+			transport.WriteError(w, r, http.StatusBadRequest, err)
+			return
+		}
+		if !m.Authorizer.Authorize(id, route, namespaces) {
+			logger.Log("user", id.Name, "namespaces", strings.Join(namespaces, ","), "err", errForbidden)
+			transport.WriteError(w, r, http.StatusForbidden, forbidden(id, route))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,267 @@
+package auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	authv1 "k8s.io/api/authentication/v1"
+
+	transport "github.com/fluxcd/flux/pkg/http"
+	"github.com/fluxcd/flux/pkg/resource"
+	"github.com/fluxcd/flux/pkg/update"
+)
+
+var testTokens = StaticTokens{
+	"admin-token": {Name: "alice", Groups: []string{"admins"}},
+	"dev-token":   {Name: "bob", Groups: []string{"developers"}},
+}
+
+var testPolicy = Policy{
+	Rules: []Rule{
+		{Groups: []string{"admins"}, Routes: []string{"*"}},
+		{
+			Groups:     []string{"developers"},
+			Routes:     []string{transport.ListServicesWithOptions, transport.UpdateManifests, transport.JobStatus, transport.Export, transport.SyncPlan, transport.Watch},
+			Namespaces: []string{"dev"},
+		},
+		{Users: []string{AnonymousUser}, Routes: []string{transport.Ping}},
+	},
+}
+
+// testServer serves the API routes with a handler that records the
+// identity it was called with, and the body it was given.
+func testServer(authz Authorizer) (*mux.Router, *Identity, *[]byte) {
+	var gotID Identity
+	var gotBody []byte
+	router := transport.NewAPIRouter()
+	router.Use((&Middleware{
+		Authenticator: testTokens,
+		Authorizer:    authz,
+		Logger:        log.NewNopLogger(),
+	}).Wrap)
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		gotID, _ = IdentityFrom(r.Context())
+		gotBody, _ = ioutil.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+	}
+	for _, route := range []string{transport.Ping, transport.ListServicesWithOptions, transport.UpdateManifests, transport.JobStatus, transport.Export, transport.SyncPlan, transport.Watch} {
+		router.Get(route).HandlerFunc(handler)
+	}
+	return router, &gotID, &gotBody
+}
+
+func request(t *testing.T, handler http.Handler, method, path, token string, body interface{}) int {
+	var reqBody []byte
+	if body != nil {
+		var err error
+		if reqBody, err = json.Marshal(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req := httptest.NewRequest(method, path, bytes.NewReader(reqBody))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func releaseSpec(specs ...update.ResourceSpec) update.Spec {
+	return update.Spec{
+		Type: update.Images,
+		Spec: update.ReleaseImageSpec{
+			ServiceSpecs: specs,
+			ImageSpec:    update.ImageSpecLatest,
+			Kind:         update.ReleaseKindExecute,
+		},
+	}
+}
+
+func TestMiddleware_Authentication(t *testing.T) {
+	router, gotID, _ := testServer(testPolicy)
+
+	assert.Equal(t, http.StatusOK, request(t, router, "GET", "/v11/ping", "", nil))
+	assert.Equal(t, Identity{}, *gotID, "anonymous requests carry no identity")
+
+	assert.Equal(t, http.StatusForbidden, request(t, router, "GET", "/v11/services", "", nil))
+	assert.Equal(t, http.StatusUnauthorized, request(t, router, "GET", "/v11/ping", "not-a-token", nil))
+
+	assert.Equal(t, http.StatusOK, request(t, router, "GET", "/v11/services", "admin-token", nil))
+	assert.Equal(t, "alice", gotID.Name)
+}
+
+func TestMiddleware_Authorization(t *testing.T) {
+	router, gotID, gotBody := testServer(testPolicy)
+
+	for _, c := range []struct {
+		name     string
+		method   string
+		path     string
+		body     interface{}
+		expected int
+	}{
+		{"list in allowed namespace", "GET", "/v11/services?namespace=dev", nil, http.StatusOK},
+		{"list in other namespace", "GET", "/v11/services?namespace=prod", nil, http.StatusForbidden},
+		{"list all namespaces", "GET", "/v11/services", nil, http.StatusForbidden},
+		{"list workloads in allowed namespace", "GET", "/v11/services?services=dev:deployment/app", nil, http.StatusOK},
+		{"list workloads in namespaces including another", "GET", "/v11/services?services=dev:deployment/app,prod:deployment/app", nil, http.StatusForbidden},
+		{"release in allowed namespace", "POST", "/v9/update-manifests", releaseSpec("dev:deployment/app"), http.StatusOK},
+		{"release in other namespace", "POST", "/v9/update-manifests", releaseSpec("dev:deployment/app", "prod:deployment/app"), http.StatusForbidden},
+		{"release everything", "POST", "/v9/update-manifests", releaseSpec(update.ResourceSpecAll), http.StatusForbidden},
+		{"policy update in other namespace", "POST", "/v9/update-manifests", update.Spec{
+			Type: update.Policy,
+			Spec: resource.PolicyUpdates{resource.MustParseID("prod:deployment/app"): {}},
+		}, http.StatusForbidden},
+		{"sync, which concerns all namespaces", "POST", "/v9/update-manifests", update.Spec{Type: update.Sync, Spec: update.ManualSync{}}, http.StatusForbidden},
+		{"job status, which concerns all namespaces", "GET", "/v6/jobs?id=foo", nil, http.StatusForbidden},
+		{"export, which concerns all namespaces", "GET", "/v6/export", nil, http.StatusForbidden},
+		{"sync plan, which concerns all namespaces", "GET", "/v12/sync-plan", nil, http.StatusForbidden},
+		{"watch workloads in allowed namespace", "GET", "/v14/stream?workloads=dev:deployment/app", nil, http.StatusOK},
+		{"watch everything", "GET", "/v14/stream", nil, http.StatusForbidden},
+		{"route not allowed", "GET", "/v11/ping", nil, http.StatusForbidden},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			assert.Equal(t, c.expected, request(t, router, c.method, c.path, "dev-token", c.body))
+		})
+	}
+
+	// Those concerning all namespaces are allowed by a rule that
+	// doesn't restrict namespaces
+	assert.Equal(t, http.StatusOK, request(t, router, "POST", "/v9/update-manifests", "admin-token", update.Spec{Type: update.Sync, Spec: update.ManualSync{}}))
+	assert.Equal(t, http.StatusOK, request(t, router, "GET", "/v6/export", "admin-token", nil))
+
+	// The handler still gets the body, after it's been inspected
+	assert.Equal(t, http.StatusOK, request(t, router, "POST", "/v9/update-manifests", "dev-token", releaseSpec("dev:deployment/app")))
+	assert.Equal(t, "bob", gotID.Name)
+	var spec update.Spec
+	assert.NoError(t, json.Unmarshal(*gotBody, &spec))
+	assert.Equal(t, releaseSpec("dev:deployment/app"), spec)
+}
+
+func TestDefaultPolicy(t *testing.T) {
+	router, _, _ := testServer(DefaultPolicy)
+	assert.Equal(t, http.StatusOK, request(t, router, "GET", "/v11/ping", "", nil))
+	assert.Equal(t, http.StatusForbidden, request(t, router, "POST", "/v9/update-manifests", "", releaseSpec(update.ResourceSpecAll)))
+	assert.Equal(t, http.StatusOK, request(t, router, "POST", "/v9/update-manifests", "dev-token", releaseSpec(update.ResourceSpecAll)))
+}
+
+func TestLoadTokenFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "flux-auth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "tokens.csv")
+	if err := ioutil.WriteFile(path, []byte(`# tokens for fluxd
+admin-token,alice,1,"admins,operators"
+ci-token,ci
+`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	tokens, err := LoadTokenFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, StaticTokens{
+		"admin-token": {Name: "alice", Groups: []string{"admins", "operators"}},
+		"ci-token":    {Name: "ci"},
+	}, tokens)
+}
+
+func TestLoadPolicy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "flux-auth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "policy.yaml")
+	if err := ioutil.WriteFile(path, []byte(`rules:
+- groups: [developers]
+  routes: [ListServicesWithOptions, UpdateManifests]
+  namespaces: [dev]
+`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	policy, err := LoadPolicy(path)
+	assert.NoError(t, err)
+	assert.Equal(t, Policy{Rules: []Rule{{
+		Groups:     []string{"developers"},
+		Routes:     []string{"ListServicesWithOptions", "UpdateManifests"},
+		Namespaces: []string{"dev"},
+	}}}, policy)
+
+	if err := ioutil.WriteFile(path, []byte(`rules:
+- routes: ["*"]
+`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	_, err = LoadPolicy(path)
+	assert.Error(t, err, "a rule must say who it applies to")
+}
+
+type fakeReviewer struct {
+	reviews int
+	err     error
+}
+
+func (f *fakeReviewer) Create(review *authv1.TokenReview) (*authv1.TokenReview, error) {
+	f.reviews++
+	if f.err != nil {
+		return nil, f.err
+	}
+	if review.Spec.Token == "sa-token" {
+		review.Status = authv1.TokenReviewStatus{
+			Authenticated: true,
+			User:          authv1.UserInfo{Username: "system:serviceaccount:ci:deployer", Groups: []string{"system:serviceaccounts"}},
+		}
+	}
+	return review, nil
+}
+
+func TestTokenReview(t *testing.T) {
+	reviewer := &fakeReviewer{}
+	tr := &TokenReview{Reviewer: reviewer, CacheTTL: time.Minute}
+	withToken := func(token string) *http.Request {
+		req := httptest.NewRequest("GET", "/v11/ping", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		return req
+	}
+
+	id, ok, err := tr.Authenticate(withToken("sa-token"))
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "system:serviceaccount:ci:deployer", id.Name)
+
+	// The review is remembered
+	_, ok, _ = tr.Authenticate(withToken("sa-token"))
+	assert.True(t, ok)
+	assert.Equal(t, 1, reviewer.reviews)
+
+	_, ok, err = tr.Authenticate(withToken("other-token"))
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	// Without a token, there's nothing to review
+	_, ok, _ = tr.Authenticate(httptest.NewRequest("GET", "/v11/ping", nil))
+	assert.False(t, ok)
+	assert.Equal(t, 2, reviewer.reviews)
+
+	reviewer.err = errors.New("API server unavailable")
+	_, ok, err = Authenticators{testTokens, tr}.Authenticate(withToken("another-token"))
+	assert.False(t, ok)
+	assert.Error(t, err)
+	id, ok, err = Authenticators{testTokens, tr}.Authenticate(withToken("dev-token"))
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "bob", id.Name)
+}
@@ -0,0 +1,308 @@
+package auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+
+	transport "github.com/fluxcd/flux/pkg/http"
+	"github.com/fluxcd/flux/pkg/resource"
+	"github.com/fluxcd/flux/pkg/update"
+)
+
+// AllNamespaces stands for a request that concerns every namespace
+// (e.g., listing workloads without giving a namespace). Only a rule
+// allowing all namespaces allows such a request.
+const AllNamespaces = "*"
+
+// Authorizer decides whether an identity may make a request to an
+// API route, concerning the namespaces given. Requests that don't
+// concern any namespace (e.g., asking for the version) have no
+// namespaces.
+type Authorizer interface {
+	Authorize(id Identity, route string, namespaces []string) bool
+}
+
+// Policy is a list of rules, each of which allows some identities to
+// use some routes, in some namespaces. Anything not allowed by a rule
+// is refused.
+type Policy struct {
+	Rules []Rule `yaml:"rules"`
+}
+
+// Rule allows the users and members of the groups listed to use the
+// routes listed (by name, or "*" for all routes), in the namespaces
+// listed (or "*" for all namespaces). Leaving out namespaces means
+// the rule applies regardless of namespace. Requests to routes that
+// don't concern any namespace (e.g., asking for the version) are
+// allowed by the route alone; requests that may see or change
+// anything in the cluster or the repo (e.g., exporting, or syncing)
+// concern all namespaces.
+type Rule struct {
+	Users      []string `yaml:"users"`
+	Groups     []string `yaml:"groups"`
+	Routes     []string `yaml:"routes"`
+	Namespaces []string `yaml:"namespaces"`
+}
+
+// DefaultPolicy is used when authentication is enabled but no policy
+// is given: any authenticated identity may do anything, and anonymous
+// requests may only check the daemon is up, and get its public key
+// (as liveness and readiness probes do).
+var DefaultPolicy = Policy{
+	Rules: []Rule{
+		{
+			Groups: []string{AuthenticatedGroup},
+			Routes: []string{"*"},
+		},
+		{
+			Groups: []string{AnonymousGroup},
+			Routes: []string{transport.Ping, transport.Version, transport.GetPublicSSHKey},
+		},
+	},
+}
+
+// LoadPolicy reads a policy from a YAML file.
+func LoadPolicy(path string) (Policy, error) {
+	var p Policy
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return p, err
+	}
+	if err := yaml.UnmarshalStrict(data, &p); err != nil {
+		return p, errors.Wrapf(err, "parsing API policy %s", path)
+	}
+	for i, rule := range p.Rules {
+		if len(rule.Users) == 0 && len(rule.Groups) == 0 {
+			return p, errors.Errorf("API policy rule %d has neither users nor groups", i)
+		}
+		if len(rule.Routes) == 0 {
+			return p, errors.Errorf("API policy rule %d has no routes", i)
+		}
+	}
+	return p, nil
+}
+
+func (p Policy) Authorize(id Identity, route string, namespaces []string) bool {
+	// Each namespace must be allowed by some rule (not necessarily
+	// the same rule).
+	allowed := map[string]bool{}
+	for _, rule := range p.Rules {
+		if !rule.appliesTo(id) || !contains(rule.Routes, route) {
+			continue
+		}
+		if len(rule.Namespaces) == 0 || len(namespaces) == 0 {
+			return true
+		}
+		for _, ns := range namespaces {
+			if contains(rule.Namespaces, ns) {
+				allowed[ns] = true
+			}
+		}
+		if len(allowed) == len(namespaces) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r Rule) appliesTo(id Identity) bool {
+	for _, u := range r.Users {
+		if u == id.Name {
+			return true
+		}
+	}
+	for _, g := range r.Groups {
+		if id.inGroup(g) {
+			return true
+		}
+	}
+	return false
+}
+
+// contains says whether the value is in the list, or the list
+// includes the wildcard "*".
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == "*" || v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// requestNamespaces works out which namespaces a request concerns,
+// from its parameters or (for updates) its body. The body is
+// restored, so it can be read again by the handler.
+func requestNamespaces(route string, r *http.Request) ([]string, error) {
+	nss := namespaceSet{}
+	query := r.URL.Query()
+
+	switch route {
+	case transport.ListServices, transport.ListServicesWithOptions:
+		if services := query.Get("services"); services != "" {
+			for _, s := range splitList(services) {
+				if err := nss.addID(s); err != nil {
+					return nil, err
+				}
+			}
+			break
+		}
+		nss.addNamespace(query.Get("namespace"))
+	case transport.ListImages, transport.ListImagesWithOptions:
+		if service := query.Get("service"); service != "" && service != string(update.ResourceSpecAll) {
+			if err := nss.addID(service); err != nil {
+				return nil, err
+			}
+			break
+		}
+		nss.addNamespace(query.Get("namespace"))
+	case transport.ListResources:
+		nss.addNamespace(query.Get("namespace"))
+	case transport.Export, transport.SyncPlan, transport.JobStatus:
+		// These show the whole cluster, the whole repo, or the
+		// outcome of any job (which may be about any workload)
+		nss.addNamespace(AllNamespaces)
+	case transport.Watch, transport.History:
+		// Watching, or looking at the history, without naming
+		// workloads means seeing everything
+		workloads := splitList(query.Get("workloads"))
+		if len(workloads) == 0 {
+			nss.addNamespace(AllNamespaces)
+		}
+		for _, w := range workloads {
+			if err := nss.addID(w); err != nil {
+				return nil, err
+			}
+		}
+	case transport.UpdateImages:
+		for _, s := range query["service"] {
+			if err := nss.addSpec(update.ResourceSpec(s)); err != nil {
+				return nil, err
+			}
+		}
+	case transport.UpdatePolicies:
+		body, err := peekBody(r)
+		if err != nil {
+			return nil, err
+		}
+		var updates resource.PolicyUpdates
+		if err := json.Unmarshal(body, &updates); err != nil {
+			return nil, err
+		}
+		for id := range updates {
+			nss.add(id)
+		}
+	case transport.UpdateManifests:
+		body, err := peekBody(r)
+		if err != nil {
+			return nil, err
+		}
+		var spec update.Spec
+		if err := json.Unmarshal(body, &spec); err != nil {
+			return nil, err
+		}
+		if err := nss.addUpdateSpec(spec); err != nil {
+			return nil, err
+		}
+	}
+	return nss.list(), nil
+}
+
+type namespaceSet map[string]struct{}
+
+func (s namespaceSet) addNamespace(ns string) {
+	if ns == "" {
+		ns = AllNamespaces
+	}
+	s[ns] = struct{}{}
+}
+
+func (s namespaceSet) add(id resource.ID) {
+	ns, _, _ := id.Components()
+	s.addNamespace(ns)
+}
+
+func (s namespaceSet) addID(str string) error {
+	id, err := resource.ParseID(str)
+	if err != nil {
+		return err
+	}
+	s.add(id)
+	return nil
+}
+
+func (s namespaceSet) addSpec(spec update.ResourceSpec) error {
+	if spec == update.ResourceSpecAll {
+		s.addNamespace(AllNamespaces)
+		return nil
+	}
+	return s.addID(string(spec))
+}
+
+func (s namespaceSet) addUpdateSpec(spec update.Spec) error {
+	switch u := spec.Spec.(type) {
+	case update.ReleaseImageSpec:
+		for _, ss := range u.ServiceSpecs {
+			if err := s.addSpec(ss); err != nil {
+				return err
+			}
+		}
+	case update.ReleaseContainersSpec:
+		for id := range u.ContainerSpecs {
+			s.add(id)
+		}
+	case resource.PolicyUpdates:
+		for id := range u {
+			s.add(id)
+		}
+	case update.Automated:
+		for _, change := range u.Changes {
+			s.add(change.WorkloadID)
+		}
+	case update.ManualSync:
+		// A sync applies everything in the repo
+		s.addNamespace(AllNamespaces)
+	}
+	return nil
+}
+
+func (s namespaceSet) list() []string {
+	var nss []string
+	for ns := range s {
+		nss = append(nss, ns)
+	}
+	sort.Strings(nss)
+	return nss
+}
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// peekBody reads the body of a request, and replaces it so it can be
+// read again.
+func peekBody(r *http.Request) ([]byte, error) {
+	if r.Body == nil {
+		return nil, nil
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
@@ -0,0 +1,27 @@
+package auth
+
+import (
+	"net/http"
+)
+
+// ClientCertificates authenticates requests made with a TLS client
+// certificate. The certificate must have been verified by the server
+// against the client CA (i.e., the server's TLS config must have
+// `ClientAuth` of at least `VerifyClientCertIfGiven`); as in
+// Kubernetes, the common name is taken as the user name, and the
+// organisations as the groups.
+type ClientCertificates struct{}
+
+func (ClientCertificates) Authenticate(r *http.Request) (Identity, bool, error) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return Identity{}, false, nil
+	}
+	cert := r.TLS.VerifiedChains[0][0]
+	if cert.Subject.CommonName == "" {
+		return Identity{}, false, nil
+	}
+	return Identity{
+		Name:   cert.Subject.CommonName,
+		Groups: append([]string{}, cert.Subject.Organization...),
+	}, true, nil
+}
@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"encoding/csv"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// StaticTokens authenticates requests bearing one of a fixed set of
+// tokens.
+type StaticTokens map[string]Identity
+
+// LoadTokenFile reads tokens from a CSV file in the same format
+// Kubernetes uses for static tokens:
+//
+//	token,user,uid,"group1,group2"
+//
+// The uid is ignored, and the groups are optional.
+func LoadTokenFile(path string) (StaticTokens, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	tokens := StaticTokens{}
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	r.Comment = '#'
+	for line := 1; ; line++ {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "reading token file %s", path)
+		}
+		if len(record) < 2 || record[0] == "" || record[1] == "" {
+			return nil, errors.Errorf("token file %s, line %d: expected at least a token and a user name", path, line)
+		}
+		id := Identity{Name: record[1]}
+		if len(record) > 3 && record[3] != "" {
+			for _, g := range strings.Split(record[3], ",") {
+				id.Groups = append(id.Groups, strings.TrimSpace(g))
+			}
+		}
+		tokens[record[0]] = id
+	}
+	return tokens, nil
+}
+
+func (ts StaticTokens) Authenticate(r *http.Request) (Identity, bool, error) {
+	token, ok := bearerToken(r)
+	if !ok {
+		return Identity{}, false, nil
+	}
+	for t, id := range ts {
+		if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			return id, true, nil
+		}
+	}
+	return Identity{}, false, nil
+}
@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	authv1 "k8s.io/api/authentication/v1"
+)
+
+// TokenReviewer is the part of the Kubernetes client needed to review
+// tokens; i.e., `clientset.AuthenticationV1().TokenReviews()`.
+type TokenReviewer interface {
+	Create(*authv1.TokenReview) (*authv1.TokenReview, error)
+}
+
+// TokenReview authenticates bearer tokens by asking the Kubernetes
+// API server to review them. This means service account tokens, and
+// tokens from whichever authentication the cluster is set up with,
+// can be used. Reviews are remembered for a short while, so that
+// commands making several requests don't each need a review.
+type TokenReview struct {
+	Reviewer  TokenReviewer
+	Audiences []string
+	CacheTTL  time.Duration
+
+	mu    sync.Mutex
+	cache map[[sha256.Size]byte]cachedReview
+}
+
+type cachedReview struct {
+	id      Identity
+	expires time.Time
+}
+
+func (tr *TokenReview) Authenticate(r *http.Request) (Identity, bool, error) {
+	token, ok := bearerToken(r)
+	if !ok {
+		return Identity{}, false, nil
+	}
+	key := sha256.Sum256([]byte(token))
+	now := time.Now()
+
+	tr.mu.Lock()
+	cached, ok := tr.cache[key]
+	tr.mu.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached.id, true, nil
+	}
+
+	review, err := tr.Reviewer.Create(&authv1.TokenReview{
+		Spec: authv1.TokenReviewSpec{
+			Token:     token,
+			Audiences: tr.Audiences,
+		},
+	})
+	if err != nil {
+		return Identity{}, false, err
+	}
+	if review.Status.Error != "" {
+		return Identity{}, false, errors.New(review.Status.Error)
+	}
+	if !review.Status.Authenticated {
+		return Identity{}, false, nil
+	}
+	id := Identity{
+		Name:   review.Status.User.Username,
+		Groups: review.Status.User.Groups,
+	}
+	if tr.CacheTTL > 0 {
+		tr.mu.Lock()
+		if tr.cache == nil {
+			tr.cache = map[[sha256.Size]byte]cachedReview{}
+		}
+		for k, c := range tr.cache {
+			if now.After(c.expires) {
+				delete(tr.cache, k)
+			}
+		}
+		tr.cache[key] = cachedReview{id: id, expires: now.Add(tr.CacheTTL)}
+		tr.mu.Unlock()
+	}
+	return id, true, nil
+}
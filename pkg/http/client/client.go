@@ -1,13 +1,17 @@
 package client
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/pkg/errors"
@@ -15,6 +19,10 @@ import (
 	"github.com/fluxcd/flux/pkg/api"
 	"github.com/fluxcd/flux/pkg/api/v10"
 	"github.com/fluxcd/flux/pkg/api/v11"
+	"github.com/fluxcd/flux/pkg/api/v12"
+	"github.com/fluxcd/flux/pkg/api/v13"
+	"github.com/fluxcd/flux/pkg/api/v14"
+	"github.com/fluxcd/flux/pkg/api/v15"
 	"github.com/fluxcd/flux/pkg/api/v6"
 	"github.com/fluxcd/flux/pkg/api/v9"
 	fluxerr "github.com/fluxcd/flux/pkg/errors"
@@ -108,6 +116,117 @@ func (c *Client) SyncStatus(ctx context.Context, ref string) ([]string, error) {
 	return res, err
 }
 
+func (c *Client) SyncPlan(ctx context.Context, opts v12.SyncPlanOptions) (v12.SyncPlan, error) {
+	var res v12.SyncPlan
+	err := c.Get(ctx, &res, transport.SyncPlan, "ref", opts.Ref, "source", opts.Source)
+	return res, err
+}
+
+func (c *Client) ListResources(ctx context.Context, opts v13.ListResourcesOptions) ([]v13.ResourceStatus, error) {
+	var res []v13.ResourceStatus
+	err := c.Get(ctx, &res, transport.ListResources, "namespace", opts.Namespace)
+	return res, err
+}
+
+func (c *Client) History(ctx context.Context, opts v15.HistoryOptions) ([]event.Event, error) {
+	var res []event.Event
+	var workloads []string
+	for _, id := range opts.Workloads {
+		workloads = append(workloads, id.String())
+	}
+	var before, limit string
+	if !opts.Before.IsZero() {
+		before = opts.Before.Format(time.RFC3339Nano)
+	}
+	if opts.Limit > 0 {
+		limit = strconv.Itoa(opts.Limit)
+	}
+	err := c.Get(ctx, &res, transport.History, "workloads", strings.Join(workloads, ","), "before", before, "limit", limit)
+	return res, err
+}
+
+// Watch opens the daemon's stream of updates, and sends each update
+// received on the channel returned, until the context is cancelled or
+// the stream ends.
+func (c *Client) Watch(ctx context.Context, opts v14.WatchOptions) (<-chan v14.Update, error) {
+	var workloads []string
+	for _, id := range opts.Workloads {
+		workloads = append(workloads, id.String())
+	}
+	u, err := transport.MakeURL(c.endpoint, c.router, transport.Watch, "workloads", strings.Join(workloads, ","))
+	if err != nil {
+		return nil, errors.Wrap(err, "constructing URL")
+	}
+
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "constructing request %s", u)
+	}
+	req = req.WithContext(ctx)
+
+	c.token.Set(req)
+	// Errors are still sent as JSON
+	req.Header.Set("Accept", "text/event-stream, application/json")
+
+	resp, err := c.executeRequest(req)
+	if err != nil {
+		if resp != nil {
+			resp.Body.Close()
+		}
+		return nil, errors.Wrap(err, "executing HTTP request")
+	}
+
+	updates := make(chan v14.Update)
+	go func() {
+		defer close(updates)
+		defer resp.Body.Close()
+		readEvents(resp.Body, func(data []byte) bool {
+			var update v14.Update
+			if err := json.Unmarshal(data, &update); err != nil {
+				return false
+			}
+			select {
+			case updates <- update:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		})
+	}()
+	return updates, nil
+}
+
+// readEvents reads server-sent events, and calls the func given with
+// the data of each, until it returns false or the stream ends.
+func readEvents(r io.Reader, fn func(data []byte) bool) error {
+	reader := bufio.NewReader(r)
+	var data []byte
+	var hasData bool
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			return err
+		}
+		line = bytes.TrimRight(line, "\r\n")
+		switch {
+		case len(line) == 0:
+			// A blank line finishes the event
+			if hasData && !fn(data) {
+				return nil
+			}
+			data, hasData = nil, false
+		case bytes.HasPrefix(line, []byte("data:")):
+			value := bytes.TrimPrefix(bytes.TrimPrefix(line, []byte("data:")), []byte(" "))
+			if hasData {
+				data = append(data, '\n')
+			}
+			data, hasData = append(data, value...), true
+		}
+		// Anything else is a comment (e.g., a keep-alive), or a
+		// field that's not needed
+	}
+}
+
 func (c *Client) UpdateManifests(ctx context.Context, spec update.Spec) (job.ID, error) {
 	var res job.ID
 	err := c.methodWithResp(ctx, "POST", &res, transport.UpdateManifests, spec)
@@ -1,9 +1,14 @@
 package daemon
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/pkg/errors"
@@ -12,8 +17,13 @@ import (
 	"github.com/fluxcd/flux/pkg/api"
 	"github.com/fluxcd/flux/pkg/api/v10"
 	"github.com/fluxcd/flux/pkg/api/v11"
+	"github.com/fluxcd/flux/pkg/api/v12"
+	"github.com/fluxcd/flux/pkg/api/v13"
+	"github.com/fluxcd/flux/pkg/api/v14"
+	"github.com/fluxcd/flux/pkg/api/v15"
 	"github.com/fluxcd/flux/pkg/api/v9"
 	transport "github.com/fluxcd/flux/pkg/http"
+	"github.com/fluxcd/flux/pkg/http/auth"
 	"github.com/fluxcd/flux/pkg/job"
 	fluxmetrics "github.com/fluxcd/flux/pkg/metrics"
 	"github.com/fluxcd/flux/pkg/resource"
@@ -54,7 +64,7 @@ func NewHandler(s api.Server, r *mux.Router) http.Handler {
 	r.Get(transport.Version).HandlerFunc(handle.Version)
 	r.Get(transport.Notify).HandlerFunc(handle.Notify)
 
-	// v6-v11 handlers
+	// v6-v15 handlers
 	r.Get(transport.ListServices).HandlerFunc(handle.ListServicesWithOptions)
 	r.Get(transport.ListServicesWithOptions).HandlerFunc(handle.ListServicesWithOptions)
 	r.Get(transport.ListImages).HandlerFunc(handle.ListImagesWithOptions)
@@ -64,6 +74,10 @@ func NewHandler(s api.Server, r *mux.Router) http.Handler {
 	r.Get(transport.SyncStatus).HandlerFunc(handle.SyncStatus)
 	r.Get(transport.Export).HandlerFunc(handle.Export)
 	r.Get(transport.GitRepoConfig).HandlerFunc(handle.GitRepoConfig)
+	r.Get(transport.SyncPlan).HandlerFunc(handle.SyncPlan)
+	r.Get(transport.ListResources).HandlerFunc(handle.ListResources)
+	r.Get(transport.Watch).HandlerFunc(handle.Watch)
+	r.Get(transport.History).HandlerFunc(handle.History)
 
 	// These handlers persist to support requests from older fluxctls. In general we
 	// should avoid adding references to them so that they can eventually be removed.
@@ -72,10 +86,22 @@ func NewHandler(s api.Server, r *mux.Router) http.Handler {
 	r.Get(transport.GetPublicSSHKey).HandlerFunc(handle.GetPublicSSHKey)
 	r.Get(transport.RegeneratePublicSSHKey).HandlerFunc(handle.RegeneratePublicSSHKey)
 
-	return middleware.Instrument{
+	instrumented := middleware.Instrument{
 		RouteMatcher: r,
 		Duration:     requestDuration,
 	}.Wrap(r)
+	// The stream of updates lasts as long as the client wants, so
+	// there's no sense in timing it; and it must be flushed as it
+	// goes, which the instrumentation's response writer doesn't
+	// allow.
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var match mux.RouteMatch
+		if r.Match(req, &match) && match.Route != nil && match.Route.GetName() == transport.Watch {
+			r.ServeHTTP(w, req)
+			return
+		}
+		instrumented.ServeHTTP(w, req)
+	})
 }
 
 type HTTPServer struct {
@@ -135,6 +161,133 @@ func (s HTTPServer) SyncStatus(w http.ResponseWriter, r *http.Request) {
 	transport.JSONResponse(w, r, commits)
 }
 
+func (s HTTPServer) SyncPlan(w http.ResponseWriter, r *http.Request) {
+	opts := v12.SyncPlanOptions{
+		Ref:    r.URL.Query().Get("ref"),
+		Source: r.URL.Query().Get("source"),
+	}
+	plan, err := s.server.SyncPlan(r.Context(), opts)
+	if err != nil {
+		transport.ErrorResponse(w, r, err)
+		return
+	}
+	transport.JSONResponse(w, r, plan)
+}
+
+func (s HTTPServer) ListResources(w http.ResponseWriter, r *http.Request) {
+	opts := v13.ListResourcesOptions{
+		Namespace: r.URL.Query().Get("namespace"),
+	}
+	res, err := s.server.ListResources(r.Context(), opts)
+	if err != nil {
+		transport.ErrorResponse(w, r, err)
+		return
+	}
+	transport.JSONResponse(w, r, res)
+}
+
+func (s HTTPServer) History(w http.ResponseWriter, r *http.Request) {
+	var opts v15.HistoryOptions
+	query := r.URL.Query()
+	if workloads := query.Get("workloads"); workloads != "" {
+		for _, workload := range strings.Split(workloads, ",") {
+			id, err := resource.ParseID(workload)
+			if err != nil {
+				transport.WriteError(w, r, http.StatusBadRequest, errors.Wrapf(err, "parsing workload ID %q", workload))
+				return
+			}
+			opts.Workloads = append(opts.Workloads, id)
+		}
+	}
+	if before := query.Get("before"); before != "" {
+		t, err := time.Parse(time.RFC3339Nano, before)
+		if err != nil {
+			transport.WriteError(w, r, http.StatusBadRequest, errors.Wrapf(err, "parsing time %q", before))
+			return
+		}
+		opts.Before = t
+	}
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil {
+			transport.WriteError(w, r, http.StatusBadRequest, errors.Wrapf(err, "parsing limit %q", limit))
+			return
+		}
+		opts.Limit = n
+	}
+
+	res, err := s.server.History(r.Context(), opts)
+	if err != nil {
+		transport.ErrorResponse(w, r, err)
+		return
+	}
+	transport.JSONResponse(w, r, res)
+}
+
+// streamKeepAlive is how often something is sent down an otherwise
+// quiet stream, so that proxies in between don't time it out.
+const streamKeepAlive = 30 * time.Second
+
+// Watch streams updates as server-sent events: each is an event named
+// for the type of update, with the update encoded as JSON in its data.
+func (s HTTPServer) Watch(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		transport.WriteError(w, r, http.StatusInternalServerError, errors.New("streaming is not supported by this server"))
+		return
+	}
+
+	var opts v14.WatchOptions
+	if workloads := r.URL.Query().Get("workloads"); workloads != "" {
+		for _, workload := range strings.Split(workloads, ",") {
+			id, err := resource.ParseID(workload)
+			if err != nil {
+				transport.WriteError(w, r, http.StatusBadRequest, errors.Wrapf(err, "parsing workload ID %q", workload))
+				return
+			}
+			opts.Workloads = append(opts.Workloads, id)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	updates, err := s.server.Watch(ctx, opts)
+	if err != nil {
+		transport.ErrorResponse(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case u, ok := <-updates:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(u)
+			if err != nil {
+				return
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", u.Type, data); err != nil {
+				return
+			}
+		case <-keepAlive.C:
+			if _, err := io.WriteString(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+		flusher.Flush()
+	}
+}
+
 func (s HTTPServer) ListImagesWithOptions(w http.ResponseWriter, r *http.Request) {
 	var opts v10.ListImagesOptions
 	queryValues := r.URL.Query()
@@ -177,6 +330,7 @@ func (s HTTPServer) UpdateManifests(w http.ResponseWriter, r *http.Request) {
 		transport.WriteError(w, r, http.StatusBadRequest, err)
 		return
 	}
+	spec.Cause.User = causeUser(r, spec.Cause.User)
 
 	jobID, err := s.server.UpdateManifests(r.Context(), spec)
 	if err != nil {
@@ -231,6 +385,16 @@ func (s HTTPServer) GitRepoConfig(w http.ResponseWriter, r *http.Request) {
 	transport.JSONResponse(w, r, res)
 }
 
+// causeUser gives the user to record as having asked for an update:
+// the authenticated identity, if there is one, rather than whoever
+// the client claims to be.
+func causeUser(r *http.Request, claimed string) string {
+	if id, ok := auth.IdentityFrom(r.Context()); ok {
+		return id.Name
+	}
+	return claimed
+}
+
 // --- handlers supporting deprecated requests
 
 func (s HTTPServer) UpdateImages(w http.ResponseWriter, r *http.Request) {
@@ -280,7 +444,7 @@ func (s HTTPServer) UpdateImages(w http.ResponseWriter, r *http.Request) {
 		Excludes:     excludes,
 	}
 	cause := update.Cause{
-		User:    r.FormValue("user"),
+		User:    causeUser(r, r.FormValue("user")),
 		Message: r.FormValue("message"),
 	}
 	result, err := s.server.UpdateManifests(r.Context(), update.Spec{Type: update.Images, Cause: cause, Spec: spec})
@@ -299,7 +463,7 @@ func (s HTTPServer) UpdatePolicies(w http.ResponseWriter, r *http.Request) {
 	}
 
 	cause := update.Cause{
-		User:    r.FormValue("user"),
+		User:    causeUser(r, r.FormValue("user")),
 		Message: r.FormValue("message"),
 	}
 
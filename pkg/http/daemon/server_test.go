@@ -1,9 +1,21 @@
 package daemon
 
 import (
+	"context"
+	nethttp "net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/stretchr/testify/assert"
+
+	"github.com/fluxcd/flux/pkg/api/v14"
+	"github.com/fluxcd/flux/pkg/event"
 	"github.com/fluxcd/flux/pkg/http"
+	"github.com/fluxcd/flux/pkg/http/client"
+	"github.com/fluxcd/flux/pkg/job"
+	"github.com/fluxcd/flux/pkg/remote"
+	"github.com/fluxcd/flux/pkg/resource"
 )
 
 func TestRouterImplementsServer(t *testing.T) {
@@ -15,3 +27,51 @@ func TestRouterImplementsServer(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestWatch(t *testing.T) {
+	started := time.Date(2019, 10, 1, 12, 0, 0, 0, time.UTC)
+	updates := []v14.Update{
+		{Type: v14.UpdateJob, Job: &v14.JobUpdate{ID: "job-1", Status: job.Status{StatusString: job.StatusQueued}}},
+		{Type: v14.UpdateSyncStarted, Sync: &v14.SyncUpdate{Revision: "abcdef0"}},
+		{Type: v14.UpdateEvent, Event: &event.Event{
+			ID:         1,
+			ServiceIDs: []resource.ID{resource.MustParseID("default:deployment/app")},
+			Type:       event.EventAutomate,
+			StartedAt:  started,
+			EndedAt:    started,
+			LogLevel:   event.LogLevelInfo,
+		}},
+		{Type: v14.UpdateSyncFinished, Sync: &v14.SyncUpdate{Revision: "abcdef0", Error: "sync failed"}},
+	}
+	mock := &remote.MockServer{WatchAnswer: updates}
+	server := httptest.NewServer(NewHandler(mock, NewRouter()))
+	defer server.Close()
+	c := client.New(nethttp.DefaultClient, http.NewAPIRouter(), server.URL, "")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stream, err := c.Watch(ctx, v14.WatchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []v14.Update
+	for u := range stream {
+		got = append(got, u)
+	}
+	assert.Equal(t, updates, got)
+
+	_, err = c.Watch(ctx, v14.WatchOptions{Workloads: []resource.ID{resource.MustParseID("default:deployment/app")}})
+	assert.NoError(t, err)
+}
+
+func TestWatch_BadWorkload(t *testing.T) {
+	server := httptest.NewServer(NewHandler(&remote.MockServer{}, NewRouter()))
+	defer server.Close()
+
+	resp, err := nethttp.Get(server.URL + "/v14/stream?workloads=not:a:workload/id/at/all")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	assert.Equal(t, nethttp.StatusBadRequest, resp.StatusCode)
+}
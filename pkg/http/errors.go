@@ -32,6 +32,12 @@ make sure you supply a service token, either by setting the
 environment variable FLUX_SERVICE_TOKEN, or using the argument --token
 with fluxctl.
 
+If you are connecting to fluxd directly, and it requires
+authentication, supply a bearer token by setting the environment
+variable FLUX_AUTH_TOKEN or using the argument --auth-token, or a
+client certificate using the arguments --tls-client-cert and
+--tls-client-key.
+
 `,
 	Err: errors.New("request failed authentication"),
 }
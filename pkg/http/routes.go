@@ -15,6 +15,10 @@ const (
 	SyncStatus              = "SyncStatus"
 	Export                  = "Export"
 	GitRepoConfig           = "GitRepoConfig"
+	SyncPlan                = "SyncPlan"
+	ListResources           = "ListResources"
+	Watch                   = "Watch"
+	History                 = "History"
 
 	UpdateImages           = "UpdateImages"
 	UpdatePolicies         = "UpdatePolicies"
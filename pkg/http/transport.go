@@ -43,6 +43,10 @@ func NewAPIRouter() *mux.Router {
 	r.NewRoute().Name(SyncStatus).Methods("GET").Path("/v6/sync").Queries("ref", "{ref}")
 	r.NewRoute().Name(Export).Methods("HEAD", "GET").Path("/v6/export")
 	r.NewRoute().Name(GitRepoConfig).Methods("POST").Path("/v9/git-repo-config")
+	r.NewRoute().Name(SyncPlan).Methods("GET").Path("/v12/sync-plan")
+	r.NewRoute().Name(ListResources).Methods("GET").Path("/v13/resources")
+	r.NewRoute().Name(Watch).Methods("GET").Path("/v14/stream")
+	r.NewRoute().Name(History).Methods("GET").Path("/v15/history")
 
 	// These routes persist to support requests from older fluxctls. In general we
 	// should avoid adding references to them so that they can eventually be removed.
@@ -0,0 +1,278 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"hash"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/fluxcd/flux/pkg/api/v9"
+	"github.com/fluxcd/flux/pkg/git"
+)
+
+// GitReceivers returns the receivers for git push webhooks, keyed by
+// the path they are served at.
+func GitReceivers() map[string]Receiver {
+	return map[string]Receiver{
+		"git/github":    GitHub{},
+		"git/gitlab":    GitLab{},
+		"git/bitbucket": Bitbucket{},
+		"git/gitea":     Gitea{},
+	}
+}
+
+// GitHub receives GitHub's push webhooks. The secret is used by
+// GitHub to sign the body of each webhook.
+type GitHub struct{}
+
+// This is used by GitHub and Gitea alike.
+type githubPushPayload struct {
+	Ref        string `json:"ref"`
+	Repository struct {
+		CloneURL string `json:"clone_url"`
+		SSHURL   string `json:"ssh_url"`
+	} `json:"repository"`
+}
+
+func (p githubPushPayload) changes() []v9.Change {
+	branch, ok := branchOf(p.Ref)
+	if !ok {
+		return nil
+	}
+	return gitChanges(branch, p.Repository.CloneURL, p.Repository.SSHURL)
+}
+
+func (GitHub) Verify(r *http.Request, body, secret []byte) bool {
+	return hubSignature(r, body, secret)
+}
+
+func (GitHub) Changes(r *http.Request, body []byte) ([]v9.Change, error) {
+	// GitHub sends a ping when the webhook is set up, and may be
+	// sending other events to the same URL
+	if event := r.Header.Get("X-GitHub-Event"); event != "" && event != "push" {
+		return nil, nil
+	}
+	var payload githubPushPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, errors.Wrap(err, "decoding GitHub webhook")
+	}
+	return payload.changes(), nil
+}
+
+// GitLab receives GitLab's push webhooks. The secret is the webhook's
+// "Secret token", which GitLab sends as is in a header.
+type GitLab struct{}
+
+type gitlabPushPayload struct {
+	ObjectKind string `json:"object_kind"`
+	Ref        string `json:"ref"`
+	Project    struct {
+		GitHTTPURL string `json:"git_http_url"`
+		GitSSHURL  string `json:"git_ssh_url"`
+	} `json:"project"`
+}
+
+func (GitLab) Verify(r *http.Request, body, secret []byte) bool {
+	token := r.Header.Get("X-Gitlab-Token")
+	return len(secret) > 0 && subtle.ConstantTimeCompare([]byte(token), secret) == 1
+}
+
+func (GitLab) Changes(r *http.Request, body []byte) ([]v9.Change, error) {
+	var payload gitlabPushPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, errors.Wrap(err, "decoding GitLab webhook")
+	}
+	if payload.ObjectKind != "push" {
+		return nil, nil
+	}
+	branch, ok := branchOf(payload.Ref)
+	if !ok {
+		return nil, nil
+	}
+	return gitChanges(branch, payload.Project.GitHTTPURL, payload.Project.GitSSHURL), nil
+}
+
+// Bitbucket receives push webhooks from Bitbucket Cloud, and from
+// Bitbucket Server. Bitbucket Server signs the body of each webhook
+// with the secret; Bitbucket Cloud can't be given a secret, other than
+// in the URL.
+type Bitbucket struct{}
+
+type bitbucketPushPayload struct {
+	// Bitbucket Cloud
+	Push struct {
+		Changes []struct {
+			New *struct {
+				Type string `json:"type"`
+				Name string `json:"name"`
+			} `json:"new"`
+		} `json:"changes"`
+	} `json:"push"`
+	// Bitbucket Server
+	Changes []struct {
+		Ref struct {
+			ID string `json:"id"`
+		} `json:"ref"`
+	} `json:"changes"`
+	Repository struct {
+		Links struct {
+			// Bitbucket Cloud
+			HTML struct {
+				Href string `json:"href"`
+			} `json:"html"`
+			// Bitbucket Server
+			Clone []struct {
+				Href string `json:"href"`
+			} `json:"clone"`
+		} `json:"links"`
+	} `json:"repository"`
+}
+
+func (Bitbucket) Verify(r *http.Request, body, secret []byte) bool {
+	if r.Header.Get("X-Hub-Signature") != "" {
+		return hubSignature(r, body, secret)
+	}
+	return sharedSecret(r, secret)
+}
+
+func (Bitbucket) Changes(r *http.Request, body []byte) ([]v9.Change, error) {
+	switch r.Header.Get("X-Event-Key") {
+	case "repo:push", "repo:refs_changed", "": // Cloud, Server
+	default:
+		return nil, nil
+	}
+	var payload bitbucketPushPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, errors.Wrap(err, "decoding Bitbucket webhook")
+	}
+
+	var branches []string
+	for _, c := range payload.Push.Changes {
+		// A deleted branch has no new state
+		if c.New != nil && c.New.Type == "branch" {
+			branches = append(branches, c.New.Name)
+		}
+	}
+	for _, c := range payload.Changes {
+		if branch, ok := branchOf(c.Ref.ID); ok {
+			branches = append(branches, branch)
+		}
+	}
+	if len(branches) == 0 {
+		return nil, nil
+	}
+
+	urls := []string{payload.Repository.Links.HTML.Href}
+	for _, link := range payload.Repository.Links.Clone {
+		urls = append(urls, link.Href)
+	}
+	var changes []v9.Change
+	for _, branch := range branches {
+		changes = append(changes, gitChanges(branch, urls...)...)
+	}
+	if len(changes) == 0 {
+		return nil, errors.New("Bitbucket webhook does not include the repository's URL")
+	}
+	return changes, nil
+}
+
+// Gitea receives Gitea's push webhooks. The secret is used by Gitea to
+// sign the body of each webhook.
+type Gitea struct{}
+
+func (Gitea) Verify(r *http.Request, body, secret []byte) bool {
+	if sig := r.Header.Get("X-Gitea-Signature"); sig != "" {
+		return validHMAC(sha256.New, secret, body, sig)
+	}
+	return hubSignature(r, body, secret)
+}
+
+func (Gitea) Changes(r *http.Request, body []byte) ([]v9.Change, error) {
+	if event := r.Header.Get("X-Gitea-Event"); event != "" && event != "push" {
+		return nil, nil
+	}
+	var payload githubPushPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, errors.Wrap(err, "decoding Gitea webhook")
+	}
+	return payload.changes(), nil
+}
+
+// branchOf gives the branch a ref refers to, if it refers to a branch
+// (rather than, e.g., a tag).
+func branchOf(ref string) (string, bool) {
+	const prefix = "refs/heads/"
+	if !strings.HasPrefix(ref, prefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(ref, prefix), true
+}
+
+// gitChanges makes change notifications for a push to the branch
+// given, in the repo at the URLs given. A repo usually has an HTTPS
+// and an SSH URL; these are equivalent unless SSH is on another host
+// or port, in which case there is a notification for each, since the
+// daemon may have been given either.
+func gitChanges(branch string, urls ...string) []v9.Change {
+	var remotes []git.Remote
+	var changes []v9.Change
+next:
+	for _, url := range urls {
+		if url == "" {
+			continue
+		}
+		for _, r := range remotes {
+			if r.Equivalent(url) {
+				continue next
+			}
+		}
+		remotes = append(remotes, git.Remote{URL: url})
+		changes = append(changes, v9.Change{
+			Kind:   v9.GitChange,
+			Source: v9.GitUpdate{URL: url, Branch: branch},
+		})
+	}
+	return changes
+}
+
+// hubSignature verifies requests signed with an HMAC of the body, in
+// the header `X-Hub-Signature-256` or `X-Hub-Signature`, as
+// `<algorithm>=<hex digest>`. This is what GitHub does, and others
+// follow.
+func hubSignature(r *http.Request, body, secret []byte) bool {
+	header := r.Header.Get("X-Hub-Signature-256")
+	if header == "" {
+		header = r.Header.Get("X-Hub-Signature")
+	}
+	parts := strings.SplitN(header, "=", 2)
+	if len(parts) != 2 {
+		return false
+	}
+	switch parts[0] {
+	case "sha256":
+		return validHMAC(sha256.New, secret, body, parts[1])
+	case "sha1":
+		return validHMAC(sha1.New, secret, body, parts[1])
+	}
+	return false
+}
+
+func validHMAC(newHash func() hash.Hash, secret, body []byte, signature string) bool {
+	if len(secret) == 0 {
+		return false
+	}
+	sig, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(newHash, secret)
+	mac.Write(body)
+	return hmac.Equal(sig, mac.Sum(nil))
+}
@@ -0,0 +1,143 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/fluxcd/flux/pkg/api/v9"
+)
+
+func gitUpdate(url, branch string) v9.Change {
+	return v9.Change{Kind: v9.GitChange, Source: v9.GitUpdate{URL: url, Branch: branch}}
+}
+
+func sign(newHash func() hash.Hash, secret, body string) string {
+	mac := hmac.New(newHash, []byte(secret))
+	mac.Write([]byte(body))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestGitReceivers(t *testing.T) {
+	githubPush := `{"ref":"refs/heads/master","repository":{"clone_url":"https://github.com/org/repo.git","ssh_url":"git@github.com:org/repo.git"}}`
+
+	for _, c := range []struct {
+		name     string
+		receiver Receiver
+		headers  map[string]string
+		body     string
+		expected []v9.Change
+	}{
+		{
+			name:     "GitHub push",
+			receiver: GitHub{},
+			headers:  map[string]string{"X-GitHub-Event": "push"},
+			body:     githubPush,
+			expected: []v9.Change{gitUpdate("https://github.com/org/repo.git", "master")},
+		},
+		{
+			name:     "GitHub ping",
+			receiver: GitHub{},
+			headers:  map[string]string{"X-GitHub-Event": "ping"},
+			body:     `{"zen":"Keep it logically awesome."}`,
+		},
+		{
+			name:     "GitHub tag",
+			receiver: GitHub{},
+			body:     `{"ref":"refs/tags/v1.0.0","repository":{"clone_url":"https://github.com/org/repo.git"}}`,
+		},
+		{
+			name:     "GitLab push, SSH on another port",
+			receiver: GitLab{},
+			body:     `{"object_kind":"push","ref":"refs/heads/dev","project":{"git_http_url":"https://gitlab.example.com/group/repo.git","git_ssh_url":"ssh://git@gitlab.example.com:2222/group/repo.git"}}`,
+			expected: []v9.Change{
+				gitUpdate("https://gitlab.example.com/group/repo.git", "dev"),
+				gitUpdate("ssh://git@gitlab.example.com:2222/group/repo.git", "dev"),
+			},
+		},
+		{
+			name:     "GitLab tag push",
+			receiver: GitLab{},
+			body:     `{"object_kind":"tag_push","ref":"refs/tags/v1","project":{"git_http_url":"https://gitlab.com/group/repo.git"}}`,
+		},
+		{
+			name:     "Bitbucket Cloud",
+			receiver: Bitbucket{},
+			headers:  map[string]string{"X-Event-Key": "repo:push"},
+			body:     `{"push":{"changes":[{"new":{"type":"branch","name":"master"}},{"new":null},{"new":{"type":"tag","name":"v1"}}]},"repository":{"full_name":"team/repo","links":{"html":{"href":"https://bitbucket.org/team/repo"}}}}`,
+			expected: []v9.Change{gitUpdate("https://bitbucket.org/team/repo", "master")},
+		},
+		{
+			name:     "Bitbucket Server",
+			receiver: Bitbucket{},
+			headers:  map[string]string{"X-Event-Key": "repo:refs_changed"},
+			body:     `{"changes":[{"ref":{"id":"refs/heads/master","displayId":"master","type":"BRANCH"}}],"repository":{"links":{"clone":[{"href":"ssh://git@bitbucket.example.com:7999/proj/repo.git","name":"ssh"},{"href":"https://bitbucket.example.com/scm/proj/repo.git","name":"http"}]}}}`,
+			expected: []v9.Change{
+				gitUpdate("ssh://git@bitbucket.example.com:7999/proj/repo.git", "master"),
+				gitUpdate("https://bitbucket.example.com/scm/proj/repo.git", "master"),
+			},
+		},
+		{
+			name:     "Bitbucket Server ping",
+			receiver: Bitbucket{},
+			headers:  map[string]string{"X-Event-Key": "diagnostics:ping"},
+			body:     `{"test":true}`,
+		},
+		{
+			name:     "Gitea push",
+			receiver: Gitea{},
+			headers:  map[string]string{"X-Gitea-Event": "push"},
+			body:     githubPush,
+			expected: []v9.Change{gitUpdate("https://github.com/org/repo.git", "master")},
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/", nil)
+			for k, v := range c.headers {
+				r.Header.Set(k, v)
+			}
+			changes, err := c.receiver.Changes(r, []byte(c.body))
+			assert.NoError(t, err)
+			assert.Equal(t, c.expected, changes)
+		})
+	}
+}
+
+func TestGitVerify(t *testing.T) {
+	const secret, body = "s3cr3t", `{"ref":"refs/heads/master"}`
+
+	for _, c := range []struct {
+		name     string
+		receiver Receiver
+		path     string
+		headers  map[string]string
+		valid    bool
+	}{
+		{"GitHub sha256", GitHub{}, "/", map[string]string{"X-Hub-Signature-256": "sha256=" + sign(sha256.New, secret, body)}, true},
+		{"GitHub sha1", GitHub{}, "/", map[string]string{"X-Hub-Signature": "sha1=" + sign(sha1.New, secret, body)}, true},
+		{"GitHub wrong secret", GitHub{}, "/", map[string]string{"X-Hub-Signature-256": "sha256=" + sign(sha256.New, "guess", body)}, false},
+		{"GitHub unsigned", GitHub{}, "/?token=" + secret, nil, false},
+		{"GitLab", GitLab{}, "/", map[string]string{"X-Gitlab-Token": secret}, true},
+		{"GitLab wrong token", GitLab{}, "/", map[string]string{"X-Gitlab-Token": "guess"}, false},
+		{"Bitbucket Cloud", Bitbucket{}, "/?token=" + secret, nil, true},
+		{"Bitbucket Cloud no token", Bitbucket{}, "/", nil, false},
+		{"Bitbucket Server", Bitbucket{}, "/", map[string]string{"X-Hub-Signature": "sha256=" + sign(sha256.New, secret, body)}, true},
+		{"Bitbucket Server wrong secret", Bitbucket{}, "/?token=" + secret, map[string]string{"X-Hub-Signature": "sha256=" + sign(sha256.New, "guess", body)}, false},
+		{"Gitea", Gitea{}, "/", map[string]string{"X-Gitea-Signature": sign(sha256.New, secret, body)}, true},
+		{"Gitea wrong secret", Gitea{}, "/", map[string]string{"X-Gitea-Signature": sign(sha256.New, "guess", body)}, false},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", c.path, nil)
+			for k, v := range c.headers {
+				r.Header.Set(k, v)
+			}
+			assert.Equal(t, c.valid, c.receiver.Verify(r, []byte(body), []byte(secret)))
+		})
+	}
+}
@@ -0,0 +1,227 @@
+package webhook
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/fluxcd/flux/pkg/api/v9"
+)
+
+// Most of the registries below can't sign their webhooks, so the
+// secret is checked as given in the URL or Authorization header (see
+// sharedSecret). Harbor and distribution notifications can be given
+// arbitrary headers, so may also be signed (see signedOrSharedSecret).
+
+// RegistryReceivers returns the receivers for image registry
+// webhooks, keyed by the path they are served at.
+func RegistryReceivers() map[string]Receiver {
+	return map[string]Receiver{
+		"registry/dockerhub":    DockerHub{},
+		"registry/harbor":       Harbor{},
+		"registry/quay":         Quay{},
+		"registry/gcr":          GCR{},
+		"registry/distribution": Distribution{},
+	}
+}
+
+// DockerHub receives Docker Hub's webhooks, which are sent when an
+// image is pushed to a repository. Docker Hub can't be given a
+// secret, other than in the URL.
+type DockerHub struct{}
+
+type dockerHubPayload struct {
+	Repository struct {
+		RepoName string `json:"repo_name"`
+	} `json:"repository"`
+}
+
+func (DockerHub) Verify(r *http.Request, body, secret []byte) bool {
+	return sharedSecret(r, secret)
+}
+
+func (DockerHub) Changes(r *http.Request, body []byte) ([]v9.Change, error) {
+	var payload dockerHubPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, errors.Wrap(err, "decoding Docker Hub webhook")
+	}
+	if payload.Repository.RepoName == "" {
+		return nil, errors.New("Docker Hub webhook has no repository name")
+	}
+	change, err := imageChange(payload.Repository.RepoName)
+	if err != nil {
+		return nil, err
+	}
+	return []v9.Change{change}, nil
+}
+
+// Harbor receives Harbor's webhooks (from Harbor 1.9 and 2.x). The
+// secret is what's given as the "Auth Header" when setting up the
+// webhook in Harbor, which is sent in the Authorization header; or,
+// the webhook may be signed.
+type Harbor struct{}
+
+type harborPayload struct {
+	Type      string `json:"type"`
+	EventData struct {
+		Resources []struct {
+			ResourceURL string `json:"resource_url"`
+		} `json:"resources"`
+	} `json:"event_data"`
+}
+
+func (Harbor) Verify(r *http.Request, body, secret []byte) bool {
+	return signedOrSharedSecret(r, body, secret)
+}
+
+func (Harbor) Changes(r *http.Request, body []byte) ([]v9.Change, error) {
+	var payload harborPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, errors.Wrap(err, "decoding Harbor webhook")
+	}
+	switch payload.Type {
+	case "pushImage", "PUSH_ARTIFACT": // Harbor 1.x, 2.x
+	default:
+		return nil, nil
+	}
+	var changes []v9.Change
+	for _, res := range payload.EventData.Resources {
+		change, err := imageChange(res.ResourceURL)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+// Quay receives Quay's "Repository Push" notifications, sent to a
+// webhook. Quay can't be given a secret, other than in the URL.
+type Quay struct{}
+
+type quayPayload struct {
+	DockerURL   string   `json:"docker_url"`
+	UpdatedTags []string `json:"updated_tags"`
+}
+
+func (Quay) Verify(r *http.Request, body, secret []byte) bool {
+	return sharedSecret(r, secret)
+}
+
+func (Quay) Changes(r *http.Request, body []byte) ([]v9.Change, error) {
+	var payload quayPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, errors.Wrap(err, "decoding Quay webhook")
+	}
+	// Other notifications (e.g., about builds) can be sent to the
+	// same webhook; only pushes have updated tags.
+	if len(payload.UpdatedTags) == 0 {
+		return nil, nil
+	}
+	change, err := imageChange(payload.DockerURL)
+	if err != nil {
+		return nil, err
+	}
+	return []v9.Change{change}, nil
+}
+
+// GCR receives the messages Google Container Registry (and Artifact
+// Registry) publish to the `gcr` Pub/Sub topic, from a push
+// subscription. The secret is given in the push endpoint URL.
+type GCR struct{}
+
+type gcrPushPayload struct {
+	Message struct {
+		Data []byte `json:"data"`
+	} `json:"message"`
+}
+
+type gcrMessage struct {
+	Action string `json:"action"`
+	Digest string `json:"digest"`
+	Tag    string `json:"tag"`
+}
+
+func (GCR) Verify(r *http.Request, body, secret []byte) bool {
+	return sharedSecret(r, secret)
+}
+
+func (GCR) Changes(r *http.Request, body []byte) ([]v9.Change, error) {
+	var payload gcrPushPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, errors.Wrap(err, "decoding Pub/Sub push message")
+	}
+	var msg gcrMessage
+	if err := json.Unmarshal(payload.Message.Data, &msg); err != nil {
+		return nil, errors.Wrap(err, "decoding GCR message")
+	}
+	if msg.Action != "INSERT" {
+		return nil, nil
+	}
+	ref := msg.Tag
+	if ref == "" {
+		ref = msg.Digest
+	}
+	change, err := imageChange(ref)
+	if err != nil {
+		return nil, err
+	}
+	return []v9.Change{change}, nil
+}
+
+// Distribution receives the notifications sent by registries
+// implementing the Docker distribution notification envelope (e.g.,
+// the Docker registry, and GitLab's container registry). The secret
+// is given in the Authorization header, in the endpoint's `headers`;
+// or, the notification may be signed.
+type Distribution struct{}
+
+type distributionEnvelope struct {
+	Events []struct {
+		Action string `json:"action"`
+		Target struct {
+			MediaType  string `json:"mediaType"`
+			Repository string `json:"repository"`
+			URL        string `json:"url"`
+		} `json:"target"`
+		Request struct {
+			Host string `json:"host"`
+		} `json:"request"`
+	} `json:"events"`
+}
+
+func (Distribution) Verify(r *http.Request, body, secret []byte) bool {
+	return signedOrSharedSecret(r, body, secret)
+}
+
+func (Distribution) Changes(r *http.Request, body []byte) ([]v9.Change, error) {
+	var envelope distributionEnvelope
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return nil, errors.Wrap(err, "decoding registry notification")
+	}
+	var changes []v9.Change
+	for _, e := range envelope.Events {
+		// Layers are pushed (and notified) before the manifest that
+		// refers to them; only the manifest makes a new image.
+		mediaType := e.Target.MediaType
+		if e.Action != "push" || !(strings.Contains(mediaType, "manifest") || strings.Contains(mediaType, "image.index")) {
+			continue
+		}
+		host := e.Request.Host
+		if u, err := url.Parse(e.Target.URL); err == nil && u.Host != "" {
+			host = u.Host
+		}
+		if host == "" {
+			return nil, errors.Errorf("registry notification for %s does not say which registry it is from", e.Target.Repository)
+		}
+		change, err := imageChange(host + "/" + e.Target.Repository)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
@@ -0,0 +1,164 @@
+// Package webhook receives webhooks from image registries and git
+// hosts, and turns them into change notifications for the daemon.
+// This means the daemon can look at a newly pushed image or commit
+// straight away, rather than waiting for the next time it polls.
+//
+// Each kind of webhook has a Receiver, which checks that a request
+// carries the secret (either one shared by all receivers, or the
+// receiver's own), in whatever form that sender presents it, and
+// parses the body into changes.
+package webhook
+
+import (
+	"context"
+	"crypto/subtle"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/go-kit/kit/log"
+	"github.com/pkg/errors"
+
+	"github.com/fluxcd/flux/pkg/api/v9"
+	"github.com/fluxcd/flux/pkg/image"
+)
+
+// maxBodySize limits how much of a webhook request body is read.
+// Payloads are a few KiB at most.
+const maxBodySize = 1 << 20
+
+// Notifier is told about the changes reported by webhooks. The
+// daemon is one.
+type Notifier interface {
+	NotifyChange(context.Context, v9.Change) error
+}
+
+// Receiver understands one kind of webhook.
+type Receiver interface {
+	// Verify says whether the request carries the secret given.
+	Verify(r *http.Request, body, secret []byte) bool
+	// Changes parses the body of a webhook into the changes it
+	// reports. It may return no changes, if the webhook is about
+	// something not of interest (e.g., a deleted tag).
+	Changes(r *http.Request, body []byte) ([]v9.Change, error)
+}
+
+// Handler serves webhooks, using the receiver named by the path of
+// the request; e.g., `/registry/dockerhub`, relative to wherever the
+// handler is mounted.
+type Handler struct {
+	Notifier Notifier
+	// Secret is the secret webhooks must carry, unless their
+	// receiver has its own secret in Secrets.
+	Secret []byte
+	// Secrets are the secrets for particular receivers, keyed by the
+	// path they are served at.
+	Secrets   map[string][]byte
+	Receivers map[string]Receiver
+	Logger    log.Logger
+}
+
+// secret returns the secret webhooks for the receiver named must
+// carry.
+func (h *Handler) secret(name string) []byte {
+	if secret, ok := h.Secrets[name]; ok {
+		return secret
+	}
+	return h.Secret
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := strings.Trim(r.URL.Path, "/")
+	receiver, ok := h.Receivers[name]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "webhooks must be POSTed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !receiver.Verify(r, body, h.secret(name)) {
+		h.Logger.Log("webhook", name, "err", "request did not carry the webhook secret", "remote", r.RemoteAddr)
+		http.Error(w, "webhook secret missing or incorrect", http.StatusUnauthorized)
+		return
+	}
+	changes, err := receiver.Changes(r, body)
+	if err != nil {
+		h.Logger.Log("webhook", name, "err", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// A webhook may report the same image (or repo) several times,
+	// e.g., once for each tag pushed; it only needs looking at once.
+	seen := map[v9.Change]bool{}
+	for _, change := range changes {
+		if seen[change] {
+			continue
+		}
+		seen[change] = true
+		h.Logger.Log("webhook", name, "change", change.Kind, "source", change.Source)
+		if err := h.Notifier.NotifyChange(r.Context(), change); err != nil {
+			h.Logger.Log("webhook", name, "err", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// sharedSecret verifies requests that present the secret as is,
+// either as the query parameter `token` (for senders that only let
+// you give a URL), or in the Authorization header, optionally as a
+// bearer token.
+func sharedSecret(r *http.Request, secret []byte) bool {
+	if len(secret) == 0 {
+		return false
+	}
+	if token := r.URL.Query().Get("token"); token != "" {
+		return subtle.ConstantTimeCompare([]byte(token), secret) == 1
+	}
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		return false
+	}
+	if len(header) > len("Bearer ") && strings.EqualFold(header[:len("Bearer ")], "Bearer ") {
+		header = header[len("Bearer "):]
+	}
+	return subtle.ConstantTimeCompare([]byte(header), secret) == 1
+}
+
+// signedOrSharedSecret verifies requests that are signed as GitHub
+// signs webhooks (see hubSignature), or failing that, carry the
+// secret as is (see sharedSecret). A request that is signed must have
+// a valid signature, whatever else it carries.
+func signedOrSharedSecret(r *http.Request, body, secret []byte) bool {
+	if r.Header.Get("X-Hub-Signature-256") != "" || r.Header.Get("X-Hub-Signature") != "" {
+		return hubSignature(r, body, secret)
+	}
+	return sharedSecret(r, secret)
+}
+
+// imageChange makes a change notification for the image named in
+// ref, which may include a tag or digest (these are ignored).
+func imageChange(ref string) (v9.Change, error) {
+	if i := strings.Index(ref, "@"); i >= 0 {
+		ref = ref[:i]
+	}
+	id, err := image.ParseRef(ref)
+	if err != nil {
+		return v9.Change{}, errors.Wrap(err, "parsing image from webhook")
+	}
+	return v9.Change{
+		Kind:   v9.ImageChange,
+		Source: v9.ImageUpdate{Name: id.Name},
+	}, nil
+}
@@ -0,0 +1,200 @@
+package webhook
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/fluxcd/flux/pkg/api/v9"
+	"github.com/fluxcd/flux/pkg/image"
+)
+
+type recordingNotifier struct {
+	changes []v9.Change
+}
+
+func (n *recordingNotifier) NotifyChange(ctx context.Context, c v9.Change) error {
+	n.changes = append(n.changes, c)
+	return nil
+}
+
+func imageUpdate(name string) v9.Change {
+	ref, err := image.ParseRef(name)
+	if err != nil {
+		panic(err)
+	}
+	return v9.Change{Kind: v9.ImageChange, Source: v9.ImageUpdate{Name: ref.Name}}
+}
+
+func TestRegistryReceivers(t *testing.T) {
+	gcrData := base64.StdEncoding.EncodeToString([]byte(`{"action":"INSERT","digest":"gcr.io/my-project/app@sha256:6ec128e26cd5","tag":"gcr.io/my-project/app:v1"}`))
+	gcrDelete := base64.StdEncoding.EncodeToString([]byte(`{"action":"DELETE","tag":"gcr.io/my-project/app:v1"}`))
+
+	for _, c := range []struct {
+		name     string
+		receiver Receiver
+		body     string
+		expected []v9.Change
+	}{
+		{
+			name:     "Docker Hub",
+			receiver: DockerHub{},
+			body:     `{"push_data":{"tag":"latest"},"repository":{"repo_name":"fluxcd/flux","namespace":"fluxcd","name":"flux"}}`,
+			expected: []v9.Change{imageUpdate("fluxcd/flux")},
+		},
+		{
+			name:     "Harbor 2.x push",
+			receiver: Harbor{},
+			body:     `{"type":"PUSH_ARTIFACT","event_data":{"resources":[{"tag":"v1","resource_url":"harbor.example.com/library/app:v1"}]}}`,
+			expected: []v9.Change{imageUpdate("harbor.example.com/library/app")},
+		},
+		{
+			name:     "Harbor delete",
+			receiver: Harbor{},
+			body:     `{"type":"DELETE_ARTIFACT","event_data":{"resources":[{"resource_url":"harbor.example.com/library/app:v1"}]}}`,
+		},
+		{
+			name:     "Quay push",
+			receiver: Quay{},
+			body:     `{"repository":"org/app","docker_url":"quay.io/org/app","updated_tags":["v1"]}`,
+			expected: []v9.Change{imageUpdate("quay.io/org/app")},
+		},
+		{
+			name:     "Quay build",
+			receiver: Quay{},
+			body:     `{"repository":"org/app","docker_url":"quay.io/org/app","build_id":"abc"}`,
+		},
+		{
+			name:     "GCR insert",
+			receiver: GCR{},
+			body:     `{"message":{"data":"` + gcrData + `","messageId":"1"},"subscription":"projects/my-project/subscriptions/flux"}`,
+			expected: []v9.Change{imageUpdate("gcr.io/my-project/app")},
+		},
+		{
+			name:     "GCR delete",
+			receiver: GCR{},
+			body:     `{"message":{"data":"` + gcrDelete + `","messageId":"2"}}`,
+		},
+		{
+			name:     "distribution",
+			receiver: Distribution{},
+			body: `{"events":[
+{"action":"push","target":{"mediaType":"application/octet-stream","repository":"team/app","url":"https://registry.example.com:5000/v2/team/app/blobs/sha256:1"},"request":{"host":"registry.example.com:5000"}},
+{"action":"push","target":{"mediaType":"application/vnd.docker.distribution.manifest.v2+json","repository":"team/app","url":"https://registry.example.com:5000/v2/team/app/manifests/sha256:2","tag":"v1"},"request":{"host":"registry.example.com:5000"}},
+{"action":"pull","target":{"mediaType":"application/vnd.docker.distribution.manifest.v2+json","repository":"team/other","url":"https://registry.example.com:5000/v2/team/other/manifests/sha256:3"},"request":{"host":"registry.example.com:5000"}}
+]}`,
+			expected: []v9.Change{imageUpdate("registry.example.com:5000/team/app")},
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/", nil)
+			changes, err := c.receiver.Changes(r, []byte(c.body))
+			assert.NoError(t, err)
+			assert.Equal(t, c.expected, changes)
+		})
+	}
+}
+
+func TestHandler(t *testing.T) {
+	notifier := &recordingNotifier{}
+	handler := &Handler{
+		Notifier:  notifier,
+		Secret:    []byte("s3cr3t"),
+		Receivers: RegistryReceivers(),
+		Logger:    log.NewNopLogger(),
+	}
+	payload := `{"repository":"org/app","docker_url":"quay.io/org/app","updated_tags":["v1","latest"]}`
+
+	for _, c := range []struct {
+		name   string
+		method string
+		path   string
+		header string
+		status int
+	}{
+		{"unknown receiver", "POST", "/registry/nonesuch?token=s3cr3t", "", http.StatusNotFound},
+		{"wrong method", "GET", "/registry/quay?token=s3cr3t", "", http.StatusMethodNotAllowed},
+		{"no secret", "POST", "/registry/quay", "", http.StatusUnauthorized},
+		{"wrong token", "POST", "/registry/quay?token=guess", "", http.StatusUnauthorized},
+		{"wrong header", "POST", "/registry/quay", "Bearer guess", http.StatusUnauthorized},
+		{"token", "POST", "/registry/quay?token=s3cr3t", "", http.StatusAccepted},
+		{"bearer", "POST", "/registry/quay", "Bearer s3cr3t", http.StatusAccepted},
+		{"plain header", "POST", "/registry/quay/", "s3cr3t", http.StatusAccepted},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			notifier.changes = nil
+			r := httptest.NewRequest(c.method, c.path, strings.NewReader(payload))
+			if c.header != "" {
+				r.Header.Set("Authorization", c.header)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			assert.Equal(t, c.status, w.Code)
+			if c.status == http.StatusAccepted {
+				assert.Equal(t, []v9.Change{imageUpdate("quay.io/org/app")}, notifier.changes)
+			} else {
+				assert.Empty(t, notifier.changes)
+			}
+		})
+	}
+}
+
+func TestRegistryVerify(t *testing.T) {
+	const secret, body = "s3cr3t", `{"events":[]}`
+
+	for _, c := range []struct {
+		name     string
+		receiver Receiver
+		path     string
+		headers  map[string]string
+		valid    bool
+	}{
+		{"Docker Hub", DockerHub{}, "/?token=" + secret, nil, true},
+		{"Docker Hub signed", DockerHub{}, "/", map[string]string{"X-Hub-Signature-256": "sha256=" + sign(sha256.New, secret, body)}, false},
+		{"Harbor", Harbor{}, "/", map[string]string{"Authorization": secret}, true},
+		{"Harbor signed", Harbor{}, "/", map[string]string{"X-Hub-Signature-256": "sha256=" + sign(sha256.New, secret, body)}, true},
+		{"Harbor wrong signature", Harbor{}, "/", map[string]string{"X-Hub-Signature-256": "sha256=" + sign(sha256.New, "guess", body), "Authorization": secret}, false},
+		{"distribution", Distribution{}, "/", map[string]string{"Authorization": "Bearer " + secret}, true},
+		{"distribution signed", Distribution{}, "/", map[string]string{"X-Hub-Signature-256": "sha256=" + sign(sha256.New, secret, body)}, true},
+		{"distribution wrong signature", Distribution{}, "/", map[string]string{"X-Hub-Signature-256": "sha256=" + sign(sha256.New, "guess", body)}, false},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", c.path, nil)
+			for k, v := range c.headers {
+				r.Header.Set(k, v)
+			}
+			assert.Equal(t, c.valid, c.receiver.Verify(r, []byte(body), []byte(secret)))
+		})
+	}
+}
+
+func TestHandler_ReceiverSecrets(t *testing.T) {
+	notifier := &recordingNotifier{}
+	handler := &Handler{
+		Notifier:  notifier,
+		Secret:    []byte("s3cr3t"),
+		Secrets:   map[string][]byte{"registry/quay": []byte("quay-s3cr3t")},
+		Receivers: RegistryReceivers(),
+		Logger:    log.NewNopLogger(),
+	}
+	post := func(path, body string) int {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("POST", path, strings.NewReader(body)))
+		return w.Code
+	}
+
+	quayPayload := `{"repository":"org/app","docker_url":"quay.io/org/app","updated_tags":["v1"]}`
+	assert.Equal(t, http.StatusAccepted, post("/registry/quay?token=quay-s3cr3t", quayPayload))
+	assert.Equal(t, http.StatusUnauthorized, post("/registry/quay?token=s3cr3t", quayPayload), "the shared secret is not accepted for a receiver with its own")
+
+	dockerHubPayload := `{"repository":{"repo_name":"fluxcd/flux"}}`
+	assert.Equal(t, http.StatusAccepted, post("/registry/dockerhub?token=s3cr3t", dockerHubPayload))
+	assert.Equal(t, http.StatusUnauthorized, post("/registry/dockerhub?token=quay-s3cr3t", dockerHubPayload))
+}
@@ -22,6 +22,7 @@ var (
 	ErrInvalidImageID   = errors.New("invalid image ID")
 	ErrBlankImageID     = errors.Wrap(ErrInvalidImageID, "blank image name")
 	ErrMalformedImageID = errors.Wrap(ErrInvalidImageID, `expected image name as either <image>:<tag> or just <image>`)
+	ErrMalformedDigest  = errors.Wrap(ErrInvalidImageID, `expected digest as <algorithm>:<hex>`)
 )
 
 // Name represents an unversioned (i.e., untagged) image a.k.a.,
@@ -102,16 +103,21 @@ func (i Name) ToRef(tag string) Ref {
 
 // Ref represents a versioned (i.e., tagged) image. The tag is
 // allowed to be empty, though it is in general undefined what that
-// means. As such, `Ref` also includes all `Name` values.
+// means. As such, `Ref` also includes all `Name` values. A ref may
+// also be pinned to a digest, in which case the digest says which
+// image is meant, and the tag is there for people (and for working
+// out what's newer).
 //
 // Examples (stringified):
 //  * alpine:3.5
 //  * library/alpine:3.5
 //  * docker.io/fluxcd/flux:1.1.0
 //  * localhost:5000/arbitrary/path/to/repo:revision-sha1
+//  * fluxcd/flux:1.1.0@sha256:1a2b3c...
 type Ref struct {
 	Name
-	Tag string
+	Tag    string
+	Digest string
 }
 
 // CanonicalRef is an image ref with none of the fields left to be
@@ -126,7 +132,11 @@ func (i Ref) String() string {
 	if i.Tag != "" {
 		tag = ":" + i.Tag
 	}
-	return fmt.Sprintf("%s%s", i.Name.String(), tag)
+	var digest string
+	if i.Digest != "" {
+		digest = "@" + i.Digest
+	}
+	return fmt.Sprintf("%s%s%s", i.Name.String(), tag, digest)
 }
 
 // ParseRef parses a string representation of an image id into an
@@ -138,22 +148,30 @@ func ParseRef(s string) (Ref, error) {
 	if s == "" {
 		return id, errors.Wrapf(ErrBlankImageID, "parsing %q", s)
 	}
-	if strings.HasPrefix(s, "/") || strings.HasSuffix(s, "/") {
+	name := s
+	// A digest comes last, after any tag
+	if at := strings.LastIndex(s, "@"); at > -1 {
+		name, id.Digest = s[:at], s[at+1:]
+		if !digestRegexp.MatchString(id.Digest) {
+			return id, errors.Wrapf(ErrMalformedDigest, "parsing %q", s)
+		}
+	}
+	if name == "" || strings.HasPrefix(name, "/") || strings.HasSuffix(name, "/") {
 		return id, errors.Wrapf(ErrMalformedImageID, "parsing %q", s)
 	}
 
-	elements := strings.Split(s, "/")
+	elements := strings.Split(name, "/")
 	switch len(elements) {
 	case 0: // NB strings.Split will never return []
 		return id, errors.Wrapf(ErrMalformedImageID, "parsing %q", s)
 	case 1: // no slashes, e.g., "alpine:1.5"; treat as library image
-		id.Image = s
+		id.Image = name
 	case 2: // may have a domain e.g., "localhost/foo", or not e.g., "weaveworks/scope"
 		if domainRegexp.MatchString(elements[0]) {
 			id.Domain = elements[0]
 			id.Image = elements[1]
 		} else {
-			id.Image = s
+			id.Image = name
 		}
 	default: // cannot be a library image, so the first element is assumed to be a domain
 		id.Domain = elements[0]
@@ -182,6 +200,7 @@ var (
 	domainComponent = `([a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9-]*[a-zA-Z0-9])`
 	domain          = fmt.Sprintf(`localhost|(%s([.]%s)+)(:[0-9]+)?`, domainComponent, domainComponent)
 	domainRegexp    = regexp.MustCompile(domain)
+	digestRegexp    = regexp.MustCompile(`^[a-z0-9]+([+._-][a-z0-9]+)*:[a-fA-F0-9]{32,}$`)
 )
 
 // ImageID is serialized/deserialized as a string
@@ -200,13 +219,14 @@ func (i *Ref) UnmarshalJSON(data []byte) (err error) {
 }
 
 // CanonicalRef returns the canonicalised reference including the tag
-// if present.
+// and digest if present.
 func (i Ref) CanonicalRef() CanonicalRef {
 	name := i.CanonicalName()
 	return CanonicalRef{
 		Ref: Ref{
-			Name: name.Name,
-			Tag:  i.Tag,
+			Name:   name.Name,
+			Tag:    i.Tag,
+			Digest: i.Digest,
 		},
 	}
 }
@@ -215,11 +235,21 @@ func (i Ref) Components() (domain, repo, tag string) {
 	return i.Domain, i.Image, i.Tag
 }
 
-// WithNewTag makes a new copy of an ImageID with a new tag
+// WithNewTag makes a new copy of an ImageID with a new tag. Any
+// digest is dropped, since it was for the old tag.
 func (i Ref) WithNewTag(t string) Ref {
 	var img Ref
 	img = i
 	img.Tag = t
+	img.Digest = ""
+	return img
+}
+
+// WithDigest makes a new copy of an ImageID pinned to the digest
+// given; an empty digest unpins it.
+func (i Ref) WithDigest(d string) Ref {
+	img := i
+	img.Digest = d
 	return img
 }
 
@@ -309,6 +339,47 @@ type Info struct {
 	CreatedAt time.Time `json:",omitempty"`
 	// the last time this image manifest was fetched
 	LastFetched time.Time `json:",omitempty"`
+	// the platforms the image is built for; if the ref is for a
+	// manifest list, there will be one for each image in the list
+	Platforms []Platform `json:",omitempty"`
+}
+
+// Platform is an OS and CPU architecture an image is built for.
+type Platform struct {
+	OS      string `json:",omitempty"`
+	Arch    string `json:",omitempty"`
+	Variant string `json:",omitempty"`
+}
+
+// String gives the platform in the form used by e.g., `docker
+// build --platform`; `linux/arm64/v8`.
+func (p Platform) String() string {
+	s := p.OS + "/" + p.Arch
+	if p.Variant != "" {
+		s += "/" + p.Variant
+	}
+	return s
+}
+
+// SupportsPlatforms says whether the image can run on each of the
+// platforms given; that is, whether it's built for the operating
+// system and CPU architecture of each (variants aren't compared). If
+// the platforms of the image aren't known, it's assumed to run
+// anywhere.
+func (im Info) SupportsPlatforms(platforms []Platform) bool {
+	if len(im.Platforms) == 0 {
+		return true
+	}
+platforms:
+	for _, want := range platforms {
+		for _, p := range im.Platforms {
+			if p.OS == want.OS && p.Arch == want.Arch {
+				continue platforms
+			}
+		}
+		return false
+	}
+	return true
 }
 
 // MarshalJSON returns the Info value in JSON (as bytes). It is
@@ -364,10 +435,12 @@ type RepositoryMetadata struct {
 }
 
 // FindImageWithRef returns image.Info given an image ref. If the image cannot be
-// found, it returns the image.Info with the ID provided.
+// found, it returns the image.Info with the ID provided. A digest in
+// the ref is not used to find the image, since images are indexed by
+// tag.
 func (rm RepositoryMetadata) FindImageWithRef(ref Ref) Info {
 	for _, img := range rm.Images {
-		if img.ID == ref {
+		if img.ID == ref.WithDigest("") {
 			return img
 		}
 	}
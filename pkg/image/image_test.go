@@ -29,6 +29,8 @@ func TestDomainRegexp(t *testing.T) {
 	}
 }
 
+const testDigest = "sha256:2c1d1d3dd1c0e4f49ee2ea4c23c53a3ce2ac0e3c9b42c9ea2e7e5b2f3ab56c4c"
+
 func TestParseRef(t *testing.T) {
 	for _, x := range []struct {
 		test     string
@@ -52,7 +54,12 @@ func TestParseRef(t *testing.T) {
 		{"quay.io/library/alpine:latest", "quay.io", "library/alpine", "quay.io/library/alpine:latest"},
 		{"quay.io/library/alpine:mytag", "quay.io", "library/alpine", "quay.io/library/alpine:mytag"},
 		{"localhost:5000/path/to/repo/alpine:mytag", "localhost:5000", "path/to/repo/alpine", "localhost:5000/path/to/repo/alpine:mytag"},
+		// A ref can be pinned to a digest, with or without a tag
+		{"alpine:mytag@" + testDigest, dockerHubHost, "library/alpine", "index.docker.io/library/alpine:mytag@" + testDigest},
+		{"alpine@" + testDigest, dockerHubHost, "library/alpine", "index.docker.io/library/alpine@" + testDigest},
+		{"localhost:5000/hello:v1.1@" + testDigest, "localhost:5000", "hello", "localhost:5000/hello:v1.1@" + testDigest},
 	} {
+
 		i, err := ParseRef(x.test)
 		if err != nil {
 			t.Errorf("Failed parsing %q: %s", x.test, err)
@@ -80,6 +87,10 @@ func TestParseRefErrorCases(t *testing.T) {
 		{":tag"},
 		{"/leading/slash"},
 		{"trailing/slash/"},
+		{"alpine:tag@"},
+		{"alpine:tag@sha256"},
+		{"alpine:tag@sha256:not-hex"},
+		{"@" + testDigest},
 	} {
 		_, err := ParseRef(x.test)
 		if err == nil {
@@ -119,6 +130,7 @@ func TestRefSerialization(t *testing.T) {
 	}{
 		{Ref{Name: Name{Image: "alpine"}, Tag: "a123"}, `"alpine:a123"`},
 		{Ref{Name: Name{Domain: "quay.io", Image: "weaveworks/foobar"}, Tag: "baz"}, `"quay.io/weaveworks/foobar:baz"`},
+		{Ref{Name: Name{Image: "alpine"}, Tag: "a123", Digest: testDigest}, `"alpine:a123@` + testDigest + `"`},
 	} {
 		serialized, err := json.Marshal(x.test)
 		if err != nil {
@@ -205,6 +217,7 @@ func TestImageInfoSerialisation(t *testing.T) {
 	info.Digest = "sha256:digest"
 	info.ImageID = "sha256:layerID"
 	info.LastFetched = t1
+	info.Platforms = []Platform{{OS: "linux", Arch: "amd64"}, {OS: "linux", Arch: "arm64", Variant: "v8"}}
 	bytes, err := json.Marshal(info)
 	if err != nil {
 		t.Fatal(err)
@@ -232,6 +245,21 @@ func TestImageInfoCreatedAtZero(t *testing.T) {
 	}
 }
 
+func TestImageInfoSupportsPlatforms(t *testing.T) {
+	linuxAMD64 := Platform{OS: "linux", Arch: "amd64"}
+	linuxARM64 := Platform{OS: "linux", Arch: "arm64"}
+	info := mustMakeInfo("my/image:tag", time.Now())
+	assert.True(t, info.SupportsPlatforms([]Platform{linuxAMD64, linuxARM64}), "platforms unknown")
+
+	info.Platforms = []Platform{{OS: "linux", Arch: "amd64"}, {OS: "linux", Arch: "arm64", Variant: "v8"}}
+	assert.Equal(t, "linux/arm64/v8", info.Platforms[1].String())
+	assert.True(t, info.SupportsPlatforms(nil))
+	assert.True(t, info.SupportsPlatforms([]Platform{linuxARM64}))
+	assert.True(t, info.SupportsPlatforms([]Platform{linuxAMD64, linuxARM64}))
+	assert.False(t, info.SupportsPlatforms([]Platform{linuxAMD64, {OS: "linux", Arch: "ppc64le"}}))
+	assert.False(t, info.SupportsPlatforms([]Platform{{OS: "windows", Arch: "amd64"}}), "operating system must match too")
+}
+
 func TestImage_OrderByCreationDate(t *testing.T) {
 	time0 := testTime.Add(time.Second)
 	time2 := testTime.Add(-time.Second)
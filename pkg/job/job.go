@@ -30,9 +30,12 @@ const (
 // used to send. But in the interest of breaking cycles before
 // they happen, it's (almost) duplicated here.
 type Result struct {
-	Revision string        `json:"revision,omitempty"`
-	Spec     *update.Spec  `json:"spec,omitempty"`
-	Result   update.Result `json:"result,omitempty"`
+	Revision string `json:"revision,omitempty"`
+	// PullRequest is the URL of the pull request proposing the
+	// changes, if they were proposed rather than pushed
+	PullRequest string        `json:"pullRequest,omitempty"`
+	Spec        *update.Spec  `json:"spec,omitempty"`
+	Result      update.Result `json:"result,omitempty"`
 }
 
 // Status holds the possible states of a job; either,
@@ -110,9 +113,9 @@ func (q *Queue) ForEach(fn func(int, *Job) bool) {
 // is only meaningful if you are using the queue from a single other
 // goroutine; i.e., it makes sense to do, say,
 //
-//    q.Enqueue(j)
-//    q.Sync()
-//    fmt.Printf("Queue length is %d\n", q.Len())
+//	q.Enqueue(j)
+//	q.Sync()
+//	fmt.Printf("Queue length is %d\n", q.Len())
 //
 // but only because those statements are sequential in a single
 // thread. So this is really only useful for testing.
@@ -13,8 +13,9 @@ import (
 )
 
 type resourceWithOrigin struct {
-	resource   resource.Resource
-	configFile *ConfigFile // only set if the resource came from a configuration file
+	resource      resource.Resource
+	configFile    *ConfigFile   // only set if the resource came from a configuration file
+	kustomization *kustomizeDir // only set if the resource came from a kustomization
 }
 
 type configAware struct {
@@ -22,9 +23,10 @@ type configAware struct {
 	rawFiles *rawFiles
 
 	// to maintain encapsulation, we don't rely on the rawFiles values
-	baseDir     string
-	manifests   Manifests
-	configFiles []*ConfigFile
+	baseDir        string
+	manifests      Manifests
+	configFiles    []*ConfigFile
+	kustomizations []*kustomizeDir
 
 	// a cache of the loaded resources, since the pattern is to update
 	// a few things at a time, and the update operations all need to
@@ -34,10 +36,13 @@ type configAware struct {
 }
 
 // NewConfigAware constructs a `Store` that processes in-repo config
-// files (`.flux.yaml`) where present, and otherwise looks for "raw"
-// YAML files.
-func NewConfigAware(baseDir string, targetPaths []string, manifests Manifests) (*configAware, error) {
-	configFiles, rawManifestDirs, err := splitConfigFilesAndRawManifestPaths(baseDir, targetPaths)
+// files (`.flux.yaml`) where present, including building
+// kustomizations where a config file says to, and otherwise looks for
+// "raw" YAML files. If kustomizeWithoutConfig is true, a target path
+// with no config file but with a kustomization file is built as a
+// kustomization, rather than being scanned for YAML files.
+func NewConfigAware(baseDir string, targetPaths []string, manifests Manifests, kustomizeWithoutConfig bool) (*configAware, error) {
+	configFiles, kustomizations, rawManifestDirs, err := splitConfigFilesAndRawManifestPaths(baseDir, targetPaths, kustomizeWithoutConfig)
 	if err != nil {
 		return nil, err
 	}
@@ -48,16 +53,18 @@ func NewConfigAware(baseDir string, targetPaths []string, manifests Manifests) (
 			baseDir:   baseDir,
 			paths:     rawManifestDirs,
 		},
-		manifests:   manifests,
-		baseDir:     baseDir,
-		configFiles: configFiles,
+		manifests:      manifests,
+		baseDir:        baseDir,
+		configFiles:    configFiles,
+		kustomizations: kustomizations,
 	}
 	return result, nil
 }
 
-func splitConfigFilesAndRawManifestPaths(baseDir string, paths []string) ([]*ConfigFile, []string, error) {
+func splitConfigFilesAndRawManifestPaths(baseDir string, paths []string, kustomizeWithoutConfig bool) ([]*ConfigFile, []*kustomizeDir, []string, error) {
 	var (
 		configFiles      []*ConfigFile
+		kustomizations   []*kustomizeDir
 		rawManifestPaths []string
 	)
 
@@ -67,28 +74,49 @@ func splitConfigFilesAndRawManifestPaths(baseDir string, paths []string) ([]*Con
 		// logs, error messages, etc.
 		relPath, err := filepath.Rel(baseDir, path)
 		if err != nil {
-			return nil, nil, err
+			return nil, nil, nil, err
 		}
 		configFilePath, workingDirPath, err := findConfigFilePaths(baseDir, path)
 		if err != nil {
 			if err == configFileNotFoundErr {
+				if kustomizeWithoutConfig {
+					kustomizationPath, err := findKustomizationFile(path)
+					if err != nil {
+						return nil, nil, nil, fmt.Errorf("error looking for a kustomization file at path %q: %s", relPath, err)
+					}
+					if kustomizationPath != "" {
+						kustomizations = append(kustomizations, newKustomizeDir(baseDir, path, kustomizationPath))
+						continue
+					}
+				}
 				rawManifestPaths = append(rawManifestPaths, path)
 				continue
 			}
-			return nil, nil, fmt.Errorf("error finding a config file starting at path %q: %s", relPath, err)
+			return nil, nil, nil, fmt.Errorf("error finding a config file starting at path %q: %s", relPath, err)
 		}
 		cf, err := NewConfigFile(relPath, configFilePath, workingDirPath)
 		if err != nil {
-			return nil, nil, fmt.Errorf("cannot parse config file: %s", err)
+			return nil, nil, nil, fmt.Errorf("cannot parse config file: %s", err)
 		}
 		if cf.IsScanForFiles() {
 			rawManifestPaths = append(rawManifestPaths, path)
 			continue
 		}
+		if cf.IsKustomize() {
+			kustomizationPath, err := findKustomizationFile(workingDirPath)
+			if err != nil {
+				return nil, nil, nil, fmt.Errorf("error looking for a kustomization file at path %q: %s", relPath, err)
+			}
+			if kustomizationPath == "" {
+				return nil, nil, nil, fmt.Errorf("no kustomization file at path %q, as needed by %s", relPath, cf.ConfigRelativeToWorkingDir())
+			}
+			kustomizations = append(kustomizations, newKustomizeDir(baseDir, workingDirPath, kustomizationPath))
+			continue
+		}
 		configFiles = append(configFiles, cf)
 	}
 
-	return configFiles, rawManifestPaths, nil
+	return configFiles, kustomizations, rawManifestPaths, nil
 }
 
 var configFileNotFoundErr = fmt.Errorf("config file not found")
@@ -142,12 +170,19 @@ func (ca *configAware) SetWorkloadContainerImage(ctx context.Context, resourceID
 	if !ok {
 		return ErrResourceNotFound(resourceID.String())
 	}
-	if resWithOrigin.configFile == nil {
+	switch {
+	case resWithOrigin.configFile != nil:
+		if err := resWithOrigin.configFile.SetWorkloadContainerImage(ctx, ca.manifests, resWithOrigin.resource, container, newImageID); err != nil {
+			return err
+		}
+	case resWithOrigin.kustomization != nil:
+		if err := resWithOrigin.kustomization.SetWorkloadContainerImage(ctx, ca.manifests, resWithOrigin.resource, container, newImageID); err != nil {
+			return err
+		}
+	default:
 		if err := ca.rawFiles.setManifestWorkloadContainerImage(resWithOrigin.resource, container, newImageID); err != nil {
 			return err
 		}
-	} else if err := resWithOrigin.configFile.SetWorkloadContainerImage(ctx, ca.manifests, resWithOrigin.resource, container, newImageID); err != nil {
-		return err
 	}
 	// Reset resources, since we have modified one
 	ca.resetResources()
@@ -164,11 +199,15 @@ func (ca *configAware) UpdateWorkloadPolicies(ctx context.Context, resourceID re
 		return false, ErrResourceNotFound(resourceID.String())
 	}
 	var changed bool
-	if resWithOrigin.configFile == nil {
-		changed, err = ca.rawFiles.updateManifestWorkloadPolicies(resWithOrigin.resource, update)
-	} else {
+	switch {
+	case resWithOrigin.configFile != nil:
 		cf := resWithOrigin.configFile
 		changed, err = cf.UpdateWorkloadPolicies(ctx, ca.manifests, resWithOrigin.resource, update)
+	case resWithOrigin.kustomization != nil:
+		kd := resWithOrigin.kustomization
+		changed, err = kd.UpdateWorkloadPolicies(ctx, ca.manifests, resWithOrigin.resource, update)
+	default:
+		changed, err = ca.rawFiles.updateManifestWorkloadPolicies(resWithOrigin.resource, update)
 	}
 	if err != nil {
 		return false, err
@@ -249,6 +288,32 @@ func (ca *configAware) getResourcesByID(ctx context.Context) (map[string]resourc
 			resourcesByID[id] = resourceWithOrigin{resource: generated, configFile: cf}
 		}
 	}
+
+	for _, kd := range ca.kustomizations {
+		resourceManifests, err := kd.GenerateManifests(ctx, ca.manifests)
+		if err != nil {
+			return nil, err
+		}
+		resources, err := ca.manifests.ParseManifest(resourceManifests, kd.Source())
+		if err != nil {
+			return nil, err
+		}
+		for id, built := range resources {
+			if duplicate, ok := resourcesByID[id]; ok {
+				var source string
+				switch {
+				case duplicate.configFile != nil:
+					source = "generated by " + duplicate.configFile.ConfigRelativeToWorkingDir()
+				case duplicate.kustomization != nil:
+					source = "built from " + duplicate.kustomization.Source()
+				default:
+					source = "in " + duplicate.resource.Source()
+				}
+				return nil, fmt.Errorf("duplicate definition of '%s' (built from %s and %s)", id, kd.Source(), source)
+			}
+			resourcesByID[id] = resourceWithOrigin{resource: built, kustomization: kd}
+		}
+	}
 	ca.mu.Lock()
 	ca.resourcesByID = resourcesByID
 	ca.mu.Unlock()
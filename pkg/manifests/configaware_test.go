@@ -53,7 +53,7 @@ func setup(t *testing.T, paths []string, configs ...config) (*configAware, strin
 			ioutil.WriteFile(filepath.Join(baseDir, p, ConfigFilename), []byte(c.fluxyaml), 0600)
 		}
 	}
-	frs, err := NewConfigAware(baseDir, searchPaths, manifests)
+	frs, err := NewConfigAware(baseDir, searchPaths, manifests, false)
 	assert.NoError(t, err)
 	return frs, baseDir, cleanup
 }
@@ -125,7 +125,7 @@ commandUpdated:
 	err := ioutil.WriteFile(filepath.Join(baseDir, "envs", ConfigFilename), []byte(configFile), 0700)
 	assert.NoError(t, err)
 
-	configFiles, rawManifestFiles, err := splitConfigFilesAndRawManifestPaths(baseDir, targets)
+	configFiles, _, rawManifestFiles, err := splitConfigFilesAndRawManifestPaths(baseDir, targets)
 	assert.NoError(t, err)
 
 	assert.Len(t, rawManifestFiles, 1)
@@ -68,6 +68,12 @@ oneOf:
     scanForFiles:
       additionalProperties: false
   additionalProperties: false
+- required: ['version', 'kustomize']
+  properties:
+    version: { '$ref': '#/definitions/version' }
+    kustomize:
+      additionalProperties: false
+  additionalProperties: false
 `
 
 func mustCompileConfigSchema() *jsonschema.Schema {
@@ -97,6 +103,7 @@ type ConfigFile struct {
 	CommandUpdated *CommandUpdated `json:"commandUpdated,omitempty"`
 	PatchUpdated   *PatchUpdated   `json:"patchUpdated,omitempty"`
 	ScanForFiles   *ScanForFiles   `json:"scanForFiles,omitempty"`
+	Kustomize      *Kustomize      `json:"kustomize,omitempty"`
 
 	// These are supplied, and can't be calculated from each other
 	configPath         string // the absolute path to the .flux.yaml
@@ -164,6 +171,19 @@ func (cf *ConfigFile) IsScanForFiles() bool {
 	return cf.ScanForFiles != nil
 }
 
+// Kustomize represents a config in which the kustomization in each
+// target path is built by fluxd itself, rather than by running
+// `kustomize build` as a generator. Only some of what kustomize does
+// is supported; see Kustomization.
+type Kustomize struct {
+}
+
+// IsKustomize returns true if the config file indicates that the
+// kustomization in the target path should be built in-process.
+func (cf *ConfigFile) IsKustomize() bool {
+	return cf.Kustomize != nil
+}
+
 func ParseConfigFile(fileBytes []byte, result *ConfigFile) error {
 	// The file contents are unmarshaled into a map so that we will
 	// see any extraneous fields. This is important, for example, for
@@ -47,6 +47,12 @@ commandUpdated:
   generators: []
   patchFile: "foo.yaml"
 `,
+
+		"generators with kustomize": `
+version: 1
+kustomize:
+  generators: []
+`,
 	} {
 		t.Run(name, func(t *testing.T) {
 			var cf ConfigFile
@@ -72,6 +78,11 @@ patchUpdated:
 		"minimal files (the only kind)": `
 version: 1
 scanForFiles: {}
+`,
+
+		"minimal kustomize (the only kind)": `
+version: 1
+kustomize: {}
 `,
 	} {
 		t.Run(name, func(t *testing.T) {
@@ -0,0 +1,831 @@
+package manifests
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	jsonyaml "github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+
+	"github.com/fluxcd/flux/pkg/image"
+	"github.com/fluxcd/flux/pkg/resource"
+)
+
+// KustomizationFilenames are the names of the file that marks a
+// directory as a kustomization, in the order kustomize looks for them.
+var KustomizationFilenames = []string{"kustomization.yaml", "kustomization.yml", "Kustomization"}
+
+// KustomizePolicyPatchFilename is the name of the strategic merge
+// patch file in which policy updates to kustomized workloads are
+// recorded. It is created next to the kustomization file, and added
+// to its `patchesStrategicMerge`, when first needed.
+const KustomizePolicyPatchFilename = "flux-policy-patch.yaml"
+
+// Kustomization is the subset of the kustomization file format that
+// is built in-process, when a `.flux.yaml` has the `kustomize`
+// directive. Fields not listed here are rejected, rather than
+// ignored. This is not kustomize: even for the fields supported, the
+// result can differ from that of `kustomize build` (e.g., name
+// prefixes and suffixes are applied to only some references, the
+// namespace isn't applied to role binding subjects, common labels are
+// added to fewer selectors, and resources are in a different order).
+// Where that matters, use a `.flux.yaml` with a generator running
+// `kustomize build`.
+type Kustomization struct {
+	Namespace             string            `json:"namespace,omitempty"`
+	NamePrefix            string            `json:"namePrefix,omitempty"`
+	NameSuffix            string            `json:"nameSuffix,omitempty"`
+	CommonLabels          map[string]string `json:"commonLabels,omitempty"`
+	CommonAnnotations     map[string]string `json:"commonAnnotations,omitempty"`
+	Resources             []string          `json:"resources,omitempty"`
+	Bases                 []string          `json:"bases,omitempty"`
+	PatchesStrategicMerge []string          `json:"patchesStrategicMerge,omitempty"`
+	Images                []KustomizeImage  `json:"images,omitempty"`
+}
+
+// KustomizeImage is an entry in the `images` field of a
+// kustomization, which replaces the name, tag or digest of matching
+// container images.
+type KustomizeImage struct {
+	Name    string `json:"name"`
+	NewName string `json:"newName,omitempty"`
+	NewTag  string `json:"newTag,omitempty"`
+	Digest  string `json:"digest,omitempty"`
+}
+
+var supportedKustomizationFields = map[string]bool{
+	"apiVersion":            true,
+	"kind":                  true,
+	"namespace":             true,
+	"namePrefix":            true,
+	"nameSuffix":            true,
+	"commonLabels":          true,
+	"commonAnnotations":     true,
+	"resources":             true,
+	"bases":                 true,
+	"patchesStrategicMerge": true,
+	"images":                true,
+}
+
+// ParseKustomization parses and checks the contents of a
+// kustomization file.
+func ParseKustomization(fileBytes []byte, result *Kustomization) error {
+	var intermediate map[string]interface{}
+	if err := jsonyaml.Unmarshal(fileBytes, &intermediate); err != nil {
+		return fmt.Errorf("cannot parse: %s", err)
+	}
+	for field := range intermediate {
+		if !supportedKustomizationFields[field] {
+			return fmt.Errorf("field %q is not supported in-process; use a %s with a kustomize generator command instead", field, ConfigFilename)
+		}
+	}
+	return jsonyaml.Unmarshal(fileBytes, result)
+}
+
+// findKustomizationFile returns the path to the kustomization file in
+// the directory given, or "" if there isn't one.
+func findKustomizationFile(dir string) (string, error) {
+	fi, err := os.Stat(dir)
+	if err != nil {
+		return "", err
+	}
+	if !fi.IsDir() {
+		return "", nil
+	}
+	for _, name := range KustomizationFilenames {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		} else if !os.IsNotExist(err) {
+			return "", err
+		}
+	}
+	return "", nil
+}
+
+// kustomizeDir is a directory, given as a `--git-path`, that has a
+// kustomization file and is built in-process.
+type kustomizeDir struct {
+	baseDir string // the absolute path to the repo root
+	dir     string // the absolute path to the directory
+	path    string // the absolute path to the kustomization file
+}
+
+func newKustomizeDir(baseDir, dir, path string) *kustomizeDir {
+	return &kustomizeDir{baseDir: baseDir, dir: dir, path: path}
+}
+
+// Source gives the path of the kustomization file relative to the
+// repo root; this is the source given for the resources it builds.
+func (kd *kustomizeDir) Source() string {
+	rel, err := filepath.Rel(kd.baseDir, kd.path)
+	if err != nil {
+		return kd.path
+	}
+	return rel
+}
+
+// GenerateManifests builds the kustomization.
+func (kd *kustomizeDir) GenerateManifests(ctx context.Context, manifests Manifests) ([]byte, error) {
+	b := &kustomizeBuilder{baseDir: kd.baseDir, manifests: manifests}
+	docs, err := b.build(kd.dir, nil)
+	if err != nil {
+		return nil, err
+	}
+	return marshalDocs(manifests, docs)
+}
+
+// SetWorkloadContainerImage records an image update in the `images`
+// field of the kustomization. The entry is for the image as it is
+// before this kustomization's own image replacements; so, as with
+// `kustomize edit set image`, it applies to every container using the
+// same image.
+func (kd *kustomizeDir) SetWorkloadContainerImage(ctx context.Context, manifests Manifests, r resource.Resource, container string, newImageID image.Ref) error {
+	b := &kustomizeBuilder{baseDir: kd.baseDir, manifests: manifests}
+	kust, docs, err := b.load(kd.dir, "", nil)
+	if err != nil {
+		return err
+	}
+	doc, err := b.findOrigin(kust, docs, r.ResourceID())
+	if err != nil {
+		return err
+	}
+	var current string
+	if spec := podSpec(doc); spec != nil {
+		for _, c := range podContainers(spec) {
+			if c["name"] == container {
+				current, _ = c["image"].(string)
+			}
+		}
+	}
+	if current == "" {
+		return fmt.Errorf("container %q not found in resource %s built from %s", container, r.ResourceID(), kd.Source())
+	}
+	name, _, _ := splitImage(current)
+
+	update := KustomizeImage{Name: name, NewTag: newImageID.Tag}
+	if newImageID.Digest != "" {
+		// kustomize's `digest` replaces the tag, but a pinned image
+		// keeps both; kustomize puts newTag after the name as it is.
+		update.NewTag += "@" + newImageID.Digest
+	}
+	if currentRef, err := image.ParseRef(current); err != nil || currentRef.CanonicalName() != newImageID.CanonicalName() {
+		update.NewName = newImageID.Name.String()
+	}
+	return kd.editKustomization(func(k yaml.MapSlice) (yaml.MapSlice, error) {
+		return setKustomizationImage(k, update), nil
+	})
+}
+
+// UpdateWorkloadPolicies records a policy update in the policy patch
+// file of the kustomization. The patch is calculated from the
+// resources as they are before this kustomization's transformations
+// (namespace, name prefix, and so on), since that's what patches
+// apply to.
+func (kd *kustomizeDir) UpdateWorkloadPolicies(ctx context.Context, manifests Manifests, r resource.Resource, update resource.PolicyUpdate) (bool, error) {
+	b := &kustomizeBuilder{baseDir: kd.baseDir, manifests: manifests}
+	kust, unpatched, err := b.load(kd.dir, KustomizePolicyPatchFilename, nil)
+	if err != nil {
+		return false, err
+	}
+	patchPath := filepath.Join(kd.dir, KustomizePolicyPatchFilename)
+	patched := unpatched
+	if patch, err := ioutil.ReadFile(patchPath); err == nil {
+		if patched, err = b.applyPatch(unpatched, patch, KustomizePolicyPatchFilename); err != nil {
+			return false, err
+		}
+	} else if !os.IsNotExist(err) {
+		return false, err
+	}
+
+	doc, err := b.findOrigin(kust, patched, r.ResourceID())
+	if err != nil {
+		return false, err
+	}
+	originID, err := b.docID(doc)
+	if err != nil {
+		return false, err
+	}
+
+	unpatchedBytes, err := marshalDocs(manifests, unpatched)
+	if err != nil {
+		return false, err
+	}
+	patchedBytes, err := marshalDocs(manifests, patched)
+	if err != nil {
+		return false, err
+	}
+	updatedBytes, err := manifests.UpdateWorkloadPolicies(patchedBytes, originID, update)
+	if err != nil {
+		return false, err
+	}
+	if bytes.Equal(patchedBytes, updatedBytes) {
+		return false, nil
+	}
+	newPatch, err := manifests.CreateManifestPatch(unpatchedBytes, updatedBytes, "kustomized resources", "updated kustomized resources")
+	if err != nil {
+		return false, err
+	}
+	if err := ioutil.WriteFile(patchPath, newPatch, 0600); err != nil {
+		return false, err
+	}
+	if err := kd.editKustomization(func(k yaml.MapSlice) (yaml.MapSlice, error) {
+		return addKustomizationPatch(k, KustomizePolicyPatchFilename), nil
+	}); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// editKustomization rewrites the kustomization file. The order of
+// fields is kept, though comments are not.
+func (kd *kustomizeDir) editKustomization(edit func(yaml.MapSlice) (yaml.MapSlice, error)) error {
+	def, err := ioutil.ReadFile(kd.path)
+	if err != nil {
+		return err
+	}
+	var k yaml.MapSlice
+	if err := yaml.Unmarshal(def, &k); err != nil {
+		return fmt.Errorf("cannot parse %s: %s", kd.Source(), err)
+	}
+	if k, err = edit(k); err != nil {
+		return err
+	}
+	newDef, err := yaml.Marshal(k)
+	if err != nil {
+		return err
+	}
+	fi, err := os.Stat(kd.path)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(kd.path, newDef, fi.Mode())
+}
+
+func setKustomizationImage(k yaml.MapSlice, update KustomizeImage) yaml.MapSlice {
+	entry := yaml.MapSlice{{Key: "name", Value: update.Name}}
+	if update.NewName != "" {
+		entry = append(entry, yaml.MapItem{Key: "newName", Value: update.NewName})
+	}
+	entry = append(entry, yaml.MapItem{Key: "newTag", Value: update.NewTag})
+
+	for i, item := range k {
+		if item.Key != "images" {
+			continue
+		}
+		images, _ := item.Value.([]interface{})
+		for j, img := range images {
+			if m, ok := img.(yaml.MapSlice); ok && mapSliceValue(m, "name") == update.Name {
+				images[j] = entry
+				return k
+			}
+		}
+		k[i].Value = append(images, entry)
+		return k
+	}
+	return append(k, yaml.MapItem{Key: "images", Value: []interface{}{entry}})
+}
+
+func addKustomizationPatch(k yaml.MapSlice, patchFile string) yaml.MapSlice {
+	for i, item := range k {
+		if item.Key != "patchesStrategicMerge" {
+			continue
+		}
+		patches, _ := item.Value.([]interface{})
+		for _, p := range patches {
+			if p == patchFile {
+				return k
+			}
+		}
+		k[i].Value = append(patches, patchFile)
+		return k
+	}
+	return append(k, yaml.MapItem{Key: "patchesStrategicMerge", Value: []interface{}{patchFile}})
+}
+
+func mapSliceValue(m yaml.MapSlice, key string) interface{} {
+	for _, item := range m {
+		if item.Key == key {
+			return item.Value
+		}
+	}
+	return nil
+}
+
+// --- building
+
+type kustomizeBuilder struct {
+	baseDir   string
+	manifests Manifests
+}
+
+// build loads the resources of the kustomization in dir, and applies
+// its transformations.
+func (b *kustomizeBuilder) build(dir string, stack []string) ([]map[string]interface{}, error) {
+	kust, docs, err := b.load(dir, "", stack)
+	if err != nil {
+		return nil, err
+	}
+	return transformDocs(kust, docs), nil
+}
+
+// load reads the kustomization in dir, and the resources it
+// includes, and applies its patches (other than `skipPatch`, if
+// given). The kustomization's transformations are not applied.
+func (b *kustomizeBuilder) load(dir string, skipPatch string, stack []string) (*Kustomization, []map[string]interface{}, error) {
+	relDir := b.rel(dir)
+	for _, d := range stack {
+		if d == dir {
+			return nil, nil, fmt.Errorf("cycle in kustomizations: %s includes itself", relDir)
+		}
+	}
+	stack = append(stack, dir)
+
+	path, err := findKustomizationFile(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	if path == "" {
+		return nil, nil, fmt.Errorf("no kustomization file found in %s", relDir)
+	}
+	def, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	var kust Kustomization
+	if err := ParseKustomization(def, &kust); err != nil {
+		return nil, nil, fmt.Errorf("processing %s: %s", b.rel(path), err)
+	}
+
+	var docs []map[string]interface{}
+	for _, res := range append(kust.Bases, kust.Resources...) {
+		if strings.Contains(res, "://") || strings.HasPrefix(res, "git@") {
+			return nil, nil, fmt.Errorf("processing %s: remote resource %q is not supported", b.rel(path), res)
+		}
+		resPath := filepath.Join(dir, res)
+		if _, _, err := cleanAndEnsureParentPath(b.baseDir, resPath); err != nil {
+			return nil, nil, fmt.Errorf("processing %s: %s", b.rel(path), err)
+		}
+		fi, err := os.Stat(resPath)
+		if err != nil {
+			return nil, nil, fmt.Errorf("processing %s: %s", b.rel(path), err)
+		}
+		var resDocs []map[string]interface{}
+		if fi.IsDir() {
+			resDocs, err = b.build(resPath, stack)
+		} else {
+			resDocs, err = b.readDocs(resPath)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		docs = append(docs, resDocs...)
+	}
+
+	for _, p := range kust.PatchesStrategicMerge {
+		if p == skipPatch {
+			continue
+		}
+		patch, err := ioutil.ReadFile(filepath.Join(dir, p))
+		if err != nil {
+			return nil, nil, fmt.Errorf("processing %s: %s", b.rel(path), err)
+		}
+		if docs, err = b.applyPatch(docs, patch, b.rel(filepath.Join(dir, p))); err != nil {
+			return nil, nil, err
+		}
+	}
+	return &kust, docs, nil
+}
+
+func (b *kustomizeBuilder) readDocs(path string) ([]map[string]interface{}, error) {
+	def, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	docs, err := parseDocs(def)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %s", b.rel(path), err)
+	}
+	return docs, nil
+}
+
+func (b *kustomizeBuilder) applyPatch(docs []map[string]interface{}, patch []byte, patchSource string) ([]map[string]interface{}, error) {
+	original, err := marshalDocs(b.manifests, docs)
+	if err != nil {
+		return nil, err
+	}
+	patched, err := b.manifests.ApplyManifestPatch(original, patch, "kustomized resources", patchSource)
+	if err != nil {
+		return nil, err
+	}
+	return parseDocs(patched)
+}
+
+// findOrigin finds the document, among those loaded for a
+// kustomization, that is transformed into the resource with the ID
+// given.
+func (b *kustomizeBuilder) findOrigin(kust *Kustomization, docs []map[string]interface{}, id resource.ID) (map[string]interface{}, error) {
+	transformed := transformDocs(kust, copyDocs(docs))
+	for i, doc := range transformed {
+		docID, err := b.docID(doc)
+		if err != nil {
+			return nil, err
+		}
+		if docID == id {
+			return docs[i], nil
+		}
+	}
+	return nil, ErrResourceNotFound(id.String())
+}
+
+func (b *kustomizeBuilder) docID(doc map[string]interface{}) (resource.ID, error) {
+	def, err := jsonyaml.Marshal(doc)
+	if err != nil {
+		return resource.ID{}, err
+	}
+	resources, err := b.manifests.ParseManifest(def, "kustomized resources")
+	if err != nil {
+		return resource.ID{}, err
+	}
+	for _, res := range resources {
+		return res.ResourceID(), nil
+	}
+	return resource.ID{}, errors.New("kustomized resource could not be parsed")
+}
+
+func (b *kustomizeBuilder) rel(path string) string {
+	rel, err := filepath.Rel(b.baseDir, path)
+	if err != nil {
+		return path
+	}
+	return rel
+}
+
+// parseDocs splits multidoc YAML into the individual documents, as
+// JSON-compatible values.
+func parseDocs(def []byte) ([]map[string]interface{}, error) {
+	var docs []map[string]interface{}
+	decoder := yaml.NewDecoder(bytes.NewReader(def))
+	for {
+		var obj interface{}
+		if err := decoder.Decode(&obj); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if obj == nil {
+			continue
+		}
+		docBytes, err := yaml.Marshal(obj)
+		if err != nil {
+			return nil, err
+		}
+		var doc map[string]interface{}
+		if err := jsonyaml.Unmarshal(docBytes, &doc); err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+	return docs, nil
+}
+
+func marshalDocs(manifests Manifests, docs []map[string]interface{}) ([]byte, error) {
+	buf := bytes.NewBuffer(nil)
+	for _, doc := range docs {
+		def, err := jsonyaml.Marshal(doc)
+		if err != nil {
+			return nil, err
+		}
+		if err := manifests.AppendManifestToBuffer(def, buf); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func copyDocs(docs []map[string]interface{}) []map[string]interface{} {
+	result := make([]map[string]interface{}, len(docs))
+	for i, doc := range docs {
+		result[i] = copyValue(doc).(map[string]interface{})
+	}
+	return result
+}
+
+func copyValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			m[k] = copyValue(val)
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(v))
+		for i, val := range v {
+			l[i] = copyValue(val)
+		}
+		return l
+	default:
+		return v
+	}
+}
+
+// --- transformations
+
+// Kinds that are not namespaced, and so are not given the namespace
+// of a kustomization.
+var clusterScopedKinds = map[string]bool{
+	"APIService":                     true,
+	"ClusterRole":                    true,
+	"ClusterRoleBinding":             true,
+	"CustomResourceDefinition":       true,
+	"MutatingWebhookConfiguration":   true,
+	"Namespace":                      true,
+	"PersistentVolume":               true,
+	"PodSecurityPolicy":              true,
+	"PriorityClass":                  true,
+	"StorageClass":                   true,
+	"ValidatingWebhookConfiguration": true,
+}
+
+// Kinds that are not given the name prefix or suffix of a
+// kustomization.
+var unprefixedKinds = map[string]bool{
+	"CustomResourceDefinition": true,
+	"Namespace":                true,
+}
+
+// transformDocs applies the transformations of a kustomization to the
+// documents given, in the order kustomize does.
+func transformDocs(kust *Kustomization, docs []map[string]interface{}) []map[string]interface{} {
+	if kust.Namespace != "" {
+		for _, doc := range docs {
+			if !clusterScopedKinds[docKind(doc)] {
+				nestedMap(doc, "metadata")["namespace"] = kust.Namespace
+			}
+		}
+	}
+	if kust.NamePrefix != "" || kust.NameSuffix != "" {
+		renameDocs(kust.NamePrefix, kust.NameSuffix, docs)
+	}
+	for _, doc := range docs {
+		for k, v := range kust.CommonLabels {
+			nestedMap(doc, "metadata", "labels")[k] = v
+			if docKind(doc) == "Service" {
+				nestedMap(doc, "spec", "selector")[k] = v
+			}
+			switch docKind(doc) {
+			case "Deployment", "ReplicaSet", "StatefulSet", "DaemonSet":
+				nestedMap(doc, "spec", "selector", "matchLabels")[k] = v
+			case "ReplicationController":
+				nestedMap(doc, "spec", "selector")[k] = v
+			case "CronJob":
+				nestedMap(doc, "spec", "jobTemplate", "metadata", "labels")[k] = v
+			}
+			if meta := podTemplateMeta(doc); meta != nil {
+				nestedMap(meta, "labels")[k] = v
+			}
+		}
+		for k, v := range kust.CommonAnnotations {
+			nestedMap(doc, "metadata", "annotations")[k] = v
+			if meta := podTemplateMeta(doc); meta != nil {
+				nestedMap(meta, "annotations")[k] = v
+			}
+		}
+		for _, img := range kust.Images {
+			if spec := podSpec(doc); spec != nil {
+				for _, c := range podContainers(spec) {
+					if current, ok := c["image"].(string); ok {
+						c["image"] = replaceImage(current, img)
+					}
+				}
+			}
+		}
+	}
+	return docs
+}
+
+// renameDocs adds the prefix and suffix given to the names of the
+// resources, and to references to them from pod templates and role
+// bindings. Other references are left alone.
+func renameDocs(prefix, suffix string, docs []map[string]interface{}) {
+	renamed := map[string]map[string]bool{}
+	for _, doc := range docs {
+		kind := docKind(doc)
+		if unprefixedKinds[kind] {
+			continue
+		}
+		meta := nestedMap(doc, "metadata")
+		name, _ := meta["name"].(string)
+		if renamed[kind] == nil {
+			renamed[kind] = map[string]bool{}
+		}
+		renamed[kind][name] = true
+		meta["name"] = prefix + name + suffix
+	}
+	rename := func(m map[string]interface{}, field, kind string) {
+		if name, ok := m[field].(string); ok && renamed[kind][name] {
+			m[field] = prefix + name + suffix
+		}
+	}
+
+	for _, doc := range docs {
+		if docKind(doc) == "StatefulSet" {
+			rename(nestedMap(doc, "spec"), "serviceName", "Service")
+		}
+		if docKind(doc) == "RoleBinding" || docKind(doc) == "ClusterRoleBinding" {
+			roleRef := nestedMap(doc, "roleRef")
+			if kind, ok := roleRef["kind"].(string); ok {
+				rename(roleRef, "name", kind)
+			}
+			subjects, _ := doc["subjects"].([]interface{})
+			for _, s := range subjects {
+				if subject, ok := s.(map[string]interface{}); ok && subject["kind"] == "ServiceAccount" {
+					rename(subject, "name", "ServiceAccount")
+				}
+			}
+		}
+		spec := podSpec(doc)
+		if spec == nil {
+			continue
+		}
+		rename(spec, "serviceAccountName", "ServiceAccount")
+		if secrets, ok := spec["imagePullSecrets"].([]interface{}); ok {
+			for _, s := range secrets {
+				if secret, ok := s.(map[string]interface{}); ok {
+					rename(secret, "name", "Secret")
+				}
+			}
+		}
+		if volumes, ok := spec["volumes"].([]interface{}); ok {
+			for _, v := range volumes {
+				volume, ok := v.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				if cm, ok := volume["configMap"].(map[string]interface{}); ok {
+					rename(cm, "name", "ConfigMap")
+				}
+				if secret, ok := volume["secret"].(map[string]interface{}); ok {
+					rename(secret, "secretName", "Secret")
+				}
+				if pvc, ok := volume["persistentVolumeClaim"].(map[string]interface{}); ok {
+					rename(pvc, "claimName", "PersistentVolumeClaim")
+				}
+			}
+		}
+		for _, c := range podContainers(spec) {
+			if envFrom, ok := c["envFrom"].([]interface{}); ok {
+				for _, e := range envFrom {
+					source, ok := e.(map[string]interface{})
+					if !ok {
+						continue
+					}
+					if ref, ok := source["configMapRef"].(map[string]interface{}); ok {
+						rename(ref, "name", "ConfigMap")
+					}
+					if ref, ok := source["secretRef"].(map[string]interface{}); ok {
+						rename(ref, "name", "Secret")
+					}
+				}
+			}
+			if env, ok := c["env"].([]interface{}); ok {
+				for _, e := range env {
+					v, ok := e.(map[string]interface{})
+					if !ok {
+						continue
+					}
+					valueFrom, ok := v["valueFrom"].(map[string]interface{})
+					if !ok {
+						continue
+					}
+					if ref, ok := valueFrom["configMapKeyRef"].(map[string]interface{}); ok {
+						rename(ref, "name", "ConfigMap")
+					}
+					if ref, ok := valueFrom["secretKeyRef"].(map[string]interface{}); ok {
+						rename(ref, "name", "Secret")
+					}
+				}
+			}
+		}
+	}
+}
+
+func docKind(doc map[string]interface{}) string {
+	kind, _ := doc["kind"].(string)
+	return kind
+}
+
+// nestedMap returns the map at the path given, creating any maps
+// along the way that don't exist.
+func nestedMap(m map[string]interface{}, path ...string) map[string]interface{} {
+	for _, field := range path {
+		next, ok := m[field].(map[string]interface{})
+		if !ok {
+			next = map[string]interface{}{}
+			m[field] = next
+		}
+		m = next
+	}
+	return m
+}
+
+// existingMap returns the map at the path given, or nil if there
+// isn't one.
+func existingMap(m map[string]interface{}, path ...string) map[string]interface{} {
+	for _, field := range path {
+		next, ok := m[field].(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		m = next
+	}
+	return m
+}
+
+// podTemplate returns the pod template of a workload, or the pod
+// itself, if the resource is a pod.
+func podTemplate(doc map[string]interface{}) map[string]interface{} {
+	switch docKind(doc) {
+	case "Pod":
+		return doc
+	case "Deployment", "ReplicaSet", "StatefulSet", "DaemonSet", "Job", "ReplicationController":
+		return existingMap(doc, "spec", "template")
+	case "CronJob":
+		return existingMap(doc, "spec", "jobTemplate", "spec", "template")
+	}
+	return nil
+}
+
+func podTemplateMeta(doc map[string]interface{}) map[string]interface{} {
+	if docKind(doc) == "Pod" {
+		return nil // already covered by the resource's own metadata
+	}
+	if template := podTemplate(doc); template != nil {
+		return nestedMap(template, "metadata")
+	}
+	return nil
+}
+
+func podSpec(doc map[string]interface{}) map[string]interface{} {
+	if template := podTemplate(doc); template != nil {
+		return existingMap(template, "spec")
+	}
+	return nil
+}
+
+func podContainers(spec map[string]interface{}) []map[string]interface{} {
+	var containers []map[string]interface{}
+	for _, field := range []string{"initContainers", "containers"} {
+		list, _ := spec[field].([]interface{})
+		for _, c := range list {
+			if container, ok := c.(map[string]interface{}); ok {
+				containers = append(containers, container)
+			}
+		}
+	}
+	return containers
+}
+
+// splitImage splits an image as given in a container spec into the
+// name, tag and digest, any of the latter two of which may be empty.
+func splitImage(img string) (name, tag, digest string) {
+	name = img
+	if i := strings.Index(name, "@"); i >= 0 {
+		name, digest = name[:i], name[i+1:]
+	}
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name, tag = name[:i], name[i+1:]
+	}
+	return name, tag, digest
+}
+
+// replaceImage applies an `images` entry to an image, if the entry
+// is for that image.
+func replaceImage(current string, img KustomizeImage) string {
+	name, tag, digest := splitImage(current)
+	if name != img.Name {
+		return current
+	}
+	if img.NewName != "" {
+		name = img.NewName
+	}
+	switch {
+	case img.Digest != "":
+		return name + "@" + img.Digest
+	case img.NewTag != "":
+		return name + ":" + img.NewTag
+	case digest != "":
+		return name + "@" + digest
+	case tag != "":
+		return name + ":" + tag
+	}
+	return name
+}
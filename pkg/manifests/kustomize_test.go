@@ -0,0 +1,279 @@
+package manifests
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/fluxcd/flux/pkg/cluster/kubernetes"
+	"github.com/fluxcd/flux/pkg/image"
+	"github.com/fluxcd/flux/pkg/policy"
+	"github.com/fluxcd/flux/pkg/resource"
+)
+
+var kustomizeFiles = map[string]string{
+	ConfigFilename: `version: 1
+kustomize: {}
+`,
+	"base/kustomization.yaml": `resources:
+- deployment.yaml
+- configmap.yaml
+`,
+	"base/deployment.yaml": `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+spec:
+  replicas: 1
+  selector:
+    matchLabels:
+      name: app
+  template:
+    metadata:
+      labels:
+        name: app
+    spec:
+      containers:
+      - name: app
+        image: quay.io/org/app:1.0
+        envFrom:
+        - configMapRef:
+            name: config
+`,
+	"base/configmap.yaml": `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+data:
+  key: value
+`,
+	"prod/kustomization.yaml": `apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+namespace: prod
+namePrefix: prod-
+commonLabels:
+  env: prod
+bases:
+- ../base
+patchesStrategicMerge:
+- replicas.yaml
+images:
+- name: quay.io/org/app
+  newTag: "1.1"
+`,
+	"prod/replicas.yaml": `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+spec:
+  replicas: 3
+`,
+}
+
+func setupKustomize(t *testing.T, paths ...string) (*configAware, string, func()) {
+	baseDir, err := ioutil.TempDir("", "flux-kustomize")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range kustomizeFiles {
+		path := filepath.Join(baseDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var searchPaths []string
+	for _, p := range paths {
+		searchPaths = append(searchPaths, filepath.Join(baseDir, p))
+	}
+	manifests := kubernetes.NewManifests(kubernetes.ConstNamespacer("default"), log.NewLogfmtLogger(os.Stdout))
+	frs, err := NewConfigAware(baseDir, searchPaths, manifests, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return frs, baseDir, func() { os.RemoveAll(baseDir) }
+}
+
+func containerImage(t *testing.T, res resource.Resource, container string) string {
+	workload, ok := res.(resource.Workload)
+	if !ok {
+		t.Fatalf("resource %s is not a workload", res.ResourceID())
+	}
+	for _, c := range workload.Containers() {
+		if c.Name == container {
+			return c.Image.String()
+		}
+	}
+	t.Fatalf("container %s not found in %s", container, res.ResourceID())
+	return ""
+}
+
+func TestKustomizeBuild(t *testing.T) {
+	frs, _, cleanup := setupKustomize(t, "prod")
+	defer cleanup()
+
+	resources, err := frs.GetAllResourcesByID(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, resources, 2)
+	assert.Contains(t, resources, "prod:configmap/prod-config")
+	dep, ok := resources["prod:deployment/prod-app"]
+	if !ok {
+		t.Fatalf("expected prod:deployment/prod-app in %v", resources)
+	}
+	assert.Equal(t, "prod/kustomization.yaml", dep.Source())
+	assert.Equal(t, "quay.io/org/app:1.1", containerImage(t, dep, "app"))
+
+	docs, err := parseDocs(dep.Bytes())
+	assert.NoError(t, err)
+	assert.Len(t, docs, 1)
+	assert.Equal(t, float64(3), existingMap(docs[0], "spec")["replicas"])
+	assert.Equal(t, "prod", existingMap(docs[0], "metadata", "labels")["env"])
+	assert.Equal(t, "prod", existingMap(docs[0], "spec", "selector", "matchLabels")["env"])
+	assert.Equal(t, "prod", existingMap(docs[0], "spec", "template", "metadata", "labels")["env"])
+	container := podContainers(podSpec(docs[0]))[0]
+	ref := container["envFrom"].([]interface{})[0].(map[string]interface{})["configMapRef"].(map[string]interface{})
+	assert.Equal(t, "prod-config", ref["name"])
+}
+
+func TestKustomizeNeedsConfigFile(t *testing.T) {
+	_, baseDir, cleanup := setupKustomize(t, "prod")
+	defer cleanup()
+
+	// Without a .flux.yaml, a kustomization is just more YAML files
+	assert.NoError(t, os.Remove(filepath.Join(baseDir, ConfigFilename)))
+	configFiles, kustomizations, rawManifestPaths, err := splitConfigFilesAndRawManifestPaths(baseDir, []string{filepath.Join(baseDir, "prod")}, false)
+	assert.NoError(t, err)
+	assert.Empty(t, configFiles)
+	assert.Empty(t, kustomizations)
+	assert.Equal(t, []string{filepath.Join(baseDir, "prod")}, rawManifestPaths)
+
+	// With the kustomize directive, there has to be a kustomization
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(baseDir, ConfigFilename), []byte(kustomizeFiles[ConfigFilename]), 0600))
+	assert.NoError(t, os.Remove(filepath.Join(baseDir, "prod", "kustomization.yaml")))
+	_, _, _, err = splitConfigFilesAndRawManifestPaths(baseDir, []string{filepath.Join(baseDir, "prod")}, false)
+	assert.Error(t, err)
+}
+
+func TestKustomizeWithoutConfigFile(t *testing.T) {
+	_, baseDir, cleanup := setupKustomize(t, "prod")
+	defer cleanup()
+	assert.NoError(t, os.Remove(filepath.Join(baseDir, ConfigFilename)))
+
+	// A kustomization is built without a .flux.yaml when asked for ...
+	configFiles, kustomizations, rawManifestPaths, err := splitConfigFilesAndRawManifestPaths(baseDir, []string{filepath.Join(baseDir, "prod")}, true)
+	assert.NoError(t, err)
+	assert.Empty(t, configFiles)
+	assert.Len(t, kustomizations, 1)
+	assert.Empty(t, rawManifestPaths)
+
+	// ... and a directory without a kustomization is still scanned
+	// for YAML files
+	assert.NoError(t, os.Remove(filepath.Join(baseDir, "prod", "kustomization.yaml")))
+	configFiles, kustomizations, rawManifestPaths, err = splitConfigFilesAndRawManifestPaths(baseDir, []string{filepath.Join(baseDir, "prod")}, true)
+	assert.NoError(t, err)
+	assert.Empty(t, configFiles)
+	assert.Empty(t, kustomizations)
+	assert.Equal(t, []string{filepath.Join(baseDir, "prod")}, rawManifestPaths)
+}
+
+func TestKustomizeBuildWithoutConfigFile(t *testing.T) {
+	_, baseDir, cleanup := setupKustomize(t, "prod")
+	defer cleanup()
+	assert.NoError(t, os.Remove(filepath.Join(baseDir, ConfigFilename)))
+
+	manifests := kubernetes.NewManifests(kubernetes.ConstNamespacer("default"), log.NewLogfmtLogger(os.Stdout))
+	frs, err := NewConfigAware(baseDir, []string{filepath.Join(baseDir, "prod")}, manifests, true)
+	assert.NoError(t, err)
+	resources, err := frs.GetAllResourcesByID(context.Background())
+	assert.NoError(t, err)
+	res, ok := resources["prod:deployment/prod-app"]
+	assert.True(t, ok, "expected the kustomization to be built")
+	assert.Equal(t, "quay.io/org/app:1.1", containerImage(t, res, "app"))
+}
+
+func TestKustomizeUnsupportedField(t *testing.T) {
+	var k Kustomization
+	err := ParseKustomization([]byte("resources: [a.yaml]\nconfigMapGenerator: []\n"), &k)
+	assert.Error(t, err)
+}
+
+func TestKustomizeSetWorkloadContainerImage(t *testing.T) {
+	frs, baseDir, cleanup := setupKustomize(t, "prod")
+	defer cleanup()
+	ctx := context.Background()
+
+	id := resource.MustParseID("prod:deployment/prod-app")
+	ref, err := image.ParseRef("quay.io/org/app:1.2")
+	assert.NoError(t, err)
+	assert.NoError(t, frs.SetWorkloadContainerImage(ctx, id, "app", ref))
+
+	resources, err := frs.GetAllResourcesByID(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "quay.io/org/app:1.2", containerImage(t, resources[id.String()], "app"))
+
+	// The update is recorded in the overlay, and the base is untouched
+	def, err := ioutil.ReadFile(filepath.Join(baseDir, "prod/kustomization.yaml"))
+	assert.NoError(t, err)
+	var k Kustomization
+	assert.NoError(t, ParseKustomization(def, &k))
+	assert.Equal(t, []KustomizeImage{{Name: "quay.io/org/app", NewTag: "1.2"}}, k.Images)
+	base, err := ioutil.ReadFile(filepath.Join(baseDir, "base/deployment.yaml"))
+	assert.NoError(t, err)
+	assert.Equal(t, kustomizeFiles["base/deployment.yaml"], string(base))
+}
+
+func TestKustomizeUpdateWorkloadPolicies(t *testing.T) {
+	frs, baseDir, cleanup := setupKustomize(t, "prod")
+	defer cleanup()
+	ctx := context.Background()
+
+	id := resource.MustParseID("prod:deployment/prod-app")
+	changed, err := frs.UpdateWorkloadPolicies(ctx, id, resource.PolicyUpdate{
+		Add: policy.Set{policy.Automated: "true"},
+	})
+	assert.NoError(t, err)
+	assert.True(t, changed)
+
+	resources, err := frs.GetAllResourcesByID(ctx)
+	assert.NoError(t, err)
+	assert.True(t, resources[id.String()].Policies().Has(policy.Automated))
+
+	def, err := ioutil.ReadFile(filepath.Join(baseDir, "prod/kustomization.yaml"))
+	assert.NoError(t, err)
+	var k Kustomization
+	assert.NoError(t, ParseKustomization(def, &k))
+	assert.Equal(t, []string{"replicas.yaml", KustomizePolicyPatchFilename}, k.PatchesStrategicMerge)
+
+	// Removing the policy again leaves the patch with nothing in it
+	changed, err = frs.UpdateWorkloadPolicies(ctx, id, resource.PolicyUpdate{
+		Remove: policy.Set{policy.Automated: "true"},
+	})
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	resources, err = frs.GetAllResourcesByID(ctx)
+	assert.NoError(t, err)
+	assert.False(t, resources[id.String()].Policies().Has(policy.Automated))
+}
+
+func TestReplaceImage(t *testing.T) {
+	for _, c := range []struct {
+		current  string
+		image    KustomizeImage
+		expected string
+	}{
+		{"app:1.0", KustomizeImage{Name: "app", NewTag: "2.0"}, "app:2.0"},
+		{"app:1.0", KustomizeImage{Name: "other", NewTag: "2.0"}, "app:1.0"},
+		{"app:1.0", KustomizeImage{Name: "app", NewName: "registry:5000/app"}, "registry:5000/app:1.0"},
+		{"registry:5000/app", KustomizeImage{Name: "registry:5000/app", NewTag: "2.0"}, "registry:5000/app:2.0"},
+		{"app@sha256:abc", KustomizeImage{Name: "app", Digest: "sha256:def"}, "app@sha256:def"},
+	} {
+		assert.Equal(t, c.expected, replaceImage(c.current, c.image))
+	}
+}
@@ -0,0 +1,117 @@
+package notify
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+
+	"github.com/fluxcd/flux/pkg/event"
+)
+
+// The kinds of sink that can be configured.
+const (
+	SinkWebhook = "webhook"
+	SinkSlack   = "slack"
+	SinkMSTeams = "msteams"
+)
+
+// Config is the notification configuration, usually loaded from a
+// file given to fluxd.
+type Config struct {
+	Sinks []SinkConfig `json:"sinks"`
+}
+
+// SinkConfig describes a single sink to which events are sent.
+type SinkConfig struct {
+	// Name identifies the sink in logs and metrics.
+	Name string `json:"name"`
+	// Type is one of `webhook`, `slack` or `msteams`.
+	Type string `json:"type"`
+	// URL is the address events are posted to.
+	URL string `json:"url"`
+	// Username and Channel override those configured for a Slack
+	// incoming webhook; they are ignored for other types.
+	Username string `json:"username,omitempty"`
+	Channel  string `json:"channel,omitempty"`
+	// EventTypes restricts the sink to these event types, e.g.,
+	// `sync` or `autorelease`; if empty, all types are sent.
+	EventTypes []string `json:"eventTypes,omitempty"`
+	// Namespaces restricts the sink to events which concern
+	// workloads in these namespaces; if empty, events are sent
+	// regardless of namespace.
+	Namespaces []string `json:"namespaces,omitempty"`
+}
+
+// LoadConfig reads a notification configuration from the file at
+// the path given.
+func LoadConfig(path string) (Config, error) {
+	var config Config
+	bytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return config, err
+	}
+	return ParseConfig(bytes)
+}
+
+// ParseConfig parses and validates a notification configuration
+// given as YAML (or JSON).
+func ParseConfig(bytes []byte) (Config, error) {
+	var config Config
+	if err := yaml.Unmarshal(bytes, &config); err != nil {
+		return config, errors.Wrap(err, "parsing notification config")
+	}
+	names := map[string]struct{}{}
+	for i, sink := range config.Sinks {
+		if sink.Name == "" {
+			return config, fmt.Errorf("notification sink %d: name is required", i)
+		}
+		if _, ok := names[sink.Name]; ok {
+			return config, fmt.Errorf("notification sink %q: name given more than once", sink.Name)
+		}
+		names[sink.Name] = struct{}{}
+		switch sink.Type {
+		case SinkWebhook, SinkSlack, SinkMSTeams:
+		default:
+			return config, fmt.Errorf("notification sink %q: unknown type %q", sink.Name, sink.Type)
+		}
+		if u, err := url.Parse(sink.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return config, fmt.Errorf("notification sink %q: url must be an http or https URL", sink.Name)
+		}
+	}
+	return config, nil
+}
+
+// Filter decides which events are sent to a sink.
+type Filter struct {
+	EventTypes []string
+	Namespaces []string
+}
+
+// Match reports whether the event passes the filter.
+func (f Filter) Match(e event.Event) bool {
+	if len(f.EventTypes) > 0 && !contains(f.EventTypes, e.Type) {
+		return false
+	}
+	if len(f.Namespaces) == 0 {
+		return true
+	}
+	for _, id := range e.ServiceIDs {
+		ns, _, _ := id.Components()
+		if contains(f.Namespaces, ns) {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(ss []string, s string) bool {
+	for _, x := range ss {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,33 @@
+package notify
+
+import (
+	"github.com/go-kit/kit/metrics/prometheus"
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
+
+	fluxmetrics "github.com/fluxcd/flux/pkg/metrics"
+)
+
+const LabelSink = "sink"
+
+var (
+	deliveries = prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+		Namespace: "flux",
+		Subsystem: "notify",
+		Name:      "deliveries_total",
+		Help:      "Count of notifications delivered (or given up on) per sink.",
+	}, []string{LabelSink, fluxmetrics.LabelSuccess})
+
+	deliveryFailures = prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+		Namespace: "flux",
+		Subsystem: "notify",
+		Name:      "delivery_failures_total",
+		Help:      "Count of failed attempts to deliver a notification, including those that were retried.",
+	}, []string{LabelSink})
+
+	dropped = prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+		Namespace: "flux",
+		Subsystem: "notify",
+		Name:      "dropped_total",
+		Help:      "Count of notifications dropped because the sink's queue was full.",
+	}, []string{LabelSink})
+)
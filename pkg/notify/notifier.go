@@ -0,0 +1,172 @@
+/*
+Package notify sends daemon events to outbound notification sinks:
+generic JSON webhooks, Slack-compatible incoming webhooks, and
+Microsoft Teams cards.
+*/
+package notify
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+
+	"github.com/fluxcd/flux/pkg/event"
+	fluxmetrics "github.com/fluxcd/flux/pkg/metrics"
+)
+
+const (
+	defaultQueueSize   = 100
+	defaultRetries     = 5
+	defaultBackoff     = time.Second
+	defaultMaxBackoff  = time.Minute
+	defaultSendTimeout = 10 * time.Second
+)
+
+// Notifier is an event.EventWriter that fans events out to the sinks
+// it's configured with. Each sink has its own queue, and is delivered
+// to independently, so that a slow or unavailable sink doesn't hold
+// up the others (or the daemon). Deliveries that fail for a reason
+// that may go away are retried, with backoff.
+type Notifier struct {
+	// Retries is the number of times delivery is retried after the
+	// first attempt fails.
+	Retries int
+	// Backoff is the time waited before the first retry; it doubles
+	// for each subsequent retry, up to MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+
+	sinks  []*sinkQueue
+	logger log.Logger
+}
+
+type sinkQueue struct {
+	name   string
+	filter Filter
+	sink   Sink
+	queue  chan event.Event
+}
+
+// NewNotifier constructs a Notifier with a sink for each entry in the
+// config given, using the HTTP client given to post to them.
+func NewNotifier(config Config, client *http.Client, logger log.Logger) (*Notifier, error) {
+	n := &Notifier{
+		Retries:    defaultRetries,
+		Backoff:    defaultBackoff,
+		MaxBackoff: defaultMaxBackoff,
+		logger:     logger,
+	}
+	for _, sc := range config.Sinks {
+		sink, err := NewSink(client, sc)
+		if err != nil {
+			return nil, err
+		}
+		n.AddSink(sc.Name, Filter{EventTypes: sc.EventTypes, Namespaces: sc.Namespaces}, sink)
+	}
+	return n, nil
+}
+
+// AddSink adds a sink, which will be sent the events that pass the
+// filter. Sinks must be added before the Notifier's Loop is started.
+func (n *Notifier) AddSink(name string, filter Filter, sink Sink) {
+	n.sinks = append(n.sinks, &sinkQueue{
+		name:   name,
+		filter: filter,
+		sink:   sink,
+		queue:  make(chan event.Event, defaultQueueSize),
+	})
+}
+
+// LogEvent queues the event for each sink that accepts it. It never
+// blocks; if a sink's queue is full, the event is dropped for that
+// sink. It always returns nil, since whether the event is delivered
+// isn't known until later.
+func (n *Notifier) LogEvent(e event.Event) error {
+	for _, s := range n.sinks {
+		if !s.filter.Match(e) {
+			continue
+		}
+		select {
+		case s.queue <- e:
+		default:
+			dropped.With(LabelSink, s.name).Add(1)
+			n.logger.Log("sink", s.name, "err", "notification queue full; dropping event", "event", e.Type)
+		}
+	}
+	return nil
+}
+
+// Loop delivers queued events to each sink, until told to stop.
+func (n *Notifier) Loop(stop <-chan struct{}, wg *sync.WaitGroup) {
+	defer wg.Done()
+	sinkWg := &sync.WaitGroup{}
+	for _, s := range n.sinks {
+		sinkWg.Add(1)
+		go func(s *sinkQueue) {
+			defer sinkWg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				case e := <-s.queue:
+					n.deliver(s, e, stop)
+				}
+			}
+		}(s)
+	}
+	sinkWg.Wait()
+}
+
+// deliver sends an event to a sink, retrying if that fails in a way
+// that may be temporary.
+func (n *Notifier) deliver(s *sinkQueue, e event.Event, stop <-chan struct{}) {
+	logger := log.With(n.logger, "sink", s.name, "event", e.Type)
+	backoff := n.Backoff
+	for attempt := 0; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), defaultSendTimeout)
+		err := s.sink.Send(ctx, e)
+		cancel()
+		if err == nil {
+			deliveries.With(LabelSink, s.name, fluxmetrics.LabelSuccess, "true").Add(1)
+			return
+		}
+		deliveryFailures.With(LabelSink, s.name).Add(1)
+		if attempt >= n.Retries || !isRetryable(err) {
+			deliveries.With(LabelSink, s.name, fluxmetrics.LabelSuccess, "false").Add(1)
+			logger.Log("err", err, "attempts", attempt+1, "giving-up", true)
+			return
+		}
+		logger.Log("err", err, "attempts", attempt+1, "retry-in", backoff)
+		select {
+		case <-stop:
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > n.MaxBackoff {
+			backoff = n.MaxBackoff
+		}
+	}
+}
+
+// MultiEventWriter returns an EventWriter that writes events to each
+// of the writers given, in order. All writers are given the event
+// even if one fails; the first error encountered is returned.
+func MultiEventWriter(writers ...event.EventWriter) event.EventWriter {
+	return multiEventWriter(writers)
+}
+
+type multiEventWriter []event.EventWriter
+
+func (ws multiEventWriter) LogEvent(e event.Event) error {
+	var firstErr error
+	for _, w := range ws {
+		if err := w.LogEvent(e); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
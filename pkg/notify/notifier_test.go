@@ -0,0 +1,227 @@
+package notify
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+
+	"github.com/fluxcd/flux/pkg/event"
+	"github.com/fluxcd/flux/pkg/resource"
+)
+
+// standIn is a local HTTP server standing in for a notification
+// endpoint; it records the bodies it's sent, and responds with the
+// status codes it's given, in order (and 200 thereafter).
+type standIn struct {
+	*httptest.Server
+	mu       sync.Mutex
+	statuses []int
+	bodies   [][]byte
+	received chan struct{}
+}
+
+func newStandIn(statuses ...int) *standIn {
+	s := &standIn{statuses: statuses, received: make(chan struct{}, 10)}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		s.mu.Lock()
+		s.bodies = append(s.bodies, body)
+		status := http.StatusOK
+		if len(s.statuses) > 0 {
+			status, s.statuses = s.statuses[0], s.statuses[1:]
+		}
+		s.mu.Unlock()
+		w.WriteHeader(status)
+		s.received <- struct{}{}
+	}))
+	return s
+}
+
+func (s *standIn) waitFor(t *testing.T, n int) [][]byte {
+	for i := 0; i < n; i++ {
+		select {
+		case <-s.received:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for request %d", i+1)
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.bodies
+}
+
+func syncEvent(ns string) event.Event {
+	return event.Event{
+		ServiceIDs: []resource.ID{resource.MustParseID(ns + ":deployment/helloworld")},
+		Type:       event.EventSync,
+		LogLevel:   event.LogLevelInfo,
+		Metadata: &event.SyncEventMetadata{
+			Commits: []event.Commit{{Revision: "0123456789abcdef", Message: "Bump helloworld\n\nMore detail"}},
+		},
+	}
+}
+
+func startNotifier(t *testing.T, config Config) (*Notifier, func()) {
+	n, err := NewNotifier(config, http.DefaultClient, log.NewLogfmtLogger(os.Stderr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.Backoff = time.Millisecond
+	n.MaxBackoff = time.Millisecond
+	stop := make(chan struct{})
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	go n.Loop(stop, wg)
+	return n, func() {
+		close(stop)
+		wg.Wait()
+	}
+}
+
+func TestParseConfig(t *testing.T) {
+	config, err := ParseConfig([]byte(`
+sinks:
+- name: ops
+  type: slack
+  url: https://hooks.slack.com/services/T/B/X
+  channel: "#deploys"
+  eventTypes: [sync, autorelease]
+  namespaces: [prod]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(config.Sinks) != 1 || config.Sinks[0].Channel != "#deploys" || len(config.Sinks[0].EventTypes) != 2 {
+		t.Errorf("unexpected config: %#v", config)
+	}
+
+	for _, bad := range []string{
+		"sinks: [{type: slack, url: 'https://example.com'}]",
+		"sinks: [{name: a, type: carrier-pigeon, url: 'https://example.com'}]",
+		"sinks: [{name: a, type: webhook, url: 'ftp://example.com'}]",
+		"sinks: [{name: a, type: webhook, url: 'https://example.com'}, {name: a, type: slack, url: 'https://example.com'}]",
+	} {
+		if _, err := ParseConfig([]byte(bad)); err == nil {
+			t.Errorf("expected error parsing %q", bad)
+		}
+	}
+}
+
+func TestFilter(t *testing.T) {
+	f := Filter{EventTypes: []string{event.EventSync}, Namespaces: []string{"prod"}}
+	if !f.Match(syncEvent("prod")) {
+		t.Error("expected sync event in prod to match")
+	}
+	if f.Match(syncEvent("staging")) {
+		t.Error("expected sync event in staging not to match")
+	}
+	release := syncEvent("prod")
+	release.Type = event.EventRelease
+	if f.Match(release) {
+		t.Error("expected release event not to match")
+	}
+	if !(Filter{}).Match(release) {
+		t.Error("expected empty filter to match everything")
+	}
+}
+
+func TestNotifier_Slack(t *testing.T) {
+	server := newStandIn()
+	defer server.Close()
+
+	n, stop := startNotifier(t, Config{Sinks: []SinkConfig{{
+		Name:     "slack",
+		Type:     SinkSlack,
+		URL:      server.URL,
+		Channel:  "#deploys",
+		Username: "flux",
+	}}})
+	defer stop()
+
+	n.LogEvent(syncEvent("prod"))
+	bodies := server.waitFor(t, 1)
+
+	var payload slackPayload
+	if err := json.Unmarshal(bodies[0], &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.Channel != "#deploys" || payload.Username != "flux" {
+		t.Errorf("channel and username not passed on: %#v", payload)
+	}
+	if payload.Text != "Sync: 0123456, prod:deployment/helloworld" {
+		t.Errorf("unexpected text %q", payload.Text)
+	}
+	if len(payload.Attachments) != 1 || payload.Attachments[0].Text != "0123456 Bump helloworld" {
+		t.Errorf("unexpected attachments: %#v", payload.Attachments)
+	}
+}
+
+func TestNotifier_WebhookAndTeams(t *testing.T) {
+	webhook := newStandIn()
+	defer webhook.Close()
+	teams := newStandIn()
+	defer teams.Close()
+
+	n, stop := startNotifier(t, Config{Sinks: []SinkConfig{
+		{Name: "webhook", Type: SinkWebhook, URL: webhook.URL},
+		{Name: "teams", Type: SinkMSTeams, URL: teams.URL},
+	}})
+	defer stop()
+
+	n.LogEvent(syncEvent("prod"))
+
+	var payload struct {
+		Summary string
+		Event   event.Event
+	}
+	if err := json.Unmarshal(webhook.waitFor(t, 1)[0], &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.Event.Type != event.EventSync || len(payload.Event.ServiceIDs) != 1 {
+		t.Errorf("unexpected event in webhook payload: %#v", payload.Event)
+	}
+
+	var card msteamsCard
+	if err := json.Unmarshal(teams.waitFor(t, 1)[0], &card); err != nil {
+		t.Fatal(err)
+	}
+	if card.Type != "MessageCard" || card.Title != "Sync: 0123456, prod:deployment/helloworld" {
+		t.Errorf("unexpected card: %#v", card)
+	}
+}
+
+func TestNotifier_Retries(t *testing.T) {
+	server := newStandIn(http.StatusServiceUnavailable, http.StatusTooManyRequests)
+	defer server.Close()
+
+	n, stop := startNotifier(t, Config{Sinks: []SinkConfig{{Name: "retry", Type: SinkWebhook, URL: server.URL}}})
+	defer stop()
+
+	n.LogEvent(syncEvent("prod"))
+	if bodies := server.waitFor(t, 3); len(bodies) != 3 {
+		t.Errorf("expected three attempts, got %d", len(bodies))
+	}
+}
+
+func TestNotifier_NoRetryOnClientError(t *testing.T) {
+	server := newStandIn(http.StatusBadRequest)
+	defer server.Close()
+
+	n, stop := startNotifier(t, Config{Sinks: []SinkConfig{{Name: "noretry", Type: SinkWebhook, URL: server.URL}}})
+	defer stop()
+
+	n.LogEvent(syncEvent("prod"))
+	server.waitFor(t, 1)
+	select {
+	case <-server.received:
+		t.Error("expected a client error not to be retried")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,273 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/fluxcd/flux/pkg/event"
+)
+
+// Sink is something events can be sent to.
+type Sink interface {
+	Send(ctx context.Context, e event.Event) error
+}
+
+// NewSink constructs the sink described by the config given.
+func NewSink(client *http.Client, config SinkConfig) (Sink, error) {
+	switch config.Type {
+	case SinkWebhook:
+		return &WebhookSink{client: client, url: config.URL}, nil
+	case SinkSlack:
+		return &SlackSink{client: client, url: config.URL, username: config.Username, channel: config.Channel}, nil
+	case SinkMSTeams:
+		return &MSTeamsSink{client: client, url: config.URL}, nil
+	default:
+		return nil, fmt.Errorf("unknown notification sink type %q", config.Type)
+	}
+}
+
+// deliveryError is returned when a sink responds with an error
+// status. Server errors and rate limiting are worth retrying; other
+// client errors are not, since sending the same thing again won't
+// make a difference.
+type deliveryError struct {
+	statusCode int
+	body       string
+}
+
+func (err *deliveryError) Error() string {
+	if err.body == "" {
+		return fmt.Sprintf("notification rejected with status %d", err.statusCode)
+	}
+	return fmt.Sprintf("notification rejected with status %d: %s", err.statusCode, err.body)
+}
+
+func (err *deliveryError) temporary() bool {
+	return err.statusCode >= 500 || err.statusCode == http.StatusTooManyRequests
+}
+
+// isRetryable reports whether a delivery that failed with the error
+// given could succeed if tried again.
+func isRetryable(err error) bool {
+	if derr, ok := err.(*deliveryError); ok {
+		return derr.temporary()
+	}
+	// Anything else is a transport error, e.g., a refused
+	// connection or a timeout.
+	return true
+}
+
+func postJSON(ctx context.Context, client *http.Client, url string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return &deliveryError{statusCode: resp.StatusCode, body: strings.TrimSpace(string(respBody))}
+	}
+	return nil
+}
+
+// WebhookSink posts events as JSON, with a summary for consumers that
+// don't want to interpret the event themselves.
+type WebhookSink struct {
+	client *http.Client
+	url    string
+}
+
+type webhookPayload struct {
+	Summary string      `json:"summary"`
+	Event   event.Event `json:"event"`
+}
+
+func (s *WebhookSink) Send(ctx context.Context, e event.Event) error {
+	return postJSON(ctx, s.client, s.url, webhookPayload{
+		Summary: e.String(),
+		Event:   e,
+	})
+}
+
+// SlackSink posts events to a Slack-compatible incoming webhook.
+type SlackSink struct {
+	client   *http.Client
+	url      string
+	username string
+	channel  string
+}
+
+type slackPayload struct {
+	Text        string            `json:"text"`
+	Username    string            `json:"username,omitempty"`
+	Channel     string            `json:"channel,omitempty"`
+	Attachments []slackAttachment `json:"attachments,omitempty"`
+}
+
+type slackAttachment struct {
+	Color  string `json:"color,omitempty"`
+	Title  string `json:"title,omitempty"`
+	Text   string `json:"text"`
+	Footer string `json:"footer,omitempty"`
+}
+
+func (s *SlackSink) Send(ctx context.Context, e event.Event) error {
+	payload := slackPayload{
+		Text:     e.String(),
+		Username: s.username,
+		Channel:  s.channel,
+	}
+	if details := eventDetails(e); len(details) > 0 {
+		payload.Attachments = []slackAttachment{{
+			Color: eventColour(e),
+			Text:  strings.Join(details, "\n"),
+		}}
+	}
+	return postJSON(ctx, s.client, s.url, payload)
+}
+
+// MSTeamsSink posts events to a Microsoft Teams incoming webhook, as
+// message cards.
+type MSTeamsSink struct {
+	client *http.Client
+	url    string
+}
+
+type msteamsCard struct {
+	Type       string           `json:"@type"`
+	Context    string           `json:"@context"`
+	Summary    string           `json:"summary"`
+	ThemeColor string           `json:"themeColor,omitempty"`
+	Title      string           `json:"title"`
+	Sections   []msteamsSection `json:"sections,omitempty"`
+}
+
+type msteamsSection struct {
+	Facts []msteamsFact `json:"facts,omitempty"`
+	Text  string        `json:"text,omitempty"`
+}
+
+type msteamsFact struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+func (s *MSTeamsSink) Send(ctx context.Context, e event.Event) error {
+	summary := e.String()
+	card := msteamsCard{
+		Type:       "MessageCard",
+		Context:    "https://schema.org/extensions",
+		Summary:    summary,
+		ThemeColor: strings.TrimPrefix(eventColour(e), "#"),
+		Title:      summary,
+	}
+	facts := []msteamsFact{{Name: "Event", Value: e.Type}}
+	if ids := e.WorkloadIDStrings(); len(ids) > 0 {
+		facts = append(facts, msteamsFact{Name: "Workloads", Value: strings.Join(ids, ", ")})
+	}
+	section := msteamsSection{Facts: facts}
+	if details := eventDetails(e); len(details) > 0 {
+		section.Text = strings.Join(details, "\n\n")
+	}
+	card.Sections = []msteamsSection{section}
+	return postJSON(ctx, s.client, s.url, card)
+}
+
+// eventDetails gives lines of detail, beyond the summary, that are
+// worth including in a notification; e.g., the commits in a sync, and
+// any errors.
+func eventDetails(e event.Event) []string {
+	var lines []string
+	switch metadata := e.Metadata.(type) {
+	case *event.SyncEventMetadata:
+		for _, c := range metadata.Commits {
+			lines = append(lines, fmt.Sprintf("%.7s %s", c.Revision, firstLine(c.Message)))
+		}
+		for _, err := range metadata.Errors {
+			lines = append(lines, fmt.Sprintf("%s (%s): %s", err.ID, err.Path, err.Error))
+		}
+	case *event.ReleaseEventMetadata:
+		if metadata.Error != "" {
+			lines = append(lines, metadata.Error)
+		}
+	case *event.AutoReleaseEventMetadata:
+		if metadata.Error != "" {
+			lines = append(lines, metadata.Error)
+		}
+	case *event.RollbackEventMetadata:
+		for _, image := range metadata.Result.ChangedImages() {
+			lines = append(lines, "restored "+image)
+		}
+	case *event.PruneAbortedEventMetadata:
+		for _, id := range metadata.Resources {
+			lines = append(lines, fmt.Sprintf("would delete %s", id))
+		}
+	case *event.DriftEventMetadata:
+		for _, r := range metadata.Resources {
+			for _, c := range r.Changes {
+				lines = append(lines, fmt.Sprintf("%s %s: %s", r.ID, c.Path, fieldChangeString(c)))
+			}
+		}
+	}
+	return lines
+}
+
+func eventColour(e event.Event) string {
+	switch {
+	case e.LogLevel == event.LogLevelError || hasErrors(e):
+		return "#d00000"
+	case e.LogLevel == event.LogLevelWarn:
+		return "#ffa500"
+	default:
+		return "#2eb886"
+	}
+}
+
+func hasErrors(e event.Event) bool {
+	switch metadata := e.Metadata.(type) {
+	case *event.SyncEventMetadata:
+		return len(metadata.Errors) > 0
+	case *event.ReleaseEventMetadata:
+		return metadata.Error != ""
+	case *event.AutoReleaseEventMetadata:
+		return metadata.Error != ""
+	}
+	return false
+}
+
+// fieldChangeString describes a drifted field, as its value in the
+// cluster and its value in git.
+func fieldChangeString(c event.FieldChange) string {
+	switch {
+	case c.Redacted:
+		return "changed"
+	case c.Old == nil:
+		return fmt.Sprintf("missing in cluster (%v in git)", c.New)
+	case c.New == nil:
+		return fmt.Sprintf("%v in cluster (not in git)", c.Old)
+	}
+	return fmt.Sprintf("%v in cluster, %v in git", c.Old, c.New)
+}
+
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
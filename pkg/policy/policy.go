@@ -6,21 +6,33 @@ import (
 )
 
 const (
-	Ignore     = Policy("ignore")
-	Locked     = Policy("locked")
-	LockedUser = Policy("locked_user")
-	LockedMsg  = Policy("locked_msg")
-	Automated  = Policy("automated")
-	TagAll     = Policy("tag_all")
+	Ignore            = Policy("ignore")
+	Locked            = Policy("locked")
+	LockedUser        = Policy("locked_user")
+	LockedMsg         = Policy("locked_msg")
+	Automated         = Policy("automated")
+	TagAll            = Policy("tag_all")
+	RollbackOnFailure = Policy("rollback-on-failure")
+	PromoteFrom       = Policy("promote-from")
+	PromoteSoak       = Policy("promote-soak")
+	SyncWave          = Policy("sync-wave")
+	Schedule          = Policy("schedule")
+	DriftReportOnly   = Policy("drift-report-only")
+	Prune             = Policy("prune")
+	PinDigest         = Policy("pin-digest")
 )
 
+// The value of the Prune policy which stops a resource from being
+// garbage collected.
+const PruneDisabled = "disabled"
+
 // Policy is an string, denoting the current deployment policy of a service,
 // e.g. automated, or locked.
 type Policy string
 
 func Boolean(policy Policy) bool {
 	switch policy {
-	case Locked, Automated, Ignore:
+	case Locked, Automated, Ignore, RollbackOnFailure, DriftReportOnly, PinDigest:
 		return true
 	}
 	return false
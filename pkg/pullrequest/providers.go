@@ -0,0 +1,263 @@
+package pullrequest
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/fluxcd/flux/pkg/githost"
+)
+
+// NewProvider constructs a provider that opens pull requests at the
+// git host given.
+func NewProvider(host *githost.Client) Provider {
+	switch host.Kind {
+	case githost.GitLab:
+		return &GitLab{host}
+	case githost.Bitbucket:
+		return &Bitbucket{host}
+	case githost.Gitea:
+		return &Gitea{GitHub{host}}
+	default:
+		return &GitHub{host}
+	}
+}
+
+// GitHub opens pull requests with GitHub's (or GitHub Enterprise's)
+// API, using a personal access token.
+type GitHub struct {
+	host *githost.Client
+}
+
+type githubPullRequest struct {
+	Number  int    `json:"number,omitempty"`
+	HTMLURL string `json:"html_url,omitempty"`
+	Title   string `json:"title"`
+	Body    string `json:"body"`
+	Head    struct {
+		Ref string `json:"ref"`
+	} `json:"head"`
+	Base struct {
+		Ref string `json:"ref"`
+	} `json:"base"`
+}
+
+type githubNewPullRequest struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+	Head  string `json:"head,omitempty"`
+	Base  string `json:"base,omitempty"`
+}
+
+func (g *GitHub) Find(ctx context.Context, repo, head, base string) (*PullRequest, error) {
+	owner := strings.SplitN(repo, "/", 2)[0]
+	query := url.Values{
+		"state": {"open"},
+		"head":  {owner + ":" + head},
+		"base":  {base},
+	}
+	var prs []githubPullRequest
+	if err := g.host.Do(ctx, "GET", "/repos/"+repo+"/pulls?"+query.Encode(), nil, &prs); err != nil {
+		return nil, err
+	}
+	// Not every API that looks like GitHub's will filter the pull
+	// requests as asked, so make sure.
+	for _, pr := range prs {
+		if pr.Head.Ref == head && pr.Base.Ref == base {
+			return &PullRequest{
+				Head:   head,
+				Base:   base,
+				Title:  pr.Title,
+				Body:   pr.Body,
+				Number: pr.Number,
+				URL:    pr.HTMLURL,
+			}, nil
+		}
+	}
+	return nil, nil
+}
+
+func (g *GitHub) Open(ctx context.Context, repo string, pr PullRequest) (*PullRequest, error) {
+	var opened githubPullRequest
+	if err := g.host.Do(ctx, "POST", "/repos/"+repo+"/pulls", githubNewPullRequest{
+		Title: pr.Title,
+		Body:  pr.Body,
+		Head:  pr.Head,
+		Base:  pr.Base,
+	}, &opened); err != nil {
+		return nil, err
+	}
+	pr.Number, pr.URL = opened.Number, opened.HTMLURL
+	return &pr, nil
+}
+
+func (g *GitHub) Update(ctx context.Context, repo string, pr PullRequest) error {
+	return g.host.Do(ctx, "PATCH", "/repos/"+repo+"/pulls/"+strconv.Itoa(pr.Number), githubNewPullRequest{
+		Title: pr.Title,
+		Body:  pr.Body,
+	}, nil)
+}
+
+// Gitea opens pull requests with Gitea's API, using an access token.
+// Gitea's API for pull requests is, near enough, the same as GitHub's.
+type Gitea struct {
+	GitHub
+}
+
+// GitLab opens merge requests with GitLab's API, using a personal or
+// project access token.
+type GitLab struct {
+	host *githost.Client
+}
+
+type gitlabMergeRequest struct {
+	IID         int    `json:"iid,omitempty"`
+	WebURL      string `json:"web_url,omitempty"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	// Only used when opening a merge request
+	SourceBranch       string `json:"source_branch,omitempty"`
+	TargetBranch       string `json:"target_branch,omitempty"`
+	RemoveSourceBranch bool   `json:"remove_source_branch,omitempty"`
+}
+
+func (g *GitLab) path(repo string) string {
+	return "/projects/" + url.PathEscape(repo) + "/merge_requests"
+}
+
+func (g *GitLab) Find(ctx context.Context, repo, head, base string) (*PullRequest, error) {
+	query := url.Values{
+		"state":         {"opened"},
+		"source_branch": {head},
+		"target_branch": {base},
+	}
+	var mrs []gitlabMergeRequest
+	if err := g.host.Do(ctx, "GET", g.path(repo)+"?"+query.Encode(), nil, &mrs); err != nil {
+		return nil, err
+	}
+	if len(mrs) == 0 {
+		return nil, nil
+	}
+	return &PullRequest{
+		Head:   head,
+		Base:   base,
+		Title:  mrs[0].Title,
+		Body:   mrs[0].Description,
+		Number: mrs[0].IID,
+		URL:    mrs[0].WebURL,
+	}, nil
+}
+
+func (g *GitLab) Open(ctx context.Context, repo string, pr PullRequest) (*PullRequest, error) {
+	var opened gitlabMergeRequest
+	if err := g.host.Do(ctx, "POST", g.path(repo), gitlabMergeRequest{
+		Title:        pr.Title,
+		Description:  pr.Body,
+		SourceBranch: pr.Head,
+		TargetBranch: pr.Base,
+		// The branch is only for the merge request
+		RemoveSourceBranch: true,
+	}, &opened); err != nil {
+		return nil, err
+	}
+	pr.Number, pr.URL = opened.IID, opened.WebURL
+	return &pr, nil
+}
+
+func (g *GitLab) Update(ctx context.Context, repo string, pr PullRequest) error {
+	return g.host.Do(ctx, "PUT", g.path(repo)+"/"+strconv.Itoa(pr.Number), gitlabMergeRequest{
+		Title:       pr.Title,
+		Description: pr.Body,
+	}, nil)
+}
+
+// Bitbucket opens pull requests with Bitbucket Cloud's API, using an
+// access token, or a username and app password.
+type Bitbucket struct {
+	host *githost.Client
+}
+
+type bitbucketBranch struct {
+	Branch struct {
+		Name string `json:"name"`
+	} `json:"branch"`
+}
+
+type bitbucketPullRequest struct {
+	ID          int    `json:"id,omitempty"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Links       *struct {
+		HTML struct {
+			Href string `json:"href"`
+		} `json:"html"`
+	} `json:"links,omitempty"`
+	Source            *bitbucketBranch `json:"source,omitempty"`
+	Destination       *bitbucketBranch `json:"destination,omitempty"`
+	CloseSourceBranch bool             `json:"close_source_branch,omitempty"`
+}
+
+func (p bitbucketPullRequest) url() string {
+	if p.Links == nil {
+		return ""
+	}
+	return p.Links.HTML.Href
+}
+
+func branch(name string) *bitbucketBranch {
+	b := &bitbucketBranch{}
+	b.Branch.Name = name
+	return b
+}
+
+func (b *Bitbucket) Find(ctx context.Context, repo, head, base string) (*PullRequest, error) {
+	query := url.Values{
+		"state": {"OPEN"},
+		"q":     {fmt.Sprintf(`source.branch.name = %q AND destination.branch.name = %q`, head, base)},
+	}
+	var page struct {
+		Values []bitbucketPullRequest `json:"values"`
+	}
+	if err := b.host.Do(ctx, "GET", "/repositories/"+repo+"/pullrequests?"+query.Encode(), nil, &page); err != nil {
+		return nil, err
+	}
+	if len(page.Values) == 0 {
+		return nil, nil
+	}
+	pr := page.Values[0]
+	return &PullRequest{
+		Head:   head,
+		Base:   base,
+		Title:  pr.Title,
+		Body:   pr.Description,
+		Number: pr.ID,
+		URL:    pr.url(),
+	}, nil
+}
+
+func (b *Bitbucket) Open(ctx context.Context, repo string, pr PullRequest) (*PullRequest, error) {
+	var opened bitbucketPullRequest
+	if err := b.host.Do(ctx, "POST", "/repositories/"+repo+"/pullrequests", bitbucketPullRequest{
+		Title:       pr.Title,
+		Description: pr.Body,
+		Source:      branch(pr.Head),
+		Destination: branch(pr.Base),
+		// The branch is only for the pull request
+		CloseSourceBranch: true,
+	}, &opened); err != nil {
+		return nil, err
+	}
+	pr.Number, pr.URL = opened.ID, opened.url()
+	return &pr, nil
+}
+
+func (b *Bitbucket) Update(ctx context.Context, repo string, pr PullRequest) error {
+	return b.host.Do(ctx, "PUT", "/repositories/"+repo+"/pullrequests/"+strconv.Itoa(pr.Number), bitbucketPullRequest{
+		Title:       pr.Title,
+		Description: pr.Body,
+		// Bitbucket insists on being told the destination again
+		Destination: branch(pr.Base),
+	}, nil)
+}
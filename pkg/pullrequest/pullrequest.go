@@ -0,0 +1,61 @@
+// Package pullrequest proposes changes as pull requests (or merge
+// requests, as GitLab calls them), for when the branch synced is
+// protected, or changes are to be reviewed before they're synced.
+//
+// The changes are pushed to a branch of their own, then a pull request
+// is opened from that branch to the branch synced; or, if there's
+// already a pull request open from that branch, it's updated.
+package pullrequest
+
+import (
+	"context"
+)
+
+// PullRequest is a request to merge one branch into another.
+type PullRequest struct {
+	// Head is the branch with the changes, and Base the branch into
+	// which they are to be merged.
+	Head, Base string
+	Title      string
+	Body       string
+
+	// Once it's open, the pull request's number, and where to see it
+	Number int
+	URL    string
+}
+
+// Provider opens and updates pull requests, at a particular git host.
+// The repo is given by its path at the host (e.g., "org/repo").
+type Provider interface {
+	// Find returns the pull request open from the head branch to the
+	// base branch given, or nil if there isn't one.
+	Find(ctx context.Context, repo, head, base string) (*PullRequest, error)
+	// Open opens a pull request, returning it with its number and URL.
+	Open(ctx context.Context, repo string, pr PullRequest) (*PullRequest, error)
+	// Update sets the title and body of the open pull request given.
+	Update(ctx context.Context, repo string, pr PullRequest) error
+}
+
+// Propose opens the pull request given, or, if there's one open
+// already from and to the same branches, updates that. It returns
+// the URL of the pull request.
+func Propose(ctx context.Context, p Provider, repo string, pr PullRequest) (string, error) {
+	existing, err := p.Find(ctx, repo, pr.Head, pr.Base)
+	if err != nil {
+		return "", err
+	}
+	if existing == nil {
+		opened, err := p.Open(ctx, repo, pr)
+		if err != nil {
+			return "", err
+		}
+		return opened.URL, nil
+	}
+	if existing.Title != pr.Title || existing.Body != pr.Body {
+		pr.Number = existing.Number
+		if err := p.Update(ctx, repo, pr); err != nil {
+			return "", err
+		}
+	}
+	return existing.URL, nil
+}
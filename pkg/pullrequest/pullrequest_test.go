@@ -0,0 +1,202 @@
+package pullrequest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/fluxcd/flux/pkg/githost"
+	"github.com/fluxcd/flux/pkg/githost/githosttest"
+)
+
+// fakeProvider keeps pull requests in memory, and counts what's done
+// with them.
+type fakeProvider struct {
+	prs             []PullRequest
+	opened, updated int
+}
+
+func (p *fakeProvider) Find(ctx context.Context, repo, head, base string) (*PullRequest, error) {
+	for _, pr := range p.prs {
+		if pr.Head == head && pr.Base == base {
+			return &pr, nil
+		}
+	}
+	return nil, nil
+}
+
+func (p *fakeProvider) Open(ctx context.Context, repo string, pr PullRequest) (*PullRequest, error) {
+	p.opened++
+	pr.Number = len(p.prs) + 1
+	pr.URL = "https://example.com/pulls/" + pr.Head
+	p.prs = append(p.prs, pr)
+	return &pr, nil
+}
+
+func (p *fakeProvider) Update(ctx context.Context, repo string, pr PullRequest) error {
+	p.updated++
+	p.prs[pr.Number-1] = pr
+	return nil
+}
+
+func TestPropose(t *testing.T) {
+	p := &fakeProvider{}
+	ctx := context.Background()
+	pr := PullRequest{Head: "flux/automated", Base: "master", Title: "Auto-release", Body: "first"}
+
+	url, err := Propose(ctx, p, "org/repo", pr)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.com/pulls/flux/automated", url)
+	assert.Equal(t, 1, p.opened)
+
+	// The same again: nothing to do
+	url, err = Propose(ctx, p, "org/repo", pr)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.com/pulls/flux/automated", url)
+	assert.Equal(t, 1, p.opened)
+	assert.Equal(t, 0, p.updated)
+
+	// Changed: the open pull request is updated, not duplicated
+	pr.Body = "second"
+	url, err = Propose(ctx, p, "org/repo", pr)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.com/pulls/flux/automated", url)
+	assert.Equal(t, 1, p.opened)
+	assert.Equal(t, 1, p.updated)
+	assert.Equal(t, "second", p.prs[0].Body)
+
+	// From another branch: another pull request
+	pr.Head = "flux/release-0123abcd"
+	_, err = Propose(ctx, p, "org/repo", pr)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, p.opened)
+}
+
+// respondByMethod responds to each method with the response given.
+func respondByMethod(responses map[string]string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(responses[r.Method]))
+	}
+}
+
+func TestProviders(t *testing.T) {
+	pr := PullRequest{Head: "flux/automated", Base: "master", Title: "Auto-release", Body: "Updates"}
+
+	for _, c := range []struct {
+		kind, apiPath, token string
+		responses            map[string]string
+		found                *PullRequest
+		opened               *PullRequest
+		expected             []githosttest.Request
+	}{
+		{
+			kind:  githost.GitHub,
+			token: "ghtoken",
+			responses: map[string]string{
+				"GET":  `[{"number":1,"html_url":"https://github.com/org/repo/pull/1","title":"Old","body":"Old","head":{"ref":"flux/automated"},"base":{"ref":"master"}}]`,
+				"POST": `{"number":2,"html_url":"https://github.com/org/repo/pull/2"}`,
+			},
+			found:  &PullRequest{Head: "flux/automated", Base: "master", Title: "Old", Body: "Old", Number: 1, URL: "https://github.com/org/repo/pull/1"},
+			opened: &PullRequest{Head: "flux/automated", Base: "master", Title: "Auto-release", Body: "Updates", Number: 2, URL: "https://github.com/org/repo/pull/2"},
+			expected: []githosttest.Request{
+				{Method: "GET", Path: "/repos/org/repo/pulls?base=master&head=org%3Aflux%2Fautomated&state=open", Auth: "token ghtoken", Body: nil},
+				{Method: "POST", Path: "/repos/org/repo/pulls", Auth: "token ghtoken", Body: map[string]interface{}{"title": "Auto-release", "body": "Updates", "head": "flux/automated", "base": "master"}},
+				{Method: "PATCH", Path: "/repos/org/repo/pulls/2", Auth: "token ghtoken", Body: map[string]interface{}{"title": "Auto-release", "body": "Updates"}},
+			},
+		},
+		{
+			kind:    githost.Gitea,
+			apiPath: "/api/v1",
+			token:   "gteatoken",
+			responses: map[string]string{
+				// Gitea doesn't filter by branch
+				"GET":  `[{"number":3,"html_url":"https://gitea.example.com/org/repo/pulls/3","title":"Other","head":{"ref":"feature"},"base":{"ref":"master"}}]`,
+				"POST": `{"number":4,"html_url":"https://gitea.example.com/org/repo/pulls/4"}`,
+			},
+			opened: &PullRequest{Head: "flux/automated", Base: "master", Title: "Auto-release", Body: "Updates", Number: 4, URL: "https://gitea.example.com/org/repo/pulls/4"},
+			expected: []githosttest.Request{
+				{Method: "GET", Path: "/api/v1/repos/org/repo/pulls?base=master&head=org%3Aflux%2Fautomated&state=open", Auth: "token gteatoken", Body: nil},
+				{Method: "POST", Path: "/api/v1/repos/org/repo/pulls", Auth: "token gteatoken", Body: map[string]interface{}{"title": "Auto-release", "body": "Updates", "head": "flux/automated", "base": "master"}},
+				{Method: "PATCH", Path: "/api/v1/repos/org/repo/pulls/4", Auth: "token gteatoken", Body: map[string]interface{}{"title": "Auto-release", "body": "Updates"}},
+			},
+		},
+		{
+			kind:    githost.GitLab,
+			apiPath: "/api/v4",
+			token:   "gltoken",
+			responses: map[string]string{
+				"GET":  `[]`,
+				"POST": `{"iid":5,"web_url":"https://gitlab.example.com/org/repo/-/merge_requests/5"}`,
+			},
+			opened: &PullRequest{Head: "flux/automated", Base: "master", Title: "Auto-release", Body: "Updates", Number: 5, URL: "https://gitlab.example.com/org/repo/-/merge_requests/5"},
+			expected: []githosttest.Request{
+				{Method: "GET", Path: "/api/v4/projects/org%2Frepo/merge_requests?source_branch=flux%2Fautomated&state=opened&target_branch=master", Auth: "Private-Token gltoken", Body: nil},
+				{Method: "POST", Path: "/api/v4/projects/org%2Frepo/merge_requests", Auth: "Private-Token gltoken", Body: map[string]interface{}{
+					"title": "Auto-release", "description": "Updates", "source_branch": "flux/automated", "target_branch": "master", "remove_source_branch": true,
+				}},
+				{Method: "PUT", Path: "/api/v4/projects/org%2Frepo/merge_requests/5", Auth: "Private-Token gltoken", Body: map[string]interface{}{"title": "Auto-release", "description": "Updates"}},
+			},
+		},
+		{
+			kind:  githost.Bitbucket,
+			token: "user:apppassword",
+			responses: map[string]string{
+				"GET":  `{"values":[{"id":6,"title":"Old","description":"Old","links":{"html":{"href":"https://bitbucket.org/org/repo/pull-requests/6"}}}]}`,
+				"POST": `{"id":7,"links":{"html":{"href":"https://bitbucket.org/org/repo/pull-requests/7"}}}`,
+			},
+			found:  &PullRequest{Head: "flux/automated", Base: "master", Title: "Old", Body: "Old", Number: 6, URL: "https://bitbucket.org/org/repo/pull-requests/6"},
+			opened: &PullRequest{Head: "flux/automated", Base: "master", Title: "Auto-release", Body: "Updates", Number: 7, URL: "https://bitbucket.org/org/repo/pull-requests/7"},
+			expected: []githosttest.Request{
+				{Method: "GET", Path: "/repositories/org/repo/pullrequests?q=source.branch.name+%3D+%22flux%2Fautomated%22+AND+destination.branch.name+%3D+%22master%22&state=OPEN", Auth: "Basic dXNlcjphcHBwYXNzd29yZA==", Body: nil},
+				{Method: "POST", Path: "/repositories/org/repo/pullrequests", Auth: "Basic dXNlcjphcHBwYXNzd29yZA==", Body: map[string]interface{}{
+					"title": "Auto-release", "description": "Updates",
+					"source":              map[string]interface{}{"branch": map[string]interface{}{"name": "flux/automated"}},
+					"destination":         map[string]interface{}{"branch": map[string]interface{}{"name": "master"}},
+					"close_source_branch": true,
+				}},
+				{Method: "PUT", Path: "/repositories/org/repo/pullrequests/7", Auth: "Basic dXNlcjphcHBwYXNzd29yZA==", Body: map[string]interface{}{
+					"title": "Auto-release", "description": "Updates",
+					"destination": map[string]interface{}{"branch": map[string]interface{}{"name": "master"}},
+				}},
+			},
+		},
+	} {
+		t.Run(c.kind, func(t *testing.T) {
+			server, requests := githosttest.FakeHost(t, respondByMethod(c.responses))
+			defer server.Close()
+			host, err := githost.NewClient(c.kind, server.URL+c.apiPath+"/", c.token, server.Client())
+			if err != nil {
+				t.Fatal(err)
+			}
+			provider := NewProvider(host)
+			ctx := context.Background()
+
+			found, err := provider.Find(ctx, "org/repo", pr.Head, pr.Base)
+			assert.NoError(t, err)
+			assert.Equal(t, c.found, found)
+			opened, err := provider.Open(ctx, "org/repo", pr)
+			assert.NoError(t, err)
+			assert.Equal(t, c.opened, opened)
+			assert.NoError(t, provider.Update(ctx, "org/repo", *opened))
+			assert.Equal(t, c.expected, *requests)
+		})
+	}
+}
+
+func TestProviderError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Validation Failed", http.StatusUnprocessableEntity)
+	}))
+	defer server.Close()
+	host, err := githost.NewClient(githost.GitHub, server.URL, "token", server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = NewProvider(host).Open(context.Background(), "org/repo", PullRequest{Head: "flux/automated", Base: "master"})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "422")
+	}
+}
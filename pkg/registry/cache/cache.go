@@ -39,7 +39,7 @@ func NewManifestKey(image image.CanonicalRef) Keyer {
 
 func (k *manifestKey) Key() string {
 	return strings.Join([]string{
-		"registryhistoryv3", // Bump the version number if the cache format changes
+		"registryhistoryv5", // Bump the version number if the cache format changes
 		k.fullRepositoryPath,
 		k.reference,
 	}, "|")
@@ -0,0 +1,275 @@
+// This package implements an image DB cache in memory, so that fluxd
+// can run without memcached.
+//
+// Items are given an expiry based on their refresh deadline, in the same
+// way as with memcached: entries are kept for a while after they would
+// have been refreshed, and dropped only if they truly need garbage
+// collection.
+//
+// The cache has a bound on the size of the values it holds. When it's
+// full, entries that have expired are evicted first, then those least
+// recently used. Optionally, the cache can be saved to a file (e.g., on
+// a persistent volume) periodically and when stopped, and loaded again
+// when started, so that a restart doesn't mean a cold cache.
+package memory
+
+import (
+	"container/list"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/pkg/errors"
+
+	"github.com/fluxcd/flux/pkg/registry/cache"
+)
+
+const (
+	// The minimum expiry given to an entry.
+	MinExpiry = time.Hour
+	// The default interval between snapshots, if snapshotting is
+	// enabled.
+	DefaultSnapshotInterval = 5 * time.Minute
+)
+
+// Config defines how a Cache should be constructed.
+type Config struct {
+	// MaxSize is the most bytes of values to hold; zero means no
+	// limit.
+	MaxSize int
+	// SnapshotPath is a file to save the cache to, and load it
+	// from; if empty, the cache is not saved.
+	SnapshotPath     string
+	SnapshotInterval time.Duration
+	Logger           log.Logger
+}
+
+// Cache is an in-memory cache client, bounded in size.
+type Cache struct {
+	maxSize          int
+	snapshotPath     string
+	snapshotInterval time.Duration
+	logger           log.Logger
+
+	mu      sync.Mutex
+	size    int
+	entries map[string]*list.Element
+	// most recently used at the front
+	lru *list.List
+
+	now func() time.Time
+}
+
+type entry struct {
+	Key      string    `json:"key"`
+	Value    []byte    `json:"value"`
+	Deadline time.Time `json:"deadline"`
+	Expiry   time.Time `json:"expiry"`
+}
+
+// snapshot is the form in which the cache is saved to a file.
+type snapshot struct {
+	Version int `json:"version"`
+	// most recently used first
+	Entries []entry `json:"entries"`
+}
+
+// Bump this if the snapshot format changes.
+const snapshotVersion = 1
+
+// New constructs a Cache from the config given. If there's a
+// snapshot, it's loaded; a snapshot that can't be read is logged
+// and otherwise ignored, since the cache can be filled again.
+func New(config Config) *Cache {
+	c := &Cache{
+		maxSize:          config.MaxSize,
+		snapshotPath:     config.SnapshotPath,
+		snapshotInterval: config.SnapshotInterval,
+		logger:           config.Logger,
+		entries:          map[string]*list.Element{},
+		lru:              list.New(),
+		now:              time.Now,
+	}
+	if c.snapshotInterval <= 0 {
+		c.snapshotInterval = DefaultSnapshotInterval
+	}
+	if c.snapshotPath != "" {
+		if err := c.load(); err != nil && !os.IsNotExist(err) {
+			c.logger.Log("err", errors.Wrapf(err, "loading cache snapshot from %s", c.snapshotPath))
+		}
+	}
+	return c
+}
+
+// GetKey gets the value and its refresh deadline from the cache.
+func (c *Cache) GetKey(k cache.Keyer) ([]byte, time.Time, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[k.Key()]
+	if !ok {
+		return []byte{}, time.Time{}, cache.ErrNotCached
+	}
+	e := elem.Value.(*entry)
+	if !c.now().Before(e.Expiry) {
+		c.remove(elem)
+		return []byte{}, time.Time{}, cache.ErrNotCached
+	}
+	c.lru.MoveToFront(elem)
+	return e.Value, e.Deadline, nil
+}
+
+// SetKey sets the value and its refresh deadline at a key. NB the key
+// expiry is set _longer_ than the deadline, to give us a grace period
+// in which to refresh the value.
+func (c *Cache) SetKey(k cache.Keyer, refreshDeadline time.Time, v []byte) error {
+	now := c.now()
+	expiry := refreshDeadline.Sub(now) * 2
+	if expiry < MinExpiry {
+		expiry = MinExpiry
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := k.Key()
+	if elem, ok := c.entries[key]; ok {
+		c.remove(elem)
+	}
+	if c.maxSize > 0 && len(v) > c.maxSize {
+		return errors.Errorf("value of %d bytes is larger than the cache", len(v))
+	}
+	c.add(&entry{
+		Key:      key,
+		Value:    v,
+		Deadline: refreshDeadline,
+		Expiry:   now.Add(expiry),
+	})
+	c.evict(now)
+	return nil
+}
+
+// Loop saves the cache to the snapshot file periodically, and once
+// more when told to stop. If there's no snapshot file, it just waits
+// to be told to stop.
+func (c *Cache) Loop(stop <-chan struct{}, wg *sync.WaitGroup) {
+	defer wg.Done()
+	if c.snapshotPath == "" {
+		<-stop
+		return
+	}
+	ticker := time.NewTicker(c.snapshotInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := c.Snapshot(); err != nil {
+				c.logger.Log("err", err)
+			}
+		case <-stop:
+			if err := c.Snapshot(); err != nil {
+				c.logger.Log("err", err)
+			}
+			return
+		}
+	}
+}
+
+// Snapshot saves the cache to the snapshot file. The file is
+// replaced all at once, so that a crash while saving doesn't leave
+// a partial snapshot.
+func (c *Cache) Snapshot() error {
+	if c.snapshotPath == "" {
+		return nil
+	}
+	c.mu.Lock()
+	snap := snapshot{Version: snapshotVersion}
+	for elem := c.lru.Front(); elem != nil; elem = elem.Next() {
+		snap.Entries = append(snap.Entries, *elem.Value.(*entry))
+	}
+	c.mu.Unlock()
+
+	bytes, err := json.Marshal(snap)
+	if err != nil {
+		return errors.Wrap(err, "serialising cache snapshot")
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(c.snapshotPath), filepath.Base(c.snapshotPath)+".tmp")
+	if err != nil {
+		return errors.Wrap(err, "creating cache snapshot file")
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(bytes); err != nil {
+		tmp.Close()
+		return errors.Wrap(err, "writing cache snapshot")
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.Wrap(err, "writing cache snapshot")
+	}
+	return errors.Wrap(os.Rename(tmp.Name(), c.snapshotPath), "replacing cache snapshot")
+}
+
+// load fills the cache from the snapshot file, leaving out entries
+// which have expired since it was saved.
+func (c *Cache) load() error {
+	bytes, err := ioutil.ReadFile(c.snapshotPath)
+	if err != nil {
+		return err
+	}
+	var snap snapshot
+	if err := json.Unmarshal(bytes, &snap); err != nil {
+		return err
+	}
+	if snap.Version != snapshotVersion {
+		return errors.Errorf("snapshot version %d is not supported", snap.Version)
+	}
+
+	now := c.now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// Entries are most recently used first; add them in reverse, so
+	// they end up in the same order.
+	for i := len(snap.Entries) - 1; i >= 0; i-- {
+		e := snap.Entries[i]
+		if !now.Before(e.Expiry) {
+			continue
+		}
+		if elem, ok := c.entries[e.Key]; ok {
+			c.remove(elem)
+		}
+		c.add(&e)
+	}
+	c.evict(now)
+	return nil
+}
+
+func (c *Cache) add(e *entry) {
+	c.entries[e.Key] = c.lru.PushFront(e)
+	c.size += len(e.Value)
+}
+
+func (c *Cache) remove(elem *list.Element) {
+	e := c.lru.Remove(elem).(*entry)
+	delete(c.entries, e.Key)
+	c.size -= len(e.Value)
+}
+
+// evict removes entries until the cache is within its size
+// bound. Expired entries go first, since they would miss anyway; then
+// those least recently used.
+func (c *Cache) evict(now time.Time) {
+	if c.maxSize <= 0 || c.size <= c.maxSize {
+		return
+	}
+	for elem := c.lru.Back(); elem != nil; {
+		prev := elem.Prev()
+		if !now.Before(elem.Value.(*entry).Expiry) {
+			c.remove(elem)
+		}
+		elem = prev
+	}
+	for c.size > c.maxSize {
+		c.remove(c.lru.Back())
+	}
+}
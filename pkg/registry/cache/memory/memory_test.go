@@ -0,0 +1,161 @@
+package memory
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+
+	"github.com/fluxcd/flux/pkg/registry/cache"
+)
+
+type testKey string
+
+func (t testKey) Key() string {
+	return string(t)
+}
+
+func newTestCache(config Config, now *time.Time) *Cache {
+	config.Logger = log.NewLogfmtLogger(os.Stderr)
+	c := New(config)
+	c.now = func() time.Time { return *now }
+	return c
+}
+
+func TestCache_ReadWrite(t *testing.T) {
+	now := time.Now().Round(time.Second)
+	c := newTestCache(Config{}, &now)
+
+	if _, _, err := c.GetKey(testKey("a")); err != cache.ErrNotCached {
+		t.Fatalf("expected ErrNotCached, got %v", err)
+	}
+
+	deadline := now.Add(time.Minute)
+	if err := c.SetKey(testKey("a"), deadline, []byte("value a")); err != nil {
+		t.Fatal(err)
+	}
+	val, gotDeadline, err := c.GetKey(testKey("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !gotDeadline.Equal(deadline) {
+		t.Errorf("deadline should be %s, but is %s", deadline, gotDeadline)
+	}
+	if string(val) != "value a" {
+		t.Errorf("should have returned %q, but got %q", "value a", string(val))
+	}
+}
+
+func TestCache_Expiry(t *testing.T) {
+	now := time.Now().Round(time.Second)
+	c := newTestCache(Config{}, &now)
+
+	// Past the deadline, the entry is still returned, so it can be
+	// refreshed ...
+	if err := c.SetKey(testKey("a"), now.Add(time.Minute), []byte("a")); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(2 * time.Minute)
+	if _, _, err := c.GetKey(testKey("a")); err != nil {
+		t.Fatal(err)
+	}
+	// ... until it has expired.
+	now = now.Add(MinExpiry)
+	if _, _, err := c.GetKey(testKey("a")); err != cache.ErrNotCached {
+		t.Fatalf("expected ErrNotCached, got %v", err)
+	}
+	if c.size != 0 || c.lru.Len() != 0 {
+		t.Errorf("expected expired entry to be removed; size %d, entries %d", c.size, c.lru.Len())
+	}
+}
+
+func TestCache_Eviction(t *testing.T) {
+	now := time.Now().Round(time.Second)
+	c := newTestCache(Config{MaxSize: 3}, &now)
+
+	set := func(key string, deadline time.Time) {
+		if err := c.SetKey(testKey(key), deadline, []byte(key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cached := func(key string) bool {
+		_, _, err := c.GetKey(testKey(key))
+		return err == nil
+	}
+
+	set("a", now.Add(time.Minute))
+	set("b", now.Add(MinExpiry))
+	set("c", now.Add(MinExpiry))
+	// use "a", so it's the most recently used
+	if !cached("a") {
+		t.Fatal("expected a to be cached")
+	}
+	set("d", now.Add(MinExpiry))
+	if cached("b") {
+		t.Error("expected b, as least recently used, to be evicted")
+	}
+
+	// "a" expires before the others; it's evicted first, though it
+	// was used more recently.
+	now = now.Add(MinExpiry + time.Minute)
+	if !cached("c") || !cached("d") {
+		t.Fatal("expected c and d to be cached")
+	}
+	set("e", now.Add(MinExpiry))
+	if c.lru.Len() != 3 {
+		t.Errorf("expected 3 entries, got %d", c.lru.Len())
+	}
+	for _, key := range []string{"c", "d", "e"} {
+		if !cached(key) {
+			t.Errorf("expected %s to be cached", key)
+		}
+	}
+
+	if err := c.SetKey(testKey("big"), now, []byte("too big")); err == nil {
+		t.Error("expected error storing value larger than the cache")
+	}
+}
+
+func TestCache_Snapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "flux-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "cache.json")
+
+	now := time.Now().Round(time.Second)
+	c := newTestCache(Config{SnapshotPath: path}, &now)
+	if err := c.SetKey(testKey("a"), now.Add(time.Minute), []byte("a")); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetKey(testKey("b"), now.Add(3*MinExpiry), []byte("b")); err != nil {
+		t.Fatal(err)
+	}
+
+	stop := make(chan struct{})
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	go c.Loop(stop, wg)
+	close(stop)
+	wg.Wait()
+
+	// By the time it's loaded, "a" has expired.
+	now = now.Add(MinExpiry)
+	loaded := New(Config{SnapshotPath: path, Logger: log.NewNopLogger()})
+	loaded.now = c.now
+	if _, _, err := loaded.GetKey(testKey("a")); err != cache.ErrNotCached {
+		t.Errorf("expected ErrNotCached for expired entry, got %v", err)
+	}
+	val, deadline, err := loaded.GetKey(testKey("b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(val) != "b" || !deadline.Equal(now.Add(-MinExpiry).Add(3*MinExpiry)) {
+		t.Errorf("unexpected value %q with deadline %s", string(val), deadline)
+	}
+}
@@ -93,7 +93,20 @@ func (w *Warmer) Loop(logger log.Logger, stop <-chan struct{}, wg *sync.WaitGrou
 	// requested the credentials.
 	priorityWarm := func(name image.Name) {
 		logger.Log("priority", name.String())
-		if creds, ok := imageCreds[name]; ok {
+		creds, ok := imageCreds[name]
+		if !ok {
+			// The name may be given differently to how it's written
+			// in the workloads (e.g., by a registry webhook), so
+			// look for it by its canonical name too.
+			canonical := name.CanonicalName()
+			for n, c := range imageCreds {
+				if n.CanonicalName() == canonical {
+					name, creds, ok = n, c, true
+					break
+				}
+			}
+		}
+		if ok {
 			w.warm(ctx, time.Now(), logger, name, creds)
 		} else {
 			logger.Log("priority", name.String(), "err", "no creds available")
@@ -62,6 +62,7 @@ func (entry *ImageEntry) UnmarshalJSON(bytes []byte) error {
 type Client interface {
 	Tags(context.Context) ([]string, error)
 	Manifest(ctx context.Context, ref string) (ImageEntry, error)
+	Signatures(ctx context.Context, tag string) (string, []Signature, error)
 }
 
 // ClientFactory supplies Client implementations for a given repo,
@@ -76,6 +77,10 @@ type Remote struct {
 	transport http.RoundTripper
 	repo      image.CanonicalName
 	base      string
+	// prefix is put before the repository path in API requests,
+	// for mirrors which keep images from other registries under a
+	// path (e.g., mirror.example.com/docker.io/fluxcd/flux).
+	prefix string
 }
 
 // Adapt to docker distribution `reference.Named`.
@@ -91,9 +96,22 @@ func (n named) Name() string {
 	return n.Image
 }
 
+// apiName gives the repository to use in API requests. This is the
+// canonical name, unless the repository is at a prefix (e.g., in a
+// mirror). Either way, the image IDs reported are in the canonical
+// name.
+func (a *Remote) apiName() named {
+	if a.prefix == "" {
+		return named{a.repo}
+	}
+	repo := a.repo
+	repo.Image = a.prefix + "/" + repo.Image
+	return named{repo}
+}
+
 // Return the tags for this repository.
 func (a *Remote) Tags(ctx context.Context) ([]string, error) {
-	repository, err := client.NewRepository(named{a.repo}, a.base, a.transport)
+	repository, err := client.NewRepository(a.apiName(), a.base, a.transport)
 	if err != nil {
 		return nil, err
 	}
@@ -103,7 +121,7 @@ func (a *Remote) Tags(ctx context.Context) ([]string, error) {
 // Manifest fetches the metadata for an image reference; currently
 // assumed to be in the same repo as that provided to `NewRemote(...)`
 func (a *Remote) Manifest(ctx context.Context, ref string) (ImageEntry, error) {
-	repository, err := client.NewRepository(named{a.repo}, a.base, a.transport)
+	repository, err := client.NewRepository(a.apiName(), a.base, a.transport)
 	if err != nil {
 		return ImageEntry{}, err
 	}
@@ -114,6 +132,10 @@ func (a *Remote) Manifest(ctx context.Context, ref string) (ImageEntry, error) {
 	var manifestDigest digest.Digest
 	digestOpt := client.ReturnContentDigest(&manifestDigest)
 	manifest, fetchErr := manifests.Get(ctx, digest.Digest(ref), digestOpt, distribution.WithTagOption{ref})
+	// the platforms in a manifest list, if that's what the ref is for,
+	// and the digest of the list itself
+	var platforms []image.Platform
+	var listDigest string
 
 interpret:
 	if fetchErr != nil {
@@ -161,6 +183,7 @@ interpret:
 		info.ImageID = v1.ID
 		info.CreatedAt = v1.Created
 		info.Labels = config.Config.Labels
+		info.Platforms = []image.Platform{{OS: v1.OS, Arch: v1.Arch}}
 	case *schema2.DeserializedManifest:
 		var man schema2.Manifest = deserialised.Manifest
 		configBytes, err := repository.Blobs(ctx).Get(ctx, man.Config.Digest)
@@ -173,6 +196,7 @@ interpret:
 			Arch            string    `json:"architecture"`
 			Created         time.Time `json:"created"`
 			OS              string    `json:"os"`
+			Variant         string    `json:"variant"`
 		}
 		if err = json.Unmarshal(configBytes, &config); err != nil {
 			return ImageEntry{}, nil
@@ -198,21 +222,57 @@ interpret:
 		info.ImageID = man.Config.Digest.String()
 		info.CreatedAt = config.Created
 		info.Labels = container.ContainerConfig.Labels
+		info.Platforms = []image.Platform{{OS: config.OS, Arch: config.Arch, Variant: config.Variant}}
 	case *manifestlist.DeserializedManifestList:
 		var list manifestlist.ManifestList = deserialised.ManifestList
-		// TODO(michael): is it valid to just pick the first one that matches?
+		if len(list.Manifests) == 0 {
+			entry := ImageEntry{}
+			entry.ExcludedReason = "no manifests in manifestlist"
+			return entry, nil
+		}
+		// Record all the platforms, so that images can be chosen
+		// according to where they will run. The rest of the
+		// metadata is taken from one of the images, preferably
+		// linux/amd64, or failing that another Linux image; they
+		// are usually built together.
+		chosen, preference := list.Manifests[0], 0
 		for _, m := range list.Manifests {
-			if m.Platform.OS == "linux" && m.Platform.Architecture == "amd64" {
-				manifest, fetchErr = manifests.Get(ctx, m.Digest, digestOpt)
-				goto interpret
+			platforms = append(platforms, image.Platform{
+				OS:      m.Platform.OS,
+				Arch:    m.Platform.Architecture,
+				Variant: m.Platform.Variant,
+			})
+			if p := manifestPreference(m.Platform); p > preference {
+				chosen, preference = m, p
 			}
 		}
-		entry := ImageEntry{}
-		entry.ExcludedReason = "no suitable manifest (linux amd64) in manifestlist"
-		return entry, nil
+		listDigest = manifestDigest.String()
+		manifest, fetchErr = manifests.Get(ctx, chosen.Digest, digestOpt)
+		goto interpret
 	default:
 		t := reflect.TypeOf(manifest)
 		return ImageEntry{}, errors.New("unknown manifest type: " + t.String())
 	}
+	if platforms != nil {
+		info.Platforms = platforms
+	}
+	// The tag refers to the manifest list, so its digest is the one
+	// to pull (or pin) the image by, on any platform.
+	if listDigest != "" {
+		info.Digest = listDigest
+	}
 	return ImageEntry{Info: info}, labelErr
 }
+
+// manifestPreference ranks the platforms in a manifest list, for
+// choosing which image to take the metadata from: linux/amd64 first,
+// then any other Linux image, then anything else.
+func manifestPreference(p manifestlist.PlatformSpec) int {
+	switch {
+	case p.OS == "linux" && p.Architecture == "amd64":
+		return 2
+	case p.OS == "linux":
+		return 1
+	}
+	return 0
+}
@@ -26,6 +26,9 @@ type RemoteClientFactory struct {
 	// hosts with which to tolerate insecure connections (e.g., with
 	// TLS_INSECURE_SKIP_VERIFY, or as a fallback, using HTTP).
 	InsecureHosts []string
+	// Mirrors to ask for image metadata before the registries
+	// themselves.
+	Mirrors Mirrors
 
 	mu               sync.Mutex
 	challengeManager challenge.Manager
@@ -92,25 +95,61 @@ attemptChallenge:
 	return &registryURL, nil
 }
 
+// ClientFor returns a client for the repository given. If there are
+// mirrors for its registry, the client asks those first, falling back
+// to the registry itself if they fail.
 func (f *RemoteClientFactory) ClientFor(repo image.CanonicalName, creds Credentials) (Client, error) {
-	repoHosts := []string{repo.Domain}
+	mirrors := f.Mirrors[repo.Domain]
+	if len(mirrors) == 0 {
+		return f.remoteFor(repo, repo.Domain, "", f.insecure(repo.Domain), creds)
+	}
+
+	mirrored := &mirroredClient{repo: repo, logger: f.Logger}
+	for _, mirror := range mirrors {
+		client, err := f.remoteFor(repo, mirror.Host, mirror.Prefix, mirror.Insecure || f.insecure(mirror.Host), creds)
+		if err != nil {
+			f.Logger.Log("info", "registry mirror unavailable; skipping", "repo", repo.String(), "mirror", mirror.String(), "err", err)
+			continue
+		}
+		mirrored.clients = append(mirrored.clients, client)
+		mirrored.endpoints = append(mirrored.endpoints, mirror.String())
+	}
+	client, err := f.remoteFor(repo, repo.Domain, "", f.insecure(repo.Domain), creds)
+	if err != nil {
+		if len(mirrored.clients) == 0 {
+			return nil, err
+		}
+		f.Logger.Log("info", "registry unavailable; using mirrors only", "repo", repo.String(), "err", err)
+	} else {
+		mirrored.clients = append(mirrored.clients, client)
+		mirrored.endpoints = append(mirrored.endpoints, repo.Domain)
+	}
+	return mirrored, nil
+}
+
+// insecure says whether the host given is one of those with which
+// insecure connections are tolerated.
+func (f *RemoteClientFactory) insecure(host string) bool {
+	hosts := []string{host}
 	// allow the insecure hosts list to contain hosts with or without the port
-	repoHostWithoutPort, _, err := net.SplitHostPort(repo.Domain)
+	hostWithoutPort, _, err := net.SplitHostPort(host)
 	if err == nil {
 		// parsing fails if no port is present
-		repoHosts = append(repoHosts, repoHostWithoutPort)
+		hosts = append(hosts, hostWithoutPort)
 	}
-	insecure := false
-insecureCheckLoop:
 	for _, h := range f.InsecureHosts {
-		for _, repoHost := range repoHosts {
-			if h == repoHost {
-				insecure = true
-				break insecureCheckLoop
+		for _, candidate := range hosts {
+			if h == candidate {
+				return true
 			}
 		}
 	}
+	return false
+}
 
+// remoteFor returns a client for the repository given, at the
+// registry host given -- either the repository's own, or a mirror.
+func (f *RemoteClientFactory) remoteFor(repo image.CanonicalName, host, prefix string, insecure bool, creds Credentials) (Client, error) {
 	tlsConfig := &tls.Config{
 		InsecureSkipVerify: insecure,
 	}
@@ -123,7 +162,7 @@ insecureCheckLoop:
 		Proxy:           http.ProxyFromEnvironment,
 	}
 	if f.Limiters != nil {
-		tx = f.Limiters.RoundTripper(tx, repo.Domain)
+		tx = f.Limiters.RoundTripper(tx, host)
 	}
 	if f.Trace {
 		tx = &logging{f.Logger, tx}
@@ -136,25 +175,29 @@ insecureCheckLoop:
 	manager := f.challengeManager
 	f.mu.Unlock()
 
-	registryURL, err := f.doChallenge(manager, tx, repo.Domain, insecure)
+	registryURL, err := f.doChallenge(manager, tx, host, insecure)
 	if err != nil {
 		return nil, err
 	}
 
-	cred := creds.credsFor(repo.Domain)
+	cred := creds.credsFor(host)
 	if f.Trace {
 		f.Logger.Log("repo", repo.String(), "auth", cred.String(), "api", registryURL.String())
 	}
 
+	scope := repo.Image
+	if prefix != "" {
+		scope = prefix + "/" + scope
+	}
 	authHandlers := []auth.AuthenticationHandler{
-		auth.NewTokenHandler(tx, &store{cred}, repo.Image, "pull"),
+		auth.NewTokenHandler(tx, &store{cred}, scope, "pull"),
 		auth.NewBasicHandler(&store{cred}),
 	}
 	tx = transport.NewTransport(tx, auth.NewAuthorizer(manager, authHandlers...))
 
 	// For the API base we want only the scheme and host.
 	registryURL.Path = ""
-	client := &Remote{transport: tx, repo: repo, base: registryURL.String()}
+	client := &Remote{transport: tx, repo: repo, base: registryURL.String(), prefix: prefix}
 	return NewInstrumentedClient(client), nil
 }
 
@@ -168,16 +211,24 @@ func (f *RemoteClientFactory) Succeed(repo image.CanonicalName) {
 }
 
 // store adapts a set of pre-selected creds to be an
-// auth.CredentialsStore
+// auth.CredentialsStore. If the creds are an identity token, it's
+// given as the refresh token, so that it's exchanged for an access
+// token with the registry's token service.
 type store struct {
 	auth creds
 }
 
 func (s *store) Basic(url *url.URL) (string, string) {
+	if s.auth.username == identityTokenUsername {
+		return "", ""
+	}
 	return s.auth.username, s.auth.password
 }
 
 func (s *store) RefreshToken(*url.URL, string) string {
+	if s.auth.username == identityTokenUsername {
+		return s.auth.password
+	}
 	return ""
 }
 
@@ -7,7 +7,9 @@ import (
 	"io/ioutil"
 	"net/url"
 	"strings"
+	"time"
 
+	"github.com/go-kit/kit/log"
 	"github.com/pkg/errors"
 )
 
@@ -27,6 +29,8 @@ func (c creds) String() string {
 // Credentials to a (Docker) registry.
 type Credentials struct {
 	m map[string]creds
+	// providers to ask for credentials for hosts not in m
+	providers []CredentialProvider
 }
 
 // NoCredentials returns a usable but empty credentials object.
@@ -52,17 +56,23 @@ func parseAuth(auth string) (creds, error) {
 	}, nil
 }
 
+// ParseCredentials parses the auths in a docker config, or in the
+// older format used by Kubernetes secrets. Credential helpers are
+// ignored, since a helper is a program to run; they are only used
+// from the docker config given to fluxd (see ImageCredsWithDefaults).
 func ParseCredentials(from string, b []byte) (Credentials, error) {
 	var config struct {
 		Auths map[string]struct {
 			Auth string
 		}
+		CredHelpers map[string]string
+		CredsStore  string
 	}
 	if err := json.Unmarshal(b, &config); err != nil {
 		return Credentials{}, err
 	}
 	// If it's in k8s format, it won't have the surrounding "Auth". Try that too.
-	if len(config.Auths) == 0 {
+	if len(config.Auths) == 0 && len(config.CredHelpers) == 0 && config.CredsStore == "" {
 		if err := json.Unmarshal(b, &config.Auths); err != nil {
 			return Credentials{}, err
 		}
@@ -74,31 +84,11 @@ func ParseCredentials(from string, b []byte) (Credentials, error) {
 			return Credentials{}, err
 		}
 
-		if host == "http://" || host == "https://" {
-			return Credentials{}, errors.New("Empty registry auth url")
-		}
-
-		// Some users were passing in credentials in the form of
-		// http://docker.io and http://docker.io/v1/, etc.
-		// So strip everything down to the host.
-		// Also, the registry might be local and on a different port.
-		// So we need to check for that because url.Parse won't parse the ip:port format very well.
-		u, err := url.Parse(host)
-
-		// if anything went wrong try to prepend https://
-		if err != nil || u.Host == "" {
-			u, err = url.Parse(fmt.Sprintf("https://%s/", host))
-			if err != nil {
-				return Credentials{}, err
-			}
-		}
-
-		if u.Host == "" { // If host is still empty the url must be broken.
-			return Credentials{}, errors.New("Invalid registry auth url. Must be a valid http address (e.g. https://gcr.io/v1/)")
+		host, err = registryHost(host)
+		if err != nil {
+			return Credentials{}, err
 		}
 
-		host = u.Host
-
 		creds.registry = host
 		creds.provenance = from
 		m[host] = creds
@@ -106,11 +96,63 @@ func ParseCredentials(from string, b []byte) (Credentials, error) {
 	return Credentials{m: m}, nil
 }
 
-func ImageCredsWithDefaults(lookup func() ImageCreds, configPath string) (func() ImageCreds, error) {
+// registryHost gets the registry host from a server address as given
+// in a docker config.
+func registryHost(host string) (string, error) {
+	if host == "http://" || host == "https://" {
+		return "", errors.New("Empty registry auth url")
+	}
+
+	// Some users were passing in credentials in the form of
+	// http://docker.io and http://docker.io/v1/, etc.
+	// So strip everything down to the host.
+	// Also, the registry might be local and on a different port.
+	// So we need to check for that because url.Parse won't parse the ip:port format very well.
+	u, err := url.Parse(host)
+
+	// if anything went wrong try to prepend https://
+	if err != nil || u.Host == "" {
+		u, err = url.Parse(fmt.Sprintf("https://%s/", host))
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if u.Host == "" { // If host is still empty the url must be broken.
+		return "", errors.New("Invalid registry auth url. Must be a valid http address (e.g. https://gcr.io/v1/)")
+	}
+
+	return u.Host, nil
+}
+
+// ImageCredsWithDefaults adds the credentials in the docker config
+// at the path given to those looked up for each image, as a default.
+// The config is read again each time, so it can be updated (e.g., if
+// it's mounted from a secret). Credential helpers named in the
+// config are run to get credentials for registries not in its auths,
+// and what they give is kept for the expiry given; their failures are
+// logged.
+func ImageCredsWithDefaults(lookup func() ImageCreds, logger log.Logger, configPath string, helperExpiry time.Duration) (func() ImageCreds, error) {
+	cache := newCredentialHelperCache(helperExpiry, logger)
+	parse := func(bs []byte) (Credentials, error) {
+		defaults, err := ParseCredentials(configPath, bs)
+		if err != nil {
+			return Credentials{}, err
+		}
+		helpers, err := parseCredentialHelpers(configPath, bs, cache)
+		if err != nil {
+			return Credentials{}, err
+		}
+		if helpers != nil {
+			defaults.providers = []CredentialProvider{helpers}
+		}
+		return defaults, nil
+	}
+
 	// pre-flight check
 	bs, err := ioutil.ReadFile(configPath)
 	if err == nil {
-		_, err = ParseCredentials(configPath, bs)
+		_, err = parse(bs)
 	}
 	if err != nil {
 		return nil, err
@@ -120,7 +162,7 @@ func ImageCredsWithDefaults(lookup func() ImageCreds, configPath string) (func()
 		var defaults Credentials
 		bs, err := ioutil.ReadFile(configPath)
 		if err == nil {
-			defaults, _ = ParseCredentials(configPath, bs)
+			defaults, _ = parse(bs)
 		}
 		imageCreds := lookup()
 		for k, v := range imageCreds {
@@ -135,23 +177,22 @@ func ImageCredsWithDefaults(lookup func() ImageCreds, configPath string) (func()
 
 // ---
 
-// credsFor yields an authenticator for a specific host.
+// credsFor yields an authenticator for a specific host. Credentials
+// given explicitly come first; then those from the providers given
+// along with them (e.g., credential helpers), then those from the
+// providers registered (e.g., for cloud registries).
 func (cs Credentials) credsFor(host string) creds {
 	if cred, found := cs.m[host]; found {
 		return cred
 	}
-	if host == "gcr.io" || strings.HasSuffix(host, ".gcr.io") {
-		if cred, err := GetGCPOauthToken(host); err == nil {
-			return cred
+	for _, p := range append(append([]CredentialProvider(nil), cs.providers...), registeredProviders()...) {
+		if !p.Provides(host) {
+			continue
 		}
-	}
-
-	if hostIsAzureContainerRegistry(host) {
-		if cred, err := getAzureCloudConfigAADToken(host); err == nil {
+		if cred, err := credsFrom(p, host); err == nil {
 			return cred
 		}
 	}
-
 	return creds{}
 }
 
@@ -164,10 +205,13 @@ func (cs Credentials) Hosts() []string {
 	return hosts
 }
 
-func (cs Credentials) Merge(c Credentials) {
+// Merge adds the credentials given to these, taking precedence over
+// any for the same host.
+func (cs *Credentials) Merge(c Credentials) {
 	for k, v := range c.m {
 		cs.m[k] = v
 	}
+	cs.providers = append(append([]CredentialProvider(nil), c.providers...), cs.providers...)
 }
 
 func (cs Credentials) String() string {
@@ -0,0 +1,216 @@
+package registry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/pkg/errors"
+)
+
+const (
+	// The default time for which credentials from a helper are kept
+	// before the helper is run again.
+	DefaultCredentialHelperExpiry = 5 * time.Minute
+
+	credentialHelperPrefix  = "docker-credential-"
+	credentialHelperTimeout = 30 * time.Second
+	// How long to wait before running a helper again, after it
+	// failed.
+	credentialHelperBackoff = time.Minute
+	// What helpers say when they don't have credentials for a
+	// server; see
+	// https://github.com/docker/docker-credential-helpers/blob/master/credentials/error.go
+	credentialsNotFound = "credentials not found in native keychain"
+	// Docker Hub is known to credential helpers by its v1 URL.
+	dockerHubHost   = "index.docker.io"
+	dockerHubServer = "https://index.docker.io/v1/"
+)
+
+// A helper is named by the suffix of its executable; it mustn't be a
+// path.
+var credentialHelperName = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// credentialHelpers provides the credentials of the docker credential
+// helpers (`credHelpers`, and `credsStore`) named in a docker config.
+type credentialHelpers struct {
+	from string
+	// the helper for each registry host, and the server URL to ask it
+	// about, as given in the config
+	helpers map[string]string
+	servers map[string]string
+	// the helper to use for any other registry host
+	store string
+	cache *credentialHelperCache
+}
+
+// parseCredentialHelpers parses the credential helpers out of a
+// docker config. It returns nil if there are none.
+func parseCredentialHelpers(from string, b []byte, cache *credentialHelperCache) (*credentialHelpers, error) {
+	var config struct {
+		CredHelpers map[string]string
+		CredsStore  string
+	}
+	if err := json.Unmarshal(b, &config); err != nil {
+		return nil, err
+	}
+	if len(config.CredHelpers) == 0 && config.CredsStore == "" {
+		return nil, nil
+	}
+	h := &credentialHelpers{
+		from:    from,
+		helpers: map[string]string{},
+		servers: map[string]string{},
+		store:   config.CredsStore,
+		cache:   cache,
+	}
+	if h.store != "" && !credentialHelperName.MatchString(h.store) {
+		return nil, fmt.Errorf("invalid credential helper name %q in credsStore", h.store)
+	}
+	for server, helper := range config.CredHelpers {
+		if !credentialHelperName.MatchString(helper) {
+			return nil, fmt.Errorf("invalid credential helper name %q for %s in credHelpers", helper, server)
+		}
+		host, err := registryHost(server)
+		if err != nil {
+			return nil, err
+		}
+		h.helpers[host] = helper
+		h.servers[host] = server
+	}
+	return h, nil
+}
+
+func (h *credentialHelpers) Name() string {
+	return h.from
+}
+
+func (h *credentialHelpers) Provides(host string) bool {
+	_, ok := h.helpers[host]
+	return ok || h.store != ""
+}
+
+func (h *credentialHelpers) Credentials(host string) (string, string, error) {
+	helper, ok := h.helpers[host]
+	server := h.servers[host]
+	if !ok {
+		helper, server = h.store, host
+		if host == dockerHubHost {
+			server = dockerHubServer
+		}
+	}
+	return h.cache.get(helper, server)
+}
+
+// credentialHelperCache runs credential helpers, and keeps what they
+// say for a while, so that they aren't run for every image scanned.
+type credentialHelperCache struct {
+	expiry time.Duration
+	logger log.Logger
+	now    func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]credentialHelperEntry
+}
+
+type credentialHelperEntry struct {
+	username, secret string
+	// set if the helper had no credentials; that's remembered too
+	notFound bool
+	// set if the helper failed; that's remembered for a shorter time
+	err     error
+	expires time.Time
+}
+
+func newCredentialHelperCache(expiry time.Duration, logger log.Logger) *credentialHelperCache {
+	if expiry <= 0 {
+		expiry = DefaultCredentialHelperExpiry
+	}
+	return &credentialHelperCache{
+		expiry:  expiry,
+		logger:  logger,
+		now:     time.Now,
+		entries: map[string]credentialHelperEntry{},
+	}
+}
+
+// get returns the credentials the helper has for the server, running
+// it if they aren't cached, or have expired. If the helper fails, the
+// failure is kept for a short while, so that a broken helper isn't run
+// for every image scanned; it's logged when it first happens, rather
+// than every time the helper is run again.
+func (c *credentialHelperCache) get(helper, server string) (string, string, error) {
+	key := helper + "|" + server
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+	if !ok || !c.now().Before(entry.expires) {
+		previous := entry.err
+		username, secret, err := runCredentialHelper(helper, server)
+		switch {
+		case err == nil || err == errCredentialsNotFound:
+			entry = credentialHelperEntry{
+				username: username,
+				secret:   secret,
+				notFound: err == errCredentialsNotFound,
+				expires:  c.now().Add(c.expiry),
+			}
+		default:
+			if previous == nil || previous.Error() != err.Error() {
+				c.logger.Log("warning", "credential helper failed", "helper", credentialHelperPrefix+helper, "server", server, "err", err, "retry", credentialHelperBackoff)
+			}
+			entry = credentialHelperEntry{
+				err:     err,
+				expires: c.now().Add(credentialHelperBackoff),
+			}
+		}
+		c.mu.Lock()
+		c.entries[key] = entry
+		c.mu.Unlock()
+	}
+	if entry.err != nil {
+		return "", "", entry.err
+	}
+	if entry.notFound {
+		return "", "", errCredentialsNotFound
+	}
+	return entry.username, entry.secret, nil
+}
+
+var errCredentialsNotFound = errors.New(credentialsNotFound)
+
+// runCredentialHelper runs `docker-credential-<helper> get`, as the
+// docker client does, to get the credentials for a server.
+func runCredentialHelper(helper, server string) (string, string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), credentialHelperTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, credentialHelperPrefix+helper, "get")
+	cmd.Stdin = strings.NewReader(server)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		out := strings.TrimSpace(stdout.String())
+		if out == credentialsNotFound {
+			return "", "", errCredentialsNotFound
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			out = msg
+		}
+		return "", "", errors.Wrapf(err, "running credential helper %s%s: %s", credentialHelperPrefix, helper, out)
+	}
+	var result struct {
+		Username string
+		Secret   string
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &result); err != nil {
+		return "", "", errors.Wrapf(err, "parsing output of credential helper %s%s", credentialHelperPrefix, helper)
+	}
+	return result.Username, result.Secret, nil
+}
@@ -0,0 +1,194 @@
+package registry
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+)
+
+// A credential helper that knows about two servers, and records each
+// time it's run.
+const fakeCredentialHelper = `#!/bin/sh
+read server
+echo "$server" >> "$(dirname "$0")/runs"
+case "$server" in
+  registry.example.com)
+    echo '{"ServerURL":"registry.example.com","Username":"helper-user","Secret":"helper-pass"}' ;;
+  https://index.docker.io/v1/)
+    echo '{"ServerURL":"https://index.docker.io/v1/","Username":"<token>","Secret":"identity-token"}' ;;
+  broken.example.com)
+    echo "cannot reach the keychain" >&2
+    exit 2 ;;
+  *)
+    echo "credentials not found in native keychain"
+    exit 1 ;;
+esac
+`
+
+// setupCredentialHelper puts the fake helper on the PATH, and returns
+// a func for counting the times it's been run.
+func setupCredentialHelper(t *testing.T) (runs func() int, cleanup func()) {
+	if runtime.GOOS == "windows" {
+		t.Skip("credential helper script needs a POSIX shell")
+	}
+	dir, err := ioutil.TempDir("", "flux-credential-helper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "docker-credential-fake"), []byte(fakeCredentialHelper), 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := os.Getenv("PATH")
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+path)
+	runs = func() int {
+		bs, _ := ioutil.ReadFile(filepath.Join(dir, "runs"))
+		return strings.Count(string(bs), "\n")
+	}
+	return runs, func() {
+		os.Setenv("PATH", path)
+		os.RemoveAll(dir)
+	}
+}
+
+func TestCredentialHelpers(t *testing.T) {
+	runs, cleanup := setupCredentialHelper(t)
+	defer cleanup()
+
+	config := []byte(`{
+  "auths": {"other.example.com": {"auth": "` + okCreds + `"}},
+  "credHelpers": {"registry.example.com": "fake"},
+  "credsStore": "fake"
+}`)
+	cache := newCredentialHelperCache(time.Minute, log.NewNopLogger())
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+
+	cs, err := ParseCredentials("config.json", config)
+	assert.NoError(t, err)
+	helpers, err := parseCredentialHelpers("config.json", config, cache)
+	assert.NoError(t, err)
+	cs.providers = []CredentialProvider{helpers}
+
+	// Auths come first
+	assert.Equal(t, user, cs.credsFor("other.example.com").username)
+	assert.Equal(t, 0, runs())
+
+	c := cs.credsFor("registry.example.com")
+	assert.Equal(t, "helper-user", c.username)
+	assert.Equal(t, "helper-pass", c.password)
+	assert.Equal(t, "config.json", c.provenance)
+	assert.Equal(t, 1, runs())
+
+	// It's cached, until it expires
+	cs.credsFor("registry.example.com")
+	assert.Equal(t, 1, runs())
+	now = now.Add(2 * time.Minute)
+	assert.Equal(t, "helper-user", cs.credsFor("registry.example.com").username)
+	assert.Equal(t, 2, runs())
+
+	// A server the helper doesn't know about gets no creds; that's
+	// cached too
+	assert.Equal(t, creds{}, cs.credsFor("unknown.example.com"))
+	assert.Equal(t, creds{}, cs.credsFor("unknown.example.com"))
+	assert.Equal(t, 3, runs())
+
+	// Docker Hub is asked about by its v1 URL, and here the helper
+	// gives an identity token
+	hub := &store{cs.credsFor("index.docker.io")}
+	username, password := hub.Basic(nil)
+	assert.Equal(t, "", username)
+	assert.Equal(t, "", password)
+	assert.Equal(t, "identity-token", hub.RefreshToken(nil, ""))
+}
+
+func TestCredentialHelpers_Failure(t *testing.T) {
+	runs, cleanup := setupCredentialHelper(t)
+	defer cleanup()
+
+	var logged []string
+	logger := log.LoggerFunc(func(keyvals ...interface{}) error {
+		logged = append(logged, fmt.Sprint(keyvals...))
+		return nil
+	})
+	cache := newCredentialHelperCache(time.Hour, logger)
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+
+	_, _, err := cache.get("fake", "broken.example.com")
+	assert.Error(t, err)
+	assert.Equal(t, 1, runs())
+	assert.Len(t, logged, 1)
+
+	// The failure is kept for a while, rather than the helper being
+	// run again straight away
+	_, _, err = cache.get("fake", "broken.example.com")
+	assert.Error(t, err)
+	assert.Equal(t, 1, runs())
+
+	// .. but not for as long as credentials are; and when it fails the
+	// same way again, that isn't logged again
+	now = now.Add(credentialHelperBackoff)
+	_, _, err = cache.get("fake", "broken.example.com")
+	assert.Error(t, err)
+	assert.Equal(t, 2, runs())
+	assert.Len(t, logged, 1)
+}
+
+func TestCredentialHelpers_Merge(t *testing.T) {
+	_, cleanup := setupCredentialHelper(t)
+	defer cleanup()
+
+	config := []byte(`{"credsStore": "fake"}`)
+	// A config with only helpers is fine
+	defaults, err := ParseCredentials("config.json", config)
+	assert.NoError(t, err)
+	helpers, err := parseCredentialHelpers("config.json", config, newCredentialHelperCache(0, log.NewNopLogger()))
+	assert.NoError(t, err)
+	defaults.providers = []CredentialProvider{helpers}
+
+	merged := NoCredentials()
+	merged.Merge(defaults)
+	assert.Equal(t, "helper-user", merged.credsFor("registry.example.com").username)
+}
+
+func TestParseCredentialHelpers_Invalid(t *testing.T) {
+	for _, config := range []string{
+		`{"credsStore": "../../tmp/helper"}`,
+		`{"credHelpers": {"registry.example.com": "/bin/sh"}}`,
+		`{"credHelpers": {"https://": "fake"}}`,
+	} {
+		_, err := parseCredentialHelpers("config.json", []byte(config), newCredentialHelperCache(0, log.NewNopLogger()))
+		assert.Error(t, err, config)
+	}
+}
+
+type fakeProvider struct{}
+
+func (fakeProvider) Name() string {
+	return "fake"
+}
+
+func (fakeProvider) Provides(host string) bool {
+	return host == "provider.example.com"
+}
+
+func (fakeProvider) Credentials(host string) (string, string, error) {
+	return "provider-user", "provider-pass", nil
+}
+
+func TestRegisterCredentialProvider(t *testing.T) {
+	RegisterCredentialProvider(fakeProvider{})
+	c := NoCredentials().credsFor("provider.example.com")
+	assert.Equal(t, "provider-user", c.username)
+	assert.Equal(t, "provider-pass", c.password)
+	assert.Equal(t, "fake", c.provenance)
+	assert.Equal(t, creds{}, NoCredentials().credsFor("other.example.com"))
+}
@@ -0,0 +1,132 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/go-kit/kit/log"
+
+	"github.com/fluxcd/flux/pkg/image"
+)
+
+// Mirror is a registry that serves the images of another registry,
+// e.g., a pull-through cache.
+type Mirror struct {
+	// Host is the mirror's host, with the port if not the default
+	Host string
+	// Prefix is the path under which the mirror keeps the images,
+	// if any
+	Prefix string
+	// Insecure is set if the mirror is to be reached over HTTP
+	Insecure bool
+}
+
+func (m Mirror) String() string {
+	s := m.Host
+	if m.Prefix != "" {
+		s += "/" + m.Prefix
+	}
+	if m.Insecure {
+		return "http://" + s
+	}
+	return s
+}
+
+// Mirrors gives the mirrors for each registry domain, in the order in
+// which they are to be tried; as with containerd's
+// `registry.mirrors`. Mirrors are only used to fetch image metadata;
+// images are still named for the registry they are mirroring.
+type Mirrors map[string][]Mirror
+
+// ParseMirrors parses mirrors given as `<domain>=<endpoint>`, where
+// the endpoint is a host (e.g., `mirror.example.com:5000`), with an
+// optional path prefix, and an optional `http://` or `https://`
+// scheme. A domain may be given more than once, for more than one
+// mirror.
+func ParseMirrors(specs []string) (Mirrors, error) {
+	mirrors := Mirrors{}
+	for _, spec := range specs {
+		parts := strings.SplitN(spec, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("registry mirror %q is not of the form <domain>=<endpoint>", spec)
+		}
+		var mirror Mirror
+		endpoint := parts[1]
+		switch {
+		case strings.HasPrefix(endpoint, "http://"):
+			mirror.Insecure = true
+			endpoint = strings.TrimPrefix(endpoint, "http://")
+		case strings.HasPrefix(endpoint, "https://"):
+			endpoint = strings.TrimPrefix(endpoint, "https://")
+		case strings.Contains(endpoint, "://"):
+			return nil, fmt.Errorf("registry mirror %q has an unsupported scheme", spec)
+		}
+		endpoint = strings.Trim(endpoint, "/")
+		pathParts := strings.SplitN(endpoint, "/", 2)
+		mirror.Host = pathParts[0]
+		if len(pathParts) == 2 {
+			mirror.Prefix = pathParts[1]
+		}
+		if mirror.Host == "" {
+			return nil, fmt.Errorf("registry mirror %q has no host", spec)
+		}
+		// Normalise the domain as it will be given in image names
+		// (e.g., docker.io is index.docker.io).
+		domain := image.Name{Domain: parts[0]}.Registry()
+		mirrors[domain] = append(mirrors[domain], mirror)
+	}
+	return mirrors, nil
+}
+
+// mirroredClient asks each of a list of clients in turn, until one
+// answers; the mirrors are tried first, then the registry itself.
+type mirroredClient struct {
+	repo      image.CanonicalName
+	clients   []Client
+	endpoints []string
+	logger    log.Logger
+}
+
+func (c *mirroredClient) Tags(ctx context.Context) (tags []string, err error) {
+	for i, client := range c.clients {
+		if tags, err = client.Tags(ctx); err == nil || !c.fallback(ctx, i, err) {
+			return tags, err
+		}
+	}
+	return tags, err
+}
+
+func (c *mirroredClient) Manifest(ctx context.Context, ref string) (entry ImageEntry, err error) {
+	for i, client := range c.clients {
+		entry, err = client.Manifest(ctx, ref)
+		if _, ok := err.(*image.LabelTimestampFormatError); ok {
+			// The image was found, but has labels that can't be
+			// parsed; it'll be the same wherever it's found.
+			return entry, err
+		}
+		if err == nil || !c.fallback(ctx, i, err) {
+			return entry, err
+		}
+	}
+	return entry, err
+}
+
+func (c *mirroredClient) Signatures(ctx context.Context, tag string) (digest string, sigs []Signature, err error) {
+	for i, client := range c.clients {
+		if digest, sigs, err = client.Signatures(ctx, tag); err == nil || !c.fallback(ctx, i, err) {
+			return digest, sigs, err
+		}
+	}
+	return digest, sigs, err
+}
+
+// fallback logs the error from the i'th client, and says whether to
+// try the next client.
+func (c *mirroredClient) fallback(ctx context.Context, i int, err error) bool {
+	if ctx.Err() != nil || i == len(c.clients)-1 {
+		return false
+	}
+	c.logger.Log("info", "registry endpoint failed; trying next", "repo", c.repo.String(), "endpoint", c.endpoints[i], "next", c.endpoints[i+1], "err", err)
+	return true
+}
@@ -0,0 +1,110 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/fluxcd/flux/pkg/image"
+)
+
+func TestParseMirrors(t *testing.T) {
+	mirrors, err := ParseMirrors([]string{
+		"docker.io=mirror.example.com:5000",
+		"docker.io=https://harbor.example.com/dockerhub/",
+		"quay.io=http://localhost:5000",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, Mirrors{
+		"index.docker.io": {
+			{Host: "mirror.example.com:5000"},
+			{Host: "harbor.example.com", Prefix: "dockerhub"},
+		},
+		"quay.io": {
+			{Host: "localhost:5000", Insecure: true},
+		},
+	}, mirrors)
+
+	for _, spec := range []string{
+		"mirror.example.com",
+		"docker.io=",
+		"=mirror.example.com",
+		"docker.io=ftp://mirror.example.com",
+		"docker.io=https://",
+	} {
+		_, err := ParseMirrors([]string{spec})
+		assert.Error(t, err, spec)
+	}
+}
+
+// registryServer serves the tags given for each repository, and a
+// 404 for any other.
+func registryServer(tags map[string][]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v2/" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		for repo, ts := range tags {
+			if r.URL.Path == "/v2/"+repo+"/tags/list" {
+				w.Header().Set("Content-Type", "application/json")
+				json.NewEncoder(w).Encode(map[string]interface{}{
+					"name": repo,
+					"tags": ts,
+				})
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+}
+
+func TestClientFor_Mirrors(t *testing.T) {
+	upstream := registryServer(map[string][]string{
+		"foo/bar": {"upstream"},
+		"foo/baz": {"upstream"},
+	})
+	defer upstream.Close()
+	mirror := registryServer(map[string][]string{
+		"cache/foo/bar": {"mirror"},
+	})
+	defer mirror.Close()
+
+	upstreamURL, err := url.Parse(upstream.URL)
+	assert.NoError(t, err)
+	mirrorURL, err := url.Parse(mirror.URL)
+	assert.NoError(t, err)
+
+	mirrors, err := ParseMirrors([]string{upstreamURL.Host + "=http://" + mirrorURL.Host + "/cache"})
+	assert.NoError(t, err)
+	factory := &RemoteClientFactory{
+		Logger:        log.NewLogfmtLogger(os.Stdout),
+		InsecureHosts: []string{upstreamURL.Host},
+		Mirrors:       mirrors,
+	}
+
+	tagsFor := func(repo string) []string {
+		name := image.Name{Domain: upstreamURL.Host, Image: repo}.CanonicalName()
+		client, err := factory.ClientFor(name, NoCredentials())
+		assert.NoError(t, err)
+		tags, err := client.Tags(context.Background())
+		assert.NoError(t, err)
+		return tags
+	}
+
+	// The mirror has foo/bar, so it's used ...
+	assert.Equal(t, []string{"mirror"}, tagsFor("foo/bar"))
+	// ... but doesn't have foo/baz, so the registry itself is used.
+	assert.Equal(t, []string{"upstream"}, tagsFor("foo/baz"))
+
+	// A mirror that can't be reached is skipped.
+	mirror.Close()
+	assert.Equal(t, []string{"upstream"}, tagsFor("foo/bar"))
+}
@@ -10,8 +10,9 @@ import (
 )
 
 type Client struct {
-	ManifestFn func(ref string) (registry.ImageEntry, error)
-	TagsFn     func() ([]string, error)
+	ManifestFn   func(ref string) (registry.ImageEntry, error)
+	TagsFn       func() ([]string, error)
+	SignaturesFn func(tag string) (string, []registry.Signature, error)
 }
 
 func (m *Client) Manifest(ctx context.Context, tag string) (registry.ImageEntry, error) {
@@ -22,6 +23,10 @@ func (m *Client) Tags(context.Context) ([]string, error) {
 	return m.TagsFn()
 }
 
+func (m *Client) Signatures(ctx context.Context, tag string) (string, []registry.Signature, error) {
+	return m.SignaturesFn(tag)
+}
+
 var _ registry.Client = &Client{}
 
 type ClientFactory struct {
@@ -61,7 +66,7 @@ func (m *Registry) GetImageRepositoryMetadata(id image.Name) (image.RepositoryMe
 
 func (m *Registry) GetImage(id image.Ref) (image.Info, error) {
 	for _, i := range m.Images {
-		if i.ID.String() == id.String() {
+		if i.ID.String() == id.WithDigest("").String() {
 			return i, nil
 		}
 	}
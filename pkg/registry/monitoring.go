@@ -15,9 +15,10 @@ import (
 )
 
 const (
-	LabelRequestKind    = "kind"
-	RequestKindTags     = "tags"
-	RequestKindMetadata = "metadata"
+	LabelRequestKind      = "kind"
+	RequestKindTags       = "tags"
+	RequestKindMetadata   = "metadata"
+	RequestKindSignatures = "signatures"
 )
 
 var (
@@ -93,3 +94,13 @@ func (m *instrumentedClient) Tags(ctx context.Context) (res []string, err error)
 	).Observe(time.Since(start).Seconds())
 	return
 }
+
+func (m *instrumentedClient) Signatures(ctx context.Context, tag string) (manifestDigest string, sigs []Signature, err error) {
+	start := time.Now()
+	manifestDigest, sigs, err = m.next.Signatures(ctx, tag)
+	remoteDuration.With(
+		LabelRequestKind, RequestKindSignatures,
+		fluxmetrics.LabelSuccess, strconv.FormatBool(err == nil),
+	).Observe(time.Since(start).Seconds())
+	return
+}
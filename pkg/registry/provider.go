@@ -0,0 +1,96 @@
+package registry
+
+import (
+	"strings"
+	"sync"
+)
+
+// CredentialProvider supplies credentials for image registries, e.g.,
+// by asking a cloud provider's API for a token. Providers are asked
+// for credentials for a registry host only when there are none given
+// explicitly (in an imagePullSecret, or in the auths of the docker
+// config given to fluxd).
+type CredentialProvider interface {
+	// Name says where the credentials come from, for logging.
+	Name() string
+	// Provides says whether the provider may have credentials for
+	// the registry host given.
+	Provides(host string) bool
+	// Credentials gets the username and secret for the registry
+	// host given. As with docker credential helpers, a username of
+	// `<token>` means the secret is an identity token, to be
+	// exchanged with the registry's token service for access tokens.
+	Credentials(host string) (username, secret string, err error)
+}
+
+// The username given with an identity token, rather than a password.
+const identityTokenUsername = "<token>"
+
+var (
+	providersMu sync.RWMutex
+	providers   = []CredentialProvider{gcpCredentials{}, azureCredentials{}}
+)
+
+// RegisterCredentialProvider adds a provider to those asked for
+// registry credentials. Providers are asked in the order in which
+// they were registered, after those built in (for Google Container
+// Registry and Azure Container Registry).
+func RegisterCredentialProvider(p CredentialProvider) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	providers = append(providers, p)
+}
+
+func registeredProviders() []CredentialProvider {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	return append([]CredentialProvider(nil), providers...)
+}
+
+// credsFrom asks a provider for credentials for a host.
+func credsFrom(p CredentialProvider, host string) (creds, error) {
+	username, secret, err := p.Credentials(host)
+	if err != nil {
+		return creds{}, err
+	}
+	return creds{
+		registry:   host,
+		provenance: p.Name(),
+		username:   username,
+		password:   secret,
+	}, nil
+}
+
+// gcpCredentials gets a token for Google Container Registry from the
+// GCE metadata service.
+type gcpCredentials struct{}
+
+func (gcpCredentials) Name() string {
+	return "gcp"
+}
+
+func (gcpCredentials) Provides(host string) bool {
+	return host == "gcr.io" || strings.HasSuffix(host, ".gcr.io")
+}
+
+func (gcpCredentials) Credentials(host string) (string, string, error) {
+	c, err := GetGCPOauthToken(host)
+	return c.username, c.password, err
+}
+
+// azureCredentials gets credentials for Azure Container Registry
+// from the cloud config on the host.
+type azureCredentials struct{}
+
+func (azureCredentials) Name() string {
+	return "azure.json"
+}
+
+func (azureCredentials) Provides(host string) bool {
+	return hostIsAzureContainerRegistry(host)
+}
+
+func (azureCredentials) Credentials(host string) (string, string, error) {
+	c, err := getAzureCloudConfigAADToken(host)
+	return c.username, c.password, err
+}
@@ -0,0 +1,234 @@
+package registry
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"reflect"
+	"strings"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/manifest/ocischema"
+	"github.com/docker/distribution/registry/client"
+	"github.com/opencontainers/go-digest"
+	"github.com/pkg/errors"
+
+	"github.com/fluxcd/flux/pkg/image"
+)
+
+// Signatures are stored the way cosign stores them: as an OCI
+// artifact in the same repository as the image, tagged after the
+// digest of the image manifest (`sha256-<hex>.sig`). Each layer of
+// the artifact is a payload naming the manifest digest, and the
+// signature over that payload is in an annotation on the layer.
+const (
+	signatureTagSuffix   = ".sig"
+	signatureAnnotation  = "dev.cosignproject.cosign/signature"
+	signaturePayloadType = "cosign container image signature"
+)
+
+var (
+	ErrNoSignatures        = errors.New("no signatures found")
+	ErrSignatureNotTrusted = errors.New("no signature could be verified with the configured keys")
+)
+
+// Signature is a signature over an image manifest, as found in the
+// registry.
+type Signature struct {
+	Payload   []byte
+	Signature []byte
+}
+
+// signatureTag gives the tag under which the signatures of the
+// manifest with the given digest are stored.
+func signatureTag(manifestDigest string) string {
+	return strings.Replace(manifestDigest, ":", "-", 1) + signatureTagSuffix
+}
+
+// Signatures fetches the signatures stored for the image with the
+// given tag, along with the digest of the manifest the tag refers to;
+// this is the digest the signatures must be over. An image without
+// any signatures is not an error; you just get none back.
+func (a *Remote) Signatures(ctx context.Context, tag string) (string, []Signature, error) {
+	repository, err := client.NewRepository(a.apiName(), a.base, a.transport)
+	if err != nil {
+		return "", nil, err
+	}
+	desc, err := repository.Tags(ctx).Get(ctx, tag)
+	if err != nil {
+		return "", nil, err
+	}
+	manifestDigest := desc.Digest.String()
+
+	sigTag := signatureTag(manifestDigest)
+	if _, err := repository.Tags(ctx).Get(ctx, sigTag); err != nil {
+		if _, ok := err.(distribution.ErrTagUnknown); ok {
+			return manifestDigest, nil, nil
+		}
+		return "", nil, err
+	}
+	manifests, err := repository.Manifests(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+	manifest, err := manifests.Get(ctx, "", distribution.WithTagOption{sigTag})
+	if err != nil {
+		return "", nil, err
+	}
+	oci, ok := manifest.(*ocischema.DeserializedManifest)
+	if !ok {
+		return "", nil, errors.New("unexpected manifest type for signatures: " + reflect.TypeOf(manifest).String())
+	}
+
+	var sigs []Signature
+	for _, layer := range oci.Layers {
+		encoded, ok := layer.Annotations[signatureAnnotation]
+		if !ok {
+			continue
+		}
+		sig, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return "", nil, errors.Wrapf(err, "decoding signature in layer %s", layer.Digest)
+		}
+		payload, err := repository.Blobs(ctx).Get(ctx, layer.Digest)
+		if err != nil {
+			return "", nil, errors.Wrapf(err, "fetching signature payload %s", layer.Digest)
+		}
+		if digest.FromBytes(payload) != layer.Digest {
+			return "", nil, fmt.Errorf("signature payload does not match its digest %s", layer.Digest)
+		}
+		sigs = append(sigs, Signature{Payload: payload, Signature: sig})
+	}
+	return manifestDigest, sigs, nil
+}
+
+// VerifySignatures checks that at least one of the signatures given
+// is over a payload naming the manifest digest, and can be verified
+// with one of the keys.
+func VerifySignatures(keys []crypto.PublicKey, manifestDigest string, sigs []Signature) error {
+	if len(sigs) == 0 {
+		return ErrNoSignatures
+	}
+	for _, sig := range sigs {
+		var payload struct {
+			Critical struct {
+				Image struct {
+					DockerManifestDigest string `json:"docker-manifest-digest"`
+				} `json:"image"`
+				Type string `json:"type"`
+			} `json:"critical"`
+		}
+		if err := json.Unmarshal(sig.Payload, &payload); err != nil {
+			continue
+		}
+		if payload.Critical.Type != signaturePayloadType || payload.Critical.Image.DockerManifestDigest != manifestDigest {
+			continue
+		}
+		for _, key := range keys {
+			if verifySignature(key, sig.Payload, sig.Signature) {
+				return nil
+			}
+		}
+	}
+	return ErrSignatureNotTrusted
+}
+
+func verifySignature(key crypto.PublicKey, payload, sig []byte) bool {
+	hash := sha256.Sum256(payload)
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		var rs struct {
+			R, S *big.Int
+		}
+		if rest, err := asn1.Unmarshal(sig, &rs); err != nil || len(rest) > 0 {
+			return false
+		}
+		return ecdsa.Verify(k, hash[:], rs.R, rs.S)
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(k, crypto.SHA256, hash[:], sig) == nil
+	case ed25519.PublicKey:
+		return ed25519.Verify(k, payload, sig)
+	}
+	return false
+}
+
+// LoadPublicKeys reads PEM-encoded public keys (ECDSA, RSA or
+// Ed25519) from the files given. Each file may contain more than one
+// key.
+func LoadPublicKeys(paths []string) ([]crypto.PublicKey, error) {
+	var keys []crypto.PublicKey
+	for _, path := range paths {
+		bytes, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		found := false
+		for {
+			var block *pem.Block
+			block, bytes = pem.Decode(bytes)
+			if block == nil {
+				break
+			}
+			if block.Type != "PUBLIC KEY" {
+				continue
+			}
+			key, err := x509.ParsePKIXPublicKey(block.Bytes)
+			if err != nil {
+				return nil, errors.Wrapf(err, "parsing public key in %s", path)
+			}
+			keys = append(keys, key)
+			found = true
+		}
+		if !found {
+			return nil, fmt.Errorf("no PEM-encoded public keys found in %s", path)
+		}
+	}
+	return keys, nil
+}
+
+// SignatureVerifier verifies that images have been signed with one of
+// a set of keys, by fetching their signatures from the registry.
+type SignatureVerifier struct {
+	Keys        []crypto.PublicKey
+	Factory     ClientFactory
+	Credentials func() ImageCreds
+}
+
+// VerifyImage fetches the signatures of the image from the registry
+// (rather than using anything cached, since it's the image as it is
+// now that will be run), and verifies them. It returns the digest of
+// the manifest verified.
+func (v *SignatureVerifier) VerifyImage(ctx context.Context, ref image.Ref) (string, error) {
+	var creds Credentials
+	if v.Credentials != nil {
+		creds = v.Credentials()[ref.Name]
+	}
+	client, err := v.Factory.ClientFor(ref.CanonicalName(), creds)
+	if err != nil {
+		return "", err
+	}
+	manifestDigest, sigs, err := client.Signatures(ctx, ref.Tag)
+	if err != nil {
+		return "", errors.Wrap(err, "fetching signatures")
+	}
+	// The signatures are those of the image the tag refers to now,
+	// which had better be the image pinned.
+	if ref.Digest != "" && ref.Digest != manifestDigest {
+		return "", fmt.Errorf("tag %s refers to %s, not the pinned digest %s", ref.Tag, manifestDigest, ref.Digest)
+	}
+	if err := VerifySignatures(v.Keys, manifestDigest, sigs); err != nil {
+		return "", err
+	}
+	return manifestDigest, nil
+}
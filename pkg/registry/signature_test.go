@@ -0,0 +1,106 @@
+package registry
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testDigest = "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+func signaturePayload(manifestDigest string) []byte {
+	return []byte(fmt.Sprintf(`{"critical":{"identity":{"docker-reference":"example.com/app"},"image":{"docker-manifest-digest":%q},"type":"cosign container image signature"},"optional":null}`, manifestDigest))
+}
+
+func signECDSA(t *testing.T, key *ecdsa.PrivateKey, payload []byte) Signature {
+	hash := sha256.Sum256(payload)
+	sig, err := key.Sign(rand.Reader, hash[:], crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Signature{Payload: payload, Signature: sig}
+}
+
+func TestSignatureTag(t *testing.T) {
+	assert.Equal(t, "sha256-0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef.sig", signatureTag(testDigest))
+}
+
+func TestVerifySignatures(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edPub, edPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := []crypto.PublicKey{&key.PublicKey, edPub}
+
+	good := signECDSA(t, key, signaturePayload(testDigest))
+	untrusted := signECDSA(t, otherKey, signaturePayload(testDigest))
+	otherImage := signECDSA(t, key, signaturePayload("sha256:fedcba9876543210fedcba9876543210fedcba9876543210fedcba9876543210"))
+	edPayload := signaturePayload(testDigest)
+	edGood := Signature{Payload: edPayload, Signature: ed25519.Sign(edPriv, edPayload)}
+
+	assert.Equal(t, ErrNoSignatures, VerifySignatures(keys, testDigest, nil))
+	assert.NoError(t, VerifySignatures(keys, testDigest, []Signature{good}))
+	assert.NoError(t, VerifySignatures(keys, testDigest, []Signature{edGood}))
+	// Any one verified signature will do
+	assert.NoError(t, VerifySignatures(keys, testDigest, []Signature{untrusted, good}))
+	assert.Equal(t, ErrSignatureNotTrusted, VerifySignatures(keys, testDigest, []Signature{untrusted}))
+	// A good signature, but for a different image
+	assert.Equal(t, ErrSignatureNotTrusted, VerifySignatures(keys, testDigest, []Signature{otherImage}))
+	// A tampered payload
+	tampered := Signature{Payload: append([]byte(" "), good.Payload...), Signature: good.Signature}
+	assert.Equal(t, ErrSignatureNotTrusted, VerifySignatures(keys, testDigest, []Signature{tampered}))
+}
+
+func TestLoadPublicKeys(t *testing.T) {
+	dir, err := ioutil.TempDir("", "flux-verify-keys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPath := filepath.Join(dir, "cosign.pub")
+	if err := ioutil.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	notKeyPath := filepath.Join(dir, "notakey")
+	if err := ioutil.WriteFile(notKeyPath, []byte("hello"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := LoadPublicKeys([]string{keyPath})
+	assert.NoError(t, err)
+	if assert.Len(t, keys, 1) {
+		assert.Equal(t, &key.PublicKey, keys[0])
+	}
+
+	_, err = LoadPublicKeys([]string{keyPath, notKeyPath})
+	assert.Error(t, err)
+}
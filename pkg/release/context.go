@@ -17,13 +17,15 @@ type ReleaseContext struct {
 	cluster       cluster.Cluster
 	resourceStore manifests.Store
 	registry      registry.Registry
+	verifier      update.ImageVerifier
 }
 
-func NewReleaseContext(cluster cluster.Cluster, resourceStore manifests.Store, registry registry.Registry) *ReleaseContext {
+func NewReleaseContext(cluster cluster.Cluster, resourceStore manifests.Store, registry registry.Registry, verifier update.ImageVerifier) *ReleaseContext {
 	return &ReleaseContext{
 		cluster:       cluster,
 		resourceStore: resourceStore,
 		registry:      registry,
+		verifier:      verifier,
 	}
 }
 
@@ -31,6 +33,10 @@ func (rc *ReleaseContext) Registry() registry.Registry {
 	return rc.registry
 }
 
+func (rc *ReleaseContext) ImageVerifier() update.ImageVerifier {
+	return rc.verifier
+}
+
 func (rc *ReleaseContext) GetAllResources(ctx context.Context) (map[string]resource.Resource, error) {
 	return rc.resourceStore.GetAllResourcesByID(ctx)
 }
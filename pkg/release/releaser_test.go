@@ -821,6 +821,66 @@ func Test_UpdateList(t *testing.T) {
 	}
 }
 
+func Test_ReleaseDigest(t *testing.T) {
+	egID := resource.MustParseID("default:deployment/multi-deploy")
+	egSvc := cluster.Workload{
+		ID: egID,
+		Containers: cluster.ContainersOrExcuse{
+			Containers: []resource.Container{
+				{
+					Name:  "hello",
+					Image: oldRef,
+				},
+			},
+		},
+	}
+	digest := "sha256:6f4ecf3f4c9b2a4b1b2dbdc0fc8e9fca3b7a5ab2bb4b0ac7b8c8e8d5d8c3d2e1"
+	pinnedRef := newHwRef.WithDigest(digest)
+
+	mCluster := mockCluster(hwSvc, lockedSvc, egSvc)
+	checkout, cleanup := setup(t)
+	defer cleanup()
+	rc := &ReleaseContext{
+		cluster:       mCluster,
+		resourceStore: NewManifestStoreOrFail(t, mockManifests, checkout),
+		registry: &registryMock.Registry{
+			Images: []image.Info{
+				{
+					ID:        newHwRef,
+					Digest:    digest,
+					CreatedAt: timeNow,
+				},
+			},
+		},
+	}
+	// Releasing an image by digest pins the workload to it
+	spec := update.ReleaseImageSpec{
+		ServiceSpecs: []update.ResourceSpec{"default:deployment/multi-deploy"},
+		ImageSpec:    update.ImageSpecFromRef(pinnedRef),
+		Kind:         update.ReleaseKindExecute,
+	}
+	results, err := Release(context.Background(), rc, spec, log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, update.WorkloadResult{
+		Status: update.ReleaseStatusSuccess,
+		PerContainer: []update.ContainerUpdate{{
+			Container: "hello",
+			Current:   oldRef,
+			Target:    pinnedRef,
+		}},
+	}, results[egID])
+
+	// A digest that isn't that of the tag is refused, rather than
+	// written to git
+	spec.ImageSpec = update.ImageSpecFromRef(newHwRef.WithDigest("sha256:0000000000000000000000000000000000000000000000000000000000000000"))
+	_, err = Release(context.Background(), rc, spec, log.NewNopLogger())
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), image.ErrInvalidImageID.Error())
+	}
+}
+
 func Test_UpdateContainers(t *testing.T) {
 	mCluster := mockCluster(hwSvc, lockedSvc)
 	checkout, cleanup := setup(t)
@@ -1095,3 +1155,67 @@ func Test_BadRelease(t *testing.T) {
 		t.Fatal("did not return an error, but was expected to fail verification")
 	}
 }
+
+type imageVerifierFunc func(image.Ref) (string, error)
+
+func (f imageVerifierFunc) VerifyImage(_ context.Context, ref image.Ref) (string, error) {
+	return f(ref)
+}
+
+const verifiedDigest = "sha256:1a2b3c4d5e6f708192a3b4c5d6e7f8091a2b3c4d5e6f708192a3b4c5d6e7f809"
+
+func Test_AutomatedImageVerification(t *testing.T) {
+	for _, c := range []struct {
+		name     string
+		verify   error
+		expected update.WorkloadResult
+	}{
+		{
+			name:   "verified",
+			verify: nil,
+			expected: update.WorkloadResult{
+				Status: update.ReleaseStatusSuccess,
+				PerContainer: []update.ContainerUpdate{{
+					Container: helloContainer,
+					Current:   oldRef,
+					// The image released is the one verified
+					Target: newHwRef.WithDigest(verifiedDigest),
+				}},
+			},
+		},
+		{
+			name:   "not verified",
+			verify: errors.New("no signatures found"),
+			expected: update.WorkloadResult{
+				Status: update.ReleaseStatusSkipped,
+				Error:  fmt.Sprintf(update.ImageNotVerified, newHwRef.String()+" (no signatures found)"),
+			},
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			checkout, cleanup := setup(t)
+			defer cleanup()
+			var verified []image.Ref
+			rc := &ReleaseContext{
+				cluster:       mockCluster(hwSvc),
+				resourceStore: NewManifestStoreOrFail(t, mockManifests, checkout),
+				registry:      mockRegistry,
+				verifier: imageVerifierFunc(func(ref image.Ref) (string, error) {
+					verified = append(verified, ref)
+					if c.verify != nil {
+						return "", c.verify
+					}
+					return verifiedDigest, nil
+				}),
+			}
+			changes := &update.Automated{}
+			changes.Add(hwSvcID, hwSvc.Containers.Containers[0], newHwRef)
+			results, err := Release(context.Background(), rc, changes, log.NewNopLogger())
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, []image.Ref{newHwRef}, verified)
+			assert.Equal(t, c.expected, results[hwSvcID])
+		})
+	}
+}
@@ -8,8 +8,13 @@ import (
 	"github.com/fluxcd/flux/pkg/api"
 	"github.com/fluxcd/flux/pkg/api/v10"
 	"github.com/fluxcd/flux/pkg/api/v11"
+	"github.com/fluxcd/flux/pkg/api/v12"
+	"github.com/fluxcd/flux/pkg/api/v13"
+	"github.com/fluxcd/flux/pkg/api/v14"
+	"github.com/fluxcd/flux/pkg/api/v15"
 	"github.com/fluxcd/flux/pkg/api/v6"
 	"github.com/fluxcd/flux/pkg/api/v9"
+	"github.com/fluxcd/flux/pkg/event"
 	"github.com/fluxcd/flux/pkg/job"
 	"github.com/fluxcd/flux/pkg/update"
 )
@@ -133,3 +138,39 @@ func (p *ErrorLoggingServer) NotifyChange(ctx context.Context, change v9.Change)
 	}()
 	return p.server.NotifyChange(ctx, change)
 }
+
+func (p *ErrorLoggingServer) SyncPlan(ctx context.Context, opts v12.SyncPlanOptions) (_ v12.SyncPlan, err error) {
+	defer func() {
+		if err != nil {
+			p.logger.Log("method", "SyncPlan", "error", err)
+		}
+	}()
+	return p.server.SyncPlan(ctx, opts)
+}
+
+func (p *ErrorLoggingServer) ListResources(ctx context.Context, opts v13.ListResourcesOptions) (_ []v13.ResourceStatus, err error) {
+	defer func() {
+		if err != nil {
+			p.logger.Log("method", "ListResources", "error", err)
+		}
+	}()
+	return p.server.ListResources(ctx, opts)
+}
+
+func (p *ErrorLoggingServer) Watch(ctx context.Context, opts v14.WatchOptions) (_ <-chan v14.Update, err error) {
+	defer func() {
+		if err != nil {
+			p.logger.Log("method", "Watch", "error", err)
+		}
+	}()
+	return p.server.Watch(ctx, opts)
+}
+
+func (p *ErrorLoggingServer) History(ctx context.Context, opts v15.HistoryOptions) (_ []event.Event, err error) {
+	defer func() {
+		if err != nil {
+			p.logger.Log("method", "History", "error", err)
+		}
+	}()
+	return p.server.History(ctx, opts)
+}
@@ -11,8 +11,13 @@ import (
 	"github.com/fluxcd/flux/pkg/api"
 	"github.com/fluxcd/flux/pkg/api/v10"
 	"github.com/fluxcd/flux/pkg/api/v11"
+	"github.com/fluxcd/flux/pkg/api/v12"
+	"github.com/fluxcd/flux/pkg/api/v13"
+	"github.com/fluxcd/flux/pkg/api/v14"
+	"github.com/fluxcd/flux/pkg/api/v15"
 	"github.com/fluxcd/flux/pkg/api/v6"
 	"github.com/fluxcd/flux/pkg/api/v9"
+	"github.com/fluxcd/flux/pkg/event"
 	"github.com/fluxcd/flux/pkg/job"
 	fluxmetrics "github.com/fluxcd/flux/pkg/metrics"
 	"github.com/fluxcd/flux/pkg/update"
@@ -157,3 +162,43 @@ func (i *instrumentedServer) NotifyChange(ctx context.Context, change v9.Change)
 	}(time.Now())
 	return i.s.NotifyChange(ctx, change)
 }
+
+func (i *instrumentedServer) SyncPlan(ctx context.Context, opts v12.SyncPlanOptions) (_ v12.SyncPlan, err error) {
+	defer func(begin time.Time) {
+		requestDuration.With(
+			fluxmetrics.LabelMethod, "SyncPlan",
+			fluxmetrics.LabelSuccess, fmt.Sprint(err == nil),
+		).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+	return i.s.SyncPlan(ctx, opts)
+}
+
+func (i *instrumentedServer) ListResources(ctx context.Context, opts v13.ListResourcesOptions) (_ []v13.ResourceStatus, err error) {
+	defer func(begin time.Time) {
+		requestDuration.With(
+			fluxmetrics.LabelMethod, "ListResources",
+			fluxmetrics.LabelSuccess, fmt.Sprint(err == nil),
+		).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+	return i.s.ListResources(ctx, opts)
+}
+
+func (i *instrumentedServer) Watch(ctx context.Context, opts v14.WatchOptions) (_ <-chan v14.Update, err error) {
+	defer func(begin time.Time) {
+		requestDuration.With(
+			fluxmetrics.LabelMethod, "Watch",
+			fluxmetrics.LabelSuccess, fmt.Sprint(err == nil),
+		).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+	return i.s.Watch(ctx, opts)
+}
+
+func (i *instrumentedServer) History(ctx context.Context, opts v15.HistoryOptions) (_ []event.Event, err error) {
+	defer func(begin time.Time) {
+		requestDuration.With(
+			fluxmetrics.LabelMethod, "History",
+			fluxmetrics.LabelSuccess, fmt.Sprint(err == nil),
+		).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+	return i.s.History(ctx, opts)
+}
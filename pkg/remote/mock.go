@@ -11,8 +11,13 @@ import (
 	"github.com/fluxcd/flux/pkg/api"
 	"github.com/fluxcd/flux/pkg/api/v10"
 	"github.com/fluxcd/flux/pkg/api/v11"
+	"github.com/fluxcd/flux/pkg/api/v12"
+	"github.com/fluxcd/flux/pkg/api/v13"
+	"github.com/fluxcd/flux/pkg/api/v14"
+	"github.com/fluxcd/flux/pkg/api/v15"
 	"github.com/fluxcd/flux/pkg/api/v6"
 	"github.com/fluxcd/flux/pkg/api/v9"
+	"github.com/fluxcd/flux/pkg/event"
 	"github.com/fluxcd/flux/pkg/guid"
 	"github.com/fluxcd/flux/pkg/image"
 	"github.com/fluxcd/flux/pkg/job"
@@ -49,6 +54,18 @@ type MockServer struct {
 
 	GitRepoConfigAnswer v6.GitConfig
 	GitRepoConfigError  error
+
+	SyncPlanAnswer v12.SyncPlan
+	SyncPlanError  error
+
+	ListResourcesAnswer []v13.ResourceStatus
+	ListResourcesError  error
+
+	WatchAnswer []v14.Update
+	WatchError  error
+
+	HistoryAnswer []event.Event
+	HistoryError  error
 }
 
 func (p *MockServer) Ping(ctx context.Context) error {
@@ -104,6 +121,32 @@ func (p *MockServer) GitRepoConfig(ctx context.Context, regenerate bool) (v6.Git
 	return p.GitRepoConfigAnswer, p.GitRepoConfigError
 }
 
+func (p *MockServer) SyncPlan(context.Context, v12.SyncPlanOptions) (v12.SyncPlan, error) {
+	return p.SyncPlanAnswer, p.SyncPlanError
+}
+
+func (p *MockServer) ListResources(context.Context, v13.ListResourcesOptions) ([]v13.ResourceStatus, error) {
+	return p.ListResourcesAnswer, p.ListResourcesError
+}
+
+// Watch sends the updates given in WatchAnswer, then closes the
+// channel.
+func (p *MockServer) Watch(context.Context, v14.WatchOptions) (<-chan v14.Update, error) {
+	if p.WatchError != nil {
+		return nil, p.WatchError
+	}
+	updates := make(chan v14.Update, len(p.WatchAnswer))
+	for _, u := range p.WatchAnswer {
+		updates <- u
+	}
+	close(updates)
+	return updates, nil
+}
+
+func (p *MockServer) History(context.Context, v15.HistoryOptions) ([]event.Event, error) {
+	return p.HistoryAnswer, p.HistoryError
+}
+
 var _ api.Server = &MockServer{}
 
 // -- Battery of tests for an api.Server implementation. Since these
@@ -8,8 +8,13 @@ import (
 	"github.com/fluxcd/flux/pkg/api"
 	"github.com/fluxcd/flux/pkg/api/v10"
 	"github.com/fluxcd/flux/pkg/api/v11"
+	"github.com/fluxcd/flux/pkg/api/v12"
+	"github.com/fluxcd/flux/pkg/api/v13"
+	"github.com/fluxcd/flux/pkg/api/v14"
+	"github.com/fluxcd/flux/pkg/api/v15"
 	"github.com/fluxcd/flux/pkg/api/v6"
 	"github.com/fluxcd/flux/pkg/api/v9"
+	"github.com/fluxcd/flux/pkg/event"
 	"github.com/fluxcd/flux/pkg/job"
 	"github.com/fluxcd/flux/pkg/remote"
 	"github.com/fluxcd/flux/pkg/update"
@@ -67,3 +72,19 @@ func (bc baseClient) SyncStatus(context.Context, string) ([]string, error) {
 func (bc baseClient) GitRepoConfig(context.Context, bool) (v6.GitConfig, error) {
 	return v6.GitConfig{}, remote.UpgradeNeededError(errors.New("GitRepoConfig method not implemented"))
 }
+
+func (bc baseClient) SyncPlan(context.Context, v12.SyncPlanOptions) (v12.SyncPlan, error) {
+	return v12.SyncPlan{}, remote.UpgradeNeededError(errors.New("SyncPlan method not implemented"))
+}
+
+func (bc baseClient) ListResources(context.Context, v13.ListResourcesOptions) ([]v13.ResourceStatus, error) {
+	return nil, remote.UpgradeNeededError(errors.New("ListResources method not implemented"))
+}
+
+func (bc baseClient) Watch(context.Context, v14.WatchOptions) (<-chan v14.Update, error) {
+	return nil, remote.UpgradeNeededError(errors.New("Watch method not implemented"))
+}
+
+func (bc baseClient) History(context.Context, v15.HistoryOptions) ([]event.Event, error) {
+	return nil, remote.UpgradeNeededError(errors.New("History method not implemented"))
+}
@@ -0,0 +1,188 @@
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cron is a parsed cron expression, of the usual five fields: minute,
+// hour, day of month, month, and day of week. Each field is a set of
+// the values it matches.
+type cron struct {
+	minute, hour, dom, month, dow uint64
+	// As with cron, if both the day of month and day of week are
+	// restricted, a day matching either will do.
+	domAny, dowAny bool
+}
+
+type cronField struct {
+	min, max int
+	names    []string // names for the values, starting at min
+}
+
+var (
+	minuteField = cronField{min: 0, max: 59}
+	hourField   = cronField{min: 0, max: 23}
+	domField    = cronField{min: 1, max: 31}
+	monthField  = cronField{min: 1, max: 12, names: []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}}
+	// 7 is Sunday as well as 0
+	dowField = cronField{min: 0, max: 7, names: []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}}
+)
+
+// parseCron parses a cron expression, e.g., `0 9 * * mon-fri`. Each
+// field may be `*`, a value, a range `a-b`, any of those with a step
+// (`*/15`, `0-30/10`), or a list of those separated by commas.
+func parseCron(expr string) (*cron, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression %q: expected five fields (minute hour day-of-month month day-of-week), got %d", expr, len(fields))
+	}
+	var c cron
+	var err error
+	if c.minute, err = minuteField.parse(fields[0]); err != nil {
+		return nil, fmt.Errorf("cron expression %q: minute: %s", expr, err)
+	}
+	if c.hour, err = hourField.parse(fields[1]); err != nil {
+		return nil, fmt.Errorf("cron expression %q: hour: %s", expr, err)
+	}
+	if c.dom, err = domField.parse(fields[2]); err != nil {
+		return nil, fmt.Errorf("cron expression %q: day of month: %s", expr, err)
+	}
+	if c.month, err = monthField.parse(fields[3]); err != nil {
+		return nil, fmt.Errorf("cron expression %q: month: %s", expr, err)
+	}
+	if c.dow, err = dowField.parse(fields[4]); err != nil {
+		return nil, fmt.Errorf("cron expression %q: day of week: %s", expr, err)
+	}
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1 << 0
+	}
+	c.domAny = fields[2] == "*"
+	c.dowAny = fields[4] == "*"
+	return &c, nil
+}
+
+func (f cronField) parse(s string) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(s, ",") {
+		rng, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			rng = part[:i]
+			if step, err = strconv.Atoi(part[i+1:]); err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step in %q", part)
+			}
+		}
+		lo, hi := f.min, f.max
+		if rng != "*" {
+			var err error
+			bounds := strings.SplitN(rng, "-", 2)
+			if lo, err = f.value(bounds[0]); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if len(bounds) == 2 {
+				if hi, err = f.value(bounds[1]); err != nil {
+					return 0, err
+				}
+			} else if step > 1 {
+				// `a/n` means from a to the end, every n
+				hi = f.max
+			}
+			if hi < lo {
+				return 0, fmt.Errorf("range %q is backwards", rng)
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			set |= 1 << uint(v)
+		}
+	}
+	return set, nil
+}
+
+func (f cronField) value(s string) (int, error) {
+	for i, name := range f.names {
+		if strings.EqualFold(s, name) {
+			return f.min + i, nil
+		}
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("%q is not a value from %d to %d", s, f.min, f.max)
+	}
+	return v, nil
+}
+
+func (c *cron) matchesDay(t time.Time) bool {
+	if c.month&(1<<uint(t.Month())) == 0 {
+		return false
+	}
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	switch {
+	case c.domAny && c.dowAny:
+		return true
+	case c.domAny:
+		return dow
+	case c.dowAny:
+		return dom
+	default:
+		return dom || dow
+	}
+}
+
+func (c *cron) matchesHour(t time.Time) bool {
+	return c.hour&(1<<uint(t.Hour())) != 0
+}
+
+func (c *cron) matchesMinute(t time.Time) bool {
+	return c.minute&(1<<uint(t.Minute())) != 0
+}
+
+// next returns the first time the expression matches at or after the
+// time given (to the minute), as long as it's no later than the limit
+// given.
+func (c *cron) next(t, limit time.Time) (time.Time, bool) {
+	loc := t.Location()
+	if t.Second() != 0 || t.Nanosecond() != 0 {
+		t = t.Truncate(time.Minute).Add(time.Minute)
+	}
+	for !t.After(limit) {
+		y, m, d := t.Date()
+		switch {
+		case !c.matchesDay(t):
+			t = time.Date(y, m, d+1, 0, 0, 0, 0, loc)
+		case !c.matchesHour(t):
+			t = time.Date(y, m, d, t.Hour()+1, 0, 0, 0, loc)
+		case !c.matchesMinute(t):
+			t = t.Add(time.Minute)
+		default:
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// prev returns the last time the expression matches at or before the
+// time given (to the minute), as long as it's no earlier than the
+// limit given.
+func (c *cron) prev(t, limit time.Time) (time.Time, bool) {
+	loc := t.Location()
+	t = t.Truncate(time.Minute)
+	for !t.Before(limit) {
+		y, m, d := t.Date()
+		switch {
+		case !c.matchesDay(t):
+			t = time.Date(y, m, d, 0, 0, 0, 0, loc).Add(-time.Minute)
+		case !c.matchesHour(t):
+			t = time.Date(y, m, d, t.Hour(), 0, 0, 0, loc).Add(-time.Minute)
+		case !c.matchesMinute(t):
+			t = t.Add(-time.Minute)
+		default:
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
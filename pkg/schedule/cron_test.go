@@ -0,0 +1,106 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func mustTime(s string) time.Time {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestParseCron(t *testing.T) {
+	for _, expr := range []string{
+		"* * * * *",
+		"0 9 * * mon-fri",
+		"*/15 0-6,22-23 1 jan,JUL 0",
+		"0 0 * * 7",
+		"30 2/4 * * *",
+	} {
+		_, err := parseCron(expr)
+		assert.NoError(t, err, expr)
+	}
+
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"* * * * fri-mon",
+		"*/0 * * * *",
+		"a * * * *",
+	} {
+		_, err := parseCron(expr)
+		assert.Error(t, err, expr)
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	for _, c := range []struct {
+		expr, from, next string
+	}{
+		// at or after the time given
+		{"0 9 * * *", "2020-06-01T09:00:00Z", "2020-06-01T09:00:00Z"},
+		{"0 9 * * *", "2020-06-01T09:00:01Z", "2020-06-02T09:00:00Z"},
+		{"*/20 * * * *", "2020-06-01T09:41:00Z", "2020-06-01T10:00:00Z"},
+		// 2020-06-06 is a Saturday
+		{"30 8 * * mon-fri", "2020-06-06T00:00:00Z", "2020-06-08T08:30:00Z"},
+		{"0 0 * * 7", "2020-06-01T00:00:00Z", "2020-06-07T00:00:00Z"},
+		// either day of month or day of week, when both are given
+		{"0 0 15 * fri", "2020-06-06T00:00:00Z", "2020-06-12T00:00:00Z"},
+		{"0 0 15 * fri", "2020-06-13T00:00:00Z", "2020-06-15T00:00:00Z"},
+		{"0 0 29 feb *", "2020-03-01T00:00:00Z", "2024-02-29T00:00:00Z"},
+	} {
+		cron, err := parseCron(c.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		next, ok := cron.next(mustTime(c.from), mustTime(c.from).Add(5*366*24*time.Hour))
+		assert.True(t, ok, c.expr)
+		assert.Equal(t, mustTime(c.next), next, c.expr+" from "+c.from)
+	}
+
+	cron, _ := parseCron("0 0 29 feb *")
+	_, ok := cron.next(mustTime("2020-03-01T00:00:00Z"), mustTime("2021-03-01T00:00:00Z"))
+	assert.False(t, ok)
+}
+
+func TestCronPrev(t *testing.T) {
+	for _, c := range []struct {
+		expr, from, prev string
+	}{
+		{"0 9 * * *", "2020-06-01T09:00:00Z", "2020-06-01T09:00:00Z"},
+		{"0 9 * * *", "2020-06-01T08:59:59Z", "2020-05-31T09:00:00Z"},
+		{"*/20 * * * *", "2020-06-01T09:19:59Z", "2020-06-01T09:00:00Z"},
+		{"30 8 * * mon-fri", "2020-06-07T12:00:00Z", "2020-06-05T08:30:00Z"},
+	} {
+		cron, err := parseCron(c.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		prev, ok := cron.prev(mustTime(c.from), mustTime(c.from).Add(-7*24*time.Hour))
+		assert.True(t, ok, c.expr)
+		assert.Equal(t, mustTime(c.prev), prev, c.expr+" from "+c.from)
+	}
+}
+
+func TestCronLocation(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+	cron, _ := parseCron("0 9 * * *")
+	next, ok := cron.next(mustTime("2020-06-01T12:00:00Z").In(loc), mustTime("2020-06-03T00:00:00Z"))
+	assert.True(t, ok)
+	assert.Equal(t, mustTime("2020-06-01T13:00:00Z"), next.UTC())
+}
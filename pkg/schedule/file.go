@@ -0,0 +1,58 @@
+package schedule
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+)
+
+// File is a schedule kept in a file, e.g., mounted from a ConfigMap.
+// The file is read again whenever it changes, so the schedule can be
+// changed without restarting fluxd.
+type File struct {
+	Path   string
+	Logger log.Logger
+
+	mu       sync.Mutex
+	modTime  time.Time
+	schedule *Schedule
+}
+
+// Schedule returns the schedule in the file, reading it again if it's
+// changed since it was last read. If the file can't be read, or
+// doesn't parse, the schedule last read successfully is returned. A nil *File has
+// no schedule.
+func (f *File) Schedule() *Schedule {
+	if f == nil {
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	info, err := os.Stat(f.Path)
+	if err != nil {
+		f.log("err", err)
+		return f.schedule
+	}
+	if info.ModTime().Equal(f.modTime) {
+		return f.schedule
+	}
+	// Whether or not it parses, don't read it again until it changes
+	f.modTime = info.ModTime()
+	s, err := Load(f.Path)
+	if err != nil {
+		f.log("err", err)
+		return f.schedule
+	}
+	f.schedule = s
+	f.log("info", "loaded schedule")
+	return f.schedule
+}
+
+func (f *File) log(keyvals ...interface{}) {
+	if f.Logger != nil {
+		f.Logger.Log(append([]interface{}{"path", f.Path}, keyvals...)...)
+	}
+}
@@ -0,0 +1,305 @@
+// Package schedule decides when automated releases (and, optionally,
+// syncs) may happen, according to maintenance windows and release
+// freezes.
+//
+// A schedule is given as YAML, e.g.,
+//
+//	timezone: Europe/London
+//	# if any windows are given, automated releases happen only
+//	# during a window
+//	windows:
+//	- cron: "0 9 * * mon-thu"
+//	  duration: 8h
+//	# automated releases never happen during a freeze
+//	freezes:
+//	- start: 2020-12-18T17:00:00Z
+//	  end: 2021-01-04T09:00:00Z
+//	  reason: end of year
+//	# syncs are held too
+//	sync: true
+//	# other schedules, which workloads can be given with the
+//	# annotation `fluxcd.io/schedule: <name>`
+//	schedules:
+//	  overnight:
+//	    windows:
+//	    - cron: "0 1 * * *"
+//	      duration: 4h
+package schedule
+
+import (
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+)
+
+// None is the name to give a workload which is to be exempt from any
+// schedule.
+const None = "none"
+
+// How far ahead to look for the next window
+const horizon = 366 * 24 * time.Hour
+
+// How many freezes (and times outside windows) to follow one into
+// another, to find when things are unfrozen
+const maxChain = 100
+
+// Config is the schedule configuration, usually loaded from a file
+// given to fluxd.
+type Config struct {
+	// Timezone is the (IANA) time zone in which cron expressions are
+	// interpreted; it defaults to UTC.
+	Timezone string `json:"timezone,omitempty"`
+	// Sync, if true, means syncs are held at the same times as
+	// automated releases under the default schedule.
+	Sync bool `json:"sync,omitempty"`
+	PeriodsConfig
+	// Schedules are named alternatives to the default schedule.
+	Schedules map[string]PeriodsConfig `json:"schedules,omitempty"`
+}
+
+// PeriodsConfig gives the windows and freezes of a schedule.
+type PeriodsConfig struct {
+	// Windows, if any are given, are the only times automated
+	// releases may happen.
+	Windows []PeriodConfig `json:"windows,omitempty"`
+	// Freezes are times automated releases may not happen.
+	Freezes []PeriodConfig `json:"freezes,omitempty"`
+}
+
+// PeriodConfig is a period of time, given either as a cron
+// expression for when it starts, and a duration; or as a start and
+// an end.
+type PeriodConfig struct {
+	Cron     string     `json:"cron,omitempty"`
+	Duration string     `json:"duration,omitempty"`
+	Start    *time.Time `json:"start,omitempty"`
+	End      *time.Time `json:"end,omitempty"`
+	// Reason is reported while a freeze is in effect.
+	Reason string `json:"reason,omitempty"`
+}
+
+// Freeze says why things are held, and until when.
+type Freeze struct {
+	// Until is when the freeze ends; if zero, it's not known when
+	// (e.g., there's no window in the foreseeable future).
+	Until  time.Time
+	Reason string
+}
+
+// Schedule is a parsed and validated schedule. A nil *Schedule never
+// holds anything.
+type Schedule struct {
+	loc       *time.Location
+	sync      bool
+	windows   []period
+	freezes   []period
+	schedules map[string]*Schedule
+}
+
+type period struct {
+	cron       *cron
+	duration   time.Duration
+	start, end time.Time
+	reason     string
+}
+
+// Load reads a schedule from the file at the path given.
+func Load(path string) (*Schedule, error) {
+	bytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(bytes)
+}
+
+// Parse parses and validates a schedule given as YAML (or JSON).
+func Parse(bytes []byte) (*Schedule, error) {
+	var config Config
+	if err := yaml.Unmarshal(bytes, &config); err != nil {
+		return nil, errors.Wrap(err, "parsing schedule")
+	}
+	return New(config)
+}
+
+// New validates the configuration given, and makes a schedule of it.
+func New(config Config) (*Schedule, error) {
+	loc := time.UTC
+	if config.Timezone != "" {
+		var err error
+		if loc, err = time.LoadLocation(config.Timezone); err != nil {
+			return nil, errors.Wrap(err, "schedule timezone")
+		}
+	}
+	s, err := newSchedule(loc, config.PeriodsConfig)
+	if err != nil {
+		return nil, err
+	}
+	s.sync = config.Sync
+	s.schedules = map[string]*Schedule{}
+	for name, periods := range config.Schedules {
+		if name == None || name == "" {
+			return nil, fmt.Errorf("schedule %q: name is reserved", name)
+		}
+		if s.schedules[name], err = newSchedule(loc, periods); err != nil {
+			return nil, errors.Wrapf(err, "schedule %q", name)
+		}
+	}
+	return s, nil
+}
+
+func newSchedule(loc *time.Location, config PeriodsConfig) (*Schedule, error) {
+	s := &Schedule{loc: loc}
+	for i, w := range config.Windows {
+		p, err := newPeriod(w)
+		if err != nil {
+			return nil, errors.Wrapf(err, "window %d", i)
+		}
+		s.windows = append(s.windows, p)
+	}
+	for i, f := range config.Freezes {
+		p, err := newPeriod(f)
+		if err != nil {
+			return nil, errors.Wrapf(err, "freeze %d", i)
+		}
+		if p.reason == "" {
+			p.reason = "release freeze"
+		}
+		s.freezes = append(s.freezes, p)
+	}
+	return s, nil
+}
+
+func newPeriod(config PeriodConfig) (period, error) {
+	p := period{reason: config.Reason}
+	switch {
+	case config.Cron != "" && config.Start == nil && config.End == nil:
+		var err error
+		if p.cron, err = parseCron(config.Cron); err != nil {
+			return p, err
+		}
+		if p.duration, err = time.ParseDuration(config.Duration); err != nil {
+			return p, errors.Wrap(err, "duration")
+		}
+		if p.duration <= 0 {
+			return p, errors.New("duration must be positive")
+		}
+	case config.Cron == "" && config.Start != nil && config.End != nil:
+		if config.Duration != "" {
+			return p, errors.New("duration is only used with cron")
+		}
+		p.start, p.end = *config.Start, *config.End
+		if !p.end.After(p.start) {
+			return p, errors.New("end must be after start")
+		}
+	default:
+		return p, errors.New("expected either cron and duration, or start and end")
+	}
+	return p, nil
+}
+
+// For returns the schedule given by name, for a workload annotated
+// with it: "" is the default schedule, and None is no schedule (i.e.,
+// nil). If there's no schedule with the name given, it returns the
+// default schedule, and an error.
+func (s *Schedule) For(name string) (*Schedule, error) {
+	switch {
+	case s == nil || name == None:
+		return nil, nil
+	case name == "":
+		return s, nil
+	}
+	if named, ok := s.schedules[name]; ok {
+		return named, nil
+	}
+	return s, fmt.Errorf("no schedule named %q", name)
+}
+
+// FrozenAt says whether automated releases are held at the time
+// given, and if so, why and until when.
+func (s *Schedule) FrozenAt(t time.Time) (Freeze, bool) {
+	if s == nil {
+		return Freeze{}, false
+	}
+	t = t.In(s.loc)
+	var freeze Freeze
+	var frozen bool
+	// One freeze may run into another, or into a time outside the
+	// windows; report when it all ends.
+	for i := 0; i < maxChain; i++ {
+		until, reason, held := s.heldAt(t)
+		if !held {
+			break
+		}
+		if !frozen {
+			freeze.Reason, frozen = reason, true
+		}
+		freeze.Until = until
+		if until.IsZero() {
+			break
+		}
+		t = until
+	}
+	return freeze, frozen
+}
+
+// SyncFrozenAt says whether syncs are held at the time given, and if
+// so, why and until when.
+func (s *Schedule) SyncFrozenAt(t time.Time) (Freeze, bool) {
+	if s == nil || !s.sync {
+		return Freeze{}, false
+	}
+	return s.FrozenAt(t)
+}
+
+// heldAt returns when the freeze (or time outside a window) at the
+// time given ends, if there is one.
+func (s *Schedule) heldAt(t time.Time) (time.Time, string, bool) {
+	var until time.Time
+	var reason string
+	for _, f := range s.freezes {
+		if end, ok := f.containing(t); ok && end.After(until) {
+			until, reason = end, f.reason
+		}
+	}
+	if !until.IsZero() || len(s.windows) == 0 {
+		return until, reason, !until.IsZero()
+	}
+
+	for _, w := range s.windows {
+		if _, ok := w.containing(t); ok {
+			return time.Time{}, "", false
+		}
+	}
+	for _, w := range s.windows {
+		if start, ok := w.next(t); ok && (until.IsZero() || start.Before(until)) {
+			until = start
+		}
+	}
+	return until, "outside maintenance windows", true
+}
+
+// containing returns the end of the occurrence of the period which
+// contains the time given, if there is one.
+func (p period) containing(t time.Time) (time.Time, bool) {
+	if p.cron == nil {
+		return p.end, !t.Before(p.start) && t.Before(p.end)
+	}
+	start, ok := p.cron.prev(t, t.Add(-p.duration))
+	if !ok {
+		return time.Time{}, false
+	}
+	end := start.Add(p.duration)
+	return end, end.After(t)
+}
+
+// next returns the start of the next occurrence of the period after
+// the time given, if there is one in the foreseeable future.
+func (p period) next(t time.Time) (time.Time, bool) {
+	if p.cron == nil {
+		return p.start, p.start.After(t)
+	}
+	return p.cron.next(t.Add(time.Nanosecond), t.Add(horizon))
+}
@@ -0,0 +1,191 @@
+package schedule
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func mustPeriod(start, end string) PeriodConfig {
+	s, e := mustTime(start), mustTime(end)
+	return PeriodConfig{Start: &s, End: &e}
+}
+
+func TestParse(t *testing.T) {
+	s, err := Parse([]byte(`
+timezone: Europe/London
+sync: true
+windows:
+- cron: "0 9 * * mon-thu"
+  duration: 8h
+freezes:
+- start: 2020-12-18T17:00:00Z
+  end: 2021-01-04T09:00:00Z
+  reason: end of year
+schedules:
+  overnight:
+    windows:
+    - cron: "0 1 * * *"
+      duration: 4h
+`))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.True(t, s.sync)
+	assert.Len(t, s.windows, 1)
+	assert.Len(t, s.freezes, 1)
+	assert.Equal(t, "end of year", s.freezes[0].reason)
+	assert.Len(t, s.schedules["overnight"].windows, 1)
+
+	for _, bad := range []string{
+		`timezone: Middle/Earth`,
+		`windows: [{cron: "0 9 * * *"}]`,
+		`windows: [{cron: "0 9 * * *", duration: -1h}]`,
+		`windows: [{cron: "0 9 * *", duration: 1h}]`,
+		`freezes: [{start: 2021-01-04T09:00:00Z, end: 2020-12-18T17:00:00Z}]`,
+		`freezes: [{start: 2020-12-18T17:00:00Z}]`,
+		`freezes: [{cron: "0 9 * * *", duration: 1h, start: 2020-12-18T17:00:00Z}]`,
+		`schedules: {none: {}}`,
+	} {
+		_, err := Parse([]byte(bad))
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestFrozenAt(t *testing.T) {
+	s, err := New(Config{
+		PeriodsConfig: PeriodsConfig{
+			// weekdays, 09:00-17:00
+			Windows: []PeriodConfig{{Cron: "0 9 * * mon-fri", Duration: "8h"}},
+			Freezes: []PeriodConfig{
+				mustPeriod("2020-06-10T12:00:00Z", "2020-06-10T15:00:00Z"),
+				// runs into the next freeze, and then past the window
+				{Cron: "0 12 * * thu", Duration: "2h", Reason: "lunch"},
+				{Cron: "0 13 * * thu", Duration: "6h", Reason: "afternoon"},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 2020-06-08 is a Monday
+	for _, c := range []struct {
+		at     string
+		frozen bool
+		until  string
+		reason string
+	}{
+		{at: "2020-06-08T10:00:00Z"},
+		{at: "2020-06-08T09:00:00Z"},
+		{"2020-06-08T17:00:00Z", true, "2020-06-09T09:00:00Z", "outside maintenance windows"},
+		{"2020-06-06T10:00:00Z", true, "2020-06-08T09:00:00Z", "outside maintenance windows"},
+		{"2020-06-10T12:00:00Z", true, "2020-06-10T15:00:00Z", "release freeze"},
+		{at: "2020-06-10T15:00:00Z"},
+		{"2020-06-11T12:30:00Z", true, "2020-06-12T09:00:00Z", "lunch"},
+		{at: "2020-06-11T11:59:00Z"},
+	} {
+		freeze, frozen := s.FrozenAt(mustTime(c.at))
+		assert.Equal(t, c.frozen, frozen, c.at)
+		if c.frozen {
+			assert.Equal(t, mustTime(c.until), freeze.Until.UTC(), c.at)
+			assert.Equal(t, c.reason, freeze.Reason, c.at)
+		}
+	}
+
+	// syncs aren't held unless asked for
+	_, frozen := s.SyncFrozenAt(mustTime("2020-06-08T17:00:00Z"))
+	assert.False(t, frozen)
+	s.sync = true
+	_, frozen = s.SyncFrozenAt(mustTime("2020-06-08T17:00:00Z"))
+	assert.True(t, frozen)
+}
+
+func TestFrozenAtNoWindow(t *testing.T) {
+	s, err := New(Config{PeriodsConfig: PeriodsConfig{
+		Windows: []PeriodConfig{mustPeriod("2020-06-01T00:00:00Z", "2020-06-02T00:00:00Z")},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	freeze, frozen := s.FrozenAt(mustTime("2020-06-03T00:00:00Z"))
+	assert.True(t, frozen)
+	assert.True(t, freeze.Until.IsZero())
+}
+
+func TestFor(t *testing.T) {
+	s, err := New(Config{
+		PeriodsConfig: PeriodsConfig{
+			Freezes: []PeriodConfig{mustPeriod("2020-06-01T00:00:00Z", "2020-06-02T00:00:00Z")},
+		},
+		Schedules: map[string]PeriodsConfig{"other": {}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := mustTime("2020-06-01T12:00:00Z")
+
+	def, err := s.For("")
+	assert.NoError(t, err)
+	_, frozen := def.FrozenAt(at)
+	assert.True(t, frozen)
+
+	other, err := s.For("other")
+	assert.NoError(t, err)
+	_, frozen = other.FrozenAt(at)
+	assert.False(t, frozen)
+
+	none, err := s.For(None)
+	assert.NoError(t, err)
+	_, frozen = none.FrozenAt(at)
+	assert.False(t, frozen)
+
+	// an unknown schedule falls back to the default, so a typo doesn't
+	// let releases through
+	unknown, err := s.For("typo")
+	assert.Error(t, err)
+	_, frozen = unknown.FrozenAt(at)
+	assert.True(t, frozen)
+
+	var nothing *Schedule
+	_, frozen = nothing.FrozenAt(at)
+	assert.False(t, frozen)
+}
+
+func TestFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "flux-schedule")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "schedule.yaml")
+	f := &File{Path: path}
+
+	// no file (yet), no schedule
+	assert.True(t, f.Schedule() == nil)
+
+	write := func(content string, modTime time.Time) {
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(`freezes: [{start: 2020-06-01T00:00:00Z, end: 2020-06-02T00:00:00Z}]`, mustTime("2020-01-01T00:00:00Z"))
+	s := f.Schedule()
+	assert.True(t, s != nil)
+	assert.True(t, s == f.Schedule())
+
+	// a bad schedule leaves the last one in place
+	write(`freezes: [{start: 2020-06-01T00:00:00Z}]`, mustTime("2020-01-02T00:00:00Z"))
+	assert.True(t, s == f.Schedule())
+
+	write(`windows: [{cron: "0 9 * * *", duration: 1h}]`, mustTime("2020-01-03T00:00:00Z"))
+	assert.True(t, s != f.Schedule())
+	assert.Len(t, f.Schedule().windows, 1)
+}
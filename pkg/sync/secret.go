@@ -18,6 +18,7 @@ type NativeSyncProvider struct {
 	namespace    string
 	revision     string
 	resourceName string
+	markerKey    string
 	resourceAPI  v1.SecretInterface
 }
 
@@ -37,10 +38,22 @@ func NewNativeSyncProvider(namespace string, resourceName string) (NativeSyncPro
 		resourceAPI:  clientset.CoreV1().Secrets(namespace),
 		namespace:    namespace,
 		resourceName: resourceName,
+		markerKey:    syncMarkerKey,
 	}, nil
 }
 
+// WithSource returns a copy of the NativeSyncProvider which records
+// the sync marker for the named git source, under an annotation of
+// its own, so that several sources can share the same secret.
+func (p NativeSyncProvider) WithSource(name string) NativeSyncProvider {
+	p.markerKey = syncMarkerKey + "-" + name
+	return p
+}
+
 func (p NativeSyncProvider) String() string {
+	if p.markerKey != syncMarkerKey {
+		return "kubernetes " + p.namespace + ":secret/" + p.resourceName + " annotation " + p.markerKey
+	}
 	return "kubernetes " + p.namespace + ":secret/" + p.resourceName
 }
 
@@ -50,7 +63,7 @@ func (p NativeSyncProvider) GetRevision(ctx context.Context) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	revision, exists := resource.Annotations[syncMarkerKey]
+	revision, exists := resource.Annotations[p.markerKey]
 	if !exists {
 		return "", p.setRevision("")
 	}
@@ -68,7 +81,7 @@ func (p NativeSyncProvider) DeleteMarker(ctx context.Context) error {
 }
 
 func (p NativeSyncProvider) setRevision(revision string) error {
-	jsonPatch, err := json.Marshal(patch(revision))
+	jsonPatch, err := json.Marshal(patch(p.markerKey, revision))
 	if err != nil {
 		return err
 	}
@@ -81,11 +94,11 @@ func (p NativeSyncProvider) setRevision(revision string) error {
 	return err
 }
 
-func patch(revision string) map[string]map[string]map[string]string {
+func patch(markerKey, revision string) map[string]map[string]map[string]string {
 	return map[string]map[string]map[string]string{
 		"metadata": map[string]map[string]string{
 			"annotations": map[string]string{
-				syncMarkerKey: revision,
+				markerKey: revision,
 			},
 		},
 	}
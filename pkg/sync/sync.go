@@ -19,6 +19,25 @@ func Sync(setName string, repoResources map[string]resource.Resource, clus Synce
 	return nil
 }
 
+// Planner can work out what a sync would do to the cluster, without
+// changing anything.
+type Planner interface {
+	PlanSync(cluster.SyncSet) ([]cluster.ResourcePlan, error)
+}
+
+// Plan reports what synchronising the cluster to the files under a
+// directory would do, without doing it.
+func Plan(setName string, repoResources map[string]resource.Resource, clus Planner) ([]cluster.ResourcePlan, error) {
+	return clus.PlanSync(makeSet(setName, repoResources))
+}
+
+// DriftReporter can say what drift was found in the cluster during the
+// last sync of a sync set; i.e., which resources had been changed in
+// the cluster since they were last applied.
+type DriftReporter interface {
+	Drifted(syncSetName string) []cluster.ResourceDrift
+}
+
 func makeSet(name string, repoResources map[string]resource.Resource) cluster.SyncSet {
 	s := cluster.SyncSet{Name: name}
 	var resources []resource.Resource
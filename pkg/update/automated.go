@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/go-kit/kit/log"
 
@@ -41,7 +42,7 @@ func (a *Automated) CalculateRelease(ctx context.Context, rc ReleaseContext, log
 	}
 
 	a.markSkipped(result)
-	updates, err = a.calculateImageUpdates(rc, updates, result, logger)
+	updates, err = a.calculateImageUpdates(ctx, rc, updates, result, logger)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -88,8 +89,9 @@ func (a *Automated) markSkipped(results Result) {
 	}
 }
 
-func (a *Automated) calculateImageUpdates(rc ReleaseContext, candidates []*WorkloadUpdate, result Result, logger log.Logger) ([]*WorkloadUpdate, error) {
+func (a *Automated) calculateImageUpdates(ctx context.Context, rc ReleaseContext, candidates []*WorkloadUpdate, result Result, logger log.Logger) ([]*WorkloadUpdate, error) {
 	updates := []*WorkloadUpdate{}
+	verifier := rc.ImageVerifier()
 
 	workloadMap := a.workloadMap()
 	for _, u := range candidates {
@@ -108,10 +110,11 @@ func (a *Automated) calculateImageUpdates(rc ReleaseContext, candidates []*Workl
 					continue
 				}
 
-				// We transplant the tag here, to make sure we keep
-				// the format of the image name as it is in the
-				// resource (e.g., to avoid canonicalising it)
-				newImageID := currentImageID.WithNewTag(change.ImageID.Tag)
+				// We transplant the tag (and digest, if pinned) here,
+				// to make sure we keep the format of the image name
+				// as it is in the resource (e.g., to avoid
+				// canonicalising it)
+				newImageID := currentImageID.WithNewTag(change.ImageID.Tag).WithDigest(change.ImageID.Digest)
 				containerUpdates = append(containerUpdates, ContainerUpdate{
 					Container: container.Name,
 					Current:   currentImageID,
@@ -120,6 +123,16 @@ func (a *Automated) calculateImageUpdates(rc ReleaseContext, candidates []*Workl
 			}
 		}
 
+		if len(containerUpdates) > 0 && verifier != nil {
+			if unverified := verifyImages(ctx, verifier, containerUpdates, log.With(logger, "workload", u.ResourceID)); len(unverified) > 0 {
+				result[u.ResourceID] = WorkloadResult{
+					Status: ReleaseStatusSkipped,
+					Error:  fmt.Sprintf(ImageNotVerified, strings.Join(unverified, ", ")),
+				}
+				continue
+			}
+		}
+
 		if len(containerUpdates) > 0 {
 			u.Updates = containerUpdates
 			updates = append(updates, u)
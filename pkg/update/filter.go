@@ -19,6 +19,7 @@ const (
 	DoesNotUseImage        = "does not use image(s)"
 	ContainerNotFound      = "container(s) not found: %s"
 	ContainerTagMismatch   = "container(s) tag mismatch: %s"
+	ImageNotVerified       = "image(s) not verified: %s"
 )
 
 type SpecificImageFilter struct {
@@ -166,34 +166,48 @@ func FetchImageRepos(reg registry.Registry, cs containers, logger log.Logger) (I
 }
 
 // Create a map of image repos to images. It will check that each image exists.
+// An image given with a digest must be the image the tag refers to;
+// otherwise, the digest is that of the image the tag refers to.
 func exactImageRepos(reg registry.Registry, images []image.Ref) (ImageRepos, error) {
 	m := imageReposMap{}
 	for _, id := range images {
+		digest := id.Digest
+		id = id.WithDigest("")
 		// We must check that the exact images requested actually exist. Otherwise we risk pushing invalid images to git.
-		exist, err := imageExists(reg, id)
+		info, err := reg.GetImage(id)
 		if err != nil {
-			return ImageRepos{}, errors.Wrap(image.ErrInvalidImageID, err.Error())
-		}
-		if !exist {
 			return ImageRepos{}, errors.Wrap(image.ErrInvalidImageID, fmt.Sprintf("image %q does not exist", id))
 		}
+		// Likewise the digest, if one is given.
+		switch {
+		case digest == "":
+			digest = info.Digest
+		case digest != info.Digest:
+			return ImageRepos{}, errors.Wrap(image.ErrInvalidImageID, fmt.Sprintf("image %q has digest %s, not %s", id, info.Digest, digest))
+		}
 		m[id.CanonicalName()] = image.RepositoryMetadata{
 			Tags: []string{id.Tag},
 			Images: map[string]image.Info{
-				id.Tag: {ID: id},
+				id.Tag: {ID: id, Digest: digest},
 			},
 		}
 	}
 	return ImageRepos{m}, nil
 }
 
-// Checks whether the given image exists in the repository.
-// Return true if exist, false otherwise.
-// FIXME(michael): never returns an error; should it?
-func imageExists(reg registry.Registry, imageID image.Ref) (bool, error) {
-	_, err := reg.GetImage(imageID)
-	if err != nil {
-		return false, nil
+// UpdatedImage gives the image ref to put in place of the current
+// one, to release the image given. Normally only the tag changes,
+// and it's an update if the tag is different. If pinning to digests,
+// the ref also gets the image's digest, and it's an update if either
+// is different; i.e., if the tag has been pushed again. If there's
+// nothing to update, it returns false.
+func UpdatedImage(current image.Ref, latest image.Info, pin bool) (image.Ref, bool) {
+	if !pin {
+		if current.Tag == latest.ID.Tag {
+			return current, false
+		}
+		return current.WithNewTag(latest.ID.Tag), true
 	}
-	return true, nil
+	updated := current.WithNewTag(latest.ID.Tag).WithDigest(latest.Digest)
+	return updated, updated != current
 }
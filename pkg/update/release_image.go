@@ -48,6 +48,9 @@ const UserAutomated = "<automated>"
 type ReleaseContext interface {
 	SelectWorkloads(context.Context, Result, []WorkloadFilter, []WorkloadFilter) ([]*WorkloadUpdate, error)
 	Registry() registry.Registry
+	// ImageVerifier gives the verifier for images to be released
+	// automatically, or nil if they're not to be verified.
+	ImageVerifier() ImageVerifier
 }
 
 // NB: these get sent from fluxctl, so we have to maintain the json format of
@@ -186,6 +189,7 @@ func (s ReleaseImageSpec) calculateImageUpdates(rc ReleaseContext, candidates []
 	// Compile an `ImageRepos` of all relevant images
 	var imageRepos ImageRepos
 	var singleRepo image.CanonicalName
+	var pinDigest bool
 	var err error
 
 	switch s.ImageSpec {
@@ -196,6 +200,7 @@ func (s ReleaseImageSpec) calculateImageUpdates(rc ReleaseContext, candidates []
 		ref, err = s.ImageSpec.AsRef()
 		if err == nil {
 			singleRepo = ref.CanonicalName()
+			pinDigest = ref.Digest != ""
 			// FIXME(fons): we probably want to allow this operation even if image
 			//              scanning is disabled. We could either avoid the validation
 			//              or use an uncached registry.
@@ -255,15 +260,23 @@ func (s ReleaseImageSpec) calculateImageUpdates(rc ReleaseContext, candidates []
 				continue
 			}
 
-			if currentImageID == latestImage.ID {
-				ignoredOrSkipped = ReleaseStatusSkipped
+			// Pin the image to its digest if the workload asks for
+			// that, or if a digest was given in the release.
+			pin := pinDigest || u.Resource.Policies().Has(policy.PinDigest)
+			if pin && latestImage.Digest == "" {
+				logger.Log("warning", "digest of image not known, so it can't be pinned", "image", latestImage.ID, "workload", u.ResourceID, "container", container.Name)
+				ignoredOrSkipped = ReleaseStatusUnknown
 				continue
 			}
 
 			// We want to update the image with respect to the form it
 			// appears in the manifest, whereas what we have is the
 			// canonical form.
-			newImageID := currentImageID.WithNewTag(latestImage.ID.Tag)
+			newImageID, ok := UpdatedImage(currentImageID, latestImage, pin)
+			if !ok {
+				ignoredOrSkipped = ReleaseStatusSkipped
+				continue
+			}
 			containerUpdates = append(containerUpdates, ContainerUpdate{
 				Container: container.Name,
 				Current:   currentImageID,
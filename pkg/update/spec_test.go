@@ -8,6 +8,8 @@ func TestParseImageSpec(t *testing.T) {
 	parseSpec(t, ":tag", true)
 	parseSpec(t, "image:", true)
 	parseSpec(t, "image", true)
+	parseSpec(t, "image:tag@sha256:0bd2c61a1e7a5c0f3e2b3df6fcd1da2e4d5a3e58f6b8b5bd5b8e0ac1d1b7b0a4", false)
+	parseSpec(t, "image@sha256:0bd2c61a1e7a5c0f3e2b3df6fcd1da2e4d5a3e58f6b8b5bd5b8e0ac1d1b7b0a4", true)
 	parseSpec(t, string(ImageSpecLatest), false)
 	parseSpec(t, "<invalid spec>", true)
 }
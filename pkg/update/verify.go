@@ -0,0 +1,38 @@
+package update
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-kit/kit/log"
+
+	"github.com/fluxcd/flux/pkg/image"
+)
+
+// ImageVerifier checks that an image can be trusted before it's
+// released automatically; for example, that it has been signed with
+// a known key. It returns the digest of the image it verified, since
+// a tag can be pushed again after it's verified.
+type ImageVerifier interface {
+	VerifyImage(context.Context, image.Ref) (digest string, err error)
+}
+
+// verifyImages verifies the target image of each container update,
+// and describes those that could not be verified. A workload is only
+// updated if all of its new images are verified, so that a release
+// doesn't go out half-done. Each verified image is pinned to the
+// digest verified, so that it's the image released, whatever the tag
+// refers to later.
+func verifyImages(ctx context.Context, verifier ImageVerifier, updates []ContainerUpdate, logger log.Logger) []string {
+	var unverified []string
+	for i, u := range updates {
+		digest, err := verifier.VerifyImage(ctx, u.Target)
+		if err != nil {
+			logger.Log("warning", "image not verified", "container", u.Container, "image", u.Target, "err", err, "action", "skip workload")
+			unverified = append(unverified, fmt.Sprintf("%s (%s)", u.Target, err))
+			continue
+		}
+		updates[i].Target, _ = UpdatedImage(u.Current, image.Info{ID: u.Target, Digest: digest}, true)
+	}
+	return unverified
+}